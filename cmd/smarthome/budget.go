@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/tts"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ttsBudget picks the provider for each turn. Once the day's ElevenLabs
+// characters pass the budget, turns go to the fallback provider, or are
+// only printed, until the day ends.
+type ttsBudget struct {
+	primary   tts.Provider
+	fallback  tts.Provider
+	limit     int64
+	costPer1K float64
+
+	mu       sync.Mutex
+	day      string
+	exceeded bool
+}
+
+// newTTSBudget wraps primary in cfg's budget. The fallback is created up
+// front so a bad one fails at startup rather than when the budget runs out.
+func newTTSBudget(cfg *config.Config, primary tts.Provider, sessionConfig func() tts.SessionConfig) (*ttsBudget, error) {
+	b := &ttsBudget{
+		primary:   primary,
+		limit:     int64(cfg.TTSDailyCharBudget),
+		costPer1K: cfg.TTSCostPer1KChars,
+		day:       time.Now().Format(time.DateOnly),
+	}
+	if b.limit <= 0 {
+		return b, nil
+	}
+	if primary.Name() != "elevenlabs" {
+		slog.Warn("tts budget only counts elevenlabs characters", "provider", primary.Name())
+	}
+	if cfg.TTSBudgetFallback == "" {
+		b.fallback = textOnly{rate: primary.SampleRate()}
+		return b, nil
+	}
+	if cfg.TTSBudgetFallback == "elevenlabs" {
+		return nil, errors.New("the tts budget fallback can't be elevenlabs, whose characters it counts")
+	}
+	fallbackCfg := *cfg
+	fallbackCfg.TTSProvider = cfg.TTSBudgetFallback
+	fallback, err := newTTSProvider(&fallbackCfg, sessionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating tts budget fallback: %w", err)
+	}
+	// Playback is set up for the primary's rate.
+	if fallback.SampleRate() != primary.SampleRate() {
+		fallback.Close()
+		return nil, fmt.Errorf("tts budget fallback %s produces %d Hz, but %s produces %d Hz",
+			fallback.Name(), fallback.SampleRate(), primary.Name(), primary.SampleRate())
+	}
+	b.fallback = fallback
+	return b, nil
+}
+
+// provider returns the provider for a turn starting now.
+func (b *ttsBudget) provider() tts.Provider {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if today := time.Now().Format(time.DateOnly); today != b.day {
+		tts.ResetUsage()
+		b.day = today
+		b.exceeded = false
+	}
+	if b.limit <= 0 {
+		return b.primary
+	}
+	if !b.exceeded && tts.Usage().Characters >= b.limit {
+		b.exceeded = true
+		slog.Warn("tts daily character budget exceeded", "budget", b.limit, "fallback", b.fallback.Name())
+	}
+	if b.exceeded {
+		return b.fallback
+	}
+	return b.primary
+}
+
+// record logs what a response cost and counts it in tts.characters.
+func (b *ttsBudget) record(ctx context.Context, provider string, chars int) {
+	if chars == 0 {
+		return
+	}
+	usage := tts.Usage()
+	attrs := []any{
+		"provider", provider,
+		"characters", chars,
+		"elevenlabs_characters_today", usage.Characters,
+		"elevenlabs_sessions_today", usage.Sessions,
+	}
+	if b.costPer1K > 0 {
+		attrs = append(attrs, "estimated_cost_today", fmt.Sprintf("%.4f", float64(usage.Characters)*b.costPer1K/1000))
+	}
+	slog.Info("tts usage", attrs...)
+	if ttsCharacters != nil {
+		ttsCharacters.Add(ctx, int64(chars), metric.WithAttributes(attribute.String("tts.provider", provider)))
+	}
+}
+
+func (b *ttsBudget) Close() error {
+	if b.fallback != nil {
+		return b.fallback.Close()
+	}
+	return nil
+}
+
+// textOnly stands in for a provider when the budget is spent and there is
+// no fallback: responses are logged instead of spoken.
+type textOnly struct {
+	rate int
+}
+
+func (t textOnly) Name() string    { return "none" }
+func (t textOnly) SampleRate() int { return t.rate }
+func (t textOnly) Phonemes() bool  { return false }
+func (t textOnly) Close() error    { return nil }
+
+func (t textOnly) NewStream(ctx context.Context, _ tts.StreamOptions) (tts.Stream, error) {
+	return &textOnlyStream{audio: make(chan tts.AudioChunk, 1)}, nil
+}
+
+type textOnlyStream struct {
+	text  strings.Builder
+	audio chan tts.AudioChunk
+	once  sync.Once
+}
+
+func (s *textOnlyStream) SendText(text string) error {
+	s.text.WriteString(text)
+	return nil
+}
+
+func (s *textOnlyStream) Flush() error {
+	s.once.Do(func() {
+		slog.Info("tts budget spent, not speaking", "text", s.text.String())
+		s.audio <- tts.AudioChunk{Done: true}
+		close(s.audio)
+	})
+	return nil
+}
+
+func (s *textOnlyStream) Audio() <-chan tts.AudioChunk { return s.audio }
+
+func (s *textOnlyStream) Close() error {
+	s.once.Do(func() { close(s.audio) })
+	return nil
+}
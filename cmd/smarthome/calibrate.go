@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+)
+
+const calibrationDuration = 10 * time.Second
+
+func runCalibrate(ctx context.Context, mic *audio.Capture) error {
+	fmt.Printf("Measuring background noise for %s, keep the room quiet...\n", calibrationDuration)
+	cal, err := mic.Calibrate(ctx, calibrationDuration)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Noise floor RMS %.0f, 99th percentile %.0f over %d frames\n", cal.NoiseFloorRMS, cal.P99RMS, cal.Frames)
+	for mode, rate := range cal.TriggerRates {
+		fmt.Printf("  vad mode %d: %.1f%% false triggers\n", mode, rate*100)
+	}
+	fmt.Println()
+	fmt.Println("Suggested settings:")
+	fmt.Printf("AUDIO_VAD_MODE=%d\n", cal.VADMode)
+	fmt.Printf("AUDIO_ENERGY_THRESHOLD=%.0f\n", cal.EnergyThreshold)
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/load"
+)
+
+// captureOptions configures the microphone, or the source --input-file or
+// AUDIO_SOURCE names instead.
+func captureOptions(cfg *config.Config, wakeWordPath string, monitor *load.Monitor, speaker *audio.Playback) ([]audio.Option, error) {
+	vadMode := cfg.AudioVADMode
+	if cfg.AudioVADAdaptive {
+		vadMode = audio.VADAdaptive
+	}
+	opts := []audio.Option{
+		audio.WithBackend(cfg.AudioBackend),
+		audio.WithWakeWord(cfg.PicovoiceAccessKey, wakeWordPath),
+		audio.WithWakeWordSensitivity(cfg.WakeWordSensitivity),
+		audio.WithWakeWordWindow(time.Duration(cfg.WakeWordWindowMs) * time.Millisecond),
+		audio.WithHighPass(cfg.AudioHighPassHz),
+		audio.WithAGC(cfg.AudioAGCTargetRMS),
+		audio.WithNoiseSuppression(cfg.AudioNoiseSuppression),
+		audio.WithNoiseSuppressionBypass(func() bool {
+			return monitor.Enabled(load.FlagSkipNoiseSuppression)
+		}),
+		audio.WithMaxUtteranceMs(cfg.AudioMaxUtteranceMs),
+		audio.WithMinUtteranceMs(cfg.AudioMinUtteranceMs),
+		audio.WithSilenceDurationMs(cfg.AudioSilenceMs),
+		audio.WithPreBufferMs(cfg.AudioPreBufferMs),
+		audio.WithTrailingPadMs(cfg.AudioTrailingPadMs),
+		audio.WithVADOnsetFrames(cfg.AudioVADOnsetFrames, cfg.AudioVADOnsetWindow),
+		audio.WithInputDevice(cfg.AudioInputDevice),
+		audio.WithInputChannels(cfg.AudioInputChannels),
+		audio.WithChannelSelect(cfg.AudioChannelSelect),
+		audio.WithRoom(cfg.AudioRoom),
+		audio.WithRecoveryTimeout(time.Duration(cfg.AudioRecoveryTimeoutMs) * time.Millisecond),
+		audio.WithGuard(panicGuard),
+		audio.WithBackpressure(cfg.AudioBackpressure),
+		audio.WithChannelBuffer(cfg.AudioChannelBuffer),
+		audio.WithVAD(cfg.AudioVAD),
+		audio.WithVADMode(vadMode),
+		audio.WithVADModeRange(cfg.AudioVADModeMin, cfg.AudioVADModeMax),
+		audio.WithEnergyThreshold(cfg.AudioEnergyThreshold),
+		audio.WithPlaybackGate(speaker.Speaking, cfg.AudioBargeInRMS),
+		audio.WithSileroModel(cfg.SileroModelPath, cfg.ONNXRuntimeLib),
+	}
+	switch {
+	case *inputFile != "":
+		src, err := audio.OpenFileSource(*inputFile, audio.DefaultSampleRate, audio.DefaultFrameDurationMs, *realtime)
+		if err != nil {
+			return nil, fmt.Errorf("opening input file: %w", err)
+		}
+		opts = append(opts, audio.WithSource(src))
+	case cfg.AudioSource == "network":
+		src, err := audio.NewNetworkSource(audio.NetworkSourceConfig{
+			URL:        cfg.AudioSourceURL,
+			SampleRate: cfg.AudioSourceRate,
+			BigEndian:  cfg.AudioSourceBigEndian,
+		}, audio.DefaultSampleRate, audio.DefaultFrameDurationMs)
+		if err != nil {
+			return nil, fmt.Errorf("creating network source: %w", err)
+		}
+		opts = append(opts, audio.WithSource(src))
+	case cfg.AudioSource != "device":
+		return nil, fmt.Errorf("unknown audio source %q, expected device or network", cfg.AudioSource)
+	}
+	return opts, nil
+}
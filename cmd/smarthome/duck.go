@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const duckCommandTimeout = 5 * time.Second
+
+// duckCommand runs PLAYBACK_DUCK_COMMAND through sh whenever the assistant
+// starts or stops talking, with SMARTHOME_DUCK set to 1 or 0, so another
+// player can turn itself down meanwhile. For example:
+//
+//	mpc volume $([ "$SMARTHOME_DUCK" = 1 ] && echo 20 || echo 80)
+//	mosquitto_pub -t radio/duck -m "$SMARTHOME_DUCK"
+func duckCommand(command string) func(active bool) {
+	return func(active bool) {
+		state := "0"
+		if active {
+			state = "1"
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), duckCommandTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Env = append(os.Environ(), "SMARTHOME_DUCK="+state)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			slog.Warn("running duck command", "active", active, "error", err, "output", string(out))
+			return
+		}
+		slog.Debug("ran duck command", "active", active)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joakimcarlsson/smarthome/internal/lexicon"
+)
+
+// runLexicon prints what the TTS would receive for a sample sentence, either
+// from the arguments or one sentence per line on stdin.
+func runLexicon(lex *lexicon.Lexicon, rules *lexicon.Rules, lang string, phonemes bool, args []string) error {
+	fmt.Printf("%d entries, %d rules, language %s, phonemes %t\n", lex.Len(), rules.Len(), lang, phonemes)
+
+	if len(args) > 0 {
+		fmt.Println(lex.Apply(rules.Apply(strings.Join(args, " ")), lang, phonemes))
+		return nil
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fmt.Println(lex.Apply(rules.Apply(scanner.Text()), lang, phonemes))
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/load"
+	"github.com/joakimcarlsson/smarthome/internal/tts"
+)
+
+// newLoadMonitor returns nil unless LOAD_MONITOR is set.
+func newLoadMonitor(cfg *config.Config) (*load.Monitor, error) {
+	if !cfg.LoadMonitor {
+		return nil, nil
+	}
+	sustain := time.Duration(cfg.LoadSustainMs) * time.Millisecond
+	return load.NewMonitor(time.Duration(cfg.LoadIntervalMs)*time.Millisecond,
+		load.Rule{
+			Flag:    load.FlagSkipNoiseSuppression,
+			Metric:  load.MetricSystemCPU,
+			Above:   cfg.LoadNSCPUAbove,
+			Below:   cfg.LoadNSCPUBelow,
+			Sustain: sustain,
+		},
+		load.Rule{
+			Flag:    load.FlagDowngradeTTS,
+			Metric:  load.MetricSystemCPU,
+			Above:   cfg.LoadTTSCPUAbove,
+			Below:   cfg.LoadTTSCPUBelow,
+			Sustain: sustain,
+		},
+	)
+}
+
+// degraded switches c to model while monitor flags the TTS for downgrading.
+func degraded(c tts.SessionConfig, monitor *load.Monitor, model string) func() tts.SessionConfig {
+	return func() tts.SessionConfig {
+		if monitor.Enabled(load.FlagDowngradeTTS) && model != "" {
+			d := c
+			d.ModelID = model
+			d.EnableSSML = tts.SupportsPhonemes(model)
+			return d
+		}
+		return c
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/config"
+)
+
+const (
+	// loopbackSettle lets the capture stream start and fill its pre-buffer
+	// before the clip plays.
+	loopbackSettle    = 500 * time.Millisecond
+	loopbackTail      = 2 * time.Second
+	loopbackTolerance = 300 * time.Millisecond
+)
+
+// runLoopback plays a WAV and captures it back from AUDIO_INPUT_DEVICE,
+// which should be the monitor of the output device, then checks that the
+// VAD made exactly one utterance about as long as the speech in the clip.
+// It runs device selection, resampling and segmenting end to end without a
+// microphone or anyone speaking.
+func runLoopback(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: loopback <file.wav>")
+	}
+	if cfg.AudioInputDevice == "" {
+		return errors.New("set AUDIO_INPUT_DEVICE to a monitor source, e.g. <sink>.monitor")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("reading clip: %w", err)
+	}
+	pcm, rate, channels, bits, err := audio.DecodeWAV(data)
+	if err != nil {
+		return fmt.Errorf("decoding clip: %w", err)
+	}
+	if channels != 1 || bits != 16 {
+		return fmt.Errorf("clip is %d channels, %d-bit; need mono 16-bit", channels, bits)
+	}
+	want := voicedSpan(pcm, rate, cfg.AudioEnergyThreshold)
+	if want == 0 {
+		return errors.New("clip has no audio above AUDIO_ENERGY_THRESHOLD")
+	}
+
+	// No echo canceller here: it would subtract exactly what the monitor
+	// hears.
+	mic, err := audio.New(nil,
+		audio.WithBackend(cfg.AudioBackend),
+		audio.WithInputDevice(cfg.AudioInputDevice),
+		audio.WithInputChannels(cfg.AudioInputChannels),
+		audio.WithChannelSelect(cfg.AudioChannelSelect),
+		audio.WithMaxUtteranceMs(cfg.AudioMaxUtteranceMs),
+		audio.WithMinUtteranceMs(cfg.AudioMinUtteranceMs),
+		audio.WithSilenceDurationMs(cfg.AudioSilenceMs),
+		audio.WithPreBufferMs(cfg.AudioPreBufferMs),
+		audio.WithTrailingPadMs(cfg.AudioTrailingPadMs),
+		audio.WithVADOnsetFrames(cfg.AudioVADOnsetFrames, cfg.AudioVADOnsetWindow),
+		audio.WithVAD(cfg.AudioVAD),
+		audio.WithVADMode(cfg.AudioVADMode),
+		audio.WithEnergyThreshold(cfg.AudioEnergyThreshold),
+		audio.WithSileroModel(cfg.SileroModelPath, cfg.ONNXRuntimeLib),
+	)
+	if err != nil {
+		return fmt.Errorf("creating audio capture: %w", err)
+	}
+	speaker, err := audio.NewPlayback(nil,
+		audio.WithBackend(cfg.AudioBackend),
+		outputDevice(cfg.PlaybackDevice),
+		audio.WithPlaybackSourceRate(rate),
+	)
+	if err != nil {
+		return fmt.Errorf("creating audio playback: %w", err)
+	}
+	defer speaker.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	utterances, err := mic.StartUtterances(ctx)
+	if err != nil {
+		return fmt.Errorf("starting audio capture: %w", err)
+	}
+	defer mic.Close()
+
+	select {
+	case <-time.After(loopbackSettle):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	fmt.Printf("Playing %s, %s of it above the energy threshold\n", args[0], want.Round(time.Millisecond))
+	played := make(chan error, 1)
+	go func() {
+		if err := speaker.Play(pcm); err != nil {
+			played <- err
+			return
+		}
+		played <- speaker.Drain(ctx)
+	}()
+
+	var got []audio.Utterance
+	var deadline <-chan time.Time
+collect:
+	for {
+		select {
+		case err := <-played:
+			if err != nil {
+				return fmt.Errorf("playing clip: %w", err)
+			}
+			deadline = time.After(time.Duration(cfg.AudioSilenceMs)*time.Millisecond + loopbackTail)
+		case u, ok := <-utterances:
+			if !ok {
+				return fmt.Errorf("capture stopped: %w", mic.Err())
+			}
+			fmt.Printf("Captured %s of speech in a %s utterance\n", speechSpan(u).Round(time.Millisecond), u.Duration.Round(time.Millisecond))
+			got = append(got, u)
+		case <-deadline:
+			break collect
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if len(got) != 1 {
+		return fmt.Errorf("captured %d utterances, want 1", len(got))
+	}
+	tolerance := max(loopbackTolerance, want/5)
+	if diff := (speechSpan(got[0]) - want).Abs(); diff > tolerance {
+		return fmt.Errorf("captured speech is off by %s, more than the %s allowed", diff.Round(time.Millisecond), tolerance)
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+// speechSpan is the utterance without its pre-buffer and hangover.
+func speechSpan(u audio.Utterance) time.Duration {
+	return u.Duration - u.LeadingSilence - u.TrailingSilence
+}
+
+// voicedSpan measures from the first to the last 30 ms frame of pcm whose
+// RMS reaches threshold.
+func voicedSpan(pcm []byte, sampleRate int, threshold float64) time.Duration {
+	frame := sampleRate * 30 / 1000
+	first, last := -1, -1
+	for i := 0; (i+1)*frame*2 <= len(pcm); i++ {
+		var sum float64
+		for j := range frame {
+			s := float64(int16(binary.LittleEndian.Uint16(pcm[(i*frame+j)*2:])))
+			sum += s * s
+		}
+		if math.Sqrt(sum/float64(frame)) >= threshold {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return 0
+	}
+	return time.Duration(last-first+1) * 30 * time.Millisecond
+}
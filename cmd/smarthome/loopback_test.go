@@ -0,0 +1,62 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/config"
+)
+
+// voicedClip is a mono 16-bit WAV at rate: quiet, then speech long, then
+// quiet. The speech is a 150 Hz tone with two harmonics, loud enough for
+// any VAD backend.
+func voicedClip(rate int, speech time.Duration) []byte {
+	quiet := rate * 300 / 1000
+	voiced := int(speech.Seconds() * float64(rate))
+	samples := make([]int16, quiet+voiced+quiet)
+	for i := range voiced {
+		t := float64(i) / float64(rate)
+		var s float64
+		for k := 1.0; k <= 3; k++ {
+			s += 3000 / k * math.Sin(2*math.Pi*150*k*t)
+		}
+		samples[quiet+i] = int16(s)
+	}
+	return audio.EncodeWAV(audio.AppendInt16LE(nil, samples), rate, 1, 16)
+}
+
+// TestLoopback plays a clip through the output device and captures it back
+// from the device's monitor, as 'smarthome loopback' does. It needs a sound
+// server, and AUDIO_INPUT_DEVICE naming the monitor source:
+//
+//	AUDIO_INPUT_DEVICE=<sink>.monitor go test -tags integration -run Loopback ./cmd/smarthome
+//
+// The clip is at 24 kHz, so playback resamples it to the device rate and
+// capture back down to 16 kHz.
+func TestLoopback(t *testing.T) {
+	if os.Getenv("AUDIO_INPUT_DEVICE") == "" {
+		t.Skip("AUDIO_INPUT_DEVICE not set")
+	}
+	cfg, err := config.Load(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clip := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(clip, voicedClip(24000, 1500*time.Millisecond), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := runLoopback(ctx, cfg, []string{clip}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,12 +3,11 @@ package main
 import (
 	"context"
 	_ "embed"
-	"fmt"
+	"flag"
 	"log/slog"
+	"math"
 	"os"
 	"os/signal"
-	"strings"
-	"sync"
 	"syscall"
 	"time"
 
@@ -17,18 +16,23 @@ import (
 	"github.com/joakimcarlsson/ai/prompt"
 	llm "github.com/joakimcarlsson/ai/providers"
 	"github.com/joakimcarlsson/ai/transcription"
-	"github.com/joakimcarlsson/ai/types"
 	"github.com/joakimcarlsson/smarthome/internal/audio"
 	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/guard"
+	"github.com/joakimcarlsson/smarthome/internal/lexicon"
 	"github.com/joakimcarlsson/smarthome/internal/otel"
+	"github.com/joakimcarlsson/smarthome/internal/scrub"
 	"github.com/joakimcarlsson/smarthome/internal/tools"
+	"github.com/joakimcarlsson/smarthome/internal/transcript"
 	"github.com/joakimcarlsson/smarthome/internal/tts"
 )
 
 const (
-	serviceName       = "smarthome"
-	serviceVersion    = "0.1.0"
-	noSpeechThreshold = 0.6
+	serviceName    = "smarthome"
+	serviceVersion = "0.1.0"
+	envFile        = "../../.env"
+
+	lexiconReloadInterval = 2 * time.Second
 )
 
 //go:embed prompts/system.md
@@ -37,8 +41,20 @@ var systemPrompt string
 //go:embed res/show-bror_en_linux_v4_0_0.ppn
 var wakeWordModel []byte
 
+// panicGuard recovers panics in turns and goroutines.
+var panicGuard *guard.Guard
+
+var (
+	inputFile = flag.String("input-file", "", "read audio from a WAV or raw PCM file instead of the microphone, - for stdin")
+	realtime  = flag.Bool("realtime", true, "replay --input-file at real-time speed")
+	calibrate = flag.Bool("calibrate", false, "measure background noise for 10 seconds and suggest VAD settings")
+	testAudio = flag.Bool("test-audio", false, "play a test tone on each speaker and report how loud the microphone hears it")
+)
+
 func main() {
-	cfg, err := config.Load("../../.env")
+	flag.Parse()
+
+	cfg, err := config.Load(envFile)
 	if err != nil {
 		slog.Error("loading config", "error", err)
 		os.Exit(1)
@@ -47,11 +63,20 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	contentPolicy, err := otel.ParseContentPolicy(cfg.TelemetryContent)
+	if err != nil {
+		slog.Error("parsing telemetry content policy", "error", err)
+		os.Exit(1)
+	}
+
 	otelShutdown, err := otel.Setup(ctx, otel.Config{
-		ServiceName:    serviceName,
-		ServiceVersion: serviceVersion,
-		OTLPEndpoint:   cfg.OTLPEndpoint,
-		OTLPToken:      cfg.OTLPToken,
+		ServiceName:     serviceName,
+		ServiceVersion:  serviceVersion,
+		OTLPEndpoint:    cfg.OTLPEndpoint,
+		OTLPToken:       cfg.OTLPToken,
+		ContentPolicy:   contentPolicy,
+		ContentSalt:     cfg.TelemetryContentSalt,
+		ContentMaxChars: cfg.TelemetryContentMaxChars,
 	})
 	if err != nil {
 		slog.Error("setting up otel", "error", err)
@@ -63,10 +88,120 @@ func main() {
 		}
 	}()
 
-	otel.SetupLogger(serviceName, cfg.LogLevel, cfg.LogFormat)
+	scrubber, err := scrub.New(scrub.Config{
+		Names:    cfg.ScrubNames,
+		Patterns: cfg.ScrubPatterns,
+	})
+	if err != nil {
+		slog.Error("creating scrubber", "error", err)
+		os.Exit(1)
+	}
+
+	otel.SetupLogger(serviceName, cfg.LogLevel, cfg.LogFormat, scrubLogging(scrubber, cfg.ScrubConsole)...)
+	otel.SetContentRedactor(scrubber.Scrub)
 
 	slog.Info("starting", "service", serviceName, "version", serviceVersion)
 
+	panicGuard, err = guard.New(cfg.PanicLimit, time.Duration(cfg.PanicWindowMs)*time.Millisecond, cancel)
+	if err != nil {
+		slog.Error("creating panic guard", "error", err)
+		os.Exit(1)
+	}
+
+	ttsConfig, err := newSessionConfig(cfg)
+	if err != nil {
+		slog.Error("invalid tts config", "error", err)
+		os.Exit(1)
+	}
+
+	lexFile, err := lexicon.Open(cfg.LexiconPath)
+	if err != nil {
+		slog.Error("loading lexicon", "error", err)
+		os.Exit(1)
+	}
+	rulesFile, err := lexicon.OpenRules(cfg.PronunciationRulesPath)
+	if err != nil {
+		slog.Error("loading pronunciation rules", "error", err)
+		os.Exit(1)
+	}
+
+	ttsCache, err := openTTSCache(cfg)
+	if err != nil {
+		slog.Error("opening tts cache", "error", err)
+		os.Exit(1)
+	}
+	registerTTSCacheMetrics(ttsCache)
+
+	if flag.Arg(0) == "voices" {
+		if err := runVoices(ctx, cfg, ttsConfig, ttsCache, flag.Args()[1:]); err != nil {
+			slog.Error("voices", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "loopback" {
+		if err := runLoopback(ctx, cfg, flag.Args()[1:]); err != nil {
+			slog.Error("loopback", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "lexicon" {
+		if err := runLexicon(lexFile.Lexicon(), rulesFile.Rules(), cfg.LexiconLanguage, ttsConfig.EnableSSML, flag.Args()[1:]); err != nil {
+			slog.Error("lexicon", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	panicGuard.Go("lexicon", func() { lexFile.Watch(ctx, lexiconReloadInterval) })
+	panicGuard.Go("pronunciation_rules", func() { rulesFile.Watch(ctx, lexiconReloadInterval) })
+	monitor, err := newLoadMonitor(cfg)
+	if err != nil {
+		slog.Error("creating load monitor", "error", err)
+		os.Exit(1)
+	}
+	if monitor != nil {
+		panicGuard.Go("load_monitor", func() { monitor.Run(ctx) })
+	}
+	sessionConfig := degraded(ttsConfig, monitor, cfg.LoadDegradedModel)
+	ttsProvider, err := newTTSProvider(cfg, sessionConfig)
+	if err != nil {
+		slog.Error("creating tts provider", "error", err)
+		os.Exit(1)
+	}
+	defer ttsProvider.Close()
+
+	if flag.Arg(0) == "phrases" {
+		if err := runPhrases(ctx, cfg, ttsProvider, flag.Args()[1:]); err != nil {
+			slog.Error("phrases", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ttsBudget, err := newTTSBudget(cfg, ttsProvider, sessionConfig)
+	if err != nil {
+		slog.Error("creating tts budget", "error", err)
+		os.Exit(1)
+	}
+	defer ttsBudget.Close()
+	cleaner := transcript.NewCleaner(cfg.TranscriptWakePhrases, cfg.TranscriptFillers)
+	stopPhrases := transcript.NewStopPhrases(cfg.TranscriptStopPhrases)
+
+	styleRules := newStyleRules(cfg)
+
+	batching, err := newBatchConfig(cfg)
+	if err != nil {
+		slog.Error("loading tts normalization", "error", err)
+		os.Exit(1)
+	}
+	pronunciation := func(p tts.Provider) *lexicon.Stream {
+		return lexFile.Lexicon().Stream(cfg.LexiconLanguage, p.Phonemes()).WithRules(rulesFile.Rules())
+	}
+
 	wakeWordFile, err := os.CreateTemp("", "wakeword-*.ppn")
 	if err != nil {
 		slog.Error("creating wake word temp file", "error", err)
@@ -83,15 +218,95 @@ func main() {
 	aec := audio.NewEchoCanceller(frameSize, audio.DefaultSampleRate)
 	defer aec.Close()
 
-	mic, err := audio.New(aec,
-		audio.WithWakeWord(cfg.PicovoiceAccessKey, wakeWordFile.Name()),
-	)
+	speaker, sinks, err := newSpeakers(cfg, aec, ttsProvider.SampleRate())
+	if err != nil {
+		slog.Error("creating audio playback", "error", err)
+		os.Exit(1)
+	}
+	defer speaker.Close()
+	defer sinks.Close()
+	speakerFor := func(room string) *audio.Playback {
+		if sink := sinks.Get(room); sink != nil {
+			return sink
+		}
+		return speaker
+	}
+
+	audioOpts, err := captureOptions(cfg, wakeWordFile.Name(), monitor, speaker)
+	if err != nil {
+		slog.Error("configuring audio capture", "error", err)
+		os.Exit(1)
+	}
+
+	// Earcons play off the capture goroutine; only the latest one matters.
+	earcons := make(chan string, 1)
+	playEarcon := func(name string) {
+		if !cfg.AudioEarcons || name == "" {
+			return
+		}
+		select {
+		case earcons <- name:
+		default:
+		}
+	}
+	if cfg.AudioEarcons {
+		audioOpts = append(audioOpts, audio.WithStateCallback(func(s audio.State) {
+			playEarcon(audio.Earcon(s))
+		}))
+	}
+
+	phrases := newPhraseBank(ttsBudget, ttsCache, cfg.AudioPhraseDir, playEarcon)
+
+	// Keyword callbacks run on the capture goroutine.
+	stopRequests := make(chan struct{}, 1)
+	if cfg.KeywordTemplateDir != "" {
+		audioOpts = append(audioOpts,
+			audio.WithKeywordTemplates(cfg.KeywordTemplateDir),
+			audio.WithKeywordThreshold(cfg.KeywordThreshold),
+		)
+		for _, word := range cfg.KeywordWords {
+			audioOpts = append(audioOpts, audio.WithKeyword(word, func() {
+				select {
+				case stopRequests <- struct{}{}:
+				default:
+				}
+			}))
+		}
+	}
+
+	mic, err := audio.New(aec, audioOpts...)
 	if err != nil {
 		slog.Error("creating audio capture", "error", err)
 		os.Exit(1)
 	}
 
-	utterances, err := mic.Start(ctx)
+	if *calibrate {
+		if err := runCalibrate(ctx, mic); err != nil {
+			slog.Error("calibrating", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *testAudio {
+		if err := runTestAudio(ctx, mic, speaker, sinks); err != nil {
+			slog.Error("testing audio", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var recorder *audio.Recorder
+	if cfg.AudioDebugDir != "" {
+		recorder, err = audio.NewRecorder(cfg.AudioDebugDir, cfg.AudioDebugMaxFiles, int64(cfg.AudioDebugMaxMB)<<20)
+		if err != nil {
+			slog.Error("creating debug recorder", "error", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		slog.Info("saving utterances for debugging", "dir", cfg.AudioDebugDir)
+	}
+
+	utterances, err := mic.StartUtterances(ctx)
 	if err != nil {
 		slog.Error("starting audio capture", "error", err)
 		os.Exit(1)
@@ -108,6 +323,8 @@ func main() {
 		os.Exit(1)
 	}
 
+	wordSTT := newWordSTT(ctx, cfg)
+
 	llmClient, err := llm.NewLLM(
 		model.ProviderAnthropic,
 		llm.WithAPIKey(cfg.AnthropicAPIKey),
@@ -128,37 +345,94 @@ func main() {
 
 	myAgent := agent.New(llmClient,
 		agent.WithSystemPrompt(renderedPrompt),
-		agent.WithTools(tools.NewWebSearchTool(cfg.SerpAPIKey)),
+		agent.WithTools(
+			tools.WithRecovery(tools.NewWebSearchTool(cfg.SerpAPIKey), panicGuard.Handle),
+			tools.WithRecovery(tools.NewVolumeTool(speaker), panicGuard.Handle),
+		),
 	)
 
-	speaker, err := audio.NewPlayback(aec)
-	if err != nil {
-		slog.Error("creating audio playback", "error", err)
-		os.Exit(1)
-	}
-	defer speaker.Close()
-
-	ttsConfig := tts.SessionConfig{
-		APIKey:       cfg.ElevenLabsAPIKey,
-		VoiceID:      cfg.ElevenLabsVoiceID,
-		ModelID:      cfg.ElevenLabsModel,
-		OutputFormat: "pcm_24000",
-		Stability:    cfg.ElevenLabsStability,
-		Similarity:   cfg.ElevenLabsSimilarity,
-		Speed:        cfg.ElevenLabsSpeed,
-	}
+	panicGuard.Go("earcons", func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case name := <-earcons:
+				if err := speaker.PlayClip(name); err != nil {
+					slog.Warn("playing earcon", "error", err)
+				}
+			}
+		}
+	})
 
 	slog.Info("listening for speech",
 		"stt", "openai/gpt-4o-mini-transcribe",
 		"llm", "anthropic/claude-4.5-haiku",
 	)
 
+	// Echo gating mutes the mic while a response plays, at the cost of barge-in.
+	var echoGate *audio.Capture
+	if cfg.AudioEchoGate {
+		echoGate = mic
+	}
+
+	turns := &responder{
+		stt:              stt,
+		wordSTT:          wordSTT,
+		uploadFormat:     cfg.STTUploadFormat,
+		cleaner:          cleaner,
+		wakeTail:         time.Duration(cfg.TranscriptWakeTailMs) * time.Millisecond,
+		stopPhrases:      stopPhrases,
+		styleRules:       styleRules,
+		agent:            myAgent,
+		phrases:          phrases,
+		echoGate:         echoGate,
+		budget:           ttsBudget,
+		cache:            ttsCache,
+		batching:         batching,
+		newPronunciation: pronunciation,
+	}
+
 	var cancelCurrent context.CancelFunc
 	var currentDone chan struct{}
+	currentSpeaker := speaker
 	processing := false
 
+	respond := func(room, text string, utt ...audio.Utterance) {
+		utterCtx, utterCancel := context.WithCancel(ctx)
+		cancelCurrent = utterCancel
+		currentDone = make(chan struct{})
+		currentSpeaker = speakerFor(room)
+		processing = true
+		go turns.processUtterance(utterCtx, currentDone, currentSpeaker, text, utt...)
+	}
+
+	// Stop the speaker first so the cut is immediate.
+	stopCurrent := func() {
+		currentSpeaker.Stop()
+		cancelCurrent()
+		<-currentDone
+		currentSpeaker.Reset()
+	}
+
 	wakeWordEvents := mic.WakeWordEvents()
 
+	// Barge-ins are transcribed off the loop, which keeps watching for keywords.
+	bargeIns := make(chan bargeIn, 1)
+
+	// SIGUSR1 toggles listening.
+	pauseToggle := make(chan os.Signal, 1)
+	signal.Notify(pauseToggle, syscall.SIGUSR1)
+	defer signal.Stop(pauseToggle)
+	go func() {
+		for range pauseToggle {
+			if mic.Paused() {
+				mic.Resume()
+			} else {
+				mic.Pause()
+			}
+		}
+	}()
+
 loop:
 	for {
 		// If something is currently processing, wait for it to finish or for an interrupt.
@@ -168,43 +442,38 @@ loop:
 				break loop
 			case <-currentDone:
 				processing = false
+			case <-stopRequests:
+				slog.Info("response stopped by keyword")
+				stopCurrent()
+				processing = false
 			case <-wakeWordEvents:
-				cancelCurrent()
-				<-currentDone
-				speaker.Reset()
+				stopCurrent()
 				slog.Info("wake word greeting")
-				utterCtx, utterCancel := context.WithCancel(ctx)
-				cancelCurrent = utterCancel
-				currentDone = make(chan struct{})
-				go processUtterance(utterCtx, currentDone, "Sho bror", stt, myAgent, speaker, ttsConfig)
-			case pcm, ok := <-utterances:
+				respond(cfg.AudioRoom, "Sho bror")
+			case utt, ok := <-utterances:
 				if !ok {
 					break loop
 				}
-				wav := audio.EncodeWAV(pcm, audio.DefaultSampleRate, 1, 16)
-				resp, err := stt.Transcribe(ctx, wav,
-					transcription.WithLanguage("sv"),
-					transcription.WithFilename("audio.wav"),
-					transcription.WithPrompt("Smarthome, Bälstaberg, Vallentuna, Sverige."),
-					transcription.WithResponseFormat("json"),
-				)
-				if err != nil {
-					slog.Debug("barge-in STT failed, ignoring", "error", err)
-					continue
-				}
-				text := strings.TrimSpace(resp.Text)
-				if text == "" || isHallucination(resp) {
-					slog.Debug("discarding non-speech interrupt")
+				recordUtterance(utt, recorder)
+				panicGuard.Go("barge_in", func() {
+					text, ok := turns.confirmBargeIn(ctx, utt)
+					if !ok {
+						return
+					}
+					select {
+					case bargeIns <- bargeIn{text: text, room: utt.Room}:
+					case <-ctx.Done():
+					}
+				})
+			case b := <-bargeIns:
+				slog.Info("barge-in confirmed", "text", b.text)
+				stopCurrent()
+				if stopPhrases.Match(b.text) {
+					slog.Info("response stopped")
+					processing = false
 					continue
 				}
-				slog.Info("barge-in confirmed", "text", text)
-				cancelCurrent()
-				<-currentDone
-				speaker.Reset()
-				utterCtx, utterCancel := context.WithCancel(ctx)
-				cancelCurrent = utterCancel
-				currentDone = make(chan struct{})
-				go processUtterance(utterCtx, currentDone, text, stt, myAgent, speaker, ttsConfig)
+				respond(b.room, b.text)
 			}
 		}
 
@@ -212,179 +481,78 @@ loop:
 		select {
 		case <-ctx.Done():
 			break loop
+		case <-stopRequests:
+			slog.Debug("stop keyword with nothing to stop")
+		case b := <-bargeIns:
+			// The response already ended, so there is nothing to stop.
+			if stopPhrases.Match(b.text) {
+				slog.Debug("stop phrase with nothing to stop")
+				continue
+			}
+			slog.Info("barge-in confirmed after the response ended", "text", b.text)
+			respond(b.room, b.text)
 		case <-wakeWordEvents:
 			slog.Info("wake word greeting")
-			utterCtx, utterCancel := context.WithCancel(ctx)
-			cancelCurrent = utterCancel
-			currentDone = make(chan struct{})
-			processing = true
-			go processUtterance(utterCtx, currentDone, "Sho bror", stt, myAgent, speaker, ttsConfig)
-		case pcm, ok := <-utterances:
+			respond(cfg.AudioRoom, "Sho bror")
+		case utt, ok := <-utterances:
 			if !ok {
 				break loop
 			}
-			utterCtx, utterCancel := context.WithCancel(ctx)
-			cancelCurrent = utterCancel
-			currentDone = make(chan struct{})
-			processing = true
-			go processUtterance(utterCtx, currentDone, "", stt, myAgent, speaker, ttsConfig, pcm)
+			recordUtterance(utt, recorder)
+			respond(utt.Room, "", utt)
 		}
 	}
 
 	if cancelCurrent != nil {
+		// The input ran out, so let the last response finish.
+		if processing && ctx.Err() == nil {
+			<-currentDone
+		}
 		cancelCurrent()
 		<-currentDone
 	}
 
-	slog.Info("shutting down")
-}
-
-func processUtterance(
-	ctx context.Context,
-	done chan struct{},
-	preTranscribed string,
-	stt transcription.SpeechToText,
-	myAgent *agent.Agent,
-	speaker *audio.Playback,
-	ttsConfig tts.SessionConfig,
-	pcm ...[]byte,
-) {
-	defer close(done)
-
-	text := preTranscribed
-	if text != "" {
-		slog.Info("processing pre-transcribed", "text", text)
-	}
-
-	var wsSession *tts.Session
-	var wsErr error
-	wsDone := make(chan struct{})
-	go func() {
-		wsSession, wsErr = tts.NewSession(ctx, ttsConfig)
-		close(wsDone)
-	}()
-
-	if text == "" && len(pcm) > 0 {
-		wav := audio.EncodeWAV(pcm[0], audio.DefaultSampleRate, 1, 16)
-
-		resp, err := stt.Transcribe(ctx, wav,
-			transcription.WithLanguage("sv"),
-			transcription.WithFilename("audio.wav"),
-			transcription.WithPrompt("Smarthome, Bälstaberg, Vallentuna, Sverige."),
-			transcription.WithResponseFormat("json"),
-		)
-		if err != nil {
-			if ctx.Err() != nil {
-				slog.Info("interrupted during transcription")
-			} else {
-				slog.Error("transcribing", "error", err)
-			}
-			<-wsDone
-			if wsSession != nil {
-				wsSession.Close()
-			}
-			return
-		}
-
-		text = strings.TrimSpace(resp.Text)
-		if text == "" || isHallucination(resp) {
-			if text != "" {
-				slog.Debug("discarding hallucination", "text", text)
-			}
-			<-wsDone
-			if wsSession != nil {
-				wsSession.Close()
-			}
-			return
-		}
-
-		slog.Info("transcribed", "text", text)
+	if err := mic.Err(); err != nil {
+		slog.Error("audio capture stopped", "error", err)
 	}
 
-	<-wsDone
-	if wsErr != nil {
-		if ctx.Err() != nil {
-			slog.Info("interrupted during tts connect")
-		} else {
-			slog.Error("creating ws session", "error", wsErr)
-		}
-		return
+	stats := mic.Stats()
+	slog.Info("audio capture stats",
+		"frames_read", stats.FramesRead,
+		"voiced_frames", stats.VoicedFrames,
+		"utterances_emitted", stats.UtterancesEmitted,
+		"utterances_dropped", stats.UtterancesDropped,
+		"read_errors", stats.ReadErrors,
+	)
+	logPlaybackStats("", speaker)
+	for _, name := range sinks.Names() {
+		logPlaybackStats(name, sinks.Get(name))
 	}
-	slog.Info("tts session ready, sending to agent", "text", text)
-	defer wsSession.Close()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for chunk := range wsSession.Audio() {
-			if ctx.Err() != nil {
-				return
-			}
-			if chunk.Error != nil {
-				if ctx.Err() == nil {
-					slog.Error("tts chunk", "error", chunk.Error)
-				}
-				return
-			}
-			if chunk.Done {
-				break
-			}
-			if err := speaker.Play(chunk.Data); err != nil {
-				if ctx.Err() == nil {
-					slog.Error("playing audio", "error", err)
-				}
-				return
-			}
-		}
-		if ctx.Err() == nil {
-			if err := speaker.Flush(); err != nil {
-				slog.Error("flushing audio", "error", err)
-			}
-		}
-	}()
+	slog.Info("shutting down")
+}
 
-	for event := range myAgent.ChatStream(ctx, text) {
-		if ctx.Err() != nil {
-			break
-		}
-		switch event.Type {
-		case types.EventContentDelta:
-			fmt.Print(event.Content)
-			if err := wsSession.SendText(event.Content); err != nil {
-				if ctx.Err() == nil {
-					slog.Error("sending text to tts", "error", err)
-				}
-			}
-		case types.EventError:
-			if ctx.Err() == nil {
-				slog.Error("agent stream", "error", event.Error)
-			}
-		}
+func recordUtterance(u audio.Utterance, recorder *audio.Recorder) {
+	if recorder != nil {
+		recorder.Save(u)
 	}
-	fmt.Println()
 
-	if ctx.Err() == nil {
-		if err := wsSession.Flush(); err != nil {
-			slog.Error("flushing ws session", "error", err)
-		}
+	attrs := []any{
+		"duration", u.Duration,
+		"speech", u.SpeechDuration,
+		"peak_rms", math.Round(u.PeakRMS),
+		"started", u.Start.Format(time.TimeOnly),
+		"hangover", u.Hangover,
+		"since_speech_end", time.Since(u.SpeechEnd).Round(time.Millisecond),
 	}
-
-	wg.Wait()
-
-	if ctx.Err() != nil {
-		slog.Info("interrupted")
+	if u.Clipped {
+		attrs = append(attrs, "clipped", true)
 	}
-}
-
-func isHallucination(resp *transcription.TranscriptionResponse) bool {
-	if len(resp.Segments) == 0 {
-		return false
+	if u.DroppedFrames > 0 {
+		attrs = append(attrs, "dropped_frames", u.DroppedFrames)
 	}
-	for _, seg := range resp.Segments {
-		if seg.NoSpeechProb >= noSpeechThreshold {
-			return true
-		}
+	if u.Room != "" {
+		attrs = append(attrs, "room", u.Room)
 	}
-	return false
+	slog.Info("utterance captured", attrs...)
 }
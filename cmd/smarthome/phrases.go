@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/tts"
+)
+
+// phraseSynthTimeout bounds synthesizing a phrase that has no audio, which
+// is tried when the synthesizer may well be what failed.
+const phraseSynthTimeout = 5 * time.Second
+
+// phraseBank says the messages that must get through: from the recorded
+// phrase if there is one, else synthesized from its text (and then cached),
+// else with the error earcon.
+type phraseBank struct {
+	budget *ttsBudget
+	cache  *tts.Cache
+	earcon func(name string)
+}
+
+// newPhraseBank logs which phrases in dir have no playable audio and will
+// be synthesized.
+func newPhraseBank(budget *ttsBudget, cache *tts.Cache, dir string, earcon func(name string)) *phraseBank {
+	for id, err := range audio.CheckPhrases(dir) {
+		if errors.Is(err, audio.ErrNoPhrase) {
+			slog.Debug("phrase has no audio, will be synthesized", "phrase", id)
+		} else {
+			slog.Warn("phrase can't be played, will be synthesized", "phrase", id, "error", err)
+		}
+	}
+	return &phraseBank{budget: budget, cache: cache, earcon: earcon}
+}
+
+func (b *phraseBank) say(ctx context.Context, speaker *audio.Playback, id string) {
+	err := speaker.PlayPhrase(id)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, audio.ErrNoPhrase) {
+		slog.Warn("playing phrase", "phrase", id, "error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, phraseSynthTimeout)
+	defer cancel()
+	p := b.budget.provider()
+	pcm, err := b.cache.Synthesize(ctx, p, audio.Phrases[id], func(ctx context.Context, text string) ([]byte, error) {
+		b.budget.record(ctx, p.Name(), utf8.RuneCountInString(text))
+		return synthesizeText(ctx, p, text)
+	})
+	if err == nil && len(pcm) == 0 {
+		err = fmt.Errorf("%s sent no audio", p.Name())
+	}
+	if err != nil {
+		slog.Warn("synthesizing phrase", "phrase", id, "error", err)
+		b.earcon(audio.ClipError)
+		return
+	}
+	if err := speaker.PlayOverlay(pcm); err != nil {
+		slog.Warn("playing phrase", "phrase", id, "error", err)
+	}
+}
+
+// synthesizeText collects all of p's audio for text, in one request when p
+// has one for it.
+func synthesizeText(ctx context.Context, p tts.Provider, text string) ([]byte, error) {
+	if fallback, ok := p.(tts.Fallback); ok {
+		return fallback.Synthesize(ctx, text)
+	}
+	stream, err := p.NewStream(ctx, tts.StreamOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("starting tts stream: %w", err)
+	}
+	defer stream.Close()
+	if err := stream.SendText(text); err != nil {
+		return nil, fmt.Errorf("sending text to tts: %w", err)
+	}
+	if err := stream.Flush(); err != nil {
+		return nil, fmt.Errorf("flushing tts stream: %w", err)
+	}
+
+	var pcm []byte
+	for chunk := range stream.Audio() {
+		if chunk.Error != nil {
+			return nil, chunk.Error
+		}
+		if chunk.Done {
+			return pcm, nil
+		}
+		pcm = append(pcm, chunk.Data...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("tts stream ended before the text was spoken")
+}
+
+// runPhrases lists the phrase bank, or renders it with the tts provider
+// into a directory to embed or point AUDIO_PHRASE_DIR at.
+func runPhrases(ctx context.Context, cfg *config.Config, p tts.Provider, args []string) error {
+	cmd := "list"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	ids := make([]string, 0, len(audio.Phrases))
+	for id := range audio.Phrases {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	switch cmd {
+	case "list":
+		failed := audio.CheckPhrases(cfg.AudioPhraseDir)
+		for _, id := range ids {
+			status := "ok"
+			if err := failed[id]; errors.Is(err, audio.ErrNoPhrase) {
+				status = "missing"
+			} else if err != nil {
+				status = err.Error()
+			}
+			fmt.Printf("%-22s %-8s %s\n", id, status, audio.Phrases[id])
+		}
+		return nil
+	case "generate":
+		dir := cfg.AudioPhraseDir
+		if len(args) > 1 {
+			dir = args[1]
+		}
+		if dir == "" {
+			return errors.New("usage: phrases generate <dir>, or set AUDIO_PHRASE_DIR")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating phrase dir: %w", err)
+		}
+		for _, id := range ids {
+			pcm, err := synthesizeText(ctx, p, audio.Phrases[id])
+			if err != nil {
+				return fmt.Errorf("synthesizing %s: %w", id, err)
+			}
+			if len(pcm) == 0 {
+				return fmt.Errorf("synthesizing %s: %s sent no audio", id, p.Name())
+			}
+			path := filepath.Join(dir, id+".wav")
+			if err := os.WriteFile(path, audio.EncodeWAV(pcm, p.SampleRate(), 1, 16), 0o644); err != nil {
+				return fmt.Errorf("writing %s: %w", id, err)
+			}
+			seconds := float64(len(pcm)/2) / float64(p.SampleRate())
+			fmt.Printf("Wrote %s (%.1fs)\n", path, seconds)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown phrases command %q, expected list or generate", cmd)
+	}
+}
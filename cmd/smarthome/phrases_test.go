@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/tts"
+)
+
+// fakeTTS answers Synthesize with pcm or err and remembers what it was
+// asked to say.
+type fakeTTS struct {
+	pcm []byte
+	err error
+
+	mu   sync.Mutex
+	said []string
+}
+
+func (f *fakeTTS) Name() string    { return "fake" }
+func (f *fakeTTS) SampleRate() int { return 16000 }
+func (f *fakeTTS) Phonemes() bool  { return false }
+func (f *fakeTTS) Close() error    { return nil }
+
+func (f *fakeTTS) NewStream(context.Context, tts.StreamOptions) (tts.Stream, error) {
+	return nil, errors.New("no streams")
+}
+
+func (f *fakeTTS) Synthesize(_ context.Context, text string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.said = append(f.said, text)
+	return f.pcm, f.err
+}
+
+// phraseDir holds a recording of the speech_unavailable phrase only.
+func phraseDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wav := audio.EncodeWAV(make([]byte, 3200), 16000, 1, 16)
+	if err := os.WriteFile(filepath.Join(dir, audio.PhraseSpeechUnavailable+".wav"), wav, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPhraseBankSay(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		synth      *fakeTTS
+		wantSaid   []string
+		wantEarcon bool
+	}{
+		{
+			name:  "recorded",
+			id:    audio.PhraseSpeechUnavailable,
+			synth: &fakeTTS{pcm: make([]byte, 320)},
+		},
+		{
+			name:     "synthesized",
+			id:       audio.PhraseAgentFailed,
+			synth:    &fakeTTS{pcm: make([]byte, 320)},
+			wantSaid: []string{audio.Phrases[audio.PhraseAgentFailed]},
+		},
+		{
+			name:       "synthesizer down",
+			id:         audio.PhraseAgentFailed,
+			synth:      &fakeTTS{err: errors.New("connection refused")},
+			wantSaid:   []string{audio.Phrases[audio.PhraseAgentFailed]},
+			wantEarcon: true,
+		},
+		{
+			name:       "no audio",
+			id:         audio.PhraseSmokeAlarm,
+			synth:      &fakeTTS{},
+			wantSaid:   []string{audio.Phrases[audio.PhraseSmokeAlarm]},
+			wantEarcon: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			speaker, err := audio.NewPlayback(nil,
+				audio.WithBackend(audio.BackendFile),
+				audio.WithPlaybackDir(t.TempDir()),
+				audio.WithPhraseDir(phraseDir(t)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer speaker.Close()
+
+			var earcons []string
+			bank := &phraseBank{
+				budget: &ttsBudget{primary: tt.synth},
+				earcon: func(name string) { earcons = append(earcons, name) },
+			}
+			bank.say(context.Background(), speaker, tt.id)
+
+			if len(tt.synth.said) != len(tt.wantSaid) || len(tt.wantSaid) > 0 && tt.synth.said[0] != tt.wantSaid[0] {
+				t.Errorf("synthesized %q, want %q", tt.synth.said, tt.wantSaid)
+			}
+			if tt.wantEarcon != (len(earcons) == 1 && earcons[0] == audio.ClipError) {
+				t.Errorf("earcons %q, want error earcon %v", earcons, tt.wantEarcon)
+			}
+		})
+	}
+}
+
+func TestRunPhrasesGenerate(t *testing.T) {
+	dir := t.TempDir()
+	synth := &fakeTTS{pcm: make([]byte, 1600)}
+	if err := runPhrases(context.Background(), &config.Config{}, synth, []string{"generate", dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(synth.said) != len(audio.Phrases) {
+		t.Errorf("synthesized %d phrases, want %d", len(synth.said), len(audio.Phrases))
+	}
+	if failed := audio.CheckPhrases(dir); len(failed) > 0 {
+		t.Errorf("generated phrases don't load: %v", failed)
+	}
+}
+
+func TestRunPhrasesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		synth *fakeTTS
+		args  []string
+	}{
+		{"no dir", &fakeTTS{pcm: make([]byte, 1600)}, []string{"generate"}},
+		{"synthesizer down", &fakeTTS{err: errors.New("connection refused")}, []string{"generate", "DIR"}},
+		{"no audio", &fakeTTS{}, []string{"generate", "DIR"}},
+		{"unknown command", &fakeTTS{}, []string{"play"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, a := range tt.args {
+				if a == "DIR" {
+					tt.args[i] = t.TempDir()
+				}
+			}
+			if err := runPhrases(context.Background(), &config.Config{}, tt.synth, tt.args); err == nil {
+				t.Error("runPhrases succeeded")
+			}
+		})
+	}
+}
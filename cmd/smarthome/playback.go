@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/config"
+)
+
+// newSpeakers opens the speaker and the other rooms' sinks. Only the
+// speaker feeds the echo canceller, and not with the null and file
+// backends, which write faster than real time.
+func newSpeakers(cfg *config.Config, aec *audio.EchoCanceller, sourceRate int) (*audio.Playback, *audio.Sinks, error) {
+	opts := []audio.Option{
+		audio.WithBackend(cfg.PlaybackBackend),
+		audio.WithPlaybackDir(cfg.PlaybackDir),
+		audio.WithPlaybackSourceRate(sourceRate),
+		audio.WithPlaybackSampleRate(cfg.PlaybackSampleRate),
+		audio.WithPlaybackChannels(cfg.PlaybackChannels),
+		audio.WithVolume(cfg.PlaybackVolume),
+		audio.WithPlaybackFades(cfg.PlaybackFades),
+		audio.WithPlaybackLimiter(cfg.PlaybackLimiter),
+		audio.WithPlaybackQueueMs(cfg.PlaybackQueueMs),
+		audio.WithClipDir(cfg.AudioEarconDir),
+		audio.WithPhraseDir(cfg.AudioPhraseDir),
+		audio.WithActiveHold(time.Duration(cfg.PlaybackDuckHoldMs) * time.Millisecond),
+		audio.WithActiveDebounce(time.Duration(cfg.PlaybackDuckDebounceMs) * time.Millisecond),
+	}
+	sinkConfigs, err := audio.ParseSinks(cfg.PlaybackSinks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing playback sinks: %w", err)
+	}
+
+	if cfg.PlaybackBackend == audio.BackendNull || cfg.PlaybackBackend == audio.BackendFile {
+		aec = nil
+	}
+	speaker, err := audio.NewPlayback(aec, append(opts, outputDevice(cfg.PlaybackDevice))...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.PlaybackDuckCommand != "" {
+		speaker.OnActive(duckCommand(cfg.PlaybackDuckCommand))
+	}
+
+	sinks := audio.NewSinks()
+	for _, sc := range sinkConfigs {
+		sink, err := audio.NewPlayback(nil, append(opts, outputDevice(sc.Device), audio.WithPlaybackName(sc.Name))...)
+		if err == nil {
+			err = sinks.Add(sc.Name, sink)
+			if err != nil {
+				sink.Close()
+			}
+		}
+		if err != nil {
+			sinks.Close()
+			speaker.Close()
+			return nil, nil, fmt.Errorf("creating playback sink %s: %w", sc.Name, err)
+		}
+		slog.Info("playback sink ready", "sink", sc.Name, "device", sc.Device)
+	}
+	return speaker, sinks, nil
+}
+
+// outputDevice reads PLAYBACK_DEVICE as an index into the output list when
+// it is a number, and as a device name otherwise.
+func outputDevice(device string) audio.Option {
+	if i, err := strconv.Atoi(device); err == nil {
+		return audio.WithOutputDeviceIndex(i)
+	}
+	return audio.WithOutputDevice(device)
+}
+
+func logPlaybackStats(sink string, p *audio.Playback) {
+	s := p.Stats()
+	slog.Info("playback stats",
+		"sink", sink,
+		"played_bytes", s.PlayedBytes,
+		"rejected_chunks", s.Rejected,
+		"underflows", s.Underflows,
+	)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/tts"
+)
+
+// newSessionConfig reads the ElevenLabs session settings from cfg.
+func newSessionConfig(cfg *config.Config) (tts.SessionConfig, error) {
+	dictionaries, err := tts.ParseDictionaryLocators(cfg.ElevenLabsDictionaries)
+	if err != nil {
+		return tts.SessionConfig{}, err
+	}
+	if cfg.ElevenLabsSeed < 0 || cfg.ElevenLabsSeed > math.MaxUint32 {
+		return tts.SessionConfig{}, fmt.Errorf("seed %d out of range 0 to %d", cfg.ElevenLabsSeed, uint32(math.MaxUint32))
+	}
+	c := tts.SessionConfig{
+		APIKey:       cfg.ElevenLabsAPIKey,
+		VoiceID:      cfg.ElevenLabsVoiceID,
+		ModelID:      cfg.ElevenLabsModel,
+		OutputFormat: tts.OutputFormat(cfg.ElevenLabsFormat),
+		Stability:    cfg.ElevenLabsStability,
+		Similarity:   cfg.ElevenLabsSimilarity,
+		Speed:        cfg.ElevenLabsSpeed,
+		EnableSSML:   tts.SupportsPhonemes(cfg.ElevenLabsModel),
+		LanguageCode: cfg.ElevenLabsLanguage,
+		Style:        cfg.ElevenLabsStyle,
+		SpeakerBoost: cfg.ElevenLabsSpeakerBoost,
+		Seed:         uint32(cfg.ElevenLabsSeed),
+
+		MaxReconnects:    cfg.ElevenLabsReconnects,
+		ReconnectBackoff: time.Duration(cfg.ElevenLabsBackoffMs) * time.Millisecond,
+		DialRetries:      cfg.ElevenLabsDialRetries,
+		DialBackoff:      time.Duration(cfg.ElevenLabsDialBackoffMs) * time.Millisecond,
+		DialMaxBackoff:   time.Duration(cfg.ElevenLabsDialMaxBackoffMs) * time.Millisecond,
+		DialJitter:       cfg.ElevenLabsDialJitter,
+		KeepAlive:        time.Duration(cfg.ElevenLabsKeepAlive) * time.Millisecond,
+		FlushTimeout:     time.Duration(cfg.ElevenLabsFlushMs) * time.Millisecond,
+		Alignment:        cfg.ElevenLabsAlignment,
+
+		ChunkLengthSchedule: cfg.ElevenLabsSchedule,
+		AutoMode:            cfg.ElevenLabsAutoMode,
+		Dictionaries:        dictionaries,
+		Guard:               panicGuard,
+	}
+	return c, c.Validate()
+}
+
+// newTTSProvider creates the provider TTS_PROVIDER names. ElevenLabs reads
+// sessionConfig for each turn, which is how load downgrades its model.
+func newTTSProvider(cfg *config.Config, sessionConfig func() tts.SessionConfig) (tts.Provider, error) {
+	switch cfg.TTSProvider {
+	case "elevenlabs":
+		// Turns take a connected session from the pool instead of dialing
+		// one while transcription runs, keep using the last turn's, or
+		// with multi-context, a context on the one socket kept for the
+		// whole run.
+		mode := tts.SessionPerTurn
+		switch {
+		case cfg.ElevenLabsMulti && cfg.ElevenLabsReuse:
+			return nil, errors.New("ELEVENLABS_MULTI_CONTEXT and ELEVENLABS_REUSE_SESSION can't both be set")
+		case cfg.ElevenLabsMulti:
+			mode = tts.SessionMulti
+		case cfg.ElevenLabsReuse:
+			if codec := sessionConfig().OutputFormat.Codec(); codec != "pcm" {
+				return nil, fmt.Errorf("ELEVENLABS_REUSE_SESSION needs a pcm ELEVENLABS_FORMAT, not %s", codec)
+			}
+			mode = tts.SessionReused
+		}
+		return tts.NewElevenLabs(sessionConfig, cfg.ElevenLabsPoolSize, mode), nil
+	case "openai":
+		return tts.NewOpenAI(tts.OpenAIConfig{
+			APIKey: cfg.OpenAIAPIKey,
+			Model:  cfg.OpenAITTSModel,
+			Voice:  cfg.OpenAITTSVoice,
+			Format: cfg.OpenAITTSFormat,
+			Speed:  cfg.OpenAITTSSpeed,
+		})
+	case "piper":
+		return tts.NewPiper(tts.PiperConfig{
+			URL:        cfg.PiperURL,
+			Bin:        cfg.PiperBin,
+			Model:      cfg.PiperModel,
+			SampleRate: cfg.PiperSampleRate,
+		})
+	case "azure":
+		return tts.NewAzure(tts.AzureConfig{
+			Key:    cfg.AzureSpeechKey,
+			Region: cfg.AzureSpeechRegion,
+			Voice:  cfg.AzureSpeechVoice,
+		})
+	default:
+		return nil, fmt.Errorf("unknown tts provider %q, expected elevenlabs, openai, piper or azure", cfg.TTSProvider)
+	}
+}
+
+// newBatchConfig reads how text is batched for the TTS, and normalized
+// first if TTS_NORMALIZE names a normalization.
+func newBatchConfig(cfg *config.Config) (tts.BatchConfig, error) {
+	b := tts.BatchConfig{
+		MaxChars: cfg.TTSBatchChars,
+		MaxDelay: time.Duration(cfg.TTSBatchMs) * time.Millisecond,
+	}
+	if cfg.TTSNormalize != "" {
+		var err error
+		if b.Normalize, err = tts.Normalizer(cfg.TTSNormalize); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// openTTSCache opens the cache TTS_CACHE_DIR names, or returns nil, which
+// caches nothing, when it is unset.
+func openTTSCache(cfg *config.Config) (*tts.Cache, error) {
+	if cfg.TTSCacheDir == "" {
+		return nil, nil
+	}
+	return tts.OpenCache(tts.CacheConfig{
+		Dir:      cfg.TTSCacheDir,
+		MaxBytes: int64(cfg.TTSCacheMaxMB) << 20,
+		MaxChars: cfg.TTSCacheMaxChars,
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/joakimcarlsson/smarthome/internal/otel"
+	"github.com/joakimcarlsson/smarthome/internal/scrub"
+)
+
+// scrubLogging scrubs every log record that is exported. The console is
+// only scrubbed with SCRUB_CONSOLE, since it is what's read when debugging.
+func scrubLogging(s *scrub.Scrubber, console bool) []otel.LoggerOption {
+	opts := []otel.LoggerOption{otel.WithExportRedactor(s.Scrub)}
+	if console {
+		opts = append(opts, otel.WithConsoleRedactor(s.Scrub))
+	}
+	return opts
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joakimcarlsson/smarthome/internal/otel"
+	"github.com/joakimcarlsson/smarthome/internal/scrub"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// exported keeps the text of every record handed to the log exporter.
+type exported struct {
+	texts []string
+}
+
+func (e *exported) OnEmit(_ context.Context, r *sdklog.Record) error {
+	text := r.Body().AsString()
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		text += " " + kv.Key + "=" + kv.Value.AsString()
+		return true
+	})
+	e.texts = append(e.texts, text)
+	return nil
+}
+
+func (e *exported) Enabled(context.Context, sdklog.EnabledParameters) bool { return true }
+func (e *exported) Shutdown(context.Context) error                         { return nil }
+func (e *exported) ForceFlush(context.Context) error                       { return nil }
+
+// logBoth sets up logging as main does, logs msg and attrs, and returns
+// what reached the console and the exporter.
+func logBoth(t *testing.T, opts []otel.LoggerOption, msg string, attrs ...any) (console, export string) {
+	t.Helper()
+	exp := &exported{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(exp))
+	prevProvider, prevLogger, prevStdout := global.GetLoggerProvider(), slog.Default(), os.Stdout
+	t.Cleanup(func() {
+		global.SetLoggerProvider(prevProvider)
+		slog.SetDefault(prevLogger)
+		os.Stdout = prevStdout
+		provider.Shutdown(context.Background())
+	})
+	global.SetLoggerProvider(provider)
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	os.Stdout = out
+	otel.SetupLogger("test", "info", "json", opts...)
+	slog.Info(msg, attrs...)
+	os.Stdout = prevStdout
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), strings.Join(exp.texts, "\n")
+}
+
+func TestScrubLogging(t *testing.T) {
+	s, err := scrub.New(scrub.Config{Names: []string{"Åsa"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		msg   = "ring Åsa på 070-123 45 67"
+		email = "asa@example.se"
+	)
+
+	tests := []struct {
+		name           string
+		console        bool
+		wantRawConsole bool
+	}{
+		{"SCRUB_CONSOLE unset", false, true},
+		{"SCRUB_CONSOLE set", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			console, export := logBoth(t, scrubLogging(s, tt.console), msg, "email", email)
+
+			// The exporter never sees the raw text.
+			for _, raw := range []string{"Åsa", "070-123", email} {
+				if strings.Contains(export, raw) {
+					t.Errorf("exported %q: %s", raw, export)
+				}
+			}
+			for _, placeholder := range []string{"[NAME]", "[PHONE]", "[EMAIL]"} {
+				if !strings.Contains(export, placeholder) {
+					t.Errorf("exported record lacks %s: %s", placeholder, export)
+				}
+			}
+
+			for _, raw := range []string{"Åsa", "070-123", email} {
+				if got := strings.Contains(console, raw); got != tt.wantRawConsole {
+					t.Errorf("console has %q: %v, want %v: %s", raw, got, tt.wantRawConsole, console)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"cmp"
+	"log/slog"
+	"strings"
+
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/style"
+)
+
+// newStyleRules returns nil unless STYLE_MATCHING is set.
+func newStyleRules(cfg *config.Config) *style.Rules {
+	if !cfg.StyleMatching {
+		return nil
+	}
+	rules := style.DefaultRules()
+	rules.ShortMaxWords = cfg.StyleShortMaxWords
+	rules.LongMinWords = cfg.StyleLongMinWords
+	rules.ShortHint = cmp.Or(cfg.StyleShortHint, rules.ShortHint)
+	rules.LongHint = cmp.Or(cfg.StyleLongHint, rules.LongHint)
+	rules.CommandHint = cmp.Or(cfg.StyleCommandHint, rules.CommandHint)
+	rules.PoliteHint = cmp.Or(cfg.StylePoliteHint, rules.PoliteHint)
+	rules.CasualHint = cmp.Or(cfg.StyleCasualHint, rules.CasualHint)
+	return &rules
+}
+
+// withStyleHints appends hints on how to answer, derived from how the user
+// asked.
+func withStyleHints(text string, rules *style.Rules) string {
+	if rules == nil {
+		return text
+	}
+	hints := rules.Hints(style.Analyze(text))
+	if len(hints) == 0 {
+		return text
+	}
+	slog.Debug("style hints", "hints", hints)
+	return text + "\n\n(Style: " + strings.Join(hints, " ") + ")"
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+)
+
+const (
+	testToneHz       = 1000
+	testToneDuration = 2 * time.Second
+	// testToneMinDB is how far above the background the tone has to be
+	// for the microphone to count as hearing it.
+	testToneMinDB = 6
+)
+
+// runTestAudio plays a tone on the speaker and every sink in turn while
+// the microphone listens, and prints how loud it heard each one against
+// the room's background.
+func runTestAudio(ctx context.Context, mic *audio.Capture, speaker *audio.Playback, sinks *audio.Sinks) error {
+	fmt.Printf("Measuring background for %s, keep the room quiet...\n", testToneDuration)
+	background, err := mic.MeasureRMS(ctx, testToneDuration)
+	if err != nil {
+		return fmt.Errorf("measuring background: %w", err)
+	}
+	fmt.Printf("Background RMS %.0f\n", background)
+
+	type target struct {
+		name string
+		p    *audio.Playback
+	}
+	targets := []target{{"speaker", speaker}}
+	for _, name := range sinks.Names() {
+		targets = append(targets, target{"sink " + name, sinks.Get(name)})
+	}
+
+	for _, t := range targets {
+		type measurement struct {
+			rms float64
+			err error
+		}
+		heard := make(chan measurement, 1)
+		go func() {
+			rms, err := mic.MeasureRMS(ctx, testToneDuration)
+			heard <- measurement{rms, err}
+		}()
+
+		fmt.Printf("Playing a %d Hz tone on the %s...\n", testToneHz, t.name)
+		if err := t.p.TestTone(ctx, testToneHz, testToneDuration); err != nil {
+			return fmt.Errorf("playing test tone on the %s: %w", t.name, err)
+		}
+		m := <-heard
+		if m.err != nil {
+			return fmt.Errorf("recording test tone: %w", m.err)
+		}
+
+		db := 20 * math.Log10(m.rms/max(background, 1))
+		verdict := "OK"
+		if db < testToneMinDB {
+			verdict = "not heard, check the speaker, its volume and the microphone"
+		}
+		fmt.Printf("%s: microphone RMS %.0f, %.1f dB over background, %s\n", t.name, m.rms, db, verdict)
+	}
+	return nil
+}
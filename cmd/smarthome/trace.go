@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/tts"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	tracer = otel.Tracer("github.com/joakimcarlsson/smarthome/cmd/smarthome")
+	meter  = otel.Meter("github.com/joakimcarlsson/smarthome/cmd/smarthome")
+)
+
+// startTurn opens the span for one turn. It starts when the user stopped
+// speaking, so playback's first_sound event lands at the time to first
+// sound; a pre-transcribed turn has no utterance and starts now.
+func startTurn(ctx context.Context, utt ...audio.Utterance) (context.Context, trace.Span) {
+	var opts []trace.SpanStartOption
+	if len(utt) > 0 && !utt[0].SpeechEnd.IsZero() {
+		opts = append(opts, trace.WithTimestamp(utt[0].SpeechEnd))
+	}
+	return tracer.Start(ctx, "turn", opts...)
+}
+
+// How a turn's response was synthesized: streamed over the websocket, or
+// over HTTP, either by a provider without a websocket or in one request
+// when the websocket couldn't be reached.
+const (
+	ttsModeWS   = "ws"
+	ttsModeHTTP = "http"
+)
+
+// ttsModeNone is a turn whose response was only printed, as the tts
+// budget was spent.
+const ttsModeNone = "none"
+
+// streamMode is how p's streams reach it; only ElevenLabs has a websocket.
+func streamMode(p tts.Provider) string {
+	switch p.(type) {
+	case *tts.ElevenLabs:
+		return ttsModeWS
+	case textOnly:
+		return ttsModeNone
+	}
+	return ttsModeHTTP
+}
+
+var (
+	ttsResponses  metric.Int64Counter
+	ttsCharacters metric.Int64Counter
+)
+
+func init() {
+	var err error
+	ttsResponses, err = meter.Int64Counter("tts.responses",
+		metric.WithDescription("Responses synthesized, by mode (ws or http)"),
+	)
+	if err != nil {
+		slog.Warn("creating tts.responses counter", "error", err)
+	}
+	ttsCharacters, err = meter.Int64Counter("tts.characters",
+		metric.WithDescription("Characters sent to be spoken, by provider"),
+	)
+	if err != nil {
+		slog.Warn("creating tts.characters counter", "error", err)
+	}
+}
+
+// countTTSMode records mode on the turn's span and in tts.responses.
+func countTTSMode(ctx context.Context, mode string) {
+	attr := attribute.String("tts.mode", mode)
+	trace.SpanFromContext(ctx).SetAttributes(attr)
+	if ttsResponses != nil {
+		ttsResponses.Add(ctx, 1, metric.WithAttributes(attr))
+	}
+}
+
+// registerTTSCacheMetrics exports the cache's lookups, by whether they hit,
+// for its hit rate.
+func registerTTSCacheMetrics(cache *tts.Cache) {
+	if cache == nil {
+		return
+	}
+	lookups, err := meter.Int64ObservableCounter("tts.cache.lookups",
+		metric.WithDescription("Lookups of short phrases in the tts cache, by hit"),
+	)
+	if err != nil {
+		slog.Warn("creating tts.cache.lookups counter", "error", err)
+		return
+	}
+	hit := metric.WithAttributes(attribute.Bool("hit", true))
+	miss := metric.WithAttributes(attribute.Bool("hit", false))
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := cache.Stats()
+		o.ObserveInt64(lookups, s.Hits, hit)
+		o.ObserveInt64(lookups, s.Misses, miss)
+		return nil
+	}, lookups)
+	if err != nil {
+		slog.Warn("registering tts cache metrics", "error", err)
+	}
+}
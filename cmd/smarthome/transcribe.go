@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/joakimcarlsson/ai/transcription"
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/transcript"
+)
+
+const noSpeechThreshold = 0.6
+
+// sttPrompt biases the spelling of names speech to text won't know.
+const sttPrompt = "Smarthome, Bälstaberg, Vallentuna, Sverige."
+
+// heard is an utterance as transcribed.
+type heard struct {
+	text     string
+	words    []transcript.Word
+	noSpeech bool
+}
+
+// transcribe uses word timestamps when the model returns them.
+func (r *responder) transcribe(ctx context.Context, u audio.Utterance) (heard, error) {
+	data, filename := u.Encode(r.uploadFormat)
+	if r.wordSTT != nil {
+		t, err := r.wordSTT.Transcribe(ctx, data, filename, "sv")
+		if err != nil {
+			return heard{}, err
+		}
+		return heard{text: t.Text, words: t.Words, noSpeech: t.NoSpeechProb >= noSpeechThreshold}, nil
+	}
+	resp, err := r.stt.Transcribe(ctx, data,
+		transcription.WithLanguage("sv"),
+		transcription.WithFilename(filename),
+		transcription.WithPrompt(sttPrompt),
+		transcription.WithResponseFormat("json"),
+	)
+	if err != nil {
+		return heard{}, err
+	}
+	text := strings.TrimSpace(resp.Text)
+	return heard{text: text, words: transcript.Words(text), noSpeech: isHallucination(resp)}, nil
+}
+
+// clean strips the wake word and leading fillers from what was heard.
+func (r *responder) clean(h heard, woken bool) string {
+	if stats, ok := transcript.SpeechRate(h.words); ok {
+		slog.Debug("speech rate",
+			"words", stats.Words,
+			"wpm", math.Round(stats.WordsPerMinute),
+			"mean_pause", stats.MeanPause,
+			"max_pause", stats.MaxPause,
+		)
+	}
+	if woken {
+		return transcript.Join(r.cleaner.CleanWoken(h.words, r.wakeTail))
+	}
+	return transcript.Join(r.cleaner.Clean(h.words))
+}
+
+func isHallucination(resp *transcription.TranscriptionResponse) bool {
+	if len(resp.Segments) == 0 {
+		return false
+	}
+	for _, seg := range resp.Segments {
+		if seg.NoSpeechProb >= noSpeechThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// newWordSTT returns nil unless word timestamps are on and the model
+// returns them.
+func newWordSTT(ctx context.Context, cfg *config.Config) *transcript.WordClient {
+	if !cfg.TranscriptWordTimestamps {
+		return nil
+	}
+	c := transcript.NewWordClient(cfg.OpenAIAPIKey, cfg.TranscriptWordModel, sttPrompt)
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	if err := c.Probe(ctx); err != nil {
+		slog.Warn("word timestamps unavailable, transcribing without", "model", cfg.TranscriptWordModel, "error", err)
+		return nil
+	}
+	slog.Info("transcribing with word timestamps", "model", cfg.TranscriptWordModel)
+	return c
+}
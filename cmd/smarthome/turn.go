@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joakimcarlsson/ai/agent"
+	"github.com/joakimcarlsson/ai/transcription"
+	"github.com/joakimcarlsson/ai/types"
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/lexicon"
+	"github.com/joakimcarlsson/smarthome/internal/otel"
+	"github.com/joakimcarlsson/smarthome/internal/style"
+	"github.com/joakimcarlsson/smarthome/internal/transcript"
+	"github.com/joakimcarlsson/smarthome/internal/tts"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// responder holds what every turn shares; only the speaker changes.
+type responder struct {
+	stt              transcription.SpeechToText
+	wordSTT          *transcript.WordClient
+	uploadFormat     string
+	cleaner          *transcript.Cleaner
+	wakeTail         time.Duration
+	stopPhrases      *transcript.StopPhrases
+	styleRules       *style.Rules
+	agent            *agent.Agent
+	phrases          *phraseBank
+	echoGate         *audio.Capture
+	budget           *ttsBudget
+	cache            *tts.Cache
+	batching         tts.BatchConfig
+	newPronunciation func(tts.Provider) *lexicon.Stream
+}
+
+// bargeIn is speech that interrupts a response, once transcribed.
+type bargeIn struct {
+	text string
+	room string
+}
+
+// confirmBargeIn returns the text of u if it is worth interrupting for.
+func (r *responder) confirmBargeIn(ctx context.Context, u audio.Utterance) (string, bool) {
+	h, err := r.transcribe(ctx, u)
+	if err != nil {
+		slog.Debug("barge-in STT failed, ignoring", "error", err)
+		return "", false
+	}
+	if h.text == "" || h.noSpeech {
+		slog.Debug("discarding non-speech interrupt")
+		return "", false
+	}
+	text := r.clean(h, u.WakeWord)
+	if text == "" {
+		slog.Debug("discarding interrupt with only wake word or fillers")
+		return "", false
+	}
+	return text, true
+}
+
+// processUtterance runs one turn and closes done. utt is transcribed
+// unless preTranscribed is set.
+func (r *responder) processUtterance(ctx context.Context, done chan struct{}, speaker *audio.Playback, preTranscribed string, utt ...audio.Utterance) {
+	defer close(done)
+	defer panicGuard.Recover("turn")
+	say := func(id string) { r.phrases.say(ctx, speaker, id) }
+
+	ctx, span := startTurn(ctx, utt...)
+	defer span.End()
+
+	ttsProvider := r.budget.provider()
+	pronunciation := r.newPronunciation(ttsProvider)
+	var chars int
+	defer func() { r.budget.record(ctx, ttsProvider.Name(), chars) }()
+
+	text := preTranscribed
+	if text != "" {
+		slog.Info("processing pre-transcribed", "text", text)
+	}
+
+	var wsSession tts.Stream
+	var wsErr error
+	wsDone := make(chan struct{})
+	go func() {
+		wsSession, wsErr = ttsProvider.NewStream(ctx, tts.StreamOptions{})
+		close(wsDone)
+	}()
+
+	if text == "" && len(utt) > 0 {
+		h, err := r.transcribe(ctx, utt[0])
+		if err != nil {
+			if ctx.Err() != nil {
+				slog.Info("interrupted during transcription")
+			} else {
+				slog.Error("transcribing", "error", err)
+				say(audio.PhraseTranscriptionFailed)
+			}
+			<-wsDone
+			if wsSession != nil {
+				wsSession.Close()
+			}
+			return
+		}
+
+		text = h.text
+		if text == "" || h.noSpeech {
+			if text != "" {
+				slog.Debug("discarding hallucination", "text", text)
+			}
+			<-wsDone
+			if wsSession != nil {
+				wsSession.Close()
+			}
+			return
+		}
+
+		slog.Info("transcribed", "text", text)
+
+		if text = r.clean(h, utt[0].WakeWord); text == "" {
+			slog.Debug("nothing left after removing wake word and fillers")
+			<-wsDone
+			if wsSession != nil {
+				wsSession.Close()
+			}
+			return
+		}
+
+		// A stop with nothing to stop isn't a request.
+		if r.stopPhrases.Match(text) {
+			slog.Debug("ignoring stop phrase with nothing to stop", "text", text)
+			<-wsDone
+			if wsSession != nil {
+				wsSession.Close()
+			}
+			return
+		}
+	}
+
+	<-wsDone
+	if wsErr != nil {
+		if ctx.Err() != nil {
+			slog.Info("interrupted during tts connect")
+			return
+		}
+		fallback, ok := ttsProvider.(tts.Fallback)
+		if !ok {
+			slog.Error("starting tts stream", "provider", ttsProvider.Name(), "error", wsErr)
+			say(audio.PhraseSpeechUnavailable)
+			return
+		}
+		slog.Warn("tts websocket unavailable, falling back to http", "error", wsErr)
+		synthesize := func(ctx context.Context, text string) ([]byte, error) {
+			if r.batching.Normalize != nil {
+				text = r.batching.Normalize(text)
+			}
+			return r.cache.Synthesize(ctx, ttsProvider, text, func(ctx context.Context, text string) ([]byte, error) {
+				chars += utf8.RuneCountInString(text)
+				return fallback.Synthesize(ctx, text)
+			})
+		}
+		respondHTTP(ctx, text, r.styleRules, r.agent, speaker, say, r.echoGate, synthesize, pronunciation)
+		return
+	}
+	mode := streamMode(ttsProvider)
+	countTTSMode(ctx, mode)
+	slog.Info("tts session ready, sending to agent", "text", text, "provider", ttsProvider.Name(), "mode", mode)
+	defer wsSession.Close()
+	// Closing on cancel unblocks the read loop playback waits on.
+	defer context.AfterFunc(ctx, func() { wsSession.Close() })()
+
+	speaker.TraceResponse(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer panicGuard.Recover("playback")
+		// Don't leave a partial frame for the next response.
+		defer speaker.Flush()
+		if r.echoGate != nil {
+			defer r.echoGate.Unmute()
+		}
+		for chunk := range wsSession.Audio() {
+			if ctx.Err() != nil {
+				return
+			}
+			if chunk.Error != nil {
+				if ctx.Err() == nil {
+					slog.Error("tts chunk", "error", chunk.Error)
+				}
+				return
+			}
+			if chunk.Done {
+				break
+			}
+			if chunk.Reconnected || len(chunk.Data) == 0 {
+				continue
+			}
+			if r.echoGate != nil && !r.echoGate.Muted() {
+				r.echoGate.Mute()
+			}
+			if err := speaker.Play(chunk.Data); errors.Is(err, audio.ErrQueueFull) {
+				slog.Warn("playback queue full, dropping audio", "bytes", len(chunk.Data))
+			} else if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("playing audio", "error", err)
+				}
+				return
+			}
+		}
+		// Play only queues; wait for the device to finish.
+		speaker.Flush()
+		select {
+		case <-speaker.Done():
+		case <-ctx.Done():
+		}
+	}()
+
+	batch := tts.NewBatcher(wsSession.SendText, r.batching)
+	defer batch.Close()
+	streamAgent(ctx, text, r.styleRules, r.agent, say, pronunciation, func(spoken string) {
+		chars += utf8.RuneCountInString(spoken)
+		if err := batch.Write(spoken); err != nil {
+			if ctx.Err() == nil {
+				slog.Error("sending text to tts", "error", err)
+			}
+		}
+	})
+	if ctx.Err() == nil {
+		if err := batch.Flush(); err != nil {
+			slog.Error("sending text to tts", "error", err)
+		}
+		if err := flushStream(ctx, wsSession); errors.Is(err, tts.ErrFlushTimeout) {
+			slog.Warn("tts response never finished, moving on", "error", err)
+		} else if err != nil && ctx.Err() == nil {
+			slog.Error("flushing ws session", "error", err)
+		}
+	}
+
+	wg.Wait()
+
+	if session, ok := wsSession.(interface{ FirstAudioLatency() time.Duration }); ok {
+		if latency := session.FirstAudioLatency(); latency > 0 {
+			slog.Info("tts first audio", "latency_ms", latency.Milliseconds())
+		}
+	}
+
+	if ctx.Err() != nil {
+		slog.Info("interrupted")
+	}
+}
+
+// flushStream flushes s, waiting for the end of the response if s can.
+func flushStream(ctx context.Context, s tts.Stream) error {
+	if session, ok := s.(interface {
+		FlushAndWait(ctx context.Context) error
+	}); ok {
+		return session.FlushAndWait(ctx)
+	}
+	return s.Flush()
+}
+
+// streamAgent runs the agent on text and passes what is to be spoken to
+// speak as it streams in.
+func streamAgent(
+	ctx context.Context,
+	text string,
+	styleRules *style.Rules,
+	myAgent *agent.Agent,
+	say func(id string),
+	pronunciation *lexicon.Stream,
+	speak func(spoken string),
+) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(otel.ContentAttrs("transcript", text)...)
+	var response strings.Builder
+	defer func() { span.SetAttributes(otel.ContentAttrs("response", response.String())...) }()
+
+	for event := range myAgent.ChatStream(ctx, withStyleHints(text, styleRules)) {
+		if ctx.Err() != nil {
+			break
+		}
+		switch event.Type {
+		case types.EventContentDelta:
+			fmt.Print(event.Content)
+			response.WriteString(event.Content)
+			if spoken := pronunciation.Write(event.Content); spoken != "" {
+				speak(spoken)
+			}
+		case types.EventError:
+			if ctx.Err() == nil {
+				slog.Error("agent stream", "error", event.Error)
+				say(audio.PhraseAgentFailed)
+			}
+		}
+	}
+	fmt.Println()
+
+	if ctx.Err() == nil {
+		if rest := pronunciation.Flush(); rest != "" {
+			speak(rest)
+		}
+	}
+}
+
+// respondHTTP synthesizes the whole response in one request, for when the
+// websocket is unavailable.
+func respondHTTP(
+	ctx context.Context,
+	text string,
+	styleRules *style.Rules,
+	myAgent *agent.Agent,
+	speaker *audio.Playback,
+	say func(id string),
+	echoGate *audio.Capture,
+	synthesize func(ctx context.Context, text string) ([]byte, error),
+	pronunciation *lexicon.Stream,
+) {
+	countTTSMode(ctx, ttsModeHTTP)
+	slog.Info("sending to agent", "text", text, "mode", ttsModeHTTP)
+
+	var response strings.Builder
+	streamAgent(ctx, text, styleRules, myAgent, say, pronunciation, func(spoken string) {
+		response.WriteString(spoken)
+	})
+	if ctx.Err() != nil {
+		slog.Info("interrupted")
+		return
+	}
+	if strings.TrimSpace(response.String()) == "" {
+		return
+	}
+
+	pcm, err := synthesize(ctx, response.String())
+	if err != nil {
+		if ctx.Err() != nil {
+			slog.Info("interrupted during http tts")
+		} else {
+			slog.Error("synthesizing over http", "error", err)
+			say(audio.PhraseSpeechUnavailable)
+		}
+		return
+	}
+
+	speaker.TraceResponse(ctx)
+	defer speaker.Flush()
+	if echoGate != nil {
+		echoGate.Mute()
+		defer echoGate.Unmute()
+	}
+	if err := playAll(ctx, speaker, pcm); err != nil {
+		if ctx.Err() == nil {
+			slog.Error("playing audio", "error", err)
+		}
+		return
+	}
+	speaker.Flush()
+	select {
+	case <-speaker.Done():
+	case <-ctx.Done():
+		slog.Info("interrupted")
+	}
+}
+
+// httpPlayChunk is how much of an HTTP response is queued at a time.
+const httpPlayChunk = 4800
+
+// playAll queues pcm, waiting whenever the queue is full.
+func playAll(ctx context.Context, speaker *audio.Playback, pcm []byte) error {
+	for len(pcm) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := min(httpPlayChunk, len(pcm))
+		err := speaker.Play(pcm[:n])
+		if errors.Is(err, audio.ErrQueueFull) {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err != nil {
+			return err
+		}
+		pcm = pcm[n:]
+	}
+	return nil
+}
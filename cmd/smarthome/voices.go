@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+	"github.com/joakimcarlsson/smarthome/internal/config"
+	"github.com/joakimcarlsson/smarthome/internal/tts"
+)
+
+const (
+	voicePreviewText = "Hej! Så här låter jag. Vad kan jag hjälpa dig med?"
+	voiceCacheTTL    = 24 * time.Hour
+)
+
+func runVoices(ctx context.Context, cfg *config.Config, ttsConfig tts.SessionConfig, cache *tts.Cache, args []string) error {
+	cmd := "list"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	cachePath := filepath.Join(cacheDir, serviceName, "voices.json")
+
+	voices, err := tts.ListVoicesCached(ctx, cfg.ElevenLabsAPIKey, cachePath, voiceCacheTTL)
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "list":
+		printVoices(voices, cfg.ElevenLabsVoiceID)
+		return nil
+	case "preview", "select":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: voices %s <name or id>", cmd)
+		}
+		name := strings.Join(args[1:], " ")
+		voice, ok := tts.FindVoice(voices, name)
+		if !ok {
+			return fmt.Errorf("voice %q not found", name)
+		}
+
+		fmt.Printf("Previewing %s (%s)\n", voice.Name, voice.VoiceID)
+		ttsConfig.VoiceID = voice.VoiceID
+		if err := previewVoice(ctx, ttsConfig, cache, cfg.PlaybackBackend); err != nil {
+			return err
+		}
+
+		if cmd == "preview" {
+			return nil
+		}
+		fmt.Printf("Use %s as the assistant voice? [y/N] ", voice.Name)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			fmt.Println("Keeping", cfg.ElevenLabsVoiceID)
+			return nil
+		}
+		if err := config.SetEnvValue(envFile, "ELEVENLABS_VOICE_ID", voice.VoiceID); err != nil {
+			return err
+		}
+		fmt.Printf("Saved ELEVENLABS_VOICE_ID=%s to %s\n", voice.VoiceID, envFile)
+		return nil
+	default:
+		return fmt.Errorf("unknown voices command %q, expected list, preview or select", cmd)
+	}
+}
+
+func printVoices(voices []tts.Voice, current string) {
+	sort.Slice(voices, func(i, j int) bool {
+		return strings.ToLower(voices[i].Name) < strings.ToLower(voices[j].Name)
+	})
+
+	for _, v := range voices {
+		marker := " "
+		if v.VoiceID == current {
+			marker = "*"
+		}
+
+		var labels []string
+		for k, val := range v.Labels {
+			labels = append(labels, k+"="+val)
+		}
+		sort.Strings(labels)
+
+		preview := "no"
+		if v.PreviewURL != "" {
+			preview = "yes"
+		}
+
+		fmt.Printf("%s %-24s %-22s preview=%-3s %s\n", marker, v.Name, v.VoiceID, preview, strings.Join(labels, " "))
+	}
+}
+
+// previewVoice plays the preview from cache when this voice has been
+// previewed before, without opening a session.
+func previewVoice(ctx context.Context, ttsConfig tts.SessionConfig, cache *tts.Cache, backend string) error {
+	speaker, err := audio.NewPlayback(nil,
+		audio.WithBackend(backend),
+		audio.WithPlaybackSourceRate(ttsConfig.SampleRate()),
+	)
+	if err != nil {
+		return fmt.Errorf("creating audio playback: %w", err)
+	}
+	defer speaker.Close()
+
+	if pcm, ok := cache.Get(ttsConfig, voicePreviewText); ok {
+		if err := speaker.Play(pcm); err != nil {
+			return err
+		}
+		return speaker.Drain(ctx)
+	}
+
+	session, err := tts.NewSession(ctx, ttsConfig)
+	if err != nil {
+		return fmt.Errorf("creating tts session: %w", err)
+	}
+	defer session.Close()
+
+	if err := session.SendText(voicePreviewText); err != nil {
+		return fmt.Errorf("sending preview text: %w", err)
+	}
+	if err := session.Flush(); err != nil {
+		return fmt.Errorf("flushing preview text: %w", err)
+	}
+
+	var pcm []byte
+	for chunk := range session.Audio() {
+		if chunk.Error != nil {
+			return chunk.Error
+		}
+		if chunk.Done {
+			// Only a preview that finished is cached, not one cut short.
+			if err := cache.Put(ttsConfig, voicePreviewText, pcm); err != nil {
+				fmt.Fprintln(os.Stderr, "Not caching the preview:", err)
+			}
+			break
+		}
+		pcm = append(pcm, chunk.Data...)
+		if err := speaker.Play(chunk.Data); err != nil {
+			return err
+		}
+	}
+	return speaker.Drain(ctx)
+}
@@ -0,0 +1,113 @@
+package audio
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultActiveHold     = 750 * time.Millisecond
+	DefaultActiveDebounce = 250 * time.Millisecond
+)
+
+// activity turns the playback queue filling and emptying into OnActive
+// calls. Going inactive waits out hold, and calls are kept debounce apart,
+// so the gaps between TTS chunks don't flap whatever is listening.
+type activity struct {
+	hold     time.Duration
+	debounce time.Duration
+
+	mu       sync.Mutex
+	fns      []func(active bool)
+	want     bool
+	reported bool
+	last     time.Time
+	timer    *time.Timer
+	// gen tells a timer that fired after being replaced to do nothing.
+	gen int
+
+	// callMu keeps the callbacks in order when they are slow.
+	callMu sync.Mutex
+}
+
+func (a *activity) add(fn func(active bool)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fns = append(a.fns, fn)
+}
+
+// update records whether there is audio to play. It never blocks on the
+// callbacks, which run on a timer goroutine.
+func (a *activity) update(active bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if active == a.want {
+		return
+	}
+	a.want = active
+	if len(a.fns) == 0 {
+		return
+	}
+	a.gen++
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	if a.want == a.reported {
+		return
+	}
+
+	var delay time.Duration
+	if !active {
+		delay = a.hold
+	}
+	if wait := a.debounce - time.Since(a.last); wait > delay {
+		delay = wait
+	}
+	gen := a.gen
+	a.timer = time.AfterFunc(delay, func() { a.fire(gen) })
+}
+
+// close reports inactive right away if needed, so nothing is left ducked
+// on shutdown, and stops further calls.
+func (a *activity) close() {
+	a.callMu.Lock()
+	defer a.callMu.Unlock()
+
+	a.mu.Lock()
+	a.gen++
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	was := a.reported
+	a.reported, a.want = false, false
+	fns := a.fns
+	a.fns = nil
+	a.mu.Unlock()
+
+	if was {
+		for _, fn := range fns {
+			fn(false)
+		}
+	}
+}
+
+func (a *activity) fire(gen int) {
+	a.callMu.Lock()
+	defer a.callMu.Unlock()
+
+	a.mu.Lock()
+	if gen != a.gen || a.want == a.reported {
+		a.mu.Unlock()
+		return
+	}
+	a.reported = a.want
+	a.last = time.Now()
+	active := a.reported
+	fns := a.fns
+	a.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(active)
+	}
+}
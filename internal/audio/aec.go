@@ -26,21 +26,28 @@ import (
 )
 
 type EchoCanceller struct {
-	st        *C.SpeexEchoState
-	frameSize int
-	refBuf    []int16
-	mu        sync.Mutex
+	st         *C.SpeexEchoState
+	frameSize  int
+	sampleRate int
+	refBuf     []int16
+	mu         sync.Mutex
 }
 
 func NewEchoCanceller(frameSize, sampleRate int) *EchoCanceller {
 	filterLen := sampleRate * 300 / 1000
 	st := C.aec_init(C.int(frameSize), C.int(filterLen), C.int(sampleRate))
 	return &EchoCanceller{
-		st:        st,
-		frameSize: frameSize,
+		st:         st,
+		frameSize:  frameSize,
+		sampleRate: sampleRate,
 	}
 }
 
+// SampleRate is the rate FeedReference expects, that of the microphone.
+func (e *EchoCanceller) SampleRate() int {
+	return e.sampleRate
+}
+
 func (e *EchoCanceller) FeedReference(samples []int16) {
 	e.mu.Lock()
 	e.refBuf = append(e.refBuf, samples...)
@@ -72,19 +79,3 @@ func (e *EchoCanceller) Close() {
 		e.st = nil
 	}
 }
-
-func Resample24to16(in []int16) []int16 {
-	outLen := len(in) * 2 / 3
-	out := make([]int16, outLen)
-	for i := 0; i < outLen; i++ {
-		srcPos := float64(i) * 3.0 / 2.0
-		idx := int(srcPos)
-		frac := srcPos - float64(idx)
-		if idx+1 < len(in) {
-			out[i] = int16(float64(in[idx])*(1-frac) + float64(in[idx+1])*frac)
-		} else if idx < len(in) {
-			out[i] = in[idx]
-		}
-	}
-	return out
-}
@@ -0,0 +1,83 @@
+package audio
+
+import "math"
+
+const (
+	agcMinGain = 0.5
+	agcMaxGain = 20.0
+	// Frames quieter than this are treated as background and don't move the
+	// gain, otherwise silence would be pumped up to the target level.
+	agcGateRMS = 60.0
+	agcAttack  = 0.3
+	agcRelease = 0.05
+)
+
+type agc struct {
+	target float64
+	gain   float64
+}
+
+func newAGC(targetRMS float64) *agc {
+	return &agc{
+		target: targetRMS,
+		gain:   1,
+	}
+}
+
+func (a *agc) Process(samples []int16) {
+	rms := frameRMS(samples)
+	if rms >= agcGateRMS {
+		desired := math.Min(math.Max(a.target/rms, agcMinGain), agcMaxGain)
+		// Back off quickly when it gets loud, recover slowly when it gets quiet.
+		coef := agcRelease
+		if desired < a.gain {
+			coef = agcAttack
+		}
+		a.gain += (desired - a.gain) * coef
+	}
+
+	gain := a.gain
+	if peak := framePeak(samples); peak > 0 && float64(peak)*gain > math.MaxInt16 {
+		gain = math.MaxInt16 / float64(peak)
+	}
+
+	for i, s := range samples {
+		samples[i] = clampInt16(float64(s) * gain)
+	}
+}
+
+func frameRMS(samples []int16) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		v := float64(s)
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func framePeak(samples []int16) int {
+	peak := 0
+	for _, s := range samples {
+		v := int(s)
+		if v < 0 {
+			v = -v
+		}
+		if v > peak {
+			peak = v
+		}
+	}
+	return peak
+}
+
+func clampInt16(v float64) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns n samples of a tone at freq Hz and the given peak amplitude.
+func sine(freq, amp float64, rate, n int) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = int16(amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return out
+}
+
+func TestAGCConvergesToTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		amp  float64
+	}{
+		{"quiet speech is raised", 300},
+		{"loud speech is lowered", 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const target = 2000.0
+			a := newAGC(target)
+			var rms float64
+			for range 200 {
+				frame := sine(440, tt.amp, 16000, 480)
+				a.Process(frame)
+				rms = frameRMS(frame)
+			}
+			if math.Abs(rms-target)/target > 0.05 {
+				t.Errorf("rms after settling = %.0f, want about %.0f", rms, target)
+			}
+		})
+	}
+}
+
+func TestAGCIgnoresBackground(t *testing.T) {
+	a := newAGC(2000)
+	for range 100 {
+		a.Process(sine(440, agcGateRMS/2, 16000, 480))
+	}
+	if a.gain != 1 {
+		t.Errorf("gain = %v after background only, want 1", a.gain)
+	}
+}
+
+func TestAGCGainLimits(t *testing.T) {
+	a := newAGC(30000)
+	for range 500 {
+		a.Process(sine(440, 100, 16000, 480))
+	}
+	if a.gain > agcMaxGain {
+		t.Errorf("gain = %v, want at most %v", a.gain, agcMaxGain)
+	}
+
+	a = newAGC(10)
+	for range 500 {
+		a.Process(sine(440, 20000, 16000, 480))
+	}
+	if a.gain < agcMinGain {
+		t.Errorf("gain = %v, want at least %v", a.gain, agcMinGain)
+	}
+}
+
+func TestAGCDoesNotClip(t *testing.T) {
+	a := newAGC(2000)
+	a.gain = agcMaxGain
+	frame := sine(440, 20000, 16000, 480)
+	a.Process(frame)
+	if peak := framePeak(frame); peak > math.MaxInt16 {
+		t.Fatalf("peak = %d, want at most %d", peak, math.MaxInt16)
+	}
+	// The frame is scaled down as a whole rather than clipped, so the
+	// waveform keeps its shape.
+	if peak := framePeak(frame); peak < math.MaxInt16-1 {
+		t.Errorf("peak = %d, want the frame scaled to full range", peak)
+	}
+}
@@ -0,0 +1,103 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	BackendPortAudio = "portaudio"
+	BackendMiniaudio = "miniaudio"
+	// BackendNull and BackendFile only play, discarding the audio or
+	// writing a WAV file per response.
+	BackendNull = "null"
+	BackendFile = "file"
+)
+
+// backend is the device layer under Capture and Playback. Everything above
+// it, from VAD to segmenting, only ever sees a Source or an output.
+type backend interface {
+	// acquire and release bracket device use. PortAudio reference counts
+	// them, so capture and playback can each hold one.
+	acquire() error
+	release() error
+	openInput(o options) (Source, SampleFormat, int, error)
+	openOutput(o options, sampleRate, frameSize int) (output, error)
+}
+
+// output plays int16 frames, interleaved when opened with more than one
+// channel, blocking until the device has room.
+type output interface {
+	Write(frame []int16) error
+	// Discard drops audio queued for the device but not yet played.
+	Discard()
+	// Latency is how long audio written now takes until it is heard.
+	Latency() time.Duration
+	// Pause stops the device once what was written has played, and
+	// Resume starts it again before the next Write.
+	Pause() error
+	Resume() error
+	Close() error
+}
+
+// responseEnder is implemented by outputs that care where one response
+// ends, like the file output writing each to its own WAV.
+type responseEnder interface {
+	EndResponse() error
+}
+
+func newBackend(name string) (backend, error) {
+	switch name {
+	case "", BackendPortAudio:
+		return portaudioBackend{}, nil
+	case BackendMiniaudio:
+		return newMiniaudioBackend()
+	case BackendNull:
+		return nullBackend{}, nil
+	case BackendFile:
+		return fileBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown audio backend %q, expected %s, %s, %s or %s", name, BackendPortAudio, BackendMiniaudio, BackendNull, BackendFile)
+	}
+}
+
+// matchDevice picks the device called name from names, preferring an exact
+// match over a case-insensitive substring so "USB" finds a long ALSA name
+// but "pulse" doesn't grab "pulse.monitor". kind names the direction in the
+// error.
+func matchDevice(kind, name string, names []string) (int, error) {
+	for i, n := range names {
+		if n == name {
+			return i, nil
+		}
+	}
+	lower := strings.ToLower(name)
+	for i, n := range names {
+		if strings.Contains(strings.ToLower(n), lower) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no %s device matching %q, have: %s", kind, name, deviceList(names))
+}
+
+// selectDevice picks from names by index when it is set, and by name
+// otherwise.
+func selectDevice(kind, name string, index int, names []string) (int, error) {
+	if index < 0 {
+		return matchDevice(kind, name, names)
+	}
+	if index >= len(names) {
+		return -1, fmt.Errorf("no %s device %d, have: %s", kind, index, deviceList(names))
+	}
+	return index, nil
+}
+
+// deviceList numbers the devices as WithOutputDeviceIndex counts them.
+func deviceList(names []string) string {
+	list := make([]string, len(names))
+	for i, n := range names {
+		list[i] = fmt.Sprintf("%d: %s", i, n)
+	}
+	return strings.Join(list, ", ")
+}
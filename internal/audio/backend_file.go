@@ -0,0 +1,93 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// nullBackend and fileBackend are playback-only, for machines without a
+// sound card. Neither paces writes to real time, so a response is "played"
+// as fast as it arrives.
+type (
+	nullBackend struct{}
+	fileBackend struct{}
+)
+
+func (nullBackend) acquire() error { return nil }
+func (nullBackend) release() error { return nil }
+
+func (nullBackend) openInput(options) (Source, SampleFormat, int, error) {
+	return nil, 0, 0, errors.New("the null audio backend only supports playback")
+}
+
+func (nullBackend) openOutput(options, int, int) (output, error) {
+	return nullOutput{}, nil
+}
+
+type nullOutput struct{}
+
+func (nullOutput) Write([]int16) error    { return nil }
+func (nullOutput) Discard()               {}
+func (nullOutput) Latency() time.Duration { return 0 }
+func (nullOutput) Pause() error           { return nil }
+func (nullOutput) Resume() error          { return nil }
+func (nullOutput) Close() error           { return nil }
+
+func (fileBackend) acquire() error { return nil }
+func (fileBackend) release() error { return nil }
+
+func (fileBackend) openInput(options) (Source, SampleFormat, int, error) {
+	return nil, 0, 0, errors.New("the file audio backend only supports playback")
+}
+
+func (fileBackend) openOutput(o options, sampleRate, _ int) (output, error) {
+	if o.playbackDir == "" {
+		return nil, errors.New("the file audio backend needs a directory, see WithPlaybackDir")
+	}
+	if err := os.MkdirAll(o.playbackDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating playback directory: %w", err)
+	}
+	return &fileOutput{dir: o.playbackDir, sampleRate: sampleRate, channels: o.playbackChannels}, nil
+}
+
+// fileOutput collects what is played and writes it out as one WAV per
+// response when Playback ends the response.
+type fileOutput struct {
+	dir        string
+	sampleRate int
+	channels   int
+	pcm        []byte
+}
+
+func (f *fileOutput) Write(frame []int16) error {
+	f.pcm = AppendInt16LE(f.pcm, frame)
+	return nil
+}
+
+// Discard keeps what was written: it counts as played, and Stop ends the
+// response right after.
+func (f *fileOutput) Discard() {}
+
+func (f *fileOutput) Latency() time.Duration { return 0 }
+func (f *fileOutput) Pause() error           { return nil }
+func (f *fileOutput) Resume() error          { return nil }
+
+func (f *fileOutput) EndResponse() error {
+	if len(f.pcm) == 0 {
+		return nil
+	}
+	name := filepath.Join(f.dir, time.Now().Format("response-20060102-150405.000")+".wav")
+	data := EncodeWAV(f.pcm, f.sampleRate, f.channels, 16)
+	f.pcm = f.pcm[:0]
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		return fmt.Errorf("writing response: %w", err)
+	}
+	return nil
+}
+
+func (f *fileOutput) Close() error {
+	return f.EndResponse()
+}
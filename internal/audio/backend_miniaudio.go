@@ -0,0 +1,286 @@
+//go:build miniaudio
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+// miniaudioQueueFrames bounds how far capture may fall behind the device, and
+// playback run ahead of it, before audio is dropped or Write blocks.
+const miniaudioQueueFrames = 8
+
+// miniaudioBackend gives each stream its own context. miniaudio needs no
+// process-wide setup, and it converts format, rate and channel count itself.
+type miniaudioBackend struct{}
+
+func newMiniaudioBackend() (backend, error) {
+	return miniaudioBackend{}, nil
+}
+
+func (miniaudioBackend) acquire() error { return nil }
+func (miniaudioBackend) release() error { return nil }
+
+func (miniaudioBackend) openInput(o options) (Source, SampleFormat, int, error) {
+	frameSize := o.sampleRate * o.frameDurationMs / 1000
+	in := &miniaudioInput{
+		channels: o.inputChannels,
+		channel:  o.channelSelect,
+		samples:  make([]int16, frameSize*o.inputChannels),
+		queue:    make(chan []byte, miniaudioQueueFrames),
+		closed:   make(chan struct{}),
+	}
+
+	cfg := malgo.DefaultDeviceConfig(malgo.Capture)
+	cfg.Capture.Format = malgo.FormatS16
+	cfg.Capture.Channels = uint32(o.inputChannels)
+	cfg.SampleRate = uint32(o.sampleRate)
+	cfg.PeriodSizeInFrames = uint32(frameSize)
+
+	dev, err := openMiniaudioDevice(cfg, malgo.DeviceCallbacks{Data: in.onData}, o.inputDevice, -1)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("opening capture stream: %w", err)
+	}
+	in.dev = dev
+	return in, FormatInt16, o.sampleRate, nil
+}
+
+func (miniaudioBackend) openOutput(o options, sampleRate, frameSize int) (output, error) {
+	channels := o.playbackChannels
+	out := &miniaudioOutput{
+		limit:      frameSize * channels * 2 * miniaudioQueueFrames,
+		sampleRate: sampleRate,
+		frameSize:  frameSize,
+		channels:   channels,
+	}
+	out.cond = sync.NewCond(&out.mu)
+
+	cfg := malgo.DefaultDeviceConfig(malgo.Playback)
+	cfg.Playback.Format = malgo.FormatS16
+	cfg.Playback.Channels = uint32(channels)
+	cfg.SampleRate = uint32(sampleRate)
+
+	dev, err := openMiniaudioDevice(cfg, malgo.DeviceCallbacks{Data: out.onData}, o.outputDevice, o.outputDeviceIndex)
+	if err != nil {
+		return nil, fmt.Errorf("opening playback stream: %w", err)
+	}
+	out.dev = dev
+	return out, nil
+}
+
+type miniaudioDevice struct {
+	ctx *malgo.AllocatedContext
+	dev *malgo.Device
+}
+
+// openMiniaudioDevice opens the device of cfg's type picked by index or
+// name, or the default device when neither is set. Monitor sources are
+// listed as ordinary capture devices on the PulseAudio backend. miniaudio
+// converts to any rate the device runs at, so no resampling is needed here.
+func openMiniaudioDevice(cfg malgo.DeviceConfig, callbacks malgo.DeviceCallbacks, name string, index int) (*miniaudioDevice, error) {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(msg string) {
+		slog.Debug("miniaudio", "message", msg)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("initializing miniaudio: %w", err)
+	}
+
+	if name != "" || index >= 0 {
+		kind := "input"
+		if cfg.DeviceType == malgo.Playback {
+			kind = "output"
+		}
+		devices, err := ctx.Devices(cfg.DeviceType)
+		if err != nil {
+			ctx.Uninit()
+			ctx.Free()
+			return nil, fmt.Errorf("listing %s devices: %w", kind, err)
+		}
+		names := make([]string, len(devices))
+		for i := range devices {
+			names[i] = devices[i].Name()
+		}
+		i, err := selectDevice(kind, name, index, names)
+		if err != nil {
+			ctx.Uninit()
+			ctx.Free()
+			return nil, err
+		}
+		slog.Info("using "+kind+" device", "name", names[i])
+		if cfg.DeviceType == malgo.Playback {
+			cfg.Playback.DeviceID = devices[i].ID.Pointer()
+		} else {
+			cfg.Capture.DeviceID = devices[i].ID.Pointer()
+		}
+	}
+
+	dev, err := malgo.InitDevice(ctx.Context, cfg, callbacks)
+	if err != nil {
+		ctx.Uninit()
+		ctx.Free()
+		return nil, err
+	}
+	if err := dev.Start(); err != nil {
+		dev.Uninit()
+		ctx.Uninit()
+		ctx.Free()
+		return nil, fmt.Errorf("starting device: %w", err)
+	}
+	return &miniaudioDevice{ctx: ctx, dev: dev}, nil
+}
+
+func (d *miniaudioDevice) close() {
+	d.dev.Uninit()
+	d.ctx.Uninit()
+	d.ctx.Free()
+}
+
+// miniaudioInput turns miniaudio's push callbacks into the blocking reads
+// Source promises. The callback must never block, so chunks that arrive
+// while the queue is full are dropped and reported on the next read.
+type miniaudioInput struct {
+	dev      *miniaudioDevice
+	channels int
+	channel  int
+	samples  []int16
+	pending  []byte
+	queue    chan []byte
+	closed   chan struct{}
+	once     sync.Once
+
+	mu      sync.Mutex
+	overrun bool
+}
+
+func (m *miniaudioInput) onData(_, input []byte, _ uint32) {
+	select {
+	case m.queue <- append([]byte(nil), input...):
+	default:
+		m.mu.Lock()
+		m.overrun = true
+		m.mu.Unlock()
+	}
+}
+
+func (m *miniaudioInput) ReadFrame(frame []int16) error {
+	m.mu.Lock()
+	overrun := m.overrun
+	m.overrun = false
+	m.mu.Unlock()
+	if overrun {
+		m.pending = m.pending[:0]
+		return errors.New("input overflowed")
+	}
+
+	need := len(m.samples) * 2
+	for len(m.pending) < need {
+		select {
+		case chunk := <-m.queue:
+			m.pending = append(m.pending, chunk...)
+		case <-m.closed:
+			return errors.New("capture stream closed")
+		}
+	}
+	DecodeInt16LE(m.samples, m.pending)
+	m.pending = m.pending[:copy(m.pending, m.pending[need:])]
+	downmix(frame, m.samples, m.channels, m.channel)
+	return nil
+}
+
+func (m *miniaudioInput) Close() error {
+	m.once.Do(func() {
+		close(m.closed)
+		m.dev.close()
+	})
+	return nil
+}
+
+// miniaudioOutput buffers written samples for the device callback to pull,
+// blocking writers once limit bytes are queued so playback keeps pace with
+// the device like a blocking PortAudio write does.
+type miniaudioOutput struct {
+	dev        *miniaudioDevice
+	limit      int
+	sampleRate int
+	frameSize  int
+	channels   int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []byte
+	closed  bool
+}
+
+func (m *miniaudioOutput) onData(output, _ []byte, _ uint32) {
+	m.mu.Lock()
+	n := copy(output, m.pending)
+	m.pending = m.pending[:copy(m.pending, m.pending[n:])]
+	m.mu.Unlock()
+	clear(output[n:])
+	m.cond.Broadcast()
+}
+
+func (m *miniaudioOutput) Write(frame []int16) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(m.pending) >= m.limit && !m.closed {
+		m.cond.Wait()
+	}
+	if m.closed {
+		return errors.New("playback stream closed")
+	}
+	m.pending = AppendInt16LE(m.pending, frame)
+	return nil
+}
+
+func (m *miniaudioOutput) Discard() {
+	m.mu.Lock()
+	m.pending = m.pending[:0]
+	m.mu.Unlock()
+	m.cond.Broadcast()
+}
+
+// Latency covers the samples still queued for the callback plus roughly
+// one period in the device's own buffer.
+func (m *miniaudioOutput) Latency() time.Duration {
+	m.mu.Lock()
+	queued := len(m.pending) / 2 / m.channels
+	m.mu.Unlock()
+	return time.Duration(queued+m.frameSize) * time.Second / time.Duration(m.sampleRate)
+}
+
+// Pause waits for the callback to take what is queued before stopping
+// the device, which would otherwise cut it off mid-buffer.
+func (m *miniaudioOutput) Pause() error {
+	m.mu.Lock()
+	for len(m.pending) > 0 && !m.closed {
+		m.cond.Wait()
+	}
+	m.mu.Unlock()
+	if err := m.dev.dev.Stop(); err != nil {
+		return fmt.Errorf("stopping playback device: %w", err)
+	}
+	return nil
+}
+
+func (m *miniaudioOutput) Resume() error {
+	if err := m.dev.dev.Start(); err != nil {
+		return fmt.Errorf("starting playback device: %w", err)
+	}
+	return nil
+}
+
+func (m *miniaudioOutput) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.cond.Broadcast()
+	m.dev.close()
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !miniaudio
+
+package audio
+
+import "errors"
+
+func newMiniaudioBackend() (backend, error) {
+	return nil, errors.New("miniaudio backend requires building with -tags miniaudio")
+}
@@ -0,0 +1,158 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+type portaudioBackend struct{}
+
+func (portaudioBackend) acquire() error {
+	if err := portaudio.Initialize(); err != nil {
+		return fmt.Errorf("initializing portaudio: %w", err)
+	}
+	return nil
+}
+
+func (portaudioBackend) release() error {
+	return portaudio.Terminate()
+}
+
+func (portaudioBackend) openInput(o options) (Source, SampleFormat, int, error) {
+	return openInput(o.inputDevice, o.sampleRate, o.frameDurationMs, o.inputChannels, o.channelSelect, o.sampleFormats)
+}
+
+// openOutput opens the selected output device at sampleRate, or at a rate
+// it supports with resampling in between, so Playback always writes
+// sampleRate frames.
+func (portaudioBackend) openOutput(o options, sampleRate, frameSize int) (output, error) {
+	dev, err := findPortaudioOutput(o.outputDevice, o.outputDeviceIndex)
+	if err != nil {
+		return nil, err
+	}
+	channels := o.playbackChannels
+	if dev.MaxOutputChannels < channels {
+		return nil, fmt.Errorf("output device %q has %d channels, need %d", dev.Name, dev.MaxOutputChannels, channels)
+	}
+
+	out, err := openPortaudioOutput(dev, sampleRate, frameSize, channels)
+	if err == nil {
+		return out, nil
+	}
+	errs := []error{fmt.Errorf("%d Hz: %w", sampleRate, err)}
+
+	for _, rate := range fallbackRates(dev, sampleRate) {
+		nativeFrame := rate * frameSize / sampleRate
+		out, err := openPortaudioOutput(dev, rate, nativeFrame, channels)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%d Hz: %w", rate, err))
+			continue
+		}
+		slog.Info("resampling playback", "device", dev.Name, "from", sampleRate, "to", rate)
+		return newResampledOutput(out, sampleRate, rate, nativeFrame, channels), nil
+	}
+	return nil, fmt.Errorf("opening playback stream: %w", errors.Join(errs...))
+}
+
+// openPortaudioOutput opens an interleaved stream of channels, whose frames
+// hold frameSize samples of each.
+func openPortaudioOutput(dev *portaudio.DeviceInfo, sampleRate, frameSize, channels int) (output, error) {
+	buf := make([]int16, frameSize*channels)
+	params := portaudio.HighLatencyParameters(nil, dev)
+	params.Output.Channels = channels
+	params.SampleRate = float64(sampleRate)
+	params.FramesPerBuffer = frameSize
+	stream, err := portaudio.OpenStream(params, &buf)
+	if err != nil {
+		return nil, fmt.Errorf("opening stream: %w", err)
+	}
+	if err := stream.Start(); err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("starting stream: %w", err)
+	}
+	return &portaudioOutput{stream: stream, buf: buf}, nil
+}
+
+// findPortaudioOutput resolves the output device by index, by name, or to
+// the default when neither is set.
+func findPortaudioOutput(name string, index int) (*portaudio.DeviceInfo, error) {
+	if name == "" && index < 0 {
+		dev, err := portaudio.DefaultOutputDevice()
+		if errors.Is(err, portaudio.NoDefaultOutputDevice) {
+			return nil, fmt.Errorf("no sound card to play on; use the %s or %s backend to run without one: %w", BackendNull, BackendFile, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("finding default output device: %w", err)
+		}
+		return dev, nil
+	}
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("listing output devices: %w", err)
+	}
+	var outputs []*portaudio.DeviceInfo
+	var names []string
+	for _, d := range devices {
+		if d.MaxOutputChannels > 0 {
+			outputs = append(outputs, d)
+			names = append(names, d.Name)
+		}
+	}
+
+	i, err := selectDevice("output", name, index, names)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("using output device", "name", outputs[i].Name)
+	return outputs[i], nil
+}
+
+type portaudioOutput struct {
+	stream *portaudio.Stream
+	buf    []int16
+}
+
+func (p *portaudioOutput) Write(frame []int16) error {
+	copy(p.buf, frame)
+	err := p.stream.Write()
+	if errors.Is(err, portaudio.OutputUnderflowed) {
+		return ErrUnderflow
+	}
+	if err != nil {
+		return fmt.Errorf("writing playback stream: %w", err)
+	}
+	return nil
+}
+
+// Discard is a no-op: the blocking stream holds at most one frame beyond
+// the one being written.
+func (p *portaudioOutput) Discard() {}
+
+func (p *portaudioOutput) Latency() time.Duration {
+	return p.stream.Info().OutputLatency
+}
+
+// Pause relies on Pa_StopStream playing out what is buffered.
+func (p *portaudioOutput) Pause() error {
+	if err := p.stream.Stop(); err != nil {
+		return fmt.Errorf("stopping playback stream: %w", err)
+	}
+	return nil
+}
+
+func (p *portaudioOutput) Resume() error {
+	if err := p.stream.Start(); err != nil {
+		return fmt.Errorf("starting playback stream: %w", err)
+	}
+	return nil
+}
+
+func (p *portaudioOutput) Close() error {
+	p.stream.Stop()
+	return p.stream.Close()
+}
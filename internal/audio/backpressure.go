@@ -0,0 +1,73 @@
+package audio
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// BackpressureBlock waits for the consumer, stalling frame reads.
+	BackpressureBlock = "block"
+	// BackpressureDropOldest discards the oldest queued utterance to make
+	// room, so the consumer catches up on what was said most recently.
+	BackpressureDropOldest = "drop_oldest"
+	// BackpressureDropNewest discards the utterance that didn't fit.
+	BackpressureDropNewest = "drop_newest"
+)
+
+func validBackpressure(policy string) bool {
+	switch policy {
+	case BackpressureBlock, BackpressureDropOldest, BackpressureDropNewest:
+		return true
+	}
+	return false
+}
+
+// emit hands u to the consumer according to the backpressure policy. Only the
+// block policy ever waits, and it returns false if ctx ends first.
+//
+// For drop_oldest the channel buffer is the queue: captureLoop is its only
+// sender, so after taking one utterance back out the send can't block.
+func (c *Capture) emit(ctx context.Context, ch chan Utterance, u Utterance) bool {
+	switch c.opts.backpressure {
+	case BackpressureDropNewest:
+		select {
+		case ch <- u:
+			c.stats.emitted.Add(1)
+		default:
+			c.discard("backpressure", "consumer is behind, dropping newest utterance")
+		}
+		return true
+	case BackpressureDropOldest:
+		for {
+			select {
+			case ch <- u:
+				c.stats.emitted.Add(1)
+				return true
+			default:
+			}
+			select {
+			case <-ch:
+				c.discard("backpressure", "consumer is behind, dropping oldest utterance")
+			default:
+			}
+		}
+	}
+
+	select {
+	case ch <- u:
+		c.stats.emitted.Add(1)
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *Capture) discard(reason, msg string, args ...any) {
+	dropped := c.stats.dropped.Add(1)
+	c.droppedCounter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("reason", reason)))
+	slog.Debug(msg, append(args, "dropped_total", dropped)...)
+}
@@ -0,0 +1,158 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+)
+
+// calibrationMaxTriggerRate is the share of ambient frames a VAD mode may
+// flag as speech and still be recommended.
+const calibrationMaxTriggerRate = 0.01
+
+// Calibration is what Calibrate measured while the room was quiet.
+type Calibration struct {
+	Frames int
+	// NoiseFloorRMS is the median frame RMS, P99RMS the loudest ambient
+	// frames apart from the odd click.
+	NoiseFloorRMS float64
+	P99RMS        float64
+	// TriggerRates holds, per VAD mode, the share of frames the configured
+	// backend mistook for speech.
+	TriggerRates []float64
+
+	VADMode         int
+	EnergyThreshold float64
+}
+
+// Calibrate listens for d without segmenting anything and suggests VAD
+// settings for the room's background noise, so nobody should talk while it
+// runs. It opens the input the same way StartUtterances does and releases
+// it again, so it can run before Start.
+func (c *Capture) Calibrate(ctx context.Context, d time.Duration) (Calibration, error) {
+	frames, err := c.listen(ctx, d)
+	if err != nil {
+		return Calibration{}, err
+	}
+	if len(frames) == 0 {
+		return Calibration{}, errors.New("no audio captured during calibration")
+	}
+	frameSize := len(frames[0])
+
+	rms := make([]float64, len(frames))
+	for i, f := range frames {
+		rms[i] = frameRMS(f)
+	}
+	slices.Sort(rms)
+
+	cal := Calibration{
+		Frames:        len(frames),
+		NoiseFloorRMS: rms[len(rms)/2],
+		P99RMS:        rms[len(rms)*99/100],
+		VADMode:       len(energyRatios) - 1,
+	}
+	cal.EnergyThreshold = math.Max(math.Ceil(cal.P99RMS*1.5/10)*10, DefaultEnergyThreshold)
+
+	for mode := range len(energyRatios) {
+		rate, err := c.triggerRate(mode, frameSize, frames)
+		if err != nil {
+			return Calibration{}, err
+		}
+		cal.TriggerRates = append(cal.TriggerRates, rate)
+	}
+	for mode, rate := range cal.TriggerRates {
+		if rate <= calibrationMaxTriggerRate {
+			cal.VADMode = mode
+			break
+		}
+	}
+	return cal, nil
+}
+
+// MeasureRMS listens for d like Calibrate and returns the RMS over all of
+// it, such as of a test tone played meanwhile.
+func (c *Capture) MeasureRMS(ctx context.Context, d time.Duration) (float64, error) {
+	frames, err := c.listen(ctx, d)
+	if err != nil {
+		return 0, err
+	}
+	if len(frames) == 0 {
+		return 0, errors.New("no audio captured")
+	}
+	return frameRMS(slices.Concat(frames...)), nil
+}
+
+// listen opens the input, reads frames for d, high-pass filtered but
+// otherwise untouched, and releases the input again.
+func (c *Capture) listen(ctx context.Context, d time.Duration) ([][]int16, error) {
+	if err := c.openSource(); err != nil {
+		return nil, err
+	}
+	defer c.releaseSource()
+
+	frameSize := c.opts.sampleRate * c.opts.frameDurationMs / 1000
+	var frames [][]int16
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		buf := make([]int16, frameSize)
+		if err := c.input.ReadFrame(buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("reading audio: %w", err)
+		}
+		if c.hp != nil {
+			c.hp.Process(buf)
+		}
+		frames = append(frames, buf)
+	}
+	return frames, nil
+}
+
+func (c *Capture) triggerRate(mode, frameSize int, frames [][]int16) (float64, error) {
+	o := c.opts
+	o.vadMode = mode
+	vad, err := newVADEngine(o, frameSize)
+	if err != nil {
+		return 0, err
+	}
+	if d, ok := vad.(interface{ destroy() }); ok {
+		defer d.destroy()
+	}
+
+	active := 0
+	for _, f := range frames {
+		ok, err := vad.Process(f)
+		if err != nil {
+			return 0, fmt.Errorf("processing vad: %w", err)
+		}
+		if ok {
+			active++
+		}
+	}
+	return float64(active) / float64(len(frames)), nil
+}
+
+// releaseSource undoes openSource for a caller that isn't going to start
+// capturing. Custom sources stay open since the caller still owns them.
+func (c *Capture) releaseSource() {
+	if c.opts.source != nil {
+		return
+	}
+	if c.input != nil {
+		c.input.Close()
+		c.input = nil
+	}
+	c.acquired = false
+	if err := c.backend.release(); err != nil {
+		slog.Warn("releasing audio backend", "error", err)
+	}
+}
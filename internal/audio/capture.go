@@ -3,16 +3,20 @@ package audio
 import (
 	"bufio"
 	"context"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gordonklaus/portaudio"
-	webrtcvad "github.com/maxhawkins/go-webrtcvad"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
 )
 
 const wakeWordScript = `import sys
@@ -24,7 +28,7 @@ try:
     porcupine = pvporcupine.create(
         access_key=sys.argv[1],
         keyword_paths=[sys.argv[2]],
-        sensitivities=[0.9]
+        sensitivities=[float(sys.argv[3])]
     )
 except Exception:
     traceback.print_exc()
@@ -63,12 +67,47 @@ type wakeWordProc struct {
 	scriptPath string
 }
 
+const (
+	// closeTimeout bounds how long Close waits for the capture goroutine.
+	// A source stuck in a read gets leaked rather than closed under it.
+	closeTimeout = 2 * time.Second
+
+	// playbackGateHold keeps the playback gate closed a little past the last
+	// frame handed to the speaker, which the device and room still play out.
+	playbackGateHold = 300 * time.Millisecond
+
+	readErrorThreshold     = 10
+	recoveryInitialBackoff = 250 * time.Millisecond
+	recoveryMaxBackoff     = 10 * time.Second
+)
+
 type Capture struct {
 	opts       options
-	vad        *webrtcvad.VAD
-	stream     *portaudio.Stream
+	backend    backend
+	vad        vadEngine
+	input      Source
+	format     SampleFormat
 	aec        *EchoCanceller
+	hp         *highPass
+	ns         *noiseSuppressor
+	agc        *agc
+	kw         *keywordSpotter
 	wakeWordCh chan struct{}
+	muted      atomic.Bool
+	paused     atomic.Bool
+
+	errMu sync.Mutex
+	err   error
+
+	acquired  bool
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	stats          captureStats
+	metrics        metric.Registration
+	droppedCounter metric.Int64Counter
 }
 
 func New(aec *EchoCanceller, opts ...Option) (*Capture, error) {
@@ -76,72 +115,277 @@ func New(aec *EchoCanceller, opts ...Option) (*Capture, error) {
 	for _, opt := range opts {
 		opt(&o)
 	}
-
-	vad, err := webrtcvad.New()
-	if err != nil {
-		return nil, fmt.Errorf("creating vad: %w", err)
+	if err := o.resolve(); err != nil {
+		return nil, err
+	}
+	if !validBackpressure(o.backpressure) {
+		return nil, fmt.Errorf("unknown backpressure policy %q, expected %s, %s or %s", o.backpressure, BackpressureBlock, BackpressureDropOldest, BackpressureDropNewest)
+	}
+	if o.channelBuffer < 0 || (o.channelBuffer == 0 && o.backpressure != BackpressureBlock) {
+		return nil, fmt.Errorf("invalid channel buffer %d for backpressure policy %s", o.channelBuffer, o.backpressure)
+	}
+	if o.inputChannels < 1 {
+		return nil, fmt.Errorf("invalid input channel count %d", o.inputChannels)
+	}
+	if o.channelSelect != MixChannels && (o.channelSelect < 0 || o.channelSelect >= o.inputChannels) {
+		return nil, fmt.Errorf("invalid channel %d for a %d channel input", o.channelSelect, o.inputChannels)
 	}
 
-	if err := vad.SetMode(o.vadMode); err != nil {
-		return nil, fmt.Errorf("setting vad mode: %w", err)
+	b, err := newBackend(o.backend)
+	if err != nil {
+		return nil, err
 	}
 
 	frameSize := o.sampleRate * o.frameDurationMs / 1000
 
-	if !vad.ValidRateAndFrameLength(o.sampleRate, frameSize) {
-		return nil, fmt.Errorf("invalid sample rate %d or frame size %d for vad", o.sampleRate, frameSize)
+	vad, err := newVADEngine(o, frameSize)
+	if err != nil {
+		return nil, err
 	}
 
 	if o.wakeWordAccessKey != "" && o.wakeWordModelPath != "" {
-		slog.Info("wake word enabled", "model", o.wakeWordModelPath)
+		if o.wakeWordSensitivity < 0 || o.wakeWordSensitivity > 1 {
+			return nil, fmt.Errorf("invalid wake word sensitivity %.2f, must be between 0 and 1", o.wakeWordSensitivity)
+		}
+		slog.Info("wake word enabled",
+			"model", o.wakeWordModelPath,
+			"sensitivity", o.wakeWordSensitivity,
+			"window", o.wakeWordWindow,
+		)
 	}
 
-	return &Capture{
-		opts: o,
-		vad:  vad,
-		aec:  aec,
-	}, nil
+	droppedCounter, err := otel.Meter("github.com/joakimcarlsson/smarthome/internal/audio").Int64Counter(
+		"audio.utterances.dropped",
+		metric.WithDescription("Utterances discarded before reaching the consumer, by reason"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating dropped utterance counter: %w", err)
+	}
+
+	c := &Capture{
+		opts:           o,
+		backend:        b,
+		vad:            vad,
+		aec:            aec,
+		droppedCounter: droppedCounter,
+	}
+	if err := c.registerMetrics(); err != nil {
+		return nil, err
+	}
+	if o.highPassHz > 0 {
+		if o.highPassHz >= float64(o.sampleRate)/2 {
+			return nil, fmt.Errorf("invalid high-pass cutoff %.0f Hz at %d Hz sample rate", o.highPassHz, o.sampleRate)
+		}
+		c.hp = newHighPass(o.highPassHz, o.sampleRate)
+		slog.Info("high-pass filter enabled", "cutoff_hz", o.highPassHz)
+	}
+	if o.noiseSuppression {
+		c.ns = newNoiseSuppressor(frameSize)
+		slog.Info("noise suppression enabled")
+	}
+	if o.agcTargetRMS > 0 {
+		c.agc = newAGC(o.agcTargetRMS)
+		slog.Info("agc enabled", "target_rms", o.agcTargetRMS)
+	}
+	if len(o.keywords) > 0 {
+		if c.kw, err = newKeywordSpotter(o, frameSize); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
 }
 
+// Start is StartUtterances for callers that only need the PCM.
 func (c *Capture) Start(ctx context.Context) (<-chan []byte, error) {
-	if err := portaudio.Initialize(); err != nil {
-		return nil, fmt.Errorf("initializing portaudio: %w", err)
+	utterances, err := c.StartUtterances(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return PCMOnly(ctx, utterances), nil
+}
 
+func (c *Capture) StartUtterances(ctx context.Context) (<-chan Utterance, error) {
 	frameSize := c.opts.sampleRate * c.opts.frameDurationMs / 1000
 	buf := make([]int16, frameSize)
 
-	stream, err := portaudio.OpenDefaultStream(1, 0, float64(c.opts.sampleRate), frameSize, buf)
-	if err != nil {
-		portaudio.Terminate()
-		return nil, fmt.Errorf("opening stream: %w", err)
+	if err := c.openSource(); err != nil {
+		return nil, err
 	}
-	c.stream = stream
 
-	if err := stream.Start(); err != nil {
-		stream.Close()
-		portaudio.Terminate()
-		return nil, fmt.Errorf("starting stream: %w", err)
-	}
-
-	ch := make(chan []byte, 4)
+	ch := make(chan Utterance, c.opts.channelBuffer)
 	if c.opts.wakeWordAccessKey != "" && c.opts.wakeWordModelPath != "" {
 		c.wakeWordCh = make(chan struct{}, 1)
 	}
-	go c.captureLoop(ctx, buf, ch)
+	c.run(ctx, buf, ch, nil)
 	return ch, nil
 }
 
+// run starts captureLoop in a goroutine Close can stop and wait for.
+func (c *Capture) run(ctx context.Context, buf []int16, ch chan Utterance, stream *chunkStream) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	c.stats.reset()
+	go func() {
+		defer close(c.done)
+		c.captureLoop(ctx, buf, ch, stream)
+	}()
+}
+
+// openSource points c.input at the custom source, or acquires the backend
+// and opens the input device.
+func (c *Capture) openSource() error {
+	if c.opts.source != nil {
+		c.input = c.opts.source
+		slog.Info("capture reading from custom source", "sample_rate", c.opts.sampleRate)
+		return nil
+	}
+
+	if err := c.backend.acquire(); err != nil {
+		return err
+	}
+
+	input, format, deviceRate, err := c.backend.openInput(c.opts)
+	if err != nil {
+		c.backend.release()
+		return err
+	}
+	c.acquired = true
+	c.input = input
+	c.format = format
+	slog.Info("capture stream opened",
+		"backend", c.opts.backend,
+		"format", format,
+		"device_rate", deviceRate,
+		"sample_rate", c.opts.sampleRate,
+		"channels", c.opts.inputChannels,
+		"resampling", deviceRate != c.opts.sampleRate,
+	)
+	return nil
+}
+
+func (c *Capture) SampleFormat() SampleFormat {
+	return c.format
+}
+
 func (c *Capture) WakeWordEvents() <-chan struct{} {
 	return c.wakeWordCh
 }
 
-func (c *Capture) Close() error {
-	if c.stream != nil {
-		c.stream.Stop()
-		c.stream.Close()
+func (c *Capture) Mute() {
+	c.muted.Store(true)
+}
+
+func (c *Capture) Unmute() {
+	c.muted.Store(false)
+}
+
+func (c *Capture) Muted() bool {
+	return c.muted.Load()
+}
+
+// Pause stops listening, including for the wake word, without closing the
+// stream. Anything captured before Pause is discarded.
+func (c *Capture) Pause() {
+	c.paused.Store(true)
+}
+
+func (c *Capture) Resume() {
+	c.paused.Store(false)
+}
+
+func (c *Capture) Paused() bool {
+	return c.paused.Load()
+}
+
+// Err reports why capture stopped once the utterance channel is closed, or
+// nil if it stopped because its context was cancelled.
+func (c *Capture) Err() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.err
+}
+
+// tooShort reports whether an utterance has too little voiced audio to be
+// worth transcribing, and counts it if so.
+func (c *Capture) tooShort(u *Utterance) bool {
+	if u.SpeechDuration >= time.Duration(c.opts.minUtteranceMs)*time.Millisecond {
+		return false
 	}
-	return portaudio.Terminate()
+	c.discard("too_short", "dropping short utterance",
+		"speech", u.SpeechDuration,
+		"min", time.Duration(c.opts.minUtteranceMs)*time.Millisecond,
+	)
+	return true
+}
+
+func (c *Capture) fail(err error) {
+	c.errMu.Lock()
+	c.err = err
+	c.errMu.Unlock()
+}
+
+// reopenInput replaces a failing input stream, backing off between
+// attempts. The backend is shared with playback, so only the stream is
+// reopened rather than reinitialising the library.
+func (c *Capture) reopenInput(ctx context.Context) error {
+	down := time.Now()
+	c.input.Close()
+	c.input = nil
+
+	backoff := recoveryInitialBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		input, format, deviceRate, err := c.backend.openInput(c.opts)
+		if err == nil {
+			c.input = input
+			c.format = format
+			slog.Info("capture stream recovered",
+				"down_for", time.Since(down),
+				"attempts", attempt,
+				"format", format,
+				"device_rate", deviceRate,
+			)
+			return nil
+		}
+
+		if time.Since(down) >= c.opts.recoveryTimeout {
+			return fmt.Errorf("capture stream down for %s: %w", time.Since(down).Round(time.Second), err)
+		}
+		slog.Warn("reopening capture stream", "attempt", attempt, "retry_in", backoff, "error", err)
+		backoff = min(backoff*2, recoveryMaxBackoff)
+	}
+}
+
+// Close stops the capture goroutine, waits for it to exit and only then
+// closes the input, so the device is never torn down mid-read. It is safe to
+// call more than once and alongside cancelling the context.
+func (c *Capture) Close() error {
+	c.closeOnce.Do(func() {
+		if c.cancel != nil {
+			c.cancel()
+			select {
+			case <-c.done:
+			case <-time.After(closeTimeout):
+				c.closeErr = fmt.Errorf("capture goroutine still running after %s, leaving input open", closeTimeout)
+				return
+			}
+		}
+		if c.input != nil {
+			c.input.Close()
+			c.input = nil
+		}
+		if c.acquired {
+			c.acquired = false
+			c.closeErr = c.backend.release()
+		}
+		c.metrics.Unregister()
+	})
+	return c.closeErr
 }
 
 func (c *Capture) startWakeWordProcess() (*wakeWordProc, error) {
@@ -155,7 +399,11 @@ func (c *Capture) startWakeWordProcess() (*wakeWordProc, error) {
 	}
 	scriptFile.Close()
 
-	cmd := exec.Command("python3", scriptFile.Name(), c.opts.wakeWordAccessKey, c.opts.wakeWordModelPath)
+	cmd := exec.Command("python3", scriptFile.Name(),
+		c.opts.wakeWordAccessKey,
+		c.opts.wakeWordModelPath,
+		strconv.FormatFloat(c.opts.wakeWordSensitivity, 'f', -1, 64),
+	)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("creating stdin pipe: %w", err)
@@ -173,7 +421,7 @@ func (c *Capture) startWakeWordProcess() (*wakeWordProc, error) {
 	if err := cmd.Start(); err != nil {
 		stdin.Close()
 		os.Remove(scriptFile.Name())
-		return nil, fmt.Errorf("starting wake word process: %w", err)
+		return nil, fmt.Errorf("running python3: %w", err)
 	}
 	go func() {
 		scanner := bufio.NewScanner(stderrPipe)
@@ -223,18 +471,37 @@ func (w *wakeWordProc) kill() {
 	os.Remove(w.scriptPath)
 }
 
-func (c *Capture) captureLoop(ctx context.Context, buf []int16, ch chan<- []byte) {
-	defer close(ch)
+// captureLoop feeds utterances to ch, or chunks to stream when streaming.
+// Exactly one of them is set.
+func (c *Capture) captureLoop(ctx context.Context, buf []int16, ch chan Utterance, stream *chunkStream) {
+	defer func() {
+		if ch != nil {
+			close(ch)
+		}
+		stream.close()
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			c.opts.guard.Handle("capture_loop", r, debug.Stack())
+			c.fail(fmt.Errorf("capture loop panic: %v", r))
+		}
+	}()
 
 	useWakeWord := c.opts.wakeWordAccessKey != "" && c.opts.wakeWordModelPath != ""
 
-	ring := newRingBuffer(c.opts.preBufferFrames)
-	var utterance []byte
-	silenceCount := 0
-	activeCount := 0
-	speaking := false
+	seg := newSegmenter(c.opts)
+	var span utteranceSpan
+	defer span.end(nil, false)
 	awake := !useWakeWord
 	var awakeExpiry time.Time
+	gated := false
+	var playedAt time.Time
+	paused := false
+	wokeUp := false
+	errStreak := 0
+	var down time.Time
+	// pcm is reused for every frame; the segmenter copies what it keeps.
+	pcm := make([]byte, 0, len(buf)*2)
 
 	var ww *wakeWordProc
 	var wwDetected chan bool
@@ -242,7 +509,7 @@ func (c *Capture) captureLoop(ctx context.Context, buf []int16, ch chan<- []byte
 		var err error
 		ww, err = c.startWakeWordProcess()
 		if err != nil {
-			slog.Error("starting wake word process", "error", err)
+			c.fail(fmt.Errorf("starting wake word process: %w", err))
 			return
 		}
 		wwDetected = make(chan bool, 1)
@@ -265,18 +532,130 @@ func (c *Capture) captureLoop(ctx context.Context, buf []int16, ch chan<- []byte
 		default:
 		}
 
-		if err := c.stream.Read(); err != nil {
-			slog.Error("reading audio stream", "error", err)
+		if err := c.input.ReadFrame(buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				slog.Info("capture source exhausted")
+				if u := seg.Flush(time.Now()); u != nil {
+					discarded := c.tooShort(u)
+					stream.finish(ctx, u, discarded)
+					if discarded {
+						span.end(u, false)
+						c.notify(StateSpeechDiscarded)
+					} else if ch != nil {
+						u.Room = c.opts.room
+						u.Emitted = time.Now()
+						select {
+						case ch <- *u:
+							span.end(u, true)
+							c.stats.emitted.Add(1)
+							c.notify(StateUtteranceEmitted)
+						case <-ctx.Done():
+						}
+					}
+				}
+				if ww != nil {
+					ww.kill()
+				}
+				return
+			}
+
+			seg.Dropped()
+			c.stats.readErrors.Add(1)
+			errStreak++
+			if errStreak < readErrorThreshold {
+				slog.Error("reading audio stream", "error", err)
+				continue
+			}
+
+			if c.opts.source != nil && sourceRecovers(c.opts.source) {
+				if errStreak == readErrorThreshold {
+					down = time.Now()
+					slog.Warn("capture source down, waiting for it to recover", "error", err)
+					if seg.Speaking() {
+						slog.Info("discarding utterance interrupted by capture failure")
+						span.end(nil, false)
+						c.notify(StateSpeechDiscarded)
+					}
+					stream.abort(ctx)
+					seg.Reset()
+					if err := c.vad.Reset(); err != nil {
+						slog.Error("resetting vad", "error", err)
+					}
+				}
+				if time.Since(down) < c.opts.recoveryTimeout {
+					continue
+				}
+				err = fmt.Errorf("capture source down for %s: %w", time.Since(down).Round(time.Second), err)
+			}
+			if c.opts.source != nil {
+				c.fail(fmt.Errorf("reading capture source: %w", err))
+				if ww != nil {
+					ww.kill()
+				}
+				return
+			}
+
+			slog.Warn("capture stream failing, reopening", "consecutive_errors", errStreak, "error", err)
+			if err := c.reopenInput(ctx); err != nil {
+				if ctx.Err() == nil {
+					slog.Error("recovering capture stream", "error", err)
+					c.fail(err)
+				}
+				if ww != nil {
+					ww.kill()
+				}
+				return
+			}
+			errStreak = 0
+			if seg.Speaking() {
+				slog.Info("discarding utterance interrupted by capture failure")
+				span.end(nil, false)
+				c.notify(StateSpeechDiscarded)
+			}
+			stream.abort(ctx)
+			seg.Reset()
+			if err := c.vad.Reset(); err != nil {
+				slog.Error("resetting vad", "error", err)
+			}
+			continue
+		}
+		if errStreak >= readErrorThreshold {
+			slog.Info("capture source recovered", "down_for", time.Since(down).Round(time.Millisecond))
+		}
+		errStreak = 0
+		c.stats.framesRead.Add(1)
+
+		// The stream keeps being read while paused so it doesn't overflow;
+		// state is reset on both edges so nothing straddles the pause.
+		if c.paused.Load() != paused {
+			paused = !paused
+			if paused {
+				slog.Info("capture paused")
+				if seg.Speaking() {
+					slog.Info("discarding utterance in progress")
+					span.end(nil, false)
+					c.notify(StateSpeechDiscarded)
+				}
+			} else {
+				slog.Info("capture resumed")
+			}
+			stream.abort(ctx)
+			seg.Reset()
+			if err := c.vad.Reset(); err != nil {
+				slog.Error("resetting vad", "error", err)
+			}
+		}
+		if paused {
 			continue
 		}
 
-		if awake && !speaking && useWakeWord && !awakeExpiry.IsZero() && time.Now().After(awakeExpiry) {
+		if awake && !seg.Speaking() && useWakeWord && !awakeExpiry.IsZero() && time.Now().After(awakeExpiry) {
 			awakeExpiry = time.Time{}
 			awake = false
 			var err error
 			ww, err = c.startWakeWordProcess()
 			if err != nil {
-				slog.Error("restarting wake word process", "error", err)
+				c.fail(fmt.Errorf("restarting wake word process: %w", err))
 				return
 			}
 			wwDetected = make(chan bool, 1)
@@ -291,13 +670,16 @@ func (c *Capture) captureLoop(ctx context.Context, buf []int16, ch chan<- []byte
 		}
 
 		if !awake {
-			pcm := samplesToBytes(buf)
+			c.kw.Process(buf)
+			pcm = AppendInt16LE(pcm[:0], buf)
 			if _, err := ww.stdin.Write(pcm); err != nil {
 				select {
 				case detected := <-wwDetected:
 					if detected {
 						slog.Info("wake word detected")
 						awake = true
+						wokeUp = true
+						awakeExpiry = time.Now().Add(c.opts.wakeWordWindow)
 						ww.kill()
 						ww = nil
 						if c.wakeWordCh != nil {
@@ -314,7 +696,7 @@ func (c *Capture) captureLoop(ctx context.Context, buf []int16, ch chan<- []byte
 				var err2 error
 				ww, err2 = c.startWakeWordProcess()
 				if err2 != nil {
-					slog.Error("restarting wake word process", "error", err2)
+					c.fail(fmt.Errorf("restarting wake word process: %w", err2))
 					return
 				}
 				wwDetected = make(chan bool, 1)
@@ -333,6 +715,8 @@ func (c *Capture) captureLoop(ctx context.Context, buf []int16, ch chan<- []byte
 				if detected {
 					slog.Info("wake word detected")
 					awake = true
+					wokeUp = true
+					awakeExpiry = time.Now().Add(c.opts.wakeWordWindow)
 					ww.kill()
 					ww = nil
 					if c.wakeWordCh != nil {
@@ -351,72 +735,105 @@ func (c *Capture) captureLoop(ctx context.Context, buf []int16, ch chan<- []byte
 		if c.aec != nil {
 			samples = c.aec.Process(buf)
 		}
+		c.kw.Process(samples)
+
+		// Keep the echo canceller consuming reference audio while muted so it
+		// stays aligned with playback, but drop everything after it.
+		if c.muted.Load() {
+			if !gated {
+				gated = true
+				if seg.Speaking() {
+					slog.Info("capture muted, discarding utterance in progress")
+					span.end(nil, false)
+					c.notify(StateSpeechDiscarded)
+				}
+				stream.abort(ctx)
+				seg.Reset()
+				if err := c.vad.Reset(); err != nil {
+					slog.Error("resetting vad", "error", err)
+				}
+			}
+			continue
+		}
+		gated = false
 
-		frame := samplesToBytes(samples)
+		if c.hp != nil {
+			c.hp.Process(samples)
+		}
+		if c.ns != nil && (c.opts.skipNoiseSuppression == nil || !c.opts.skipNoiseSuppression()) {
+			c.ns.Process(samples)
+		}
+		if c.agc != nil {
+			c.agc.Process(samples)
+		}
 
-		active, err := c.vad.Process(c.opts.sampleRate, frame)
+		pcm = AppendInt16LE(pcm[:0], samples)
+
+		active, err := c.vad.Process(samples)
 		if err != nil {
 			slog.Error("processing vad", "error", err)
 			continue
 		}
+		if c.opts.playing != nil {
+			if c.opts.playing() {
+				playedAt = time.Now()
+			}
+			if active && time.Since(playedAt) < playbackGateHold && frameRMS(samples) < c.opts.bargeInRMS {
+				active = false
+			}
+		}
 
 		if active {
-			if !speaking {
-				activeCount++
-				ring.Push(frame)
-				if activeCount >= c.opts.minActiveFrames {
-					slog.Info("speech started")
-					speaking = true
-					silenceCount = 0
-					utterance = ring.Drain()
-				}
-			} else {
-				utterance = append(utterance, frame...)
-			}
-		} else {
-			if !speaking {
-				activeCount = 0
+			c.stats.voiced.Add(1)
+		}
+
+		wasSpeaking := seg.Speaking()
+		utterance := seg.Push(pcm, active, time.Now())
+		if utterance == nil {
+			if !wasSpeaking && seg.Speaking() {
+				span.start(ctx, seg.speechStart)
+				c.notify(StateSpeechStarted)
 			}
-			ring.Push(frame)
-			if speaking {
-				utterance = append(utterance, frame...)
-				silenceCount++
-				if silenceCount >= c.opts.silenceFrames {
-					slog.Info("speech ended")
-					select {
-					case ch <- utterance:
-					case <-ctx.Done():
-						if ww != nil {
-							ww.kill()
-						}
-						return
-					}
-					utterance = nil
-					speaking = false
-					silenceCount = 0
-					activeCount = 0
-					if useWakeWord {
-						awakeExpiry = time.Now().Add(c.opts.postUtteranceTimeout)
-					}
-				}
+			stream.progress(ctx, seg)
+			continue
+		}
+		discarded := c.tooShort(utterance)
+		stream.finish(ctx, utterance, discarded)
+		if discarded {
+			span.end(utterance, false)
+			c.notify(StateSpeechDiscarded)
+			continue
+		}
+		utterance.WakeWord = wokeUp
+		utterance.Room = c.opts.room
+		wokeUp = false
+		utterance.Emitted = time.Now()
+		if ch != nil && !c.emit(ctx, ch, *utterance) {
+			if ww != nil {
+				ww.kill()
 			}
+			return
+		}
+		if ch == nil {
+			// Streaming has no backpressure; finishing the stream is the
+			// emission.
+			c.stats.emitted.Add(1)
+		}
+		span.end(utterance, true)
+		c.notify(StateUtteranceEmitted)
+		if useWakeWord {
+			awakeExpiry = time.Now().Add(c.opts.postUtteranceTimeout)
 		}
 	}
 }
 
-func samplesToBytes(samples []int16) []byte {
-	b := make([]byte, len(samples)*2)
-	for i, s := range samples {
-		binary.LittleEndian.PutUint16(b[i*2:], uint16(s))
-	}
-	return b
-}
-
+// ringBuffer holds the last size frames before speech starts. Drain hands
+// back only what was pushed since the previous Drain, oldest first.
 type ringBuffer struct {
 	buf  [][]byte
 	size int
 	pos  int
-	full bool
+	n    int
 }
 
 func newRingBuffer(size int) *ringBuffer {
@@ -427,30 +844,22 @@ func newRingBuffer(size int) *ringBuffer {
 }
 
 func (r *ringBuffer) Push(frame []byte) {
-	cp := make([]byte, len(frame))
-	copy(cp, frame)
-	r.buf[r.pos] = cp
-	r.pos = (r.pos + 1) % r.size
-	if r.pos == 0 {
-		r.full = true
+	if r.size == 0 {
+		return
 	}
+	r.buf[r.pos] = append(r.buf[r.pos][:0], frame...)
+	r.pos = (r.pos + 1) % r.size
+	r.n = min(r.n+1, r.size)
 }
 
 func (r *ringBuffer) Drain() []byte {
 	var out []byte
-	if r.full {
-		for i := r.pos; i < r.size; i++ {
-			if r.buf[i] != nil {
-				out = append(out, r.buf[i]...)
-			}
-		}
-	}
-	for i := 0; i < r.pos; i++ {
-		if r.buf[i] != nil {
-			out = append(out, r.buf[i]...)
-		}
+	for i := r.size - r.n; i < r.size; i++ {
+		slot := (r.pos + i) % r.size
+		out = append(out, r.buf[slot]...)
+		r.buf[slot] = r.buf[slot][:0]
 	}
 	r.pos = 0
-	r.full = false
+	r.n = 0
 	return out
 }
@@ -0,0 +1,322 @@
+package audio
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/guard"
+)
+
+// scriptSource plays frames in order and runs at[i] just before handing
+// out frame i, then returns io.EOF.
+type scriptSource struct {
+	frames [][]int16
+	at     map[int]func()
+	next   int
+}
+
+func (s *scriptSource) ReadFrame(frame []int16) error {
+	if s.next >= len(s.frames) {
+		return io.EOF
+	}
+	if fn := s.at[s.next]; fn != nil {
+		fn()
+	}
+	copy(frame, s.frames[s.next])
+	s.next++
+	return nil
+}
+
+func (s *scriptSource) Close() error { return nil }
+
+// scriptPart is frames frames, each made by frame.
+type scriptPart struct {
+	frames int
+	frame  func() []int16
+}
+
+// buildScript lays parts out in order and returns the frames along with
+// the index each part starts at.
+func buildScript(parts ...scriptPart) (frames [][]int16, starts []int) {
+	for _, p := range parts {
+		starts = append(starts, len(frames))
+		for range p.frames {
+			frames = append(frames, p.frame())
+		}
+	}
+	return frames, starts
+}
+
+// collectUtterances runs c over its source until EOF.
+func collectUtterances(t *testing.T, c *Capture) []Utterance {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ch, err := c.StartUtterances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []Utterance
+	for u := range ch {
+		out = append(out, u)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("capture didn't finish the source")
+	}
+	return out
+}
+
+func TestCapturePauseResume(t *testing.T) {
+	const (
+		f1, f2 = 300.0, 700.0
+		amp    = 3000
+	)
+	r := rand.New(rand.NewPCG(1, 2))
+	quiet := func() []int16 { return noise(r, 100, 480) }
+	tone := func(f float64) func() []int16 {
+		return func() []int16 { return sine(f, amp, 16000, 480) }
+	}
+
+	tests := []struct {
+		name string
+		// parts are: background, first speech, gap, background, second
+		// speech, trailing silence.
+		gap        int
+		pauseAfter int // frames into the first speech to pause at
+		wantTones  []float64
+	}{
+		// Speech cut by the pause is dropped, and the pre-buffer after
+		// Resume holds nothing from before it.
+		{"pause mid-utterance", 10, 15, []float64{f2}},
+		// An utterance that ended before the pause is emitted on its own.
+		{"pause between utterances", 30, 50, []float64{f1, f2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, starts := buildScript(
+				scriptPart{20, quiet},
+				scriptPart{30, tone(f1)},
+				scriptPart{tt.gap, quiet},
+				scriptPart{30, quiet},
+				scriptPart{30, tone(f2)},
+				scriptPart{30, quiet},
+			)
+			var c *Capture
+			src := &scriptSource{frames: frames, at: map[int]func(){
+				starts[1] + tt.pauseAfter: func() { c.Pause() },
+				starts[3]:                 func() { c.Resume() },
+			}}
+			var err error
+			c, err = New(nil, WithSource(src), WithVAD(VADEnergy), WithVADMode(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			got := collectUtterances(t, c)
+			if len(got) != len(tt.wantTones) {
+				t.Fatalf("got %d utterances, want %d", len(got), len(tt.wantTones))
+			}
+			for i, u := range got {
+				samples := make([]int16, len(u.PCM)/2)
+				DecodeInt16LE(samples, u.PCM)
+				want, other := tt.wantTones[i], f1+f2-tt.wantTones[i]
+				if a := toneAmplitude(samples, want, 16000); a < amp/4 {
+					t.Errorf("utterance %d: %.0f Hz at %.0f, want the speech in it", i, want, a)
+				}
+				if a := toneAmplitude(samples, other, 16000); a > amp/20 {
+					t.Errorf("utterance %d: %.0f Hz at %.0f, straddles the pause", i, other, a)
+				}
+			}
+		})
+	}
+}
+
+// panicSource panics on the first read, like a driver bug would.
+type panicSource struct{}
+
+func (panicSource) ReadFrame([]int16) error { panic("driver bug") }
+func (panicSource) Close() error            { return nil }
+
+func TestCaptureLoopPanic(t *testing.T) {
+	escalated := false
+	g, err := guard.New(1, time.Minute, func() { escalated = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(nil, WithSource(panicSource{}), WithVAD(VADEnergy), WithGuard(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if got := collectUtterances(t, c); len(got) != 0 {
+		t.Errorf("got %d utterances, want none", len(got))
+	}
+	if err := c.Err(); err == nil || !strings.Contains(err.Error(), "driver bug") {
+		t.Errorf("Err() = %v, want the panic", err)
+	}
+	if !escalated {
+		t.Error("the panic wasn't reported to the guard")
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		pushes []byte // each is pushed as a one-byte frame
+		want   []byte
+	}{
+		{"empty", 3, nil, nil},
+		{"partial", 3, []byte{1, 2}, []byte{1, 2}},
+		{"exactly full", 3, []byte{1, 2, 3}, []byte{1, 2, 3}},
+		{"wrapped", 3, []byte{1, 2, 3, 4, 5}, []byte{3, 4, 5}},
+		{"wrapped twice", 3, []byte{1, 2, 3, 4, 5, 6, 7}, []byte{5, 6, 7}},
+		{"size 1", 1, []byte{1, 2, 3}, []byte{3}},
+		{"size 0", 0, []byte{1, 2}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRingBuffer(tt.size)
+			for _, b := range tt.pushes {
+				r.Push([]byte{b})
+			}
+			if got := r.Drain(); !slices.Equal(got, tt.want) {
+				t.Errorf("Drain = %v, want %v", got, tt.want)
+			}
+			if got := r.Drain(); len(got) != 0 {
+				t.Errorf("second Drain = %v, want nothing", got)
+			}
+		})
+	}
+}
+
+func TestRingBufferAfterDrain(t *testing.T) {
+	r := newRingBuffer(3)
+	for _, b := range []byte{1, 2, 3, 4} {
+		r.Push([]byte{b})
+	}
+	r.Drain()
+
+	// Filling again after a drain starts from empty, with nothing left
+	// over from before.
+	r.Push([]byte{5})
+	if got := r.Drain(); !slices.Equal(got, []byte{5}) {
+		t.Errorf("Drain after refilling one = %v, want [5]", got)
+	}
+	for _, b := range []byte{6, 7, 8, 9} {
+		r.Push([]byte{b})
+	}
+	if got := r.Drain(); !slices.Equal(got, []byte{7, 8, 9}) {
+		t.Errorf("Drain after wrapping again = %v, want [7 8 9]", got)
+	}
+}
+
+func TestRingBufferCopiesFrames(t *testing.T) {
+	r := newRingBuffer(2)
+	frame := []byte{1, 2}
+	r.Push(frame)
+	frame[0] = 9
+	if got := r.Drain(); !slices.Equal(got, []byte{1, 2}) {
+		t.Errorf("Drain = %v, want the frame as pushed", got)
+	}
+}
+
+// loopSource talks and pauses forever, and notes any read after Close.
+type loopSource struct {
+	frames    atomic.Int64
+	closed    atomic.Int32
+	lateReads atomic.Int32
+}
+
+func (s *loopSource) ReadFrame(frame []int16) error {
+	if s.closed.Load() > 0 {
+		s.lateReads.Add(1)
+	}
+	n := s.frames.Add(1)
+	if n%40 < 20 {
+		copy(frame, sine(300, 3000, 16000, len(frame)))
+	} else {
+		clear(frame)
+	}
+	return nil
+}
+
+func (s *loopSource) Close() error {
+	s.closed.Add(1)
+	return nil
+}
+
+func TestCaptureCloseRace(t *testing.T) {
+	tests := []struct {
+		name    string
+		consume bool
+		// frames is how far to let capture get before closing. Without a
+		// consumer it blocks handing on the first utterance, a few frames
+		// after the speech in frames 1 to 19.
+		frames int64
+	}{
+		{"blocked on send", false, 20},
+		{"consumer reading", true, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &loopSource{}
+			c, err := New(nil, WithSource(src), WithVAD(VADEnergy), WithVADMode(0),
+				WithChannelBuffer(0), WithSilenceFrames(5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			ch, err := c.StartUtterances(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.consume {
+				go func() {
+					for range ch {
+					}
+				}()
+			}
+			for src.frames.Load() < tt.frames {
+				time.Sleep(time.Millisecond)
+			}
+
+			// Close from several goroutines while the context is cancelled.
+			var wg sync.WaitGroup
+			errs := make([]error, 4)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs[i] = c.Close()
+				}()
+			}
+			cancel()
+			wg.Wait()
+
+			for i, err := range errs {
+				if err != nil {
+					t.Errorf("Close %d: %v", i, err)
+				}
+			}
+			if n := src.closed.Load(); n != 1 {
+				t.Errorf("source closed %d times, want once", n)
+			}
+			if n := src.lateReads.Load(); n != 0 {
+				t.Errorf("%d reads after the source was closed", n)
+			}
+			if err := c.Close(); err != nil {
+				t.Errorf("Close again: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package audio
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// State is a change in what capture is doing, reported through
+// WithStateCallback as it happens rather than once an utterance is done.
+type State int
+
+const (
+	// StateSpeechStarted fires when the VAD decides someone is talking.
+	StateSpeechStarted State = iota
+	// StateUtteranceEmitted fires when a finished utterance is handed on.
+	StateUtteranceEmitted
+	// StateSpeechDiscarded fires when speech in progress is thrown away,
+	// for being too short or because capture was muted, paused or failed.
+	StateSpeechDiscarded
+)
+
+func (s State) String() string {
+	switch s {
+	case StateSpeechStarted:
+		return "speech_started"
+	case StateUtteranceEmitted:
+		return "utterance_emitted"
+	case StateSpeechDiscarded:
+		return "speech_discarded"
+	default:
+		return "unknown"
+	}
+}
+
+// Names of the embedded clips, for PlayClip.
+const (
+	ClipListening = "listening"
+	ClipDone      = "done"
+	ClipError     = "error"
+	ClipAlarm     = "alarm"
+)
+
+//go:embed earcons/*.wav
+var clips embed.FS
+
+// Earcon returns the name of the clip acknowledging s, or "" if s has
+// none.
+func Earcon(s State) string {
+	switch s {
+	case StateSpeechStarted:
+		return ClipListening
+	case StateUtteranceEmitted:
+		return ClipDone
+	default:
+		return ""
+	}
+}
+
+// loadClip decodes the clip called name as samples at rate. A <name>.wav in
+// dir takes precedence over the embedded clip, so sounds can be replaced
+// without rebuilding. It is read on every call, so edits apply right away.
+func loadClip(dir, name string, rate int) ([]int16, error) {
+	samples, err := loadWAV(clips, "earcons", dir, name, rate)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unknown clip %q", name)
+	}
+	return samples, err
+}
+
+// loadWAV reads <name>.wav from dir, or from embedded under sub, as mono
+// samples at rate, or at the file's own rate when rate is 0. It fails with
+// fs.ErrNotExist when neither has it.
+func loadWAV(embedded fs.FS, sub, dir, name string, rate int) ([]int16, error) {
+	var data []byte
+	if dir != "" {
+		d, err := os.ReadFile(filepath.Join(dir, name+".wav"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("reading clip %s: %w", name, err)
+		}
+		data = d
+	}
+	if data == nil {
+		d, err := fs.ReadFile(embedded, sub+"/"+name+".wav")
+		if err != nil {
+			return nil, fmt.Errorf("clip %s: %w", name, fs.ErrNotExist)
+		}
+		data = d
+	}
+
+	pcm, clipRate, channels, bits, err := DecodeWAV(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding clip %s: %w", name, err)
+	}
+	if channels != 1 || bits != 16 {
+		return nil, fmt.Errorf("clip %s is %d channels, %d-bit; need mono 16-bit", name, channels, bits)
+	}
+	samples := make([]int16, len(pcm)/2)
+	DecodeInt16LE(samples, pcm)
+	if rate != 0 && clipRate != rate {
+		samples = NewResampler(clipRate, rate).Process(nil, samples)
+	}
+	return samples, nil
+}
+
+func (c *Capture) notify(s State) {
+	if c.opts.onState != nil {
+		c.opts.onState(s)
+	}
+}
@@ -0,0 +1,37 @@
+package audio
+
+import (
+	"log/slog"
+	"sync"
+)
+
+const (
+	UploadWAV  = "wav"
+	UploadFLAC = "flac"
+	UploadOpus = "opus"
+)
+
+var warnOpus sync.Once
+
+// Encode returns u's audio in the given upload format along with a file name
+// whose extension matches it, which is how STT servers tell formats apart.
+// Formats this build can't produce fall back to WAV with a warning.
+func (u Utterance) Encode(format string) ([]byte, string) {
+	rate := u.SampleRate
+	if rate == 0 {
+		rate = DefaultSampleRate
+	}
+
+	switch format {
+	case UploadFLAC:
+		return EncodeFLAC(u.PCM, rate, 1), "audio.flac"
+	case UploadOpus:
+		warnOpus.Do(func() {
+			slog.Warn("opus encoding is not available in this build, uploading wav")
+		})
+	case "", UploadWAV:
+	default:
+		slog.Warn("unknown upload format, uploading wav", "format", format)
+	}
+	return u.WAV(), "audio.wav"
+}
@@ -0,0 +1,37 @@
+package audio
+
+import "math"
+
+// highPass is a second order Butterworth high-pass biquad (RBJ cookbook,
+// direct form I). It removes DC offset and low rumble; state carries over
+// between frames so there are no clicks at frame boundaries.
+type highPass struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func newHighPass(cutoffHz float64, sampleRate int) *highPass {
+	w0 := 2 * math.Pi * cutoffHz / float64(sampleRate)
+	// Q = 1/sqrt(2), so alpha = sin(w0)/(2Q) reduces to this.
+	alpha := math.Sin(w0) / math.Sqrt2
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+
+	return &highPass{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (h *highPass) Process(samples []int16) {
+	for i, s := range samples {
+		x := float64(s)
+		y := h.b0*x + h.b1*h.x1 + h.b2*h.x2 - h.a1*h.y1 - h.a2*h.y2
+		h.x2, h.x1 = h.x1, x
+		h.y2, h.y1 = h.y1, y
+		samples[i] = clampInt16(y)
+	}
+}
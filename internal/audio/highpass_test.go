@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// highPassed runs samples through a fresh 100 Hz filter in 30 ms frames.
+func highPassed(samples []int16) []int16 {
+	out := slices.Clone(samples)
+	h := newHighPass(100, 16000)
+	for off := 0; off < len(out); off += 480 {
+		h.Process(out[off:min(off+480, len(out))])
+	}
+	return out
+}
+
+func TestHighPass(t *testing.T) {
+	tests := []struct {
+		name     string
+		freq     float64
+		min, max float64 // gain
+	}{
+		// An octave below a 2nd order cutoff is about -12 dB.
+		{"50 Hz hum", 50, 0, 0.3},
+		{"1 kHz voice", 1000, 0.98, 1.02},
+		{"4 kHz voice", 4000, 0.98, 1.02},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const amp = 10000
+			out := highPassed(sine(tt.freq, amp, 16000, 16000))
+			// Skip the first 100 ms while the filter settles.
+			gain := toneAmplitude(out[1600:], tt.freq, 16000) / amp
+			if gain < tt.min || gain > tt.max {
+				t.Errorf("gain at %.0f Hz = %.3f, want %.2f to %.2f", tt.freq, gain, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestHighPassRemovesDC(t *testing.T) {
+	in := sine(1000, 5000, 16000, 16000)
+	for i := range in {
+		in[i] += 3000
+	}
+	out := highPassed(in)
+
+	var sum float64
+	for _, s := range out[1600:] {
+		sum += float64(s)
+	}
+	if mean := sum / float64(len(out)-1600); math.Abs(mean) > 5 {
+		t.Errorf("mean after filtering = %.1f, want the offset gone", mean)
+	}
+}
+
+func TestHighPassAcrossFrames(t *testing.T) {
+	in := sine(440, 8000, 16000, 4800)
+	whole := slices.Clone(in)
+	newHighPass(100, 16000).Process(whole)
+
+	if framed := highPassed(in); !slices.Equal(framed, whole) {
+		t.Error("filtering frame by frame differs from filtering in one go")
+	}
+}
@@ -0,0 +1,239 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+type SampleFormat int
+
+const (
+	FormatInt16 SampleFormat = iota
+	FormatFloat32
+)
+
+func (f SampleFormat) String() string {
+	switch f {
+	case FormatInt16:
+		return "int16"
+	case FormatFloat32:
+		return "float32"
+	default:
+		return fmt.Sprintf("SampleFormat(%d)", int(f))
+	}
+}
+
+// Source delivers mono int16 frames at the capture sample rate. ReadFrame
+// fills the whole frame and returns io.EOF once the source is exhausted.
+type Source interface {
+	ReadFrame(frame []int16) error
+	Close() error
+}
+
+type streamOpener func(format SampleFormat) (Source, error)
+
+// negotiateFormat tries each format in order and returns the first stream
+// that opens, so devices that only offer float32 still work.
+func negotiateFormat(open streamOpener, formats ...SampleFormat) (Source, SampleFormat, error) {
+	var errs []error
+	for _, f := range formats {
+		s, err := open(f)
+		if err == nil {
+			return s, f, nil
+		}
+		slog.Debug("capture format rejected", "format", f, "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", f, err))
+	}
+	return nil, 0, fmt.Errorf("no supported sample format: %w", errors.Join(errs...))
+}
+
+type portaudioInput struct {
+	stream   *portaudio.Stream
+	format   SampleFormat
+	channels int
+	channel  int
+	i16      []int16
+	f32      []float32
+}
+
+func openPortaudioInput(dev *portaudio.DeviceInfo, sampleRate, frameSize, channels, channel int) streamOpener {
+	return func(format SampleFormat) (Source, error) {
+		in := &portaudioInput{format: format, channels: channels, channel: channel}
+
+		// Buffers hold interleaved samples; the float path converts into i16
+		// before the downmix.
+		in.i16 = make([]int16, frameSize*channels)
+		var buf any
+		switch format {
+		case FormatInt16:
+			buf = in.i16
+		case FormatFloat32:
+			in.f32 = make([]float32, frameSize*channels)
+			buf = in.f32
+		default:
+			return nil, fmt.Errorf("unsupported sample format %s", format)
+		}
+
+		params := portaudio.HighLatencyParameters(dev, nil)
+		params.Input.Channels = channels
+		params.SampleRate = float64(sampleRate)
+		params.FramesPerBuffer = frameSize
+		stream, err := portaudio.OpenStream(params, buf)
+		if err != nil {
+			return nil, fmt.Errorf("opening stream: %w", err)
+		}
+		if err := stream.Start(); err != nil {
+			stream.Close()
+			return nil, fmt.Errorf("starting stream: %w", err)
+		}
+		in.stream = stream
+		return in, nil
+	}
+}
+
+func (p *portaudioInput) ReadFrame(frame []int16) error {
+	if err := p.stream.Read(); err != nil {
+		return err
+	}
+	if p.format == FormatFloat32 {
+		float32ToInt16(p.i16, p.f32)
+	}
+	downmix(frame, p.i16, p.channels, p.channel)
+	return nil
+}
+
+// downmix turns interleaved samples into mono, either by picking one channel
+// or, with channel set to MixChannels, by averaging all of them.
+func downmix(dst, src []int16, channels, channel int) {
+	if channels == 1 {
+		copy(dst, src)
+		return
+	}
+	for i := range dst {
+		frame := src[i*channels : (i+1)*channels]
+		if channel != MixChannels {
+			dst[i] = frame[channel]
+			continue
+		}
+		sum := 0
+		for _, s := range frame {
+			sum += int(s)
+		}
+		dst[i] = int16(sum / channels)
+	}
+}
+
+func (p *portaudioInput) Close() error {
+	p.stream.Stop()
+	return p.stream.Close()
+}
+
+// float32ToInt16 maps [-1, 1] onto the full int16 range, clipping anything
+// outside it and treating NaN as silence.
+func float32ToInt16(dst []int16, src []float32) {
+	for i, f := range src {
+		v := float64(f)
+		switch {
+		case math.IsNaN(v):
+			dst[i] = 0
+		case v >= 1:
+			dst[i] = math.MaxInt16
+		case v <= -1:
+			dst[i] = math.MinInt16
+		default:
+			dst[i] = clampInt16(v * 32768)
+		}
+	}
+}
+
+// openInput opens the named input device, or the default one, at
+// sampleRate, trying formats in order. Devices that reject the rate are
+// opened at their native rate instead and resampled, so the rest of the
+// pipeline always sees sampleRate frames.
+func openInput(device string, sampleRate, frameDurationMs, channels, channel int, formats []SampleFormat) (Source, SampleFormat, int, error) {
+	dev, err := findPortaudioInput(device)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	frameSize := sampleRate * frameDurationMs / 1000
+	in, format, err := negotiateFormat(openPortaudioInput(dev, sampleRate, frameSize, channels, channel), formats...)
+	if err == nil {
+		return in, format, sampleRate, nil
+	}
+	errs := []error{fmt.Errorf("%d Hz: %w", sampleRate, err)}
+
+	for _, rate := range fallbackRates(dev, sampleRate) {
+		nativeFrame := rate * frameDurationMs / 1000
+		in, format, err := negotiateFormat(openPortaudioInput(dev, rate, nativeFrame, channels, channel), formats...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%d Hz: %w", rate, err))
+			continue
+		}
+		return newResampledInput(in, rate, sampleRate, nativeFrame), format, rate, nil
+	}
+	return nil, 0, 0, fmt.Errorf("opening capture stream: %w", errors.Join(errs...))
+}
+
+// findPortaudioInput resolves a device name, or the default device for an
+// empty one. PortAudio reaches PulseAudio and PipeWire through ALSA, where
+// monitor sources aren't listed; for those the pulse device is opened with
+// PULSE_SOURCE pointing the plugin at the monitor.
+func findPortaudioInput(name string) (*portaudio.DeviceInfo, error) {
+	if name == "" {
+		dev, err := portaudio.DefaultInputDevice()
+		if err != nil {
+			return nil, fmt.Errorf("finding default input device: %w", err)
+		}
+		return dev, nil
+	}
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("listing input devices: %w", err)
+	}
+	var inputs []*portaudio.DeviceInfo
+	var names []string
+	for _, d := range devices {
+		if d.MaxInputChannels > 0 {
+			inputs = append(inputs, d)
+			names = append(names, d.Name)
+		}
+	}
+
+	i, err := matchDevice("input", name, names)
+	if err != nil && strings.HasSuffix(name, ".monitor") {
+		if j, perr := matchDevice("input", "pulse", names); perr == nil {
+			slog.Info("capturing monitor source through the pulse device", "source", name)
+			if err := os.Setenv("PULSE_SOURCE", name); err != nil {
+				return nil, fmt.Errorf("selecting monitor source: %w", err)
+			}
+			i, err = j, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("using input device", "name", inputs[i].Name)
+	return inputs[i], nil
+}
+
+func fallbackRates(dev *portaudio.DeviceInfo, sampleRate int) []int {
+	rates := []int{int(dev.DefaultSampleRate), 48000, 44100}
+
+	seen := map[int]bool{sampleRate: true}
+	out := rates[:0]
+	for _, r := range rates {
+		if r > 0 && !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	return out
+}
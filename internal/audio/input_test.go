@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestFloat32ToInt16(t *testing.T) {
+	tests := []struct {
+		in   float32
+		want int16
+	}{
+		{0, 0},
+		{1, math.MaxInt16},
+		{-1, math.MinInt16},
+		{0.5, 16384},
+		{-0.5, -16384},
+		{1.5, math.MaxInt16},
+		{-1.5, math.MinInt16},
+		{float32(math.Inf(1)), math.MaxInt16},
+		{float32(math.Inf(-1)), math.MinInt16},
+		{float32(math.NaN()), 0},
+		{0.99999, math.MaxInt16},
+		{1e-6, 0},
+	}
+	src := make([]float32, len(tests))
+	for i, tt := range tests {
+		src[i] = tt.in
+	}
+	dst := make([]int16, len(src))
+	float32ToInt16(dst, src)
+	for i, tt := range tests {
+		if dst[i] != tt.want {
+			t.Errorf("float32ToInt16(%v) = %d, want %d", tt.in, dst[i], tt.want)
+		}
+	}
+}
+
+func TestFloat32ToInt16Allocations(t *testing.T) {
+	src := make([]float32, 480)
+	dst := make([]int16, 480)
+	if n := testing.AllocsPerRun(100, func() { float32ToInt16(dst, src) }); n != 0 {
+		t.Errorf("float32ToInt16 allocates %v times per frame, want 0", n)
+	}
+}
+
+// formatSource is a Source that only remembers the format it was opened with.
+type formatSource struct {
+	format SampleFormat
+}
+
+func (formatSource) ReadFrame([]int16) error { return nil }
+func (formatSource) Close() error            { return nil }
+
+func TestNegotiateFormat(t *testing.T) {
+	errUnsupported := errors.New("invalid sample format")
+	tests := []struct {
+		name      string
+		accepts   []SampleFormat
+		formats   []SampleFormat
+		want      SampleFormat
+		wantErr   bool
+		wantTried []SampleFormat
+	}{
+		{
+			name:      "int16 preferred",
+			accepts:   []SampleFormat{FormatInt16, FormatFloat32},
+			formats:   []SampleFormat{FormatInt16, FormatFloat32},
+			want:      FormatInt16,
+			wantTried: []SampleFormat{FormatInt16},
+		},
+		{
+			name:      "float32 only device",
+			accepts:   []SampleFormat{FormatFloat32},
+			formats:   []SampleFormat{FormatInt16, FormatFloat32},
+			want:      FormatFloat32,
+			wantTried: []SampleFormat{FormatInt16, FormatFloat32},
+		},
+		{
+			name:      "nothing fits",
+			accepts:   []SampleFormat{FormatFloat32},
+			formats:   []SampleFormat{FormatInt16},
+			wantErr:   true,
+			wantTried: []SampleFormat{FormatInt16},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried []SampleFormat
+			open := func(f SampleFormat) (Source, error) {
+				tried = append(tried, f)
+				if !slices.Contains(tt.accepts, f) {
+					return nil, errUnsupported
+				}
+				return formatSource{format: f}, nil
+			}
+
+			src, got, err := negotiateFormat(open, tt.formats...)
+			if !slices.Equal(tried, tt.wantTried) {
+				t.Errorf("tried %v, want %v", tried, tt.wantTried)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, errUnsupported) {
+					t.Errorf("err = %v, want it to wrap the device's error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || src.(formatSource).format != tt.want {
+				t.Errorf("negotiated %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSampleFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		accepts []SampleFormat
+		want    SampleFormat
+		wantErr bool
+	}{
+		{"default prefers int16", nil, []SampleFormat{FormatInt16, FormatFloat32}, FormatInt16, false},
+		{"default falls back", nil, []SampleFormat{FormatFloat32}, FormatFloat32, false},
+		{"forced float32", []Option{WithSampleFormat(FormatFloat32)}, []SampleFormat{FormatInt16, FormatFloat32}, FormatFloat32, false},
+		{"forced int16 on a float32 device", []Option{WithSampleFormat(FormatInt16)}, []SampleFormat{FormatFloat32}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultOptions()
+			for _, opt := range tt.opts {
+				opt(&o)
+			}
+			open := func(f SampleFormat) (Source, error) {
+				if !slices.Contains(tt.accepts, f) {
+					return nil, errors.New("invalid sample format")
+				}
+				return formatSource{format: f}, nil
+			}
+
+			_, got, err := negotiateFormat(open, o.sampleFormats...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("opened %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package audio
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	DefaultKeywordThreshold = 0.8
+
+	kwBands   = 20
+	kwMinHz   = 100.0
+	kwMaxHz   = 8000.0
+	kwMinTmpl = 3
+
+	// kwCooldown keeps one spoken word from firing its callback on every
+	// frame of the tail end of the match.
+	kwCooldown = time.Second
+)
+
+type keywordHandler struct {
+	word string
+	fn   func()
+}
+
+// keywordSpotter matches each frame against recorded examples of a few
+// fixed words with open-begin DTW, so a word is recognised wherever it
+// starts without buffering or a round trip to STT. It is deliberately
+// simple: a handful of templates per word and a high threshold keep false
+// positives rare at the cost of missing some detections.
+type keywordSpotter struct {
+	features  *kwFeatures
+	matchers  []*kwMatcher
+	threshold float64
+	minRMS    float64
+	quietTill time.Time
+}
+
+type kwMatcher struct {
+	word string
+	fn   func()
+	tmpl [][]float64
+	cost []float64
+	len  []int
+	next []float64
+	nlen []int
+}
+
+// newKeywordSpotter loads templates for every word from dir, as 16-bit mono
+// WAVs at the capture sample rate named after the word, e.g. stopp.wav or
+// stopp-2.wav. Clips saved by the debug recorder work as templates.
+func newKeywordSpotter(o options, frameSize int) (*keywordSpotter, error) {
+	if o.keywordThreshold <= 0 || o.keywordThreshold > 1 {
+		return nil, fmt.Errorf("invalid keyword threshold %.2f, must be above 0 and at most 1", o.keywordThreshold)
+	}
+	if o.keywordDir == "" {
+		return nil, fmt.Errorf("keywords need a template directory")
+	}
+
+	k := &keywordSpotter{
+		features:  newKWFeatures(frameSize, o.sampleRate),
+		threshold: o.keywordThreshold,
+		minRMS:    o.energyThreshold,
+	}
+	for _, h := range o.keywords {
+		paths, err := filepath.Glob(filepath.Join(o.keywordDir, h.word+"*.wav"))
+		if err != nil {
+			return nil, fmt.Errorf("finding templates for %q: %w", h.word, err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no templates for keyword %q in %s", h.word, o.keywordDir)
+		}
+		for _, path := range paths {
+			tmpl, err := k.loadTemplate(path, o.sampleRate, frameSize)
+			if err != nil {
+				return nil, err
+			}
+			k.matchers = append(k.matchers, &kwMatcher{
+				word: h.word,
+				fn:   h.fn,
+				tmpl: tmpl,
+				cost: make([]float64, len(tmpl)),
+				len:  make([]int, len(tmpl)),
+				next: make([]float64, len(tmpl)),
+				nlen: make([]int, len(tmpl)),
+			})
+		}
+		slog.Info("keyword enabled", "word", h.word, "templates", len(paths), "threshold", o.keywordThreshold)
+	}
+	k.reset()
+	return k, nil
+}
+
+func (k *keywordSpotter) loadTemplate(path string, sampleRate, frameSize int) ([][]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading keyword template: %w", err)
+	}
+	pcm, rate, channels, bits, err := DecodeWAV(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding keyword template %s: %w", path, err)
+	}
+	if rate != sampleRate || channels != 1 || bits != 16 {
+		return nil, fmt.Errorf("keyword template %s is %d Hz, %d channels, %d-bit; need %d Hz mono 16-bit", path, rate, channels, bits, sampleRate)
+	}
+
+	// Leading and trailing silence would let quiet input match, so only
+	// the voiced part of the clip is kept.
+	var tmpl [][]float64
+	frame := make([]int16, frameSize)
+	last := 0
+	for off := 0; off+frameSize*2 <= len(pcm); off += frameSize * 2 {
+		DecodeInt16LE(frame, pcm[off:])
+		voiced := frameRMS(frame) >= k.minRMS
+		if !voiced && len(tmpl) == 0 {
+			continue
+		}
+		tmpl = append(tmpl, k.features.extract(frame))
+		if voiced {
+			last = len(tmpl)
+		}
+	}
+	tmpl = tmpl[:last]
+	if len(tmpl) < kwMinTmpl {
+		return nil, fmt.Errorf("keyword template %s has %d voiced frames, need at least %d", path, len(tmpl), kwMinTmpl)
+	}
+	return tmpl, nil
+}
+
+// Process feeds one frame and calls the callback of any word that matches.
+// A nil spotter does nothing.
+func (k *keywordSpotter) Process(samples []int16) {
+	if k == nil {
+		return
+	}
+	now := time.Now()
+	if now.Before(k.quietTill) {
+		return
+	}
+	if frameRMS(samples) < k.minRMS {
+		k.reset()
+		return
+	}
+
+	x := k.features.extract(samples)
+	for _, m := range k.matchers {
+		score := m.push(x)
+		if score < k.threshold {
+			continue
+		}
+		slog.Info("keyword detected", "word", m.word, "score", math.Round(score*1000)/1000)
+		k.reset()
+		k.quietTill = now.Add(kwCooldown)
+		m.fn()
+		return
+	}
+}
+
+func (k *keywordSpotter) reset() {
+	for _, m := range k.matchers {
+		for i := range m.cost {
+			m.cost[i] = math.Inf(1)
+			m.len[i] = 0
+		}
+	}
+}
+
+// push advances the DTW by one input frame and returns the score of the
+// best match ending here: one minus the mean cosine distance along the
+// path. The input may be up to twice as fast or as slow as the template.
+func (m *kwMatcher) push(x []float64) float64 {
+	n := len(m.tmpl)
+	for i := range n {
+		d := 1 - dot(m.tmpl[i], x)
+		best, bestLen := math.Inf(1), 0
+		consider := func(cost float64, l int) {
+			if math.IsInf(cost, 1) || l >= 2*n {
+				return
+			}
+			if best == math.Inf(1) || (cost+d)/float64(l+1) < (best+d)/float64(bestLen+1) {
+				best, bestLen = cost, l
+			}
+		}
+		if i == 0 {
+			consider(0, 0)
+		}
+		consider(m.cost[i], m.len[i])
+		if i >= 1 {
+			consider(m.cost[i-1], m.len[i-1])
+		}
+		if i >= 2 {
+			consider(m.cost[i-2], m.len[i-2])
+		}
+		if math.IsInf(best, 1) {
+			m.next[i], m.nlen[i] = math.Inf(1), 0
+			continue
+		}
+		m.next[i], m.nlen[i] = best+d, bestLen+1
+	}
+	m.cost, m.next = m.next, m.cost
+	m.len, m.nlen = m.nlen, m.len
+
+	if math.IsInf(m.cost[n-1], 1) {
+		return math.Inf(-1)
+	}
+	return 1 - m.cost[n-1]/float64(m.len[n-1])
+}
+
+func dot(a, b []float64) float64 {
+	var s float64
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// kwFeatures turns a frame into a unit-length vector of mean-removed log
+// mel band energies, which describes the spectral shape independently of
+// how loud the word was said.
+type kwFeatures struct {
+	window   []float64
+	spectrum []complex128
+	bands    [][2]int
+	power    []float64
+}
+
+func newKWFeatures(frameSize, sampleRate int) *kwFeatures {
+	fftSize := 1
+	for fftSize < frameSize {
+		fftSize <<= 1
+	}
+	window := make([]float64, frameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize))
+	}
+
+	mel := func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) }
+	hz := func(m float64) float64 { return 700 * (math.Pow(10, m/2595) - 1) }
+	maxHz := min(kwMaxHz, float64(sampleRate)/2)
+	binHz := float64(sampleRate) / float64(fftSize)
+
+	bands := make([][2]int, kwBands)
+	lo, hi := mel(kwMinHz), mel(maxHz)
+	for b := range bands {
+		from := hz(lo + (hi-lo)*float64(b)/kwBands)
+		to := hz(lo + (hi-lo)*float64(b+1)/kwBands)
+		bands[b] = [2]int{int(from / binHz), max(int(from/binHz)+1, int(to/binHz))}
+	}
+
+	return &kwFeatures{
+		window:   window,
+		spectrum: make([]complex128, fftSize),
+		bands:    bands,
+		power:    make([]float64, fftSize/2+1),
+	}
+}
+
+func (f *kwFeatures) extract(samples []int16) []float64 {
+	clear(f.spectrum)
+	for i, s := range samples {
+		f.spectrum[i] = complex(float64(s)*f.window[i], 0)
+	}
+	fft(f.spectrum, false)
+	for i := range f.power {
+		re, im := real(f.spectrum[i]), imag(f.spectrum[i])
+		f.power[i] = re*re + im*im
+	}
+
+	v := make([]float64, len(f.bands))
+	var mean float64
+	for b, r := range f.bands {
+		var e float64
+		for i := r[0]; i < r[1] && i < len(f.power); i++ {
+			e += f.power[i]
+		}
+		v[b] = math.Log(e + 1)
+		mean += v[b]
+	}
+	mean /= float64(len(v))
+
+	var norm float64
+	for b := range v {
+		v[b] -= mean
+		norm += v[b] * v[b]
+	}
+	if norm = math.Sqrt(norm); norm > 0 {
+		for b := range v {
+			v[b] /= norm
+		}
+	}
+	return v
+}
@@ -0,0 +1,35 @@
+package audio
+
+import (
+	"math"
+)
+
+// limiterKnee is the share of the ceiling below which the limiter leaves
+// samples alone.
+const limiterKnee = 0.7
+
+// softLimiter keeps output under a ceiling without the harsh distortion of
+// clipping: samples below the knee pass unchanged, and above it they bend
+// smoothly towards the ceiling. It looks at one sample at a time, so it
+// adds no latency.
+type softLimiter struct {
+	knee    float64
+	ceiling float64
+}
+
+// newSoftLimiter limits to ceiling, a share of full scale.
+func newSoftLimiter(ceiling float64) *softLimiter {
+	c := ceiling * math.MaxInt16
+	return &softLimiter{knee: c * limiterKnee, ceiling: c}
+}
+
+func (l *softLimiter) apply(x float64) float64 {
+	a := math.Abs(x)
+	if a <= l.knee {
+		return x
+	}
+	// tanh has slope 1 at 0, so the curve joins the linear part without a
+	// kink and never quite reaches the ceiling.
+	span := l.ceiling - l.knee
+	return math.Copysign(l.knee+span*math.Tanh((a-l.knee)/span), x)
+}
@@ -0,0 +1,39 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSoftLimiter(t *testing.T) {
+	const ceiling = 0.5
+	l := newSoftLimiter(ceiling)
+	top := ceiling * math.MaxInt16
+	knee := top * limiterKnee
+
+	// Below the knee samples pass unchanged, in either direction.
+	for _, x := range []float64{0, 1, -1, knee / 2, -knee / 2, knee, -knee} {
+		if got := l.apply(x); got != x {
+			t.Errorf("apply(%.0f) = %.0f, want it unchanged", x, got)
+		}
+	}
+
+	// Above it the curve never falls back, stays within the ceiling and
+	// is the same both ways.
+	prev := knee
+	for x := knee + 1; x <= 4*math.MaxInt16; x += 97 {
+		got := l.apply(x)
+		if got < prev || got > top {
+			t.Fatalf("apply(%.0f) = %.1f after %.1f, want no lower and at most %.1f", x, got, prev, top)
+		}
+		if neg := l.apply(-x); neg != -got {
+			t.Fatalf("apply(%.0f) = %.1f, want %.1f", -x, neg, -got)
+		}
+		prev = got
+	}
+
+	// It joins the linear part without a kink.
+	if slope := l.apply(knee+1) - l.apply(knee); math.Abs(slope-1) > 0.01 {
+		t.Errorf("slope just above the knee = %.3f, want 1", slope)
+	}
+}
@@ -0,0 +1,160 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+const (
+	nsOverSubtraction = 2.0
+	nsGainFloor       = 0.1
+	nsGainSmoothing   = 0.5
+	nsInitFrames      = 10
+	nsUpdateRatio     = 3.0
+	nsUpdateRate      = 0.05
+	nsRiseRate        = 1.002
+)
+
+// noiseSuppressor is a spectral subtraction filter running a 50% overlapped
+// STFT with a hop of exactly one frame. Output is delayed by one frame, but
+// every call still consumes and produces frameSize samples, so the VAD frame
+// contract is unchanged.
+type noiseSuppressor struct {
+	frameSize int
+	fftSize   int
+	window    []float64
+	prevIn    []float64
+	overlap   []float64
+	noise     []float64
+	gain      []float64
+	spectrum  []complex128
+	frames    int
+}
+
+func newNoiseSuppressor(frameSize int) *noiseSuppressor {
+	fftSize := 1
+	for fftSize < 2*frameSize {
+		fftSize <<= 1
+	}
+
+	winLen := 2 * frameSize
+	window := make([]float64, winLen)
+	for i := range window {
+		window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(winLen)))
+	}
+
+	bins := fftSize/2 + 1
+	gain := make([]float64, bins)
+	for i := range gain {
+		gain[i] = 1
+	}
+
+	return &noiseSuppressor{
+		frameSize: frameSize,
+		fftSize:   fftSize,
+		window:    window,
+		prevIn:    make([]float64, frameSize),
+		overlap:   make([]float64, frameSize),
+		noise:     make([]float64, bins),
+		gain:      gain,
+		spectrum:  make([]complex128, fftSize),
+	}
+}
+
+func (n *noiseSuppressor) Process(samples []int16) {
+	if len(samples) != n.frameSize {
+		return
+	}
+
+	spec := n.spectrum
+	for i := range spec {
+		spec[i] = 0
+	}
+	for i := 0; i < n.frameSize; i++ {
+		spec[i] = complex(n.prevIn[i]*n.window[i], 0)
+		cur := float64(samples[i])
+		spec[n.frameSize+i] = complex(cur*n.window[n.frameSize+i], 0)
+		n.prevIn[i] = cur
+	}
+
+	fft(spec, false)
+
+	bins := n.fftSize/2 + 1
+	n.frames++
+	for k := 0; k < bins; k++ {
+		mag := cmplx.Abs(spec[k])
+		power := mag * mag
+
+		switch {
+		case n.frames <= nsInitFrames:
+			n.noise[k] += (power - n.noise[k]) / float64(n.frames)
+		case power < nsUpdateRatio*n.noise[k]:
+			n.noise[k] += nsUpdateRate * (power - n.noise[k])
+		default:
+			n.noise[k] *= nsRiseRate
+		}
+
+		g := 1.0
+		if power > 0 {
+			g = 1 - nsOverSubtraction*n.noise[k]/power
+		}
+		g = math.Sqrt(math.Max(g, nsGainFloor*nsGainFloor))
+		g = nsGainSmoothing*n.gain[k] + (1-nsGainSmoothing)*g
+		n.gain[k] = g
+
+		spec[k] *= complex(g, 0)
+		if k > 0 && k < n.fftSize/2 {
+			spec[n.fftSize-k] = cmplx.Conj(spec[k])
+		}
+	}
+
+	fft(spec, true)
+
+	for i := 0; i < n.frameSize; i++ {
+		out := n.overlap[i] + real(spec[i])*n.window[i]
+		n.overlap[i] = real(spec[n.frameSize+i]) * n.window[n.frameSize+i]
+		samples[i] = clampInt16(out)
+	}
+}
+
+// fft is an in-place iterative radix-2 transform; len(x) must be a power of
+// two. The inverse is scaled by 1/n.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, sign*2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a := x[start+k]
+				b := x[start+k+size/2] * w
+				x[start+k] = a + b
+				x[start+k+size/2] = a - b
+				w *= step
+			}
+		}
+	}
+
+	if inverse {
+		scale := complex(1/float64(n), 0)
+		for i := range x {
+			x[i] *= scale
+		}
+	}
+}
@@ -1,31 +1,253 @@
 package audio
 
-import "time"
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/joakimcarlsson/smarthome/internal/guard"
+)
 
 const (
 	DefaultSampleRate      = 16000
 	DefaultFrameDurationMs = 30
 	DefaultVADMode         = 3
+	DefaultEnergyThreshold = 100.0
 	DefaultSilenceFrames   = 15
 	DefaultPreBufferFrames = 8
 	DefaultMinActiveFrames = 3
+	DefaultMaxUtteranceMs  = 30000
+	DefaultMinUtteranceMs  = 300
+	DefaultRecoveryTimeout = 2 * time.Minute
+	DefaultChannelBuffer   = 4
+	DefaultTrailingPadMs   = 150
+
+	DefaultWakeWordSensitivity = 0.9
+
+	// MixChannels averages all input channels instead of picking one.
+	MixChannels = -1
 )
 
+// The frame sizes webrtcvad accepts, which the rest of the pipeline is
+// built around.
+var (
+	supportedSampleRates     = []int{8000, 16000, 32000, 48000}
+	supportedFrameDurationMs = []int{10, 20, 30}
+)
+
+// SupportedFrameDurations returns the frame durations in milliseconds that
+// can be used at sampleRate, or nil if the rate itself isn't supported.
+func SupportedFrameDurations(sampleRate int) []int {
+	if !slices.Contains(supportedSampleRates, sampleRate) {
+		return nil
+	}
+	return slices.Clone(supportedFrameDurationMs)
+}
+
 type options struct {
+	backend              string
 	sampleRate           int
 	frameDurationMs      int
+	inputDevice          string
+	outputDevice         string
+	outputDeviceIndex    int
+	playbackQueueMs      int
+	playbackSampleRate   int
+	playbackSourceRate   int
+	playbackChannels     int
+	playbackSourceChans  int
+	playbackName         string
+	clipDir              string
+	phraseDir            string
+	playbackDir          string
+	activeHold           time.Duration
+	activeDebounce       time.Duration
+	volume               float64
+	playbackFades        bool
+	playbackCeiling      float64
+	inputChannels        int
+	channelSelect        int
+	sampleFormats        []SampleFormat
 	vadMode              int
+	vadMinMode           int
+	vadMaxMode           int
+	vadBackend           string
+	energyThreshold      float64
+	sileroModelPath      string
+	onnxRuntimeLib       string
 	silenceFrames        int
 	preBufferFrames      int
+	silenceMs            int
+	preBufferMs          int
+	trailingPadMs        int
 	minActiveFrames      int
+	vadOnsetWindow       int
+	maxUtteranceMs       int
+	minUtteranceMs       int
+	streamChunkMs        int
 	postUtteranceTimeout time.Duration
+	recoveryTimeout      time.Duration
+	guard                *guard.Guard
+	room                 string
+	backpressure         string
+	channelBuffer        int
+	source               Source
+	agcTargetRMS         float64
+	highPassHz           float64
+	noiseSuppression     bool
+	skipNoiseSuppression func() bool
+	onState              func(State)
+	playing              func() bool
+	bargeInRMS           float64
+	keywords             []keywordHandler
+	keywordDir           string
+	keywordThreshold     float64
 
-	wakeWordAccessKey string
-	wakeWordModelPath string
+	wakeWordAccessKey   string
+	wakeWordModelPath   string
+	wakeWordSensitivity float64
+	wakeWordWindow      time.Duration
 }
 
 type Option func(*options)
 
+// WithBackend picks the device layer, BackendPortAudio or BackendMiniaudio.
+func WithBackend(name string) Option {
+	return func(o *options) {
+		o.backend = name
+	}
+}
+
+// WithOutputDevice plays through the output device called name, or the
+// first whose name contains it, instead of the default.
+func WithOutputDevice(name string) Option {
+	return func(o *options) {
+		o.outputDevice = name
+	}
+}
+
+// WithOutputDeviceIndex plays through the output device at index in the
+// list of outputs, as printed when a name doesn't match. It takes
+// precedence over WithOutputDevice.
+func WithOutputDeviceIndex(index int) Option {
+	return func(o *options) {
+		o.outputDeviceIndex = index
+	}
+}
+
+// WithPlaybackQueueMs bounds how much audio Play may queue ahead of the
+// device before it returns ErrQueueFull.
+func WithPlaybackQueueMs(ms int) Option {
+	return func(o *options) {
+		o.playbackQueueMs = ms
+	}
+}
+
+// WithPlaybackSampleRate opens the output device at rate instead of the
+// rate of the audio given to Play, for DACs that only take a few rates.
+func WithPlaybackSampleRate(rate int) Option {
+	return func(o *options) {
+		o.playbackSampleRate = rate
+	}
+}
+
+// WithPlaybackSourceRate sets the sample rate of the PCM given to Play,
+// PlaybackSampleRate unless set. Playback resamples it when the device
+// runs at another rate.
+func WithPlaybackSourceRate(rate int) Option {
+	return func(o *options) {
+		o.playbackSourceRate = rate
+	}
+}
+
+// WithPlaybackChannels opens the output device with n channels, for
+// devices that won't open in mono. Mono audio is played on all of them.
+func WithPlaybackChannels(n int) Option {
+	return func(o *options) {
+		o.playbackChannels = n
+	}
+}
+
+// WithPlaybackSourceChannels sets how many channels the PCM given to Play
+// interleaves: 1 by default, or as many as the output has.
+func WithPlaybackSourceChannels(n int) Option {
+	return func(o *options) {
+		o.playbackSourceChans = n
+	}
+}
+
+// WithPlaybackName labels a Playback's metrics and logs, to tell sinks
+// apart.
+func WithPlaybackName(name string) Option {
+	return func(o *options) {
+		o.playbackName = name
+	}
+}
+
+// WithClipDir makes PlayClip look for <name>.wav in dir before falling
+// back to the embedded clips.
+func WithClipDir(dir string) Option {
+	return func(o *options) {
+		o.clipDir = dir
+	}
+}
+
+// WithPhraseDir makes PlayPhrase look for <id>.wav in dir before falling
+// back to the embedded phrases.
+func WithPhraseDir(dir string) Option {
+	return func(o *options) {
+		o.phraseDir = dir
+	}
+}
+
+// WithPlaybackDir sets where the file backend writes its WAV files.
+func WithPlaybackDir(dir string) Option {
+	return func(o *options) {
+		o.playbackDir = dir
+	}
+}
+
+// WithActiveHold sets how long the playback queue must stay empty before
+// OnActive reports false, so pauses between chunks and sentences don't
+// count.
+func WithActiveHold(d time.Duration) Option {
+	return func(o *options) {
+		o.activeHold = d
+	}
+}
+
+// WithActiveDebounce sets the least time between two OnActive calls.
+func WithActiveDebounce(d time.Duration) Option {
+	return func(o *options) {
+		o.activeDebounce = d
+	}
+}
+
+// WithVolume sets the initial playback volume, from 0 to 1.
+func WithVolume(v float64) Option {
+	return func(o *options) {
+		o.volume = v
+	}
+}
+
+// WithPlaybackFades fades each response in over its first few
+// milliseconds, and has Stop fade out instead of cutting off mid-wave, to
+// avoid clicks at either end.
+func WithPlaybackFades(on bool) Option {
+	return func(o *options) {
+		o.playbackFades = on
+	}
+}
+
+// WithPlaybackLimiter softly limits output to ceiling, a share of full
+// scale from 0 to 1, for speakers that distort when driven to full scale.
+// 0 leaves output untouched.
+func WithPlaybackLimiter(ceiling float64) Option {
+	return func(o *options) {
+		o.playbackCeiling = ceiling
+	}
+}
+
 func WithSampleRate(rate int) Option {
 	return func(o *options) {
 		o.sampleRate = rate
@@ -38,30 +260,158 @@ func WithFrameDurationMs(ms int) Option {
 	}
 }
 
+// WithInputDevice captures from the named device instead of the default,
+// matched exactly or as a case-insensitive substring. PulseAudio and
+// PipeWire monitor sources, named like "<sink>.monitor", capture whatever
+// is being played, which makes the whole pipeline testable without a mic.
+func WithInputDevice(name string) Option {
+	return func(o *options) {
+		o.inputDevice = name
+	}
+}
+
+func WithInputChannels(n int) Option {
+	return func(o *options) {
+		o.inputChannels = n
+	}
+}
+
+// WithChannelSelect picks which input channel feeds the VAD, or MixChannels
+// to average them.
+func WithChannelSelect(idx int) Option {
+	return func(o *options) {
+		o.channelSelect = idx
+	}
+}
+
+// WithSampleFormat opens the input device in format only, instead of trying
+// int16 and falling back to float32. Either way VAD sees int16 samples.
+func WithSampleFormat(format SampleFormat) Option {
+	return func(o *options) {
+		o.sampleFormats = []SampleFormat{format}
+	}
+}
+
+// WithVADMode sets the VAD aggressiveness from 0 to 3, or VADAdaptive to
+// follow the background noise.
 func WithVADMode(mode int) Option {
 	return func(o *options) {
 		o.vadMode = mode
 	}
 }
 
+// WithVADModeRange bounds the modes VADAdaptive switches between.
+func WithVADModeRange(minMode, maxMode int) Option {
+	return func(o *options) {
+		o.vadMinMode = minMode
+		o.vadMaxMode = maxMode
+	}
+}
+
+// WithEnergyThreshold sets the RMS below which the energy VAD never reports
+// speech, however quiet the room has been.
+func WithEnergyThreshold(rms float64) Option {
+	return func(o *options) {
+		o.energyThreshold = rms
+	}
+}
+
+func WithVAD(backend string) Option {
+	return func(o *options) {
+		o.vadBackend = backend
+	}
+}
+
+func WithSileroModel(modelPath, onnxRuntimeLib string) Option {
+	return func(o *options) {
+		o.sileroModelPath = modelPath
+		o.onnxRuntimeLib = onnxRuntimeLib
+	}
+}
+
+// WithSilenceDurationMs sets how long the VAD must report silence before an
+// utterance ends. It must be a multiple of the frame duration.
+func WithSilenceDurationMs(ms int) Option {
+	return func(o *options) {
+		o.silenceMs = ms
+	}
+}
+
+// WithPreBufferMs sets how much audio before speech onset is kept. It must be
+// a multiple of the frame duration.
+func WithPreBufferMs(ms int) Option {
+	return func(o *options) {
+		o.preBufferMs = ms
+	}
+}
+
+// Deprecated: use WithSilenceDurationMs, which doesn't depend on the frame
+// duration.
 func WithSilenceFrames(n int) Option {
 	return func(o *options) {
 		o.silenceFrames = n
 	}
 }
 
+// WithTrailingPadMs keeps ms of the silence after the last voiced frame
+// and trims the rest of the hangover before the utterance is emitted. A
+// negative value keeps all of it.
+func WithTrailingPadMs(ms int) Option {
+	return func(o *options) {
+		o.trailingPadMs = ms
+	}
+}
+
+// Deprecated: use WithPreBufferMs, which doesn't depend on the frame
+// duration.
 func WithPreBufferFrames(n int) Option {
 	return func(o *options) {
 		o.preBufferFrames = n
 	}
 }
 
+// WithVADOnsetFrames starts an utterance once k of the last m frames are
+// voiced. Ending uses the same tolerance: silenceFrames inactive frames out
+// of the last silenceFrames+m-k. With k == m both must be consecutive.
+func WithVADOnsetFrames(k, m int) Option {
+	return func(o *options) {
+		o.minActiveFrames = k
+		o.vadOnsetWindow = m
+	}
+}
+
+// Deprecated: use WithVADOnsetFrames.
 func WithMinActiveFrames(n int) Option {
 	return func(o *options) {
 		o.minActiveFrames = n
 	}
 }
 
+// WithMaxUtteranceMs cuts an utterance once it holds this much audio, in
+// whole frames. 0 turns the cutoff off; otherwise it must be at least one
+// frame.
+func WithMaxUtteranceMs(ms int) Option {
+	return func(o *options) {
+		o.maxUtteranceMs = ms
+	}
+}
+
+// WithMinUtteranceMs drops utterances with less voiced audio than this, which
+// filters out coughs, door slams and the like before they reach STT.
+func WithMinUtteranceMs(ms int) Option {
+	return func(o *options) {
+		o.minUtteranceMs = ms
+	}
+}
+
+// WithStreamChunkMs sets how much new audio StartStreaming collects before
+// sending a chunk.
+func WithStreamChunkMs(ms int) Option {
+	return func(o *options) {
+		o.streamChunkMs = ms
+	}
+}
+
 func WithWakeWord(accessKey, modelPath string) Option {
 	return func(o *options) {
 		o.wakeWordAccessKey = accessKey
@@ -69,20 +419,229 @@ func WithWakeWord(accessKey, modelPath string) Option {
 	}
 }
 
+func WithWakeWordSensitivity(sensitivity float64) Option {
+	return func(o *options) {
+		o.wakeWordSensitivity = sensitivity
+	}
+}
+
+func WithWakeWordWindow(d time.Duration) Option {
+	return func(o *options) {
+		o.wakeWordWindow = d
+	}
+}
+
 func WithPostUtteranceTimeout(d time.Duration) Option {
 	return func(o *options) {
 		o.postUtteranceTimeout = d
 	}
 }
 
+// WithRecoveryTimeout sets how long capture keeps trying to reopen a failed
+// input stream before giving up and closing the utterance channel.
+func WithRecoveryTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.recoveryTimeout = d
+	}
+}
+
+// WithGuard reports a panic in the capture loop to g, so it counts toward
+// g's limit, before the utterance channel is closed.
+func WithGuard(g *guard.Guard) Option {
+	return func(o *options) {
+		o.guard = g
+	}
+}
+
+// WithSource replaces the microphone with another frame source, such as a
+// FileSource. Capture then never touches the audio backend.
+func WithSource(src Source) Option {
+	return func(o *options) {
+		o.source = src
+	}
+}
+
+// WithBackpressure sets what capture does when the consumer falls behind and
+// the utterance channel is full: BackpressureBlock, BackpressureDropOldest or
+// BackpressureDropNewest.
+func WithBackpressure(policy string) Option {
+	return func(o *options) {
+		o.backpressure = policy
+	}
+}
+
+// WithChannelBuffer sets how many utterances StartUtterances queues for a
+// slow consumer before the backpressure policy applies. Zero makes every
+// utterance wait for the consumer, which only the block policy allows.
+func WithChannelBuffer(n int) Option {
+	return func(o *options) {
+		o.channelBuffer = n
+	}
+}
+
+// WithStateCallback calls fn from the capture goroutine whenever the capture
+// state changes. fn must return quickly, since capture waits for it.
+func WithStateCallback(fn func(State)) Option {
+	return func(o *options) {
+		o.onState = fn
+	}
+}
+
+// WithRoom tags every utterance with where it was captured.
+func WithRoom(room string) Option {
+	return func(o *options) {
+		o.room = room
+	}
+}
+
+// WithHighPass removes DC offset and rumble below cutoffHz before VAD.
+func WithHighPass(cutoffHz float64) Option {
+	return func(o *options) {
+		o.highPassHz = cutoffHz
+	}
+}
+
+func WithAGC(targetRMS float64) Option {
+	return func(o *options) {
+		o.agcTargetRMS = targetRMS
+	}
+}
+
+func WithNoiseSuppression(enabled bool) Option {
+	return func(o *options) {
+		o.noiseSuppression = enabled
+	}
+}
+
+// resolve fills in derived frame counts and validates them. Millisecond
+// options take precedence over the frame-count options when set.
+func (o *options) resolve() error {
+	durations := SupportedFrameDurations(o.sampleRate)
+	if durations == nil {
+		return fmt.Errorf("unsupported sample rate %d Hz, expected one of %v", o.sampleRate, supportedSampleRates)
+	}
+	if !slices.Contains(durations, o.frameDurationMs) {
+		return fmt.Errorf("unsupported frame duration %dms at %d Hz, expected one of %v", o.frameDurationMs, o.sampleRate, durations)
+	}
+	if o.vadOnsetWindow == 0 {
+		o.vadOnsetWindow = o.minActiveFrames
+	}
+	if o.minActiveFrames < 1 || o.vadOnsetWindow < o.minActiveFrames {
+		return fmt.Errorf("invalid vad onset of %d in %d frames", o.minActiveFrames, o.vadOnsetWindow)
+	}
+	if o.silenceMs != 0 {
+		n, err := msToFrames("silence duration", o.silenceMs, o.frameDurationMs)
+		if err != nil {
+			return err
+		}
+		o.silenceFrames = n
+	}
+	if o.preBufferMs != 0 {
+		n, err := msToFrames("pre-buffer", o.preBufferMs, o.frameDurationMs)
+		if err != nil {
+			return err
+		}
+		o.preBufferFrames = n
+	}
+	if o.preBufferFrames < 1 {
+		return fmt.Errorf("invalid pre-buffer of %d frames, must be at least one", o.preBufferFrames)
+	}
+	if o.maxUtteranceMs > 0 && o.maxUtteranceMs < o.frameDurationMs {
+		return fmt.Errorf("invalid max utterance %dms, must be at least one %dms frame", o.maxUtteranceMs, o.frameDurationMs)
+	}
+	if o.streamChunkMs <= 0 {
+		return fmt.Errorf("invalid stream chunk of %dms", o.streamChunkMs)
+	}
+	return nil
+}
+
+func msToFrames(name string, ms, frameMs int) (int, error) {
+	if ms < frameMs {
+		return 0, fmt.Errorf("invalid %s %dms, must be at least one %dms frame", name, ms, frameMs)
+	}
+	if ms%frameMs != 0 {
+		return 0, fmt.Errorf("invalid %s %dms, must be a multiple of the %dms frame duration", name, ms, frameMs)
+	}
+	return ms / frameMs, nil
+}
+
+// WithNoiseSuppressionBypass lets the caller switch noise suppression off
+// per frame, for example while the machine is under heavy load.
+func WithNoiseSuppressionBypass(skip func() bool) Option {
+	return func(o *options) {
+		o.skipNoiseSuppression = skip
+	}
+}
+
+// WithPlaybackGate keeps listening while playing reports the speaker busy,
+// but only frames at or above minRMS count as speech then, so the response
+// bleeding into the mic can't open an utterance while someone talking over
+// it still can.
+func WithPlaybackGate(playing func() bool, minRMS float64) Option {
+	return func(o *options) {
+		o.playing = playing
+		o.bargeInRMS = minRMS
+	}
+}
+
+// WithKeyword calls fn when word is spoken, recognised locally from the
+// templates given to WithKeywordTemplates. It listens regardless of wake
+// word, VAD and playback state. fn runs on the capture goroutine and must
+// return quickly.
+func WithKeyword(word string, fn func()) Option {
+	return func(o *options) {
+		o.keywords = append(o.keywords, keywordHandler{word: word, fn: fn})
+	}
+}
+
+// WithKeywordTemplates sets the directory holding example recordings of the
+// keywords, see WithKeyword.
+func WithKeywordTemplates(dir string) Option {
+	return func(o *options) {
+		o.keywordDir = dir
+	}
+}
+
+// WithKeywordThreshold sets how closely a word must match a template, from
+// 0 to 1. Raise it if keywords fire on other speech.
+func WithKeywordThreshold(t float64) Option {
+	return func(o *options) {
+		o.keywordThreshold = t
+	}
+}
+
 func defaultOptions() options {
 	return options{
 		sampleRate:           DefaultSampleRate,
 		frameDurationMs:      DefaultFrameDurationMs,
+		inputChannels:        1,
+		channelSelect:        MixChannels,
+		sampleFormats:        []SampleFormat{FormatInt16, FormatFloat32},
+		volume:               1,
+		outputDeviceIndex:    -1,
+		playbackQueueMs:      DefaultPlaybackQueueMs,
+		playbackSourceRate:   PlaybackSampleRate,
+		playbackChannels:     1,
+		playbackSourceChans:  1,
+		activeHold:           DefaultActiveHold,
+		activeDebounce:       DefaultActiveDebounce,
 		vadMode:              DefaultVADMode,
+		vadMinMode:           1,
+		vadMaxMode:           3,
+		energyThreshold:      DefaultEnergyThreshold,
+		keywordThreshold:     DefaultKeywordThreshold,
+		channelBuffer:        DefaultChannelBuffer,
+		trailingPadMs:        DefaultTrailingPadMs,
 		silenceFrames:        DefaultSilenceFrames,
 		preBufferFrames:      DefaultPreBufferFrames,
 		minActiveFrames:      DefaultMinActiveFrames,
+		maxUtteranceMs:       DefaultMaxUtteranceMs,
+		minUtteranceMs:       DefaultMinUtteranceMs,
+		streamChunkMs:        DefaultStreamChunkMs,
 		postUtteranceTimeout: 60 * time.Second,
+		recoveryTimeout:      DefaultRecoveryTimeout,
+		backpressure:         BackpressureBlock,
+		wakeWordSensitivity:  DefaultWakeWordSensitivity,
+		wakeWordWindow:       10 * time.Second,
 	}
 }
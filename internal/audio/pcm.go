@@ -0,0 +1,31 @@
+package audio
+
+import "slices"
+
+// AppendInt16LE appends src to dst as 16-bit little-endian PCM and returns
+// the extended slice. Passing a reused dst[:0] makes a conversion per frame
+// allocation free.
+func AppendInt16LE(dst []byte, src []int16) []byte {
+	n := len(dst)
+	dst = slices.Grow(dst, len(src)*2)[:n+len(src)*2]
+	// Reslicing to exact lengths up front lets the compiler drop the bounds
+	// checks from the loop.
+	out := dst[n : n+len(src)*2]
+	for i, s := range src {
+		out[i*2] = byte(s)
+		out[i*2+1] = byte(uint16(s) >> 8)
+	}
+	return dst
+}
+
+// DecodeInt16LE decodes 16-bit little-endian PCM from src into dst and
+// returns the number of samples written, the smaller of len(dst) and
+// len(src)/2. A trailing odd byte is ignored.
+func DecodeInt16LE(dst []int16, src []byte) int {
+	n := min(len(dst), len(src)/2)
+	dst, src = dst[:n], src[:n*2]
+	for i := range dst {
+		dst[i] = int16(uint16(src[i*2]) | uint16(src[i*2+1])<<8)
+	}
+	return n
+}
@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestAppendInt16LE(t *testing.T) {
+	samples := []int16{0, 1, -1, 0x1234, math.MaxInt16, math.MinInt16}
+	want := []byte{0, 0, 1, 0, 0xff, 0xff, 0x34, 0x12, 0xff, 0x7f, 0x00, 0x80}
+
+	if got := AppendInt16LE(nil, samples); !bytes.Equal(got, want) {
+		t.Errorf("AppendInt16LE = %x, want %x", got, want)
+	}
+	prefix := []byte{9, 9}
+	if got := AppendInt16LE(prefix, samples); !bytes.Equal(got, append([]byte{9, 9}, want...)) {
+		t.Errorf("AppendInt16LE after a prefix = %x", got)
+	}
+	if got := AppendInt16LE(nil, nil); len(got) != 0 {
+		t.Errorf("AppendInt16LE of nothing = %x", got)
+	}
+}
+
+func TestDecodeInt16LE(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  int
+		src  []byte
+		want []int16
+	}{
+		{"exact", 3, []byte{1, 0, 0xff, 0xff, 0x00, 0x80}, []int16{1, -1, math.MinInt16}},
+		{"short dst", 2, []byte{1, 0, 2, 0, 3, 0}, []int16{1, 2}},
+		{"short src", 3, []byte{1, 0, 2, 0}, []int16{1, 2}},
+		{"odd trailing byte", 3, []byte{1, 0, 2}, []int16{1}},
+		{"empty", 3, nil, []int16{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := make([]int16, tt.dst)
+			n := DecodeInt16LE(dst, tt.src)
+			if n != len(tt.want) || !slices.Equal(dst[:n], tt.want) {
+				t.Errorf("DecodeInt16LE = %v (%d), want %v", dst[:n], n, tt.want)
+			}
+		})
+	}
+}
+
+func TestInt16LERoundTrip(t *testing.T) {
+	samples := make([]int16, 65536)
+	for i := range samples {
+		samples[i] = int16(i - 32768)
+	}
+	pcm := AppendInt16LE(nil, samples)
+
+	// Same bytes as encoding/binary writes.
+	var ref bytes.Buffer
+	binary.Write(&ref, binary.LittleEndian, samples)
+	if !bytes.Equal(pcm, ref.Bytes()) {
+		t.Fatal("AppendInt16LE differs from encoding/binary")
+	}
+
+	got := make([]int16, len(samples))
+	if n := DecodeInt16LE(got, pcm); n != len(samples) || !slices.Equal(got, samples) {
+		t.Error("every int16 value doesn't survive a round trip")
+	}
+}
+
+func TestInt16LEAllocations(t *testing.T) {
+	samples := make([]int16, 480)
+	buf := make([]byte, 0, 960)
+	if n := testing.AllocsPerRun(100, func() { buf = AppendInt16LE(buf[:0], samples) }); n != 0 {
+		t.Errorf("AppendInt16LE into a reused buffer allocates %v times, want 0", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { DecodeInt16LE(samples, buf) }); n != 0 {
+		t.Errorf("DecodeInt16LE allocates %v times, want 0", n)
+	}
+}
+
+func BenchmarkAppendInt16LE(b *testing.B) {
+	samples := sine(440, 8000, 16000, 480)
+	buf := make([]byte, 0, 960)
+	b.SetBytes(960)
+	for b.Loop() {
+		buf = AppendInt16LE(buf[:0], samples)
+	}
+}
+
+func BenchmarkDecodeInt16LE(b *testing.B) {
+	pcm := AppendInt16LE(nil, sine(440, 8000, 16000, 480))
+	samples := make([]int16, 480)
+	b.SetBytes(960)
+	for b.Loop() {
+		DecodeInt16LE(samples, pcm)
+	}
+}
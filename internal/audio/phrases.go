@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// IDs of the phrase bank, for PlayPhrase: what must still be said when no
+// synthesizer can be reached.
+const (
+	PhraseSpeechUnavailable   = "speech_unavailable"
+	PhraseTranscriptionFailed = "transcription_failed"
+	PhraseAgentFailed         = "agent_failed"
+	PhraseSmokeAlarm          = "smoke_alarm"
+)
+
+// Phrases is what each phrase says. It is what the phrases are rendered
+// from, and what is synthesized instead when one has no audio.
+var Phrases = map[string]string{
+	PhraseSpeechUnavailable:   "Jag når inte taltjänsten just nu.",
+	PhraseTranscriptionFailed: "Jag hörde inte vad du sa. Kan du säga det igen?",
+	PhraseAgentFailed:         "Något gick fel. Försök igen om en stund.",
+	PhraseSmokeAlarm:          "Varning! Brandvarnaren har löst ut.",
+}
+
+// ErrNoPhrase is returned by PlayPhrase for a phrase with no audio, either
+// embedded or in the phrase dir.
+var ErrNoPhrase = errors.New("no audio for phrase")
+
+// The phrases are rendered into this directory before building; see the
+// phrases subcommand.
+//
+//go:embed phrases
+var phrases embed.FS
+
+// PlayPhrase plays the phrase id from WithPhraseDir or the embedded set,
+// mixed over whatever is playing like PlayClip.
+func (p *Playback) PlayPhrase(id string) error {
+	samples, err := loadPhrase(p.phraseDir, id, p.rate)
+	if err != nil {
+		return err
+	}
+	return p.queueOverlay(samples)
+}
+
+func loadPhrase(dir, id string, rate int) ([]int16, error) {
+	if _, ok := Phrases[id]; !ok {
+		return nil, fmt.Errorf("unknown phrase %q", id)
+	}
+	samples, err := loadWAV(phrases, "phrases", dir, id, rate)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w %s", ErrNoPhrase, id)
+	}
+	return samples, err
+}
+
+// CheckPhrases loads every phrase as PlayPhrase would, returning why each
+// one that can't be played can't. A missing one is ErrNoPhrase.
+func CheckPhrases(dir string) map[string]error {
+	failed := map[string]error{}
+	for id := range Phrases {
+		if _, err := loadPhrase(dir, id, 0); err != nil {
+			failed[id] = err
+		}
+	}
+	return failed
+}
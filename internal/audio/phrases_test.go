@@ -0,0 +1,105 @@
+package audio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePhrase writes n samples of a tone as the phrase id in dir.
+func writePhrase(t *testing.T, dir, id string, rate, channels, n int) {
+	t.Helper()
+	samples := make([]int16, n*channels)
+	for i := range samples {
+		samples[i] = int16(i%64*200 - 6400)
+	}
+	wav := EncodeWAV(AppendInt16LE(nil, samples), rate, channels, 16)
+	if err := os.WriteFile(filepath.Join(dir, id+".wav"), wav, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPhrase(t *testing.T) {
+	dir := t.TempDir()
+	writePhrase(t, dir, PhraseSpeechUnavailable, 16000, 1, 1600)
+	writePhrase(t, dir, PhraseAgentFailed, 16000, 2, 1600)
+	if err := os.WriteFile(filepath.Join(dir, PhraseSmokeAlarm+".wav"), []byte("not a wav"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		id      string
+		rate    int
+		want    int
+		missing bool
+		wantErr bool
+	}{
+		{name: "as recorded", dir: dir, id: PhraseSpeechUnavailable, want: 1600},
+		{name: "resampled", dir: dir, id: PhraseSpeechUnavailable, rate: 48000, want: 4800},
+		{name: "missing", dir: dir, id: PhraseTranscriptionFailed, missing: true},
+		{name: "no dir", id: PhraseSpeechUnavailable, missing: true},
+		{name: "unknown", dir: dir, id: "weather_report", wantErr: true},
+		{name: "stereo", dir: dir, id: PhraseAgentFailed, wantErr: true},
+		{name: "not a wav", dir: dir, id: PhraseSmokeAlarm, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := loadPhrase(tt.dir, tt.id, tt.rate)
+			if tt.missing != errors.Is(err, ErrNoPhrase) {
+				t.Fatalf("loadPhrase error = %v, missing %v", err, tt.missing)
+			}
+			if tt.missing {
+				return
+			}
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("loadPhrase error = %v, want error %v", err, tt.wantErr)
+			}
+			// The resampler may be a few samples off at the edges.
+			if d := len(samples) - tt.want; d < -tt.want/100 || d > tt.want/100 {
+				t.Errorf("got %d samples, want %d", len(samples), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPhrases(t *testing.T) {
+	dir := t.TempDir()
+	writePhrase(t, dir, PhraseSpeechUnavailable, 22050, 1, 2205)
+	writePhrase(t, dir, PhraseAgentFailed, 22050, 2, 2205)
+
+	failed := CheckPhrases(dir)
+	if err, ok := failed[PhraseSpeechUnavailable]; ok {
+		t.Errorf("good phrase failed: %v", err)
+	}
+	if err := failed[PhraseAgentFailed]; err == nil || errors.Is(err, ErrNoPhrase) {
+		t.Errorf("stereo phrase: %v, want a format error", err)
+	}
+	for _, id := range []string{PhraseTranscriptionFailed, PhraseSmokeAlarm} {
+		if err := failed[id]; !errors.Is(err, ErrNoPhrase) {
+			t.Errorf("%s: %v, want ErrNoPhrase", id, err)
+		}
+	}
+	if len(failed) != len(Phrases)-1 {
+		t.Errorf("%d phrases failed, want %d", len(failed), len(Phrases)-1)
+	}
+}
+
+func TestPlayPhrase(t *testing.T) {
+	dir := t.TempDir()
+	writePhrase(t, dir, PhraseSpeechUnavailable, 16000, 1, 1600)
+	p, err := NewPlayback(nil, WithBackend(BackendFile), WithPlaybackDir(t.TempDir()), WithPhraseDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.PlayPhrase(PhraseSpeechUnavailable); err != nil {
+		t.Errorf("PlayPhrase = %v", err)
+	}
+	if err := p.PlayPhrase(PhraseSmokeAlarm); !errors.Is(err, ErrNoPhrase) {
+		t.Errorf("PlayPhrase of a missing phrase = %v, want ErrNoPhrase", err)
+	}
+}
@@ -1,116 +1,930 @@
 package audio
 
 import (
-	"encoding/binary"
+	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
 	"sync/atomic"
+	"time"
 
-	"github.com/gordonklaus/portaudio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const PlaybackSampleRate = 24000
+const (
+	// PlaybackSampleRate is the rate Play takes unless WithPlaybackSourceRate
+	// says otherwise, that of ElevenLabs' pcm_24000.
+	PlaybackSampleRate = 24000
 
+	DefaultPlaybackQueueMs = 60000
+
+	// pauseFadeMs is how long Pause fades out over, and Resume back in.
+	pauseFadeMs = 10
+)
+
+// ErrQueueFull is returned by Play when the queue already holds as much
+// audio as WithPlaybackQueueMs allows. The chunk is dropped.
+var ErrQueueFull = errors.New("playback queue full")
+
+// ErrUnderflow is what an output returns when the device ran out of audio
+// before the frame arrived. The frame is still played, so Playback only
+// counts it. Any other write error stops playback for good, and Play and
+// Drain return it from then on.
+var ErrUnderflow = errors.New("playback underflow")
+
+// ErrNotPaused is returned by Resume when there is no pause to resume,
+// including after Stop, which ends a pause along with the response.
+var ErrNotPaused = errors.New("playback not paused")
+
+// PlaybackStats describes the queue between Play and the device.
+type PlaybackStats struct {
+	Queued      time.Duration
+	QueuedBytes int
+	// PlayedBytes counts response audio written to the device, at its
+	// rate and channels.
+	PlayedBytes int64
+	// FirstWrite is how long the last response took from its first Play
+	// to its first frame reaching the device. It tells a slow TTS stream,
+	// which shows here, from a device that can't keep up, which shows in
+	// Underflows.
+	FirstWrite time.Duration
+	// Rejected counts chunks dropped with ErrQueueFull.
+	Rejected int64
+	// Underflows counts frames that reached the device after it had run
+	// dry although Playback had them queued. A steady climb while speaking
+	// points at a frame size the device doesn't like or a starved CPU.
+	Underflows int64
+}
+
+// Playback queues PCM from Play and writes it to the device on a goroutine
+// of its own, so whoever produces the audio, such as the TTS websocket
+// reader, never waits on the sound card.
 type Playback struct {
-	stream    *portaudio.Stream
+	name      string
+	backend   backend
+	out       output
+	outMu     sync.Mutex
 	frameBuf  []int16
 	frameSize int
-	pending   []byte
-	aec       *EchoCanceller
-	speaking  atomic.Bool
+	// rate and channels are the device's; Play's audio is resampled to it
+	// from srcRate by rs when the two differ, and mono is copied to every
+	// channel. frameSize counts samples per channel.
+	rate        int
+	srcRate     int
+	channels    int
+	srcChannels int
+	rs          *channelResampler
+	carry       []byte
+	samples     []int16
+	resampled   []int16
+	upmixed     []int16
+	aec         *EchoCanceller
+	aecRs       *Resampler
+	aecMono     []int16
+	aecBuf      []int16
+	clipDir     string
+	phraseDir   string
+	limiter     *softLimiter
+	// fades is WithPlaybackFades. fadeBuf holds the fade out Stop leaves
+	// for the writer when stopFading is set.
+	fades    bool
+	fadeBuf  []int16
+	speaking atomic.Bool
+	// volume holds the float64 bits of the scale, so SetVolume can be
+	// called while the writer runs.
+	volume atomic.Uint64
+
+	// mu guards the queue and the writer's state; cond wakes the writer
+	// and Drain.
+	mu    sync.Mutex
+	cond  *sync.Cond
+	queue []byte
+	head  int
+	// overlay holds PlayOverlay's samples, already at the device rate.
+	overlay     []int16
+	overlayHead int
+	limit       int
+	flushing    bool
+	writing     bool
+	stopped     bool
+	closed      bool
+	rejected    int64
+	underflows  int64
+	played      int64
+	// started is when the current response's first chunk was queued and
+	// sounded whether it has reached the device since; span is the trace
+	// TraceResponse gave it.
+	started    time.Time
+	sounded    bool
+	span       trace.Span
+	firstWrite time.Duration
+	// respDone is what Done handed out for the current response, and
+	// playedUntil, in Unix nanoseconds, when the last frame written will
+	// have left the device.
+	respDone    chan struct{}
+	playedUntil atomic.Int64
+	// paused is set by Pause and only changed with mu held; it is atomic
+	// so Speaking can read it. outPaused is whether the writer has stopped
+	// the device for it.
+	paused     atomic.Bool
+	outPaused  bool
+	stopFading bool
+	active     activity
+	// failed is the write error that stopped the writer.
+	failed error
+	done   chan struct{}
+
+	metrics         metric.Registration
+	firstWriteHisto metric.Float64Histogram
 }
 
-func NewPlayback(aec *EchoCanceller) (*Playback, error) {
-	frameSize := PlaybackSampleRate / 10
-	buf := make([]int16, frameSize)
+// NewPlayback opens the default output device, or the one picked with
+// WithOutputDevice or WithOutputDeviceIndex, at the rate of the audio Play
+// takes unless WithPlaybackSampleRate picks another. Capture options, such
+// as WithSampleRate, are ignored.
+func NewPlayback(aec *EchoCanceller, opts ...Option) (*Playback, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.playbackSourceRate <= 0 {
+		return nil, fmt.Errorf("invalid playback source rate %d Hz", o.playbackSourceRate)
+	}
+	rate := o.playbackSampleRate
+	if rate == 0 {
+		rate = o.playbackSourceRate
+	}
+	if rate < 0 {
+		return nil, fmt.Errorf("invalid playback sample rate %d Hz", rate)
+	}
+	if o.playbackChannels < 1 {
+		return nil, fmt.Errorf("invalid playback channel count %d", o.playbackChannels)
+	}
+	if o.playbackSourceChans != 1 && o.playbackSourceChans != o.playbackChannels {
+		return nil, fmt.Errorf("can't play %d channels on %d, the source must be mono or match", o.playbackSourceChans, o.playbackChannels)
+	}
+	if o.playbackCeiling < 0 || o.playbackCeiling > 1 {
+		return nil, fmt.Errorf("invalid playback limiter ceiling %g, expected 0 to 1", o.playbackCeiling)
+	}
 
-	stream, err := portaudio.OpenDefaultStream(0, 1, float64(PlaybackSampleRate), frameSize, &buf)
+	b, err := newBackend(o.backend)
 	if err != nil {
-		return nil, fmt.Errorf("opening playback stream: %w", err)
+		return nil, err
+	}
+
+	// Playback holds its own backend reference rather than depending on
+	// capture having started first.
+	if err := b.acquire(); err != nil {
+		return nil, err
+	}
+
+	frameSize := rate / 10
+	out, err := b.openOutput(o, rate, frameSize)
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+
+	p := &Playback{
+		name:        o.playbackName,
+		backend:     b,
+		out:         out,
+		frameBuf:    make([]int16, frameSize*o.playbackChannels),
+		frameSize:   frameSize,
+		rate:        rate,
+		srcRate:     o.playbackSourceRate,
+		channels:    o.playbackChannels,
+		srcChannels: o.playbackSourceChans,
+		clipDir:     o.clipDir,
+		phraseDir:   o.phraseDir,
+		fades:       o.playbackFades,
+		aec:         aec,
+		limit:       max(rate*o.playbackQueueMs/1000, frameSize) * o.playbackChannels * 2,
+		done:        make(chan struct{}),
+	}
+	if rate != p.srcRate {
+		p.rs = newChannelResampler(p.srcRate, rate, p.srcChannels)
+		slog.Info("resampling playback source", "from", p.srcRate, "to", rate)
+	}
+	if aec != nil && aec.SampleRate() != rate {
+		p.aecRs = NewResampler(rate, aec.SampleRate())
+	}
+	if o.playbackCeiling > 0 {
+		p.limiter = newSoftLimiter(o.playbackCeiling)
+	}
+	if p.fades {
+		p.fadeBuf = make([]int16, len(p.frameBuf))
+	}
+	p.cond = sync.NewCond(&p.mu)
+	p.active.hold = o.activeHold
+	p.active.debounce = o.activeDebounce
+	p.SetVolume(o.volume)
+	if err := p.registerMetrics(); err != nil {
+		out.Close()
+		b.release()
+		return nil, err
 	}
+	go p.run()
+	return p, nil
+}
 
-	if err := stream.Start(); err != nil {
-		stream.Close()
-		return nil, fmt.Errorf("starting playback stream: %w", err)
+// SetVolume scales all further output by v, clamped to 0 through 1. It
+// applies from the next frame, also mid-response, and is safe to call from
+// any goroutine.
+func (p *Playback) SetVolume(v float64) {
+	if math.IsNaN(v) {
+		return
 	}
+	p.volume.Store(math.Float64bits(min(max(v, 0), 1)))
+}
 
-	return &Playback{
-		stream:    stream,
-		frameBuf:  buf,
-		frameSize: frameSize,
-		aec:       aec,
-	}, nil
+func (p *Playback) Volume() float64 {
+	return math.Float64frombits(p.volume.Load())
 }
 
+// Speaking reports whether a response is queued or being written, from the
+// first Play until Flush's last frame has gone to the device. It is false
+// while paused, since nothing is heard then.
 func (p *Playback) Speaking() bool {
-	return p.speaking.Load()
+	return p.speaking.Load() && !p.paused.Load()
+}
+
+// OnActive calls fn with true when audio starts being queued and with
+// false once it has all been played, for ducking other audio while the
+// assistant talks. See WithActiveHold and WithActiveDebounce for how gaps
+// are smoothed over. fn runs on a goroutine of its own, one call at a
+// time.
+func (p *Playback) OnActive(fn func(active bool)) {
+	p.active.add(fn)
+}
+
+// noteActivity passes the queue's state to OnActive. A paused response
+// counts as inactive, since nothing is heard. Call with mu held.
+func (p *Playback) noteActivity() {
+	p.active.update(!p.paused.Load() && (p.head < len(p.queue) || p.writing))
+}
+
+// Pause holds playback at the next frame without dropping anything: the
+// device fades out and is stopped, Play keeps queueing and Drain waits
+// until Resume. It is safe to call from any goroutine.
+func (p *Playback) Pause() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("playback closed")
+	}
+	if p.failed != nil {
+		return p.failed
+	}
+	p.paused.Store(true)
+	p.noteActivity()
+	p.cond.Broadcast()
+	return nil
+}
+
+// Resume continues from the frame Pause stopped at, fading back in. It
+// returns ErrNotPaused if there is nothing to resume.
+func (p *Playback) Resume() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused.Load() {
+		return ErrNotPaused
+	}
+	p.paused.Store(false)
+	p.noteActivity()
+	p.cond.Broadcast()
+	return nil
+}
+
+func (p *Playback) Paused() bool {
+	return p.paused.Load()
 }
 
+func (p *Playback) Stats() PlaybackStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queued := len(p.queue) - p.head
+	return PlaybackStats{
+		Queued:      time.Duration(queued/2/p.channels) * time.Second / time.Duration(p.rate),
+		QueuedBytes: queued,
+		PlayedBytes: p.played,
+		FirstWrite:  p.firstWrite,
+		Rejected:    p.rejected,
+		Underflows:  p.underflows,
+	}
+}
+
+// Done returns a channel that is closed once the current response has
+// played out: everything queued up to Flush written to the device and the
+// device's latency waited out. Call it after the response's first Play;
+// with no response under way the channel closes as soon as the last one
+// has left the device. Stop, Close and a failed write close it right away.
+func (p *Playback) Done() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.respDone == nil {
+		p.respDone = make(chan struct{})
+	}
+	ch := p.respDone
+	if p.closed || p.failed != nil {
+		p.closeDone(false)
+	} else if !p.speaking.Load() {
+		p.closeDone(true)
+	}
+	return ch
+}
+
+// closeDone closes the channel from Done, once the device has played what
+// was written if played is set. Call with mu held.
+func (p *Playback) closeDone(played bool) {
+	ch := p.respDone
+	if ch == nil {
+		return
+	}
+	p.respDone = nil
+	wait := time.Until(time.Unix(0, p.playedUntil.Load()))
+	if !played || wait <= 0 {
+		close(ch)
+		return
+	}
+	time.AfterFunc(wait, func() { close(ch) })
+}
+
+// TraceResponse adds a first_sound event to the span in ctx when the
+// response's first frame reaches the device, so a trace shows the time to
+// first sound. Call it before the response's first Play.
+func (p *Playback) TraceResponse(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.span = trace.SpanFromContext(ctx)
+}
+
+// Play queues data and returns without waiting for the device. When the
+// queue is full the chunk is dropped and ErrQueueFull returned; after Stop
+// chunks are dropped silently until Reset.
 func (p *Playback) Play(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("playback closed")
+	}
+	if p.failed != nil {
+		return p.failed
+	}
+	if p.stopped {
+		return nil
+	}
+	if p.rs != nil || p.srcChannels != p.channels {
+		data = p.convert(data)
+	}
+	if len(p.queue)-p.head+len(data) > p.limit {
+		p.rejected++
+		return ErrQueueFull
+	}
+	if p.head > 0 && len(p.queue)+len(data) > cap(p.queue) {
+		p.queue = p.queue[:copy(p.queue, p.queue[p.head:])]
+		p.head = 0
+	}
+	p.queue = append(p.queue, data...)
+	if p.started.IsZero() {
+		p.started = time.Now()
+	}
 	p.speaking.Store(true)
-	p.pending = append(p.pending, data...)
-	frameSizeBytes := p.frameSize * 2
+	p.noteActivity()
+	p.cond.Broadcast()
+	return nil
+}
+
+// convert brings data to the device's rate and channels. Chunks may split
+// a sample frame, so a partial one is held for the next chunk.
+func (p *Playback) convert(data []byte) []byte {
+	if len(p.carry) > 0 {
+		data = append(p.carry, data...)
+		p.carry = nil
+	}
+	if rem := len(data) % (2 * p.srcChannels); rem > 0 {
+		p.carry = append([]byte(nil), data[len(data)-rem:]...)
+		data = data[:len(data)-rem]
+	}
+	if n := len(data) / 2; cap(p.samples) < n {
+		p.samples = make([]int16, n)
+	}
+	p.samples = p.samples[:len(data)/2]
+	DecodeInt16LE(p.samples, data)
+
+	samples := p.samples
+	if p.rs != nil {
+		p.resampled = p.rs.Process(p.resampled[:0], samples)
+		samples = p.resampled
+	}
+	if p.srcChannels != p.channels {
+		p.upmixed = upmix(p.upmixed[:0], samples, p.channels)
+		samples = p.upmixed
+	}
+	return AppendInt16LE(nil, samples)
+}
+
+// upmix appends mono to dst with each sample repeated on every channel.
+func upmix(dst, mono []int16, channels int) []int16 {
+	for _, s := range mono {
+		for range channels {
+			dst = append(dst, s)
+		}
+	}
+	return dst
+}
+
+// resetSource forgets the tail of the previous response, so it doesn't
+// bleed into the next one through the resampler.
+func (p *Playback) resetSource() {
+	p.carry = nil
+	if p.rs != nil {
+		p.rs.Reset()
+	}
+}
+
+// run writes queued frames to the device until Close or a write fails.
+func (p *Playback) run() {
+	defer close(p.done)
+	frameSizeBytes := len(p.frameBuf) * 2
+	for {
+		p.mu.Lock()
+		// A device left without audio between responses, or while waiting
+		// on the TTS stream, underflows too; only count those while the
+		// writer was keeping up.
+		waited := false
+		for !p.closed && !p.stopFading && !p.pausing() && !p.frameReady(frameSizeBytes) && !p.overlayReady() {
+			waited = true
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+
+		if p.stopFading {
+			p.stopFading = false
+			copy(p.frameBuf, p.fadeBuf)
+			p.writing = true
+			p.mu.Unlock()
+
+			err := p.write(p.frameBuf)
+
+			p.mu.Lock()
+			p.writing = false
+			if err != nil && !errors.Is(err, ErrUnderflow) {
+				p.fail(err)
+				p.mu.Unlock()
+				return
+			}
+			p.cond.Broadcast()
+			p.mu.Unlock()
+			p.endResponse()
+			continue
+		}
+
+		if p.pausing() {
+			p.fadeOut(p.frameBuf)
+			p.writing = true
+			p.mu.Unlock()
+
+			err := p.pauseOutput()
+
+			p.mu.Lock()
+			p.writing = false
+			p.outPaused = true
+			if err != nil {
+				p.fail(err)
+				p.mu.Unlock()
+				return
+			}
+			p.cond.Broadcast()
+			p.mu.Unlock()
+			continue
+		}
 
-	for len(p.pending) >= frameSizeBytes {
-		for i := 0; i < p.frameSize; i++ {
-			p.frameBuf[i] = int16(binary.LittleEndian.Uint16(p.pending[i*2:]))
+		// The last frame of a flushed response is padded with silence, as
+		// is a frame of overlay alone while the response is still coming.
+		n := 0
+		if p.frameReady(frameSizeBytes) {
+			n = min(len(p.queue)-p.head, frameSizeBytes)
+			DecodeInt16LE(p.frameBuf, p.queue[p.head:p.head+n])
+			p.head += n
+			if p.head == len(p.queue) {
+				p.queue, p.head = p.queue[:0], 0
+			}
 		}
-		p.pending = p.pending[frameSizeBytes:]
+		clear(p.frameBuf[n/2:])
+		fadeIn := p.fades && n > 0 && !p.sounded
+		p.mixOverlay()
+		resume := p.outPaused
+		p.outPaused = false
+		p.writing = true
+		p.mu.Unlock()
 
-		if p.aec != nil {
-			resampled := Resample24to16(p.frameBuf)
-			p.aec.FeedReference(resampled)
+		var err error
+		if resume || fadeIn {
+			ramp(p.frameBuf[:min(p.fadeLen()*p.channels, len(p.frameBuf))], p.channels, true)
+		}
+		if resume {
+			err = p.resumeOutput()
+		}
+		if err == nil {
+			err = p.write(p.frameBuf)
 		}
 
-		if err := p.stream.Write(); err != nil {
-			if !strings.Contains(err.Error(), "Output underflowed") {
-				return fmt.Errorf("writing playback stream: %w", err)
+		p.mu.Lock()
+		p.writing = false
+		if errors.Is(err, ErrUnderflow) {
+			if !waited && !resume {
+				p.underflows++
 			}
+		} else if err != nil {
+			p.fail(err)
+			p.mu.Unlock()
+			return
+		}
+		if n > 0 {
+			p.played += int64(n)
+			p.noteSound()
+		}
+		ended := p.flushing && p.head == len(p.queue)
+		if ended {
+			p.flushing = false
+			p.speaking.Store(false)
+			p.endTiming()
+			p.closeDone(true)
+		}
+		p.noteActivity()
+		p.cond.Broadcast()
+		p.mu.Unlock()
+		if ended {
+			p.endResponse()
+		}
+	}
+}
+
+func (p *Playback) overlayReady() bool {
+	return !p.paused.Load() && p.overlayHead < len(p.overlay)
+}
+
+// mixOverlay adds the next frame of overlay to every channel of frameBuf.
+// Where the two together clip, the sum saturates instead of wrapping
+// around. Call with mu held.
+func (p *Playback) mixOverlay() {
+	m := min(len(p.overlay)-p.overlayHead, p.frameSize)
+	for i, s := range p.overlay[p.overlayHead : p.overlayHead+m] {
+		for j := i * p.channels; j < (i+1)*p.channels; j++ {
+			p.frameBuf[j] = clampInt16(float64(p.frameBuf[j]) + float64(s))
+		}
+	}
+	p.overlayHead += m
+	if p.overlayHead == len(p.overlay) {
+		p.overlay, p.overlayHead = p.overlay[:0], 0
+	}
+}
+
+// noteSound records the time to first sound once per response. Call with
+// mu held.
+func (p *Playback) noteSound() {
+	if p.sounded || p.started.IsZero() {
+		return
+	}
+	p.sounded = true
+	p.firstWrite = time.Since(p.started)
+	p.firstWriteHisto.Record(context.Background(), p.firstWrite.Seconds(), p.metricAttrs())
+	if p.span != nil {
+		p.span.AddEvent("first_sound", trace.WithAttributes(
+			attribute.Int64("audio.first_write_ms", p.firstWrite.Milliseconds()),
+		))
+	}
+}
+
+// endTiming readies noteSound for the next response. Call with mu held.
+func (p *Playback) endTiming() {
+	p.started = time.Time{}
+	p.sounded = false
+	p.span = nil
+}
+
+// endResponse tells an output that keeps responses apart that one is
+// over, whether played out or stopped.
+func (p *Playback) endResponse() {
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	if e, ok := p.out.(responseEnder); ok {
+		if err := e.EndResponse(); err != nil {
+			slog.Warn("ending playback response", "error", err)
 		}
 	}
+}
+
+// fail stops playback for good after a write error. Call with mu held.
+func (p *Playback) fail(err error) {
+	p.failed = err
+	p.flushing = false
+	p.queue, p.head = p.queue[:0], 0
+	p.overlay, p.overlayHead = p.overlay[:0], 0
+	p.speaking.Store(false)
+	p.endTiming()
+	p.closeDone(false)
+	p.noteActivity()
+	p.cond.Broadcast()
+	if p.name != "" {
+		slog.Error("playback failed", "sink", p.name, "error", err)
+	} else {
+		slog.Error("playback failed", "error", err)
+	}
+}
 
+// pausing reports whether Pause was called and the writer hasn't stopped
+// the device yet.
+func (p *Playback) pausing() bool {
+	return p.paused.Load() && !p.outPaused
+}
+
+func (p *Playback) fadeLen() int {
+	return p.rate * pauseFadeMs / 1000
+}
+
+// fadeOut fills dst, a frame long, with the start of what is queued, faded
+// to silence, without taking it off the queue. After a pause Resume plays
+// it again with a fade in, so the response picks up where it stopped. Call
+// with mu held.
+func (p *Playback) fadeOut(dst []int16) {
+	n := min(len(p.queue)-p.head, p.fadeLen()*p.channels*2, len(dst)*2)
+	n -= n % (p.channels * 2)
+	clear(dst)
+	DecodeInt16LE(dst, p.queue[p.head:p.head+n])
+	ramp(dst[:n/2], p.channels, false)
+}
+
+// ramp fades interleaved samples in from silence, or out to it.
+func ramp(samples []int16, channels int, in bool) {
+	frames := len(samples) / channels
+	for i, s := range samples {
+		g := float64(i/channels+1) / float64(frames)
+		if !in {
+			g = 1 - g
+		}
+		samples[i] = int16(float64(s) * g)
+	}
+}
+
+// pauseOutput writes the fade out and stops the device once it has played,
+// so it neither clicks nor underflows while paused.
+func (p *Playback) pauseOutput() error {
+	if err := p.write(p.frameBuf); err != nil && !errors.Is(err, ErrUnderflow) {
+		return err
+	}
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	if p.out == nil {
+		return errors.New("playback closed")
+	}
+	return p.out.Pause()
+}
+
+func (p *Playback) resumeOutput() error {
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	if p.out == nil {
+		return errors.New("playback closed")
+	}
+	return p.out.Resume()
+}
+
+func (p *Playback) frameReady(frameSizeBytes int) bool {
+	if p.paused.Load() {
+		return false
+	}
+	queued := len(p.queue) - p.head
+	return queued >= frameSizeBytes || (p.flushing && queued > 0)
+}
+
+// PlayClip plays the named clip, such as ClipListening, from WithClipDir
+// or the embedded set, mixed over whatever is playing like PlayOverlay.
+func (p *Playback) PlayClip(name string) error {
+	samples, err := loadClip(p.clipDir, name, p.rate)
+	if err != nil {
+		return err
+	}
+	return p.queueOverlay(samples)
+}
+
+// PlayOverlay mixes mono pcm, at the same rate as Play's, over the response
+// from the next frame on instead of queueing it behind, for sounds like a
+// timer going off mid-answer. Overlays play one after another and don't
+// count as speaking. It returns without waiting and is safe to call from
+// any goroutine.
+func (p *Playback) PlayOverlay(pcm []byte) error {
+	samples := make([]int16, len(pcm)/2)
+	DecodeInt16LE(samples, pcm)
+	if p.rate != p.srcRate {
+		samples = NewResampler(p.srcRate, p.rate).Process(nil, samples)
+	}
+	return p.queueOverlay(samples)
+}
+
+func (p *Playback) queueOverlay(samples []int16) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("playback closed")
+	}
+	if p.failed != nil {
+		return p.failed
+	}
+	if (len(p.overlay)-p.overlayHead+len(samples))*2 > p.limit {
+		p.rejected++
+		return ErrQueueFull
+	}
+	p.overlay = append(p.overlay, samples...)
+	p.cond.Broadcast()
 	return nil
 }
 
+// write scales frame by the volume, limits it and plays it. The echo
+// canceller gets the processed frame, since that is what the microphone
+// will hear.
+func (p *Playback) write(frame []int16) error {
+	if v := p.Volume(); v < 1 || p.limiter != nil {
+		for i, s := range frame {
+			x := float64(s) * v
+			if p.limiter != nil {
+				x = p.limiter.apply(x)
+			}
+			frame[i] = clampInt16(x)
+		}
+	}
+
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	if p.out == nil {
+		return errors.New("playback closed")
+	}
+	if p.aec != nil {
+		ref := frame
+		if p.channels > 1 {
+			p.aecMono = slices.Grow(p.aecMono[:0], p.frameSize)[:len(frame)/p.channels]
+			downmix(p.aecMono, frame, p.channels, MixChannels)
+			ref = p.aecMono
+		}
+		if p.aecRs != nil {
+			p.aecBuf = p.aecRs.Process(p.aecBuf[:0], ref)
+			ref = p.aecBuf
+		}
+		p.aec.FeedReference(ref)
+	}
+	err := p.out.Write(frame)
+	p.playedUntil.Store(time.Now().Add(p.out.Latency()).UnixNano())
+	return err
+}
+
+// Flush marks the end of a response: the writer pads out the last partial
+// frame instead of waiting for more. A chunk that ended mid-sample is cut
+// back to whole samples, so the next response starts aligned. It doesn't
+// wait; Drain does.
 func (p *Playback) Flush() error {
-	defer p.speaking.Store(false)
-	if len(p.pending) < 2 {
-		p.pending = p.pending[:0]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resetSource()
+	if rem := (len(p.queue) - p.head) % (2 * p.channels); rem > 0 {
+		p.queue = p.queue[:len(p.queue)-rem]
+	}
+	if p.stopped || (p.head == len(p.queue) && !p.writing) {
+		p.speaking.Store(false)
+		p.endTiming()
+		p.closeDone(true)
 		return nil
 	}
+	p.flushing = true
+	p.cond.Broadcast()
+	return nil
+}
 
-	samples := len(p.pending) / 2
-	for i := 0; i < samples; i++ {
-		p.frameBuf[i] = int16(binary.LittleEndian.Uint16(p.pending[i*2:]))
+// Drain flushes and then blocks until everything queued has been played,
+// or ctx is done: written to the device, and the device's output latency
+// waited out, so closing right after doesn't cut off the last word.
+func (p *Playback) Drain(ctx context.Context) error {
+	if err := p.drainQueue(ctx); err != nil {
+		return err
 	}
-	for i := samples; i < p.frameSize; i++ {
-		p.frameBuf[i] = 0
+
+	var latency time.Duration
+	p.outMu.Lock()
+	if p.out != nil {
+		latency = p.out.Latency()
 	}
-	p.pending = p.pending[:0]
+	p.outMu.Unlock()
 
-	if p.aec != nil {
-		resampled := Resample24to16(p.frameBuf)
-		p.aec.FeedReference(resampled)
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
+
+func (p *Playback) drainQueue(ctx context.Context) error {
+	p.Flush()
+	stop := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.cond.Broadcast()
+	})
+	defer stop()
 
-	if err := p.stream.Write(); err != nil {
-		if !strings.Contains(err.Error(), "Output underflowed") {
-			return fmt.Errorf("writing playback stream: %w", err)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for !p.closed && p.failed == nil && (p.head < len(p.queue) || p.overlayHead < len(p.overlay) || p.writing || p.stopFading) {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		p.cond.Wait()
 	}
+	return p.failed
+}
 
-	return nil
+// Stop silences playback right away, from any goroutine: queued audio,
+// overlays and audio the device hasn't played yet are dropped, a pause is
+// ended, and Play discards everything until Reset. With WithPlaybackFades
+// the device instead plays out what it holds, followed by a short fade, so
+// the cut doesn't click.
+func (p *Playback) Stop() {
+	p.mu.Lock()
+	fade := p.fades && !p.paused.Load() && (p.head < len(p.queue) || p.writing)
+	if fade {
+		p.fadeOut(p.fadeBuf)
+		p.stopFading = true
+	}
+	p.stopped = true
+	p.paused.Store(false)
+	p.flushing = false
+	p.queue, p.head = p.queue[:0], 0
+	p.overlay, p.overlayHead = p.overlay[:0], 0
+	p.resetSource()
+	p.speaking.Store(false)
+	p.endTiming()
+	p.closeDone(false)
+	p.noteActivity()
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	// The writer ends the response once the fade has been written.
+	if fade {
+		return
+	}
+	p.outMu.Lock()
+	if p.out != nil {
+		p.out.Discard()
+	}
+	p.outMu.Unlock()
+	p.endResponse()
 }
 
+// Reset readies playback for the next response after Stop. Call it once
+// the goroutine feeding Play has returned, or late chunks of the stopped
+// response play as the start of the next.
 func (p *Playback) Reset() {
-	p.pending = p.pending[:0]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = false
+	p.paused.Store(false)
+	p.flushing = false
+	p.queue, p.head = p.queue[:0], 0
+	p.resetSource()
 	p.speaking.Store(false)
+	p.endTiming()
+	p.closeDone(false)
+	p.noteActivity()
 }
 
+// Close stops the writer, dropping anything still queued; Drain first to
+// let it play out. An OnActive callback that was told true is told false.
 func (p *Playback) Close() error {
-	if p.stream != nil {
-		p.stream.Stop()
-		p.stream.Close()
+	p.mu.Lock()
+	p.closed = true
+	p.closeDone(false)
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	<-p.done
+	p.active.close()
+	p.metrics.Unregister()
+
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	if p.out != nil {
+		p.out.Close()
+		p.out = nil
+		return p.backend.release()
 	}
 	return nil
 }
@@ -0,0 +1,852 @@
+package audio
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// playToFile plays chunks as one response through the file backend and
+// returns what reached the device, read back from the WAV it wrote.
+func playToFile(t *testing.T, chunks [][]byte, opts ...Option) (samples []int16, rate, channels int) {
+	t.Helper()
+	dir := t.TempDir()
+	p, err := NewPlayback(nil, append([]Option{WithBackend(BackendFile), WithPlaybackDir(dir)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks {
+		if err := p.Play(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("playback wrote %d files, want one", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm, rate, channels, _, err := DecodeWAV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples = make([]int16, len(pcm)/2)
+	DecodeInt16LE(samples, pcm)
+	return samples, rate, channels
+}
+
+// split cuts data into chunks of n bytes, the last one shorter.
+func split(data []byte, n int) [][]byte {
+	var out [][]byte
+	for len(data) > n {
+		out = append(out, data[:n])
+		data = data[n:]
+	}
+	return append(out, data)
+}
+
+func TestPlaybackResampling(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+	}{
+		{"16k to 48k", 16000, 48000},
+		{"24k to 44.1k", 24000, 44100},
+		{"no resampling", 24000, 24000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const amp = 10000
+			pcm := AppendInt16LE(nil, sine(1000, amp, tt.from, tt.from)) // one second
+			// Chunks of an odd size split samples, as a websocket's do.
+			got, rate, channels := playToFile(t, split(pcm, 4801),
+				WithPlaybackSourceRate(tt.from), WithPlaybackSampleRate(tt.to))
+
+			if rate != tt.to || channels != 1 {
+				t.Fatalf("wrote %d Hz with %d channels, want %d Hz mono", rate, channels, tt.to)
+			}
+			// The last frame is padded out with silence.
+			frame := tt.to / 10
+			if len(got)%frame != 0 || len(got) < tt.to-1 || len(got) > tt.to+frame {
+				t.Errorf("wrote %d samples for one second, want %d padded to a %d sample frame", len(got), tt.to, frame)
+			}
+			steady := got[tt.to/10 : tt.to*9/10]
+			if a := toneAmplitude(steady, 1000, tt.to); a < amp*0.98 || a > amp*1.02 {
+				t.Errorf("1 kHz tone came out at %.0f, want %d", a, amp)
+			}
+		})
+	}
+}
+
+func TestPlaybackChunkSplitting(t *testing.T) {
+	pcm := AppendInt16LE(nil, sine(440, 8000, 24000, 24000))
+	opts := []Option{WithPlaybackSourceRate(24000), WithPlaybackSampleRate(44100)}
+
+	whole, _, _ := playToFile(t, [][]byte{pcm}, opts...)
+	for _, n := range []int{1, 3, 1001} {
+		got, _, _ := playToFile(t, split(pcm, n), opts...)
+		if !slices.Equal(got, whole) {
+			t.Errorf("played in %d byte chunks, the output differs from playing it in one", n)
+		}
+	}
+}
+
+func TestChannelResampler(t *testing.T) {
+	const (
+		amp         = 10000
+		left, right = 500.0, 1500.0
+	)
+	l, r := sine(left, amp, 24000, 24000), sine(right, amp, 24000, 24000)
+	in := make([]int16, 0, 2*len(l))
+	for i := range l {
+		in = append(in, l[i], r[i])
+	}
+
+	cr := newChannelResampler(24000, 48000, 2)
+	var out []int16
+	for off := 0; off < len(in); off += 2 * 480 {
+		out = cr.Process(out, in[off:min(off+2*480, len(in))])
+	}
+	if len(out)%2 != 0 {
+		t.Fatalf("got %d samples, not whole stereo frames", len(out))
+	}
+	var gotL, gotR []int16
+	for i := 0; i < len(out); i += 2 {
+		gotL = append(gotL, out[i])
+		gotR = append(gotR, out[i+1])
+	}
+	if len(gotL) < 48000-1 || len(gotL) > 48000+1 {
+		t.Errorf("got %d frames for one second, want 48000", len(gotL))
+	}
+
+	tests := []struct {
+		name        string
+		samples     []int16
+		want, other float64
+	}{
+		{"left", gotL, left, right},
+		{"right", gotR, right, left},
+	}
+	for _, tt := range tests {
+		steady := tt.samples[4800:]
+		if a := toneAmplitude(steady, tt.want, 48000); a < amp*0.98 || a > amp*1.02 {
+			t.Errorf("%s: %.0f Hz came out at %.0f, want %d", tt.name, tt.want, a, amp)
+		}
+		if a := toneAmplitude(steady, tt.other, 48000); a > amp*0.01 {
+			t.Errorf("%s: %.0f Hz from the other channel at %.0f, want under 1%%", tt.name, tt.other, a)
+		}
+	}
+}
+
+// recordOutput is an output that keeps what is written to it. Writes
+// numbered in underflows report ErrUnderflow, and onWrite, if set, is
+// called with each write's number once it is recorded.
+type recordOutput struct {
+	mu         sync.Mutex
+	frames     [][]int16
+	underflows map[int]bool
+	onWrite    func(n int)
+	discards   int
+	pauses     int
+	resumes    int
+	closed     bool
+}
+
+func (o *recordOutput) Write(frame []int16) error {
+	o.mu.Lock()
+	n := len(o.frames)
+	o.frames = append(o.frames, slices.Clone(frame))
+	underflow := o.underflows[n]
+	o.mu.Unlock()
+	if o.onWrite != nil {
+		o.onWrite(n)
+	}
+	if underflow {
+		return ErrUnderflow
+	}
+	return nil
+}
+
+// count reads one of o's counters.
+func (o *recordOutput) count(n *int) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return *n
+}
+
+// written returns a copy of the frames written so far.
+func (o *recordOutput) written() [][]int16 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.frames)
+}
+
+func (o *recordOutput) Discard() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.discards++
+}
+
+func (o *recordOutput) Latency() time.Duration { return 0 }
+
+func (o *recordOutput) Pause() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pauses++
+	return nil
+}
+
+func (o *recordOutput) Resume() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.resumes++
+	return nil
+}
+
+func (o *recordOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	return nil
+}
+
+func TestResampledOutput(t *testing.T) {
+	const (
+		channels = 2
+		dstFrame = 960 // 20 ms at 48 kHz
+	)
+	rec := &recordOutput{underflows: map[int]bool{0: true}}
+	out := newResampledOutput(rec, 24000, 48000, dstFrame, channels)
+
+	// 10 ms at 24 kHz, so it takes two writes to fill a device frame.
+	chunk := make([]int16, 240*channels)
+	var underflowed bool
+	for range 10 {
+		err := out.Write(chunk)
+		if err == ErrUnderflow {
+			underflowed = true
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !underflowed {
+		t.Error("the device's underflow wasn't reported")
+	}
+	if len(rec.frames) != 5 {
+		t.Errorf("wrote %d frames for 100 ms, want 5", len(rec.frames))
+	}
+	for i, f := range rec.frames {
+		if len(f) != dstFrame*channels {
+			t.Errorf("frame %d has %d samples, want %d", i, len(f), dstFrame*channels)
+		}
+	}
+
+	// Discard drops the partial frame still pending along with the device's.
+	out.Write(chunk)
+	out.Discard()
+	if rec.discards != 1 {
+		t.Errorf("Discard reached the device %d times, want once", rec.discards)
+	}
+	out.Write(chunk)
+	if len(rec.frames) != 5 {
+		t.Errorf("half a frame after Discard made %d frames, want none new", len(rec.frames)-5)
+	}
+
+	if err := out.Close(); err != nil || !rec.closed {
+		t.Error("Close didn't close the device")
+	}
+}
+
+// newTestPlayback returns a Playback that writes to a recordOutput in place
+// of a device. Set the output's fields before the first Play.
+func newTestPlayback(t *testing.T, opts ...Option) (*Playback, *recordOutput) {
+	t.Helper()
+	p, err := NewPlayback(nil, append([]Option{WithBackend(BackendNull)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordOutput{}
+	p.outMu.Lock()
+	p.out = rec
+	p.outMu.Unlock()
+	t.Cleanup(func() { p.Close() })
+	return p, rec
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func drain(t *testing.T, p *Playback) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlaybackPauseResume(t *testing.T) {
+	const frame = PlaybackSampleRate / 10
+	// Each sample holds its index, so any skip or repeat shows.
+	samples := make([]int16, 10*frame)
+	for i := range samples {
+		samples[i] = int16(i + 1)
+	}
+	p, rec := newTestPlayback(t)
+	pausedAt := make(chan struct{})
+	rec.onWrite = func(n int) {
+		if n == 1 {
+			p.Pause()
+			close(pausedAt)
+		}
+	}
+
+	if err := p.Play(AppendInt16LE(nil, samples)); err != nil {
+		t.Fatal(err)
+	}
+	<-pausedAt
+	waitFor(t, "the device to stop", func() bool { return rec.count(&rec.pauses) == 1 })
+	if p.Speaking() || !p.Paused() {
+		t.Errorf("paused: Speaking() = %v, Paused() = %v", p.Speaking(), p.Paused())
+	}
+	// Drain waits for a Resume, however long.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Drain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Drain while paused = %v, want it to wait", err)
+	}
+	if err := p.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, p)
+
+	frames := rec.written()
+	if len(frames) != 11 {
+		t.Fatalf("wrote %d frames, want the 10 queued and a fade out", len(frames))
+	}
+	if rec.resumes != 1 {
+		t.Errorf("device resumed %d times, want once", rec.resumes)
+	}
+
+	// The fade out starts at the next frame and reaches silence within
+	// pauseFadeMs.
+	fade, fadeLen := frames[2], PlaybackSampleRate*pauseFadeMs/1000
+	if fade[0] == 0 || fade[0] > samples[2*frame] {
+		t.Errorf("fade out starts at %d, want just under %d", fade[0], samples[2*frame])
+	}
+	for i, s := range fade[fadeLen-1:] {
+		if s != 0 {
+			t.Fatalf("fade out sample %d = %d, want silence", fadeLen-1+i, s)
+		}
+	}
+
+	// Resume picks up at the frame the pause held, fading back in.
+	got := slices.Concat(append(frames[:2:2], frames[3:]...)...)
+	resumed := 2 * frame
+	for i, s := range got {
+		if i >= resumed && i < resumed+fadeLen {
+			if s < 0 || s > samples[i] {
+				t.Fatalf("fade in sample %d = %d, want 0 to %d", i, s, samples[i])
+			}
+			continue
+		}
+		if s != samples[i] {
+			t.Fatalf("sample %d = %d, want %d", i, s, samples[i])
+		}
+	}
+}
+
+func TestPlaybackPauseStates(t *testing.T) {
+	pcm := make([]byte, PlaybackSampleRate/10*2)
+
+	t.Run("resume without pause", func(t *testing.T) {
+		p, _ := newTestPlayback(t)
+		if err := p.Resume(); err != ErrNotPaused {
+			t.Errorf("Resume = %v, want ErrNotPaused", err)
+		}
+	})
+
+	t.Run("play while paused", func(t *testing.T) {
+		p, rec := pausedPlayback(t)
+		if err := p.Play(pcm); err != nil {
+			t.Fatalf("Play while paused = %v, want it queued", err)
+		}
+		if p.Speaking() {
+			t.Error("Speaking() while paused, want false")
+		}
+		if q := p.Stats().QueuedBytes; q != len(pcm) {
+			t.Errorf("%d bytes queued while paused, want %d", q, len(pcm))
+		}
+		if err := p.Resume(); err != nil {
+			t.Fatal(err)
+		}
+		drain(t, p)
+		if q := p.Stats().QueuedBytes; q != 0 {
+			t.Errorf("%d bytes left after Resume and Drain, want none", q)
+		}
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		if rec.pauses != 1 || rec.resumes != 1 {
+			t.Errorf("device paused %d and resumed %d times, want once each", rec.pauses, rec.resumes)
+		}
+	})
+
+	t.Run("stop while paused", func(t *testing.T) {
+		p, _ := newTestPlayback(t)
+		p.Pause()
+		p.Play(pcm)
+		done := p.Done()
+		p.Stop()
+		if p.Paused() {
+			t.Error("still paused after Stop")
+		}
+		if q := p.Stats().QueuedBytes; q != 0 {
+			t.Errorf("Stop left %d bytes queued", q)
+		}
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("Done wasn't closed by Stop")
+		}
+		if err := p.Resume(); err != ErrNotPaused {
+			t.Errorf("Resume after Stop = %v, want ErrNotPaused", err)
+		}
+	})
+
+	t.Run("pause after close", func(t *testing.T) {
+		p, _ := newTestPlayback(t)
+		p.Close()
+		if err := p.Pause(); err == nil {
+			t.Error("Pause after Close succeeded, want an error")
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		p, _ := newTestPlayback(t)
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 50 {
+					switch i % 4 {
+					case 0:
+						p.Pause()
+					case 1:
+						p.Resume()
+					case 2:
+						p.Play(pcm)
+					case 3:
+						p.Speaking()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		p.Stop()
+		p.Reset()
+		if err := p.Play(pcm); err != nil {
+			t.Fatal(err)
+		}
+		drain(t, p)
+	})
+}
+
+// pausedPlayback returns a paused test Playback whose device has stopped,
+// so what is queued next starts on a frame boundary at Resume.
+func pausedPlayback(t *testing.T, opts ...Option) (*Playback, *recordOutput) {
+	t.Helper()
+	p, rec := newTestPlayback(t, opts...)
+	if err := p.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the device to stop", func() bool { return rec.count(&rec.pauses) == 1 })
+	return p, rec
+}
+
+func TestPlaybackOverlay(t *testing.T) {
+	const rate = PlaybackSampleRate
+	tests := []struct {
+		name string
+		amp  float64
+	}{
+		{"mixed", 10000},
+		// Where the sum passes full scale it saturates instead of wrapping.
+		{"clipping", 25000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			speech := sine(440, tt.amp, rate, rate)
+			chime := sine(1250, tt.amp, rate, rate/2)
+			p, rec := pausedPlayback(t)
+			if err := p.Play(AppendInt16LE(nil, speech)); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.PlayOverlay(AppendInt16LE(nil, chime)); err != nil {
+				t.Fatal(err)
+			}
+			p.Resume()
+			drain(t, p)
+
+			// The first frame is the silent fade out of the pause, and
+			// Resume fades the next back in.
+			got := slices.Concat(rec.written()[1:]...)
+			if len(got) != len(speech) {
+				t.Fatalf("wrote %d samples, want %d", len(got), len(speech))
+			}
+			for i := rate * pauseFadeMs / 1000; i < len(got); i++ {
+				want := speech[i]
+				if i < len(chime) {
+					want = clampInt16(float64(speech[i]) + float64(chime[i]))
+				}
+				if got[i] != want {
+					t.Fatalf("sample %d = %d, want %d", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaybackOverlayAlone(t *testing.T) {
+	const frame = PlaybackSampleRate / 10
+	chime := sine(1250, 10000, PlaybackSampleRate, frame*3/2)
+	p, rec := newTestPlayback(t)
+	if err := p.PlayOverlay(AppendInt16LE(nil, chime)); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, p)
+
+	if p.Speaking() {
+		t.Error("an overlay alone counts as speaking")
+	}
+	// It goes out on its own, the last frame padded with silence.
+	got := slices.Concat(rec.written()...)
+	if len(got) != 2*frame {
+		t.Fatalf("wrote %d samples, want two frames", len(got))
+	}
+	if !slices.Equal(got[:len(chime)], chime) {
+		t.Error("the overlay changed on its own")
+	}
+	for i, s := range got[len(chime):] {
+		if s != 0 {
+			t.Fatalf("padding sample %d = %d, want silence", i, s)
+		}
+	}
+}
+
+func TestPlaybackChannels(t *testing.T) {
+	const rate = PlaybackSampleRate
+	mono := sine(440, 10000, rate, rate/4)
+	stereo := make([]int16, 0, 2*len(mono))
+	for i, s := range mono {
+		stereo = append(stereo, s, int16(i))
+	}
+
+	tests := []struct {
+		name     string
+		in       []int16
+		srcChans int
+		channels int
+		want     []int16
+	}{
+		{"mono to stereo", mono, 1, 2, upmix(nil, mono, 2)},
+		{"mono to four", mono, 1, 4, upmix(nil, mono, 4)},
+		{"stereo to stereo", stereo, 2, 2, stereo},
+		{"mono to mono", mono, 1, 1, mono},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, channels := playToFile(t, split(AppendInt16LE(nil, tt.in), 999),
+				WithPlaybackSourceChannels(tt.srcChans), WithPlaybackChannels(tt.channels))
+			if channels != tt.channels {
+				t.Fatalf("wrote %d channels, want %d", channels, tt.channels)
+			}
+			// The last frame is padded with silence on every channel.
+			frame := rate / 10 * tt.channels
+			if len(got)%frame != 0 {
+				t.Errorf("wrote %d samples, not whole %d sample frames", len(got), frame)
+			}
+			if !slices.Equal(got[:len(tt.want)], tt.want) {
+				t.Error("output isn't the input on every channel")
+			}
+			for i, s := range got[len(tt.want):] {
+				if s != 0 {
+					t.Fatalf("padding sample %d = %d, want silence", i, s)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaybackChannelsResampled(t *testing.T) {
+	got, _, _ := playToFile(t, [][]byte{AppendInt16LE(nil, sine(440, 10000, 16000, 16000))},
+		WithPlaybackSourceRate(16000), WithPlaybackSampleRate(48000), WithPlaybackChannels(2))
+	for i := 0; i < len(got); i += 2 {
+		if got[i] != got[i+1] {
+			t.Fatalf("frame %d: left %d, right %d, want mono on both", i/2, got[i], got[i+1])
+		}
+	}
+}
+
+func TestPlaybackChannelsInvalid(t *testing.T) {
+	tests := []struct {
+		name               string
+		srcChans, channels int
+	}{
+		{"no channels", 1, 0},
+		{"stereo onto four", 2, 4},
+		{"stereo onto mono", 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPlayback(nil, WithBackend(BackendNull),
+				WithPlaybackSourceChannels(tt.srcChans), WithPlaybackChannels(tt.channels))
+			if err == nil {
+				t.Error("NewPlayback succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestPlaybackFlushCutsPartialFrame(t *testing.T) {
+	// Five stereo frames and half of a sixth.
+	stereo := []int16{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	p, rec := newTestPlayback(t, WithPlaybackSourceChannels(2), WithPlaybackChannels(2))
+	if err := p.Play(AppendInt16LE(nil, stereo)); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, p)
+
+	got := slices.Concat(rec.written()...)
+	if !slices.Equal(got[:10], stereo[:10]) {
+		t.Errorf("wrote %v, want %v", got[:10], stereo[:10])
+	}
+	if got[10] != 0 {
+		t.Errorf("the half frame was played as %d, want it cut", got[10])
+	}
+}
+
+func TestPlaybackOverlayStereo(t *testing.T) {
+	const frame = PlaybackSampleRate / 10
+	chime := sine(1250, 10000, PlaybackSampleRate, frame)
+	p, rec := newTestPlayback(t, WithPlaybackChannels(2))
+	if err := p.PlayOverlay(AppendInt16LE(nil, chime)); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, p)
+
+	if got := slices.Concat(rec.written()...); !slices.Equal(got, upmix(nil, chime, 2)) {
+		t.Error("the overlay isn't on both channels")
+	}
+}
+
+func TestPlaybackResponseBoundary(t *testing.T) {
+	const rate = PlaybackSampleRate
+	clean := sine(440, 10000, rate, rate)
+	// A stream that broke off 1001 bytes in, half way through a sample.
+	broken := AppendInt16LE(nil, sine(1250, 10000, rate, rate))[:1001]
+
+	tests := []struct {
+		name     string
+		to       int
+		channels int
+	}{
+		{"as is", rate, 1},
+		{"resampled", 48000, 1},
+		{"upmixed", rate, 2},
+	}
+	ends := []struct {
+		name string
+		end  func(t *testing.T, p *Playback)
+	}{
+		{"flushed", func(t *testing.T, p *Playback) { drain(t, p) }},
+		{"stopped", func(t *testing.T, p *Playback) { p.Stop(); p.Reset() }},
+	}
+	for _, tt := range tests {
+		for _, e := range ends {
+			t.Run(tt.name+" "+e.name, func(t *testing.T) {
+				want := clean
+				if tt.to != rate {
+					want = NewResampler(rate, tt.to).Process(nil, want)
+				}
+				want = upmix(nil, want, tt.channels)
+
+				p, rec := newTestPlayback(t, WithPlaybackSampleRate(tt.to), WithPlaybackChannels(tt.channels))
+				if err := p.Play(broken); err != nil {
+					t.Fatal(err)
+				}
+				e.end(t, p)
+				before := len(rec.written())
+
+				if err := p.Play(AppendInt16LE(nil, clean)); err != nil {
+					t.Fatal(err)
+				}
+				drain(t, p)
+
+				// The next response starts on a frame of its own with
+				// nothing of the broken one in it.
+				got := slices.Concat(rec.written()[before:]...)
+				if len(got) < len(want) {
+					t.Fatalf("wrote %d samples, want at least %d", len(got), len(want))
+				}
+				for i := range want {
+					if got[i] != want[i] {
+						t.Fatalf("sample %d = %d, want %d", i, got[i], want[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestPlaybackProcessingOff(t *testing.T) {
+	// Without fades or a limiter, silence and full scale noise both reach
+	// the device bit for bit.
+	r := rand.New(rand.NewPCG(1, 2))
+	in := append(make([]int16, PlaybackSampleRate/10), noise(r, 20000, PlaybackSampleRate/2)...)
+	got, _, _ := playToFile(t, [][]byte{AppendInt16LE(nil, in)})
+	if len(got) != len(in) {
+		t.Fatalf("wrote %d samples, want %d", len(got), len(in))
+	}
+	if !slices.Equal(got, in) {
+		t.Error("output differs from the input with processing off")
+	}
+}
+
+// constant returns n samples of v.
+func constant(v int16, n int) []int16 {
+	s := make([]int16, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+func TestPlaybackFadeIn(t *testing.T) {
+	const (
+		frame   = PlaybackSampleRate / 10
+		fadeLen = PlaybackSampleRate * pauseFadeMs / 1000
+		level   = 10000
+	)
+	p, rec := newTestPlayback(t, WithPlaybackFades(true))
+	// Each response fades in on its first frame and only there.
+	for range 2 {
+		before := len(rec.written())
+		if err := p.Play(AppendInt16LE(nil, constant(level, 2*frame))); err != nil {
+			t.Fatal(err)
+		}
+		drain(t, p)
+
+		got := slices.Concat(rec.written()[before:]...)
+		for i, s := range got {
+			want := int16(level)
+			if i < fadeLen {
+				want = int16(float64(level) * float64(i+1) / fadeLen)
+			}
+			if s != want {
+				t.Fatalf("sample %d = %d, want %d", i, s, want)
+			}
+		}
+	}
+}
+
+func TestPlaybackStopFade(t *testing.T) {
+	const (
+		frame   = PlaybackSampleRate / 10
+		fadeLen = PlaybackSampleRate * pauseFadeMs / 1000
+		level   = 10000
+	)
+	p, rec := newTestPlayback(t, WithPlaybackFades(true))
+	// Hold the writer in the first frame's write while Stop is called.
+	writing, release := make(chan struct{}), make(chan struct{})
+	rec.onWrite = func(n int) {
+		if n == 0 {
+			close(writing)
+			<-release
+		}
+	}
+	if err := p.Play(AppendInt16LE(nil, constant(level, 10*frame))); err != nil {
+		t.Fatal(err)
+	}
+	<-writing
+	p.Stop()
+	close(release)
+	drain(t, p)
+
+	frames := rec.written()
+	if len(frames) != 2 {
+		t.Fatalf("wrote %d frames, want the first and a fade out", len(frames))
+	}
+	if n := rec.count(&rec.discards); n != 0 {
+		t.Errorf("Stop discarded the device's audio %d times, want it played out", n)
+	}
+	for i, s := range frames[1] {
+		want := int16(0)
+		if i < fadeLen {
+			want = int16(float64(level) * (1 - float64(i+1)/fadeLen))
+		}
+		if s != want {
+			t.Fatalf("fade out sample %d = %d, want %d", i, s, want)
+		}
+	}
+}
+
+func TestPlaybackLimiter(t *testing.T) {
+	const ceiling = 0.5
+	tests := []struct {
+		name string
+		amp  float64
+		// unchanged is whether the tone is quiet enough to pass the
+		// limiter untouched.
+		unchanged bool
+	}{
+		{"below the knee", ceiling * limiterKnee * math.MaxInt16 * 0.9, true},
+		{"full scale", math.MaxInt16, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := sine(440, tt.amp, PlaybackSampleRate, PlaybackSampleRate/2)
+			got, _, _ := playToFile(t, [][]byte{AppendInt16LE(nil, in)}, WithPlaybackLimiter(ceiling))
+			if len(got) != len(in) {
+				t.Fatalf("wrote %d samples, want %d with no added latency", len(got), len(in))
+			}
+			if tt.unchanged && !slices.Equal(got, in) {
+				t.Error("the limiter changed a tone below its knee")
+			}
+			for i, s := range got {
+				if math.Abs(float64(s)) > ceiling*math.MaxInt16 {
+					t.Fatalf("sample %d = %d, over the %.0f ceiling", i, s, ceiling*math.MaxInt16)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package audio
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	recorderPrefix = "utterance-"
+	recorderQueue  = 8
+)
+
+// Recorder writes utterances to WAV files for debugging transcriptions. Files
+// are written on their own goroutine so a slow disk never holds up capture,
+// and the oldest files are removed once either limit is exceeded.
+type Recorder struct {
+	dir      string
+	maxFiles int
+	maxBytes int64
+	queue    chan Utterance
+	wg       sync.WaitGroup
+}
+
+func NewRecorder(dir string, maxFiles int, maxBytes int64) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating debug dir: %w", err)
+	}
+	r := &Recorder{
+		dir:      dir,
+		maxFiles: maxFiles,
+		maxBytes: maxBytes,
+		queue:    make(chan Utterance, recorderQueue),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r, nil
+}
+
+// Save queues an utterance for writing and never blocks; utterances arriving
+// while the queue is full are dropped.
+func (r *Recorder) Save(u Utterance) {
+	select {
+	case r.queue <- u:
+	default:
+		slog.Warn("debug recorder busy, dropping utterance")
+	}
+}
+
+func (r *Recorder) Close() {
+	close(r.queue)
+	r.wg.Wait()
+}
+
+func (r *Recorder) run() {
+	defer r.wg.Done()
+	for u := range r.queue {
+		name := recorderPrefix + u.Start.Format("20060102-150405.000") + ".wav"
+		path := filepath.Join(r.dir, name)
+		if err := os.WriteFile(path, u.WAV(), 0o644); err != nil {
+			slog.Error("writing debug utterance", "path", path, "error", err)
+			continue
+		}
+		slog.Debug("saved debug utterance", "path", path, "duration", u.Duration)
+		if err := r.prune(); err != nil {
+			slog.Error("pruning debug utterances", "dir", r.dir, "error", err)
+		}
+	}
+}
+
+func (r *Recorder) prune() error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+
+	type file struct {
+		name string
+		size int64
+	}
+	var files []file
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), recorderPrefix) || !strings.HasSuffix(e.Name(), ".wav") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{name: e.Name(), size: info.Size()})
+		total += info.Size()
+	}
+	// Names embed the capture time, so lexical order is oldest first.
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+
+	for len(files) > 1 && ((r.maxFiles > 0 && len(files) > r.maxFiles) || (r.maxBytes > 0 && total > r.maxBytes)) {
+		if err := os.Remove(filepath.Join(r.dir, files[0].name)); err != nil {
+			return err
+		}
+		total -= files[0].size
+		files = files[1:]
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package audio
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const resampleTapsPerPhase = 32
+
+// Resampler is a streaming rational polyphase resampler (windowed sinc
+// lowpass), used to bring devices and streams that don't run at the rate
+// the pipeline needs to it. It keeps its filter history across calls, so
+// frames can be fed in one at a time without edge artifacts.
+type Resampler struct {
+	up     int
+	down   int
+	coeffs [][]float64
+	buf    []float64
+	t      int
+	phase  int
+}
+
+func NewResampler(fromRate, toRate int) *Resampler {
+	g := gcd(fromRate, toRate)
+	up, down := toRate/g, fromRate/g
+
+	n := up * resampleTapsPerPhase
+	// Cut off a little below the lower Nyquist so the transition band
+	// doesn't alias back into the passband.
+	fc := 0.45 / float64(max(up, down))
+	center := float64(n-1) / 2
+
+	coeffs := make([][]float64, up)
+	for p := range coeffs {
+		coeffs[p] = make([]float64, resampleTapsPerPhase)
+	}
+	for i := 0; i < n; i++ {
+		x := float64(i) - center
+		h := 2 * fc
+		if x != 0 {
+			h = math.Sin(2*math.Pi*fc*x) / (math.Pi * x)
+		}
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1)) + 0.08*math.Cos(4*math.Pi*float64(i)/float64(n-1))
+		coeffs[i%up][i/up] = h * w * float64(up)
+	}
+
+	return &Resampler{
+		up:     up,
+		down:   down,
+		coeffs: coeffs,
+		buf:    make([]float64, resampleTapsPerPhase-1),
+	}
+}
+
+// Reset drops the filter history, for when the next input doesn't follow
+// on from the last.
+func (r *Resampler) Reset() {
+	clear(r.buf)
+	r.buf = r.buf[:resampleTapsPerPhase-1]
+	r.t, r.phase = 0, 0
+}
+
+// Process appends the resampled output for in to dst.
+func (r *Resampler) Process(dst, in []int16) []int16 {
+	hist := resampleTapsPerPhase - 1
+	r.buf = r.buf[:hist]
+	for _, s := range in {
+		r.buf = append(r.buf, float64(s))
+	}
+
+	for r.t < len(in) {
+		idx := hist + r.t
+		c := r.coeffs[r.phase]
+		var sum float64
+		for k, h := range c {
+			sum += h * r.buf[idx-k]
+		}
+		dst = append(dst, clampInt16(sum))
+
+		r.phase += r.down
+		r.t += r.phase / r.up
+		r.phase %= r.up
+	}
+
+	r.t -= len(in)
+	copy(r.buf, r.buf[len(r.buf)-hist:])
+	return dst
+}
+
+// resampledInput adapts a stream running at the device rate to fixed size
+// frames at the configured rate.
+type resampledInput struct {
+	src     Source
+	rs      *Resampler
+	in      []int16
+	pending []int16
+}
+
+func newResampledInput(src Source, fromRate, toRate, srcFrameSize int) *resampledInput {
+	return &resampledInput{
+		src: src,
+		rs:  NewResampler(fromRate, toRate),
+		in:  make([]int16, srcFrameSize),
+	}
+}
+
+func (r *resampledInput) ReadFrame(frame []int16) error {
+	for len(r.pending) < len(frame) {
+		if err := r.src.ReadFrame(r.in); err != nil {
+			return err
+		}
+		r.pending = r.rs.Process(r.pending, r.in)
+	}
+	copy(frame, r.pending)
+	r.pending = r.pending[:copy(r.pending, r.pending[len(frame):])]
+	return nil
+}
+
+func (r *resampledInput) Close() error {
+	return r.src.Close()
+}
+
+// channelResampler runs a Resampler per channel over interleaved audio.
+// The channels advance in lockstep, so each produces the same number of
+// samples per call.
+type channelResampler struct {
+	rs      []*Resampler
+	in, out [][]int16
+}
+
+func newChannelResampler(fromRate, toRate, channels int) *channelResampler {
+	c := &channelResampler{
+		rs:  make([]*Resampler, channels),
+		in:  make([][]int16, channels),
+		out: make([][]int16, channels),
+	}
+	// The filter is the same for every channel; only the history differs.
+	base := NewResampler(fromRate, toRate)
+	for ch := range c.rs {
+		r := *base
+		r.buf = make([]float64, resampleTapsPerPhase-1)
+		c.rs[ch] = &r
+	}
+	return c
+}
+
+// Process appends the resampled output for in, which must hold whole
+// sample frames, to dst.
+func (c *channelResampler) Process(dst, in []int16) []int16 {
+	n := len(c.rs)
+	if n == 1 {
+		return c.rs[0].Process(dst, in)
+	}
+	for ch, r := range c.rs {
+		c.in[ch] = c.in[ch][:0]
+		for i := ch; i < len(in); i += n {
+			c.in[ch] = append(c.in[ch], in[i])
+		}
+		c.out[ch] = r.Process(c.out[ch][:0], c.in[ch])
+	}
+	for i := range c.out[0] {
+		for ch := range c.rs {
+			dst = append(dst, c.out[ch][i])
+		}
+	}
+	return dst
+}
+
+func (c *channelResampler) Reset() {
+	for _, r := range c.rs {
+		r.Reset()
+	}
+}
+
+// resampledOutput is the playback counterpart of resampledInput, for
+// output devices that don't support the playback rate.
+type resampledOutput struct {
+	out     output
+	rs      *channelResampler
+	frame   int
+	pending []int16
+}
+
+// newResampledOutput writes to out in frames of dstFrameSize samples per
+// channel.
+func newResampledOutput(out output, fromRate, toRate, dstFrameSize, channels int) *resampledOutput {
+	return &resampledOutput{
+		out:   out,
+		rs:    newChannelResampler(fromRate, toRate, channels),
+		frame: dstFrameSize * channels,
+	}
+}
+
+func (r *resampledOutput) Write(frame []int16) error {
+	r.pending = r.rs.Process(r.pending, frame)
+	off := 0
+	defer func() {
+		r.pending = r.pending[:copy(r.pending, r.pending[off:])]
+	}()
+	// An underflowed frame was still played, so keep going and report it
+	// once the rest is written.
+	var underflow error
+	for len(r.pending)-off >= r.frame {
+		err := r.out.Write(r.pending[off : off+r.frame])
+		if errors.Is(err, ErrUnderflow) {
+			underflow = err
+		} else if err != nil {
+			return err
+		}
+		off += r.frame
+	}
+	return underflow
+}
+
+func (r *resampledOutput) Discard() {
+	r.pending = r.pending[:0]
+	r.out.Discard()
+}
+
+func (r *resampledOutput) Latency() time.Duration {
+	return r.out.Latency()
+}
+
+func (r *resampledOutput) Pause() error {
+	return r.out.Pause()
+}
+
+func (r *resampledOutput) Resume() error {
+	return r.out.Resume()
+}
+
+func (r *resampledOutput) Close() error {
+	return r.out.Close()
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,143 @@
+package audio
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+// toneAmplitude returns the amplitude of the freq Hz component of samples.
+func toneAmplitude(samples []int16, freq float64, rate int) float64 {
+	var re, im float64
+	for i, s := range samples {
+		a := 2 * math.Pi * freq * float64(i) / float64(rate)
+		re += float64(s) * math.Cos(a)
+		im += float64(s) * math.Sin(a)
+	}
+	return 2 * math.Hypot(re, im) / float64(len(samples))
+}
+
+func TestResampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+	}{
+		{"48k to 16k", 48000, 16000},
+		{"44.1k to 16k", 44100, 16000},
+		{"16k to 48k", 16000, 48000},
+		{"16k to 24k", 16000, 24000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const amp = 10000
+			in := sine(1000, amp, tt.from, tt.from) // one second
+			out := NewResampler(tt.from, tt.to).Process(nil, in)
+
+			if len(out) < tt.to-1 || len(out) > tt.to+1 {
+				t.Errorf("got %d samples for one second, want %d", len(out), tt.to)
+			}
+			// Skip the filter's start-up before measuring.
+			steady := out[tt.to/10:]
+			if got := toneAmplitude(steady, 1000, tt.to); math.Abs(got-amp)/amp > 0.02 {
+				t.Errorf("1 kHz tone came out at %.0f, want %d", got, amp)
+			}
+		})
+	}
+}
+
+func TestResamplerRejectsAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		freq     float64
+	}{
+		{"48k to 16k", 48000, 16000, 14000},
+		{"44.1k to 16k", 44100, 16000, 12000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const amp = 10000
+			out := NewResampler(tt.from, tt.to).Process(nil, sine(tt.freq, amp, tt.from, tt.from))
+			// A tone above the new Nyquist folds back to |to - freq|.
+			alias := math.Abs(float64(tt.to) - tt.freq)
+			if got := toneAmplitude(out[tt.to/10:], alias, tt.to); got > amp*0.01 {
+				t.Errorf("%.0f Hz tone aliased to %.0f Hz at %.0f, want under 1%%", tt.freq, alias, got)
+			}
+		})
+	}
+}
+
+func TestResamplerStreaming(t *testing.T) {
+	in := sine(440, 8000, 44100, 44100)
+	whole := NewResampler(44100, 16000).Process(nil, in)
+
+	rs := NewResampler(44100, 16000)
+	var framed []int16
+	for off := 0; off < len(in); off += 441 {
+		framed = rs.Process(framed, in[off:min(off+441, len(in))])
+	}
+	if len(framed) != len(whole) {
+		t.Fatalf("frame by frame gave %d samples, in one go %d", len(framed), len(whole))
+	}
+	for i := range whole {
+		if framed[i] != whole[i] {
+			t.Fatalf("sample %d: frame by frame %d, in one go %d", i, framed[i], whole[i])
+		}
+	}
+
+	rs.Reset()
+	again := rs.Process(nil, in)
+	if len(again) != len(whole) || again[100] != whole[100] {
+		t.Error("Reset didn't start the resampler over")
+	}
+}
+
+// sliceSource plays samples in frames of whatever size is asked for, then
+// returns io.EOF.
+type sliceSource struct {
+	samples []int16
+	closed  bool
+}
+
+func (s *sliceSource) ReadFrame(frame []int16) error {
+	if len(s.samples) < len(frame) {
+		return io.EOF
+	}
+	copy(frame, s.samples)
+	s.samples = s.samples[len(frame):]
+	return nil
+}
+
+func (s *sliceSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestResampledInput(t *testing.T) {
+	src := &sliceSource{samples: sine(1000, 10000, 48000, 48000)}
+	in := newResampledInput(src, 48000, 16000, 1440)
+
+	var out []int16
+	frame := make([]int16, 480)
+	for {
+		err := in.ReadFrame(frame)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, frame...)
+	}
+	// Each whole 30 ms device frame becomes one 30 ms frame at 16 kHz.
+	if want := 48000 / 1440 * 480; len(out) != want {
+		t.Errorf("got %d samples at 16 kHz, want %d", len(out), want)
+	}
+	if got := toneAmplitude(out[1600:], 1000, 16000); math.Abs(got-10000)/10000 > 0.02 {
+		t.Errorf("1 kHz tone came out at %.0f, want 10000", got)
+	}
+
+	if err := in.Close(); err != nil || !src.closed {
+		t.Error("Close didn't close the device stream")
+	}
+}
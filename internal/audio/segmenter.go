@@ -0,0 +1,247 @@
+package audio
+
+import (
+	"log/slog"
+	"time"
+)
+
+// segmenter turns a stream of VAD-labelled frames into utterances. It is
+// fed one frame at a time from the capture goroutine and keeps no other
+// state, so it doesn't care where the frames came from.
+//
+// Both edges vote over a sliding window so single spurious frames don't
+// flip state: speech starts when onsetFrames of the last onsetWindow frames
+// are voiced, and ends when silenceFrames of the last offsetWindow frames
+// are not.
+type segmenter struct {
+	silenceFrames int
+	onsetFrames   int
+	maxBytes      int
+	frameBytes    int
+	trailingPad   int
+	sampleRate    int
+
+	onset  *frameVotes
+	offset *frameVotes
+
+	ring      *ringBuffer
+	utterance []byte
+	speaking  bool
+
+	start         time.Time
+	speechStart   time.Time
+	preRoll       int
+	trailing      int
+	peakRMS       float64
+	clipped       bool
+	activeFrames  int
+	droppedFrames int
+}
+
+func newSegmenter(o options) *segmenter {
+	frameBytes := o.sampleRate * o.frameDurationMs / 1000 * 2
+	maxBytes := 0
+	if o.maxUtteranceMs > 0 {
+		maxBytes = o.maxUtteranceMs / o.frameDurationMs * frameBytes
+	}
+	trailingPad := -1
+	if o.trailingPadMs >= 0 {
+		trailingPad = o.trailingPadMs * o.sampleRate / 1000 * 2
+	}
+	onsetWindow := max(o.vadOnsetWindow, o.minActiveFrames)
+	return &segmenter{
+		silenceFrames: o.silenceFrames,
+		onsetFrames:   o.minActiveFrames,
+		maxBytes:      maxBytes,
+		frameBytes:    frameBytes,
+		trailingPad:   trailingPad,
+		sampleRate:    o.sampleRate,
+		onset:         newFrameVotes(onsetWindow),
+		offset:        newFrameVotes(o.silenceFrames + onsetWindow - o.minActiveFrames),
+		ring:          newRingBuffer(o.preBufferFrames),
+	}
+}
+
+func (s *segmenter) Speaking() bool {
+	return s.speaking
+}
+
+// Dropped records a frame lost to a read error while speech is in progress.
+func (s *segmenter) Dropped() {
+	if s.speaking {
+		s.droppedFrames++
+	}
+}
+
+// Push adds a frame captured at now and returns a finished utterance, or nil
+// while one is still being collected.
+func (s *segmenter) Push(frame []byte, active bool, now time.Time) *Utterance {
+	if !s.speaking {
+		s.ring.Push(frame)
+		s.onset.Push(active, now)
+		if s.onset.Count() < s.onsetFrames {
+			return nil
+		}
+
+		slog.Info("speech started")
+		s.speaking = true
+		s.utterance = s.ring.Drain()
+		s.activeFrames = s.onset.Count()
+		age, at := s.onset.First()
+		s.preRoll = max(len(s.utterance)-age*len(frame), 0)
+		s.speechStart = at
+		s.start = now.Add(-s.duration(len(s.utterance) - len(frame)))
+		for i := 0; i+s.frameBytes <= len(s.utterance); i += s.frameBytes {
+			s.observe(s.utterance[i : i+s.frameBytes])
+		}
+		s.onset.Reset()
+		s.offset.Reset()
+		return nil
+	}
+
+	s.utterance = append(s.utterance, frame...)
+	s.observe(frame)
+	s.offset.Push(!active, now)
+	if active {
+		s.activeFrames++
+		s.trailing = 0
+	} else {
+		s.trailing += len(frame)
+		if s.offset.Count() >= s.silenceFrames {
+			slog.Info("speech ended")
+			return s.finish(now)
+		}
+	}
+
+	if s.maxBytes > 0 && len(s.utterance) >= s.maxBytes {
+		slog.Info("speech cut at max utterance length", "bytes", len(s.utterance))
+		return s.finish(now)
+	}
+	return nil
+}
+
+// Flush ends the utterance in progress, if any, when the input runs out.
+func (s *segmenter) Flush(now time.Time) *Utterance {
+	if !s.speaking {
+		return nil
+	}
+	slog.Info("speech ended at end of input")
+	return s.finish(now)
+}
+
+func (s *segmenter) Reset() {
+	s.clear()
+	s.ring.Drain()
+}
+
+func (s *segmenter) observe(frame []byte) {
+	rms, clipped := bytesStats(frame)
+	s.peakRMS = max(s.peakRMS, rms)
+	s.clipped = s.clipped || clipped
+}
+
+func (s *segmenter) finish(now time.Time) *Utterance {
+	frameDuration := s.duration(s.frameBytes)
+	end := now.Add(frameDuration)
+
+	// Most of the hangover is silence by construction, and STT time grows
+	// with the audio sent, so only a short pad of it is kept.
+	if s.trailingPad >= 0 && s.trailing > s.trailingPad {
+		cut := s.trailing - s.trailingPad
+		s.utterance = s.utterance[:len(s.utterance)-cut]
+		s.trailing = s.trailingPad
+		end = end.Add(-s.duration(cut))
+	}
+
+	u := &Utterance{
+		PCM:             s.utterance,
+		SampleRate:      s.sampleRate,
+		Start:           s.start,
+		End:             end,
+		Duration:        s.duration(len(s.utterance)),
+		SpeechDuration:  time.Duration(s.activeFrames) * frameDuration,
+		LeadingSilence:  s.duration(s.preRoll),
+		TrailingSilence: s.duration(s.trailing),
+		PeakRMS:         s.peakRMS,
+		Clipped:         s.clipped,
+		TriggerFrames:   s.activeFrames,
+		DroppedFrames:   s.droppedFrames,
+		SpeechStart:     s.speechStart,
+		SpeechEnd:       now,
+		Hangover:        time.Duration(s.silenceFrames) * frameDuration,
+	}
+	s.clear()
+	return u
+}
+
+func (s *segmenter) clear() {
+	s.utterance = nil
+	s.speaking = false
+	s.onset.Reset()
+	s.offset.Reset()
+	s.speechStart = time.Time{}
+	s.preRoll = 0
+	s.trailing = 0
+	s.peakRMS = 0
+	s.clipped = false
+	s.activeFrames = 0
+	s.droppedFrames = 0
+}
+
+func (s *segmenter) duration(n int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(s.sampleRate*2)
+}
+
+// frameVotes counts how many of the last n frames voted yes.
+type frameVotes struct {
+	votes []bool
+	at    []time.Time
+	pos   int
+	len   int
+	count int
+}
+
+func newFrameVotes(n int) *frameVotes {
+	n = max(n, 1)
+	return &frameVotes{votes: make([]bool, n), at: make([]time.Time, n)}
+}
+
+func (v *frameVotes) Push(yes bool, at time.Time) {
+	if v.len == len(v.votes) {
+		if v.votes[v.pos] {
+			v.count--
+		}
+	} else {
+		v.len++
+	}
+	v.votes[v.pos] = yes
+	v.at[v.pos] = at
+	if yes {
+		v.count++
+	}
+	v.pos = (v.pos + 1) % len(v.votes)
+}
+
+func (v *frameVotes) Count() int {
+	return v.count
+}
+
+// First returns how many frames ago, inclusive, the oldest yes vote in the
+// window was cast, and when.
+func (v *frameVotes) First() (int, time.Time) {
+	for age := v.len; age > 0; age-- {
+		i := (v.pos - age + len(v.votes)) % len(v.votes)
+		if v.votes[i] {
+			return age, v.at[i]
+		}
+	}
+	return 0, time.Time{}
+}
+
+func (v *frameVotes) Reset() {
+	clear(v.votes)
+	clear(v.at)
+	v.pos = 0
+	v.len = 0
+	v.count = 0
+}
@@ -0,0 +1,251 @@
+package audio
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrameVotes(t *testing.T) {
+	v := newFrameVotes(3)
+	t0 := time.Unix(0, 0)
+	at := func(i int) time.Time { return t0.Add(time.Duration(i) * 30 * time.Millisecond) }
+
+	steps := []struct {
+		yes       bool
+		wantCount int
+		wantAge   int
+	}{
+		{false, 0, 0},
+		{true, 1, 1},
+		{true, 2, 2},
+		{false, 2, 3},
+		// The first yes falls out of the window.
+		{false, 1, 3},
+		{false, 0, 0},
+	}
+	for i, s := range steps {
+		v.Push(s.yes, at(i))
+		if got := v.Count(); got != s.wantCount {
+			t.Errorf("step %d: Count = %d, want %d", i, got, s.wantCount)
+		}
+		age, when := v.First()
+		if age != s.wantAge {
+			t.Errorf("step %d: First age = %d, want %d", i, age, s.wantAge)
+		}
+		if age > 0 && !when.Equal(at(i-age+1)) {
+			t.Errorf("step %d: First at frame %v, want frame %d", i, when.Sub(t0)/(30*time.Millisecond), i-age+1)
+		}
+	}
+
+	v.Reset()
+	if age, _ := v.First(); v.Count() != 0 || age != 0 {
+		t.Error("Reset left votes behind")
+	}
+}
+
+// segments runs pattern through s, one frame per rune with 'x' voiced, and
+// returns the frame each utterance started and ended on.
+func segments(s *segmenter, pattern string) [][2]int {
+	frame := make([]byte, s.frameBytes)
+	t0 := time.Unix(0, 0)
+	var out [][2]int
+	start := -1
+	for i, c := range pattern {
+		u := s.Push(frame, c == 'x', t0.Add(time.Duration(i)*30*time.Millisecond))
+		if start < 0 && s.Speaking() {
+			start = i
+		}
+		if u != nil {
+			out = append(out, [2]int{start, i})
+			start = -1
+		}
+	}
+	return out
+}
+
+func TestSegmenterVoting(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    [][2]int
+	}{
+		// Onset needs 3 voiced frames out of the last 5.
+		{"isolated blips", "x..x..x..x..x..", nil},
+		{"consecutive onset", "..xxx" + "....", [][2]int{{4, 8}}},
+		{"spread onset", "x.x.x" + "....", [][2]int{{4, 8}}},
+		// Offset needs 4 unvoiced frames out of the last 6.
+		{"blip in the hangover", "xxx" + "..x..." + "..", [][2]int{{2, 7}}},
+		// Gaps in the last window count toward the offset.
+		{"choppy speech holds", "xxx" + "x.x.x.x.x.x." + "....", [][2]int{{2, 15}}},
+		{"two utterances", "xxx...." + "xxx....", [][2]int{{2, 6}, {9, 13}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultOptions()
+			o.minActiveFrames = 3
+			o.vadOnsetWindow = 5
+			o.silenceFrames = 4
+			s := newSegmenter(o)
+			if got := segments(s, tt.pattern); !slices.Equal(got, tt.want) {
+				t.Errorf("utterances %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmenterUtterance(t *testing.T) {
+	o := defaultOptions()
+	o.minActiveFrames = 2
+	o.vadOnsetWindow = 2
+	o.silenceFrames = 3
+	o.preBufferFrames = 4
+	o.trailingPadMs = 30
+	s := newSegmenter(o)
+
+	// Frames carry their index so the utterance can be checked against them.
+	t0 := time.Unix(0, 0)
+	var u *Utterance
+	for i, c := range "......xxxx..." {
+		frame := make([]byte, s.frameBytes)
+		frame[0] = byte(i)
+		if got := s.Push(frame, c == 'x', t0.Add(time.Duration(i)*30*time.Millisecond)); got != nil {
+			u = got
+		}
+	}
+	if u == nil {
+		t.Fatal("no utterance")
+	}
+
+	var frames []int
+	for off := 0; off < len(u.PCM); off += s.frameBytes {
+		frames = append(frames, int(u.PCM[off]))
+	}
+	// Four frames of pre-buffer ending on the second voiced one, the rest
+	// of the speech and one frame of trailing pad.
+	if want := []int{4, 5, 6, 7, 8, 9, 10}; !slices.Equal(frames, want) {
+		t.Errorf("utterance holds frames %v, want %v", frames, want)
+	}
+	if u.TriggerFrames != 4 {
+		t.Errorf("TriggerFrames = %d, want 4", u.TriggerFrames)
+	}
+	if u.LeadingSilence != 60*time.Millisecond || u.TrailingSilence != 30*time.Millisecond {
+		t.Errorf("leading %s and trailing %s silence, want 60ms and 30ms", u.LeadingSilence, u.TrailingSilence)
+	}
+	if want := t0.Add(6 * 30 * time.Millisecond); !u.SpeechStart.Equal(want) {
+		t.Errorf("SpeechStart = %v, want the first voiced frame", u.SpeechStart.Sub(t0))
+	}
+
+	if s.Speaking() || s.Flush(t0) != nil {
+		t.Error("segmenter still speaking after the utterance ended")
+	}
+}
+
+func TestSegmenterTrailingPad(t *testing.T) {
+	const silence = 10 // frames of hangover
+	tests := []struct {
+		name  string
+		padMs int
+		// want is how much of the hangover is kept.
+		want time.Duration
+	}{
+		{"no pad", 0, 0},
+		{"default pad", DefaultTrailingPadMs, 150 * time.Millisecond},
+		{"pad within a frame", 45, 45 * time.Millisecond},
+		{"pad longer than the hangover", 1000, silence * 30 * time.Millisecond},
+		{"trimming off", -1, silence * 30 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultOptions()
+			o.minActiveFrames = 1
+			o.vadOnsetWindow = 1
+			o.silenceFrames = silence
+			o.preBufferFrames = 1
+			o.trailingPadMs = tt.padMs
+			s := newSegmenter(o)
+
+			frame := make([]byte, s.frameBytes)
+			t0 := time.Unix(0, 0)
+			var u *Utterance
+			for i, c := range "xxxxx" + strings.Repeat(".", silence) {
+				if got := s.Push(frame, c == 'x', t0.Add(time.Duration(i)*30*time.Millisecond)); got != nil {
+					u = got
+				}
+			}
+			if u == nil {
+				t.Fatal("no utterance")
+			}
+
+			voiced := 5 * 30 * time.Millisecond
+			if got := u.Duration - voiced; got != tt.want {
+				t.Errorf("PCM runs %s past the last voiced frame, want %s", got, tt.want)
+			}
+			if u.TrailingSilence != tt.want {
+				t.Errorf("TrailingSilence = %s, want %s", u.TrailingSilence, tt.want)
+			}
+			if got := u.End.Sub(u.Start); got != u.Duration {
+				t.Errorf("End - Start = %s, want the duration %s", got, u.Duration)
+			}
+			if got := u.Hangover; got != silence*30*time.Millisecond {
+				t.Errorf("Hangover = %s, want the configured wait", got)
+			}
+		})
+	}
+}
+
+func TestSegmenterMaxUtterance(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxMs   int
+		pattern string
+		want    [][2]int
+	}{
+		{"off", 0, "xxxxxxxxx..", [][2]int{{0, 10}}},
+		// Speech that goes on is cut every three frames, each cut starting
+		// a new utterance on the next voiced frame.
+		{"cut", 90, "xxxxxxxxx..", [][2]int{{0, 2}, {3, 5}, {6, 8}}},
+		{"rounded down to whole frames", 100, "xxxxxx..", [][2]int{{0, 2}, {3, 5}}},
+		// The frame speech starts on is never cut off on its own.
+		{"one frame", 30, "xxxx..", [][2]int{{0, 1}, {2, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultOptions()
+			o.minActiveFrames = 1
+			o.vadOnsetWindow = 1
+			o.silenceFrames = 2
+			o.preBufferFrames = 1
+			o.maxUtteranceMs = tt.maxMs
+			if err := o.resolve(); err != nil {
+				t.Fatal(err)
+			}
+			s := newSegmenter(o)
+			if got := segments(s, tt.pattern); !slices.Equal(got, tt.want) {
+				t.Errorf("utterances %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveMaxUtterance(t *testing.T) {
+	tests := []struct {
+		ms      int
+		wantErr bool
+	}{
+		{0, false},
+		{-1, false},
+		{1, true},
+		{29, true},
+		{30, false},
+		{DefaultMaxUtteranceMs, false},
+	}
+	for _, tt := range tests {
+		o := defaultOptions()
+		o.maxUtteranceMs = tt.ms
+		if err := o.resolve(); (err != nil) != tt.wantErr {
+			t.Errorf("max utterance %dms: err = %v, want error %v", tt.ms, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SinkConfig names an output device, such as the speaker in one room.
+type SinkConfig struct {
+	Name   string
+	Device string
+}
+
+// ParseSinks reads comma-separated name:device pairs, as in
+// office:hw:1,kitchen:hw:2. The device is everything after the first
+// colon, so ALSA names keep theirs, and is matched like WithOutputDevice:
+// a part without commas, such as hw:2, is enough.
+func ParseSinks(s string) ([]SinkConfig, error) {
+	var sinks []SinkConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, device, ok := strings.Cut(entry, ":")
+		name, device = strings.TrimSpace(name), strings.TrimSpace(device)
+		if !ok || name == "" || device == "" {
+			return nil, fmt.Errorf("invalid playback sink %q, expected name:device", entry)
+		}
+		sinks = append(sinks, SinkConfig{Name: name, Device: device})
+	}
+	return sinks, nil
+}
+
+// Sinks looks up named Playbacks, so a response can be played where it
+// was asked for. Each sink keeps its own volume and stats. A nil *Sinks
+// has no sinks.
+type Sinks struct {
+	mu     sync.RWMutex
+	byName map[string]*Playback
+	names  []string
+}
+
+func NewSinks() *Sinks {
+	return &Sinks{byName: make(map[string]*Playback)}
+}
+
+// Add registers p under name. The Sinks close it from then on.
+func (s *Sinks) Add(name string, p *Playback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byName[name]; ok {
+		return fmt.Errorf("duplicate playback sink %q", name)
+	}
+	s.byName[name] = p
+	s.names = append(s.names, name)
+	return nil
+}
+
+// Get returns the sink called name, or nil if there is none.
+func (s *Sinks) Get(name string) *Playback {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byName[name]
+}
+
+// Names returns the sinks' names in the order they were added.
+func (s *Sinks) Names() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.names...)
+}
+
+// Close closes every sink, even when some fail, and forgets them.
+func (s *Sinks) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, name := range s.names {
+		if err := s.byName[name].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing playback sink %s: %w", name, err))
+		}
+	}
+	s.byName = make(map[string]*Playback)
+	s.names = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,146 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// FileSource replays a WAV or raw PCM file, or stdin, as capture frames. Raw
+// input is taken to be mono 16-bit little-endian at the capture rate; WAV
+// files at other rates are resampled.
+type FileSource struct {
+	r        io.Reader
+	closer   io.Closer
+	buf      []byte
+	realtime bool
+	frameDur time.Duration
+	started  time.Time
+	frames   int
+	eof      bool
+}
+
+// OpenFileSource opens path, or stdin for "-". With realtime set, frames are
+// paced at the rate they would arrive from a microphone; otherwise they are
+// read as fast as the pipeline consumes them.
+func OpenFileSource(path string, sampleRate, frameDurationMs int, realtime bool) (Source, error) {
+	var rc io.ReadCloser = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening audio file: %w", err)
+		}
+		rc = f
+	}
+
+	br := bufio.NewReader(rc)
+	rate := sampleRate
+	if head, err := br.Peek(4); err == nil && string(head) == "RIFF" {
+		r, err := ReadWAVHeader(br)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		rate = r
+	}
+
+	fs := &FileSource{
+		r:        br,
+		closer:   rc,
+		realtime: realtime,
+		frameDur: time.Duration(frameDurationMs) * time.Millisecond,
+	}
+	if rate == sampleRate {
+		return fs, nil
+	}
+	srcFrame := rate * frameDurationMs / 1000
+	return newResampledInput(fs, rate, sampleRate, srcFrame), nil
+}
+
+// ReadWAVHeader skips to the data chunk and returns the sample rate. Only
+// mono 16-bit PCM is accepted.
+func ReadWAVHeader(r io.Reader) (int, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return 0, fmt.Errorf("reading wav header: %w", err)
+	}
+	if string(riff[8:12]) != "WAVE" {
+		return 0, fmt.Errorf("not a wav file")
+	}
+
+	rate := 0
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return 0, fmt.Errorf("reading wav chunk: %w", err)
+		}
+		id := string(hdr[:4])
+		size := int64(binary.LittleEndian.Uint32(hdr[4:]))
+
+		switch id {
+		case "fmt ":
+			fmtChunk := make([]byte, size)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return 0, fmt.Errorf("reading wav format: %w", err)
+			}
+			f, err := parseWAVFormat(fmtChunk)
+			if err != nil {
+				return 0, err
+			}
+			if f.channels != 1 || f.bits != 16 {
+				return 0, fmt.Errorf("unsupported wav with %d channels, %d bits, need mono 16-bit pcm", f.channels, f.bits)
+			}
+			rate = f.sampleRate
+		case "data":
+			if rate == 0 {
+				return 0, fmt.Errorf("wav data before format chunk")
+			}
+			return rate, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return 0, fmt.Errorf("skipping wav chunk %q: %w", id, err)
+			}
+		}
+	}
+}
+
+func (f *FileSource) ReadFrame(frame []int16) error {
+	if f.eof {
+		return io.EOF
+	}
+	if f.realtime {
+		if f.started.IsZero() {
+			f.started = time.Now()
+		}
+		time.Sleep(time.Until(f.started.Add(time.Duration(f.frames) * f.frameDur)))
+	}
+
+	if cap(f.buf) < len(frame)*2 {
+		f.buf = make([]byte, len(frame)*2)
+	}
+	buf := f.buf[:len(frame)*2]
+	n, err := io.ReadFull(f.r, buf)
+	switch {
+	case errors.Is(err, io.EOF):
+		f.eof = true
+		return io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		// Pad the final partial frame with silence.
+		f.eof = true
+		clear(buf[n:])
+	case err != nil:
+		return fmt.Errorf("reading audio file: %w", err)
+	}
+
+	DecodeInt16LE(frame, buf)
+	f.frames++
+	return nil
+}
+
+func (f *FileSource) Close() error {
+	return f.closer.Close()
+}
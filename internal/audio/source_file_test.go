@@ -0,0 +1,168 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeFile writes data to a file in a fresh temp dir and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readAll reads src frame by frame until io.EOF.
+func readAll(t *testing.T, src Source, frameSize int) []int16 {
+	t.Helper()
+	var out []int16
+	frame := make([]int16, frameSize)
+	for {
+		err := src.ReadFrame(frame)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, frame...)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	samples := sine(1000, 10000, 16000, 1000)
+	pcm := AppendInt16LE(nil, samples)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		// The last partial frame is padded out with silence.
+		{"raw", pcm, 3 * 480},
+		{"wav", EncodeWAV(pcm, 16000, 1, 16), 3 * 480},
+		// 1000 samples at 8 kHz are five 240 sample frames, the last padded.
+		{"wav at 8 kHz", EncodeWAV(pcm, 8000, 1, 16), 5 * 480},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := OpenFileSource(writeFile(t, "in", tt.data), 16000, 30, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+
+			got := readAll(t, src, 480)
+			if len(got) != tt.want {
+				t.Fatalf("read %d samples, want %d", len(got), tt.want)
+			}
+			if tt.name != "wav at 8 kHz" {
+				if !slices.Equal(got[:len(samples)], samples) {
+					t.Error("samples changed on the way through")
+				}
+				for i, s := range got[len(samples):] {
+					if s != 0 {
+						t.Fatalf("padding sample %d = %d, want silence", i, s)
+					}
+				}
+			}
+			if err := src.ReadFrame(make([]int16, 480)); err != io.EOF {
+				t.Errorf("ReadFrame after the end = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestFileSourceRejectsStereo(t *testing.T) {
+	wav := EncodeWAV(make([]byte, 3840), 16000, 2, 16)
+	if _, err := OpenFileSource(writeFile(t, "in.wav", wav), 16000, 30, false); err == nil {
+		t.Error("opened a stereo wav, want an error")
+	}
+}
+
+func TestFileSourceRealtime(t *testing.T) {
+	pcm := make([]byte, 4*480*2)
+	src, err := OpenFileSource(writeFile(t, "in", pcm), 16000, 30, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	start := time.Now()
+	readAll(t, src, 480)
+	// The first frame goes out at once, each later one 30 ms after it.
+	if took := time.Since(start); took < 90*time.Millisecond {
+		t.Errorf("four frames took %s in real time, want at least 90ms", took)
+	}
+}
+
+// The fixture is background noise around two voiced stretches, laid out
+// on 30 ms frame boundaries.
+const (
+	fixtureRate  = 16000
+	fixtureFrame = 480
+)
+
+var fixtureSpeech = []struct{ start, end int }{
+	{16 * fixtureFrame, 36 * fixtureFrame},
+	{60 * fixtureFrame, 77 * fixtureFrame},
+}
+
+func TestFileSourceUtterances(t *testing.T) {
+	const path = "testdata/two_utterances.wav"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _, _, _, err := DecodeWAV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := OpenFileSource(path, fixtureRate, 30, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(nil, WithSource(src), WithVAD(VADEnergy), WithVADMode(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got := collectUtterances(t, c)
+	if len(got) != len(fixtureSpeech) {
+		t.Fatalf("got %d utterances, want %d", len(got), len(fixtureSpeech))
+	}
+	o := defaultOptions()
+	for i, u := range got {
+		// Find where the utterance sits in the file.
+		at := bytes.Index(all, u.PCM)
+		if at < 0 || at%2 != 0 {
+			t.Fatalf("utterance %d isn't a stretch of the file", i)
+		}
+		start, end := at/2, at/2+len(u.PCM)/2
+		speech := fixtureSpeech[i]
+
+		// It covers the speech, with no more than the pre-buffer ahead of
+		// it and the hangover and trailing pad after.
+		if start > speech.start || start < speech.start-(o.preBufferFrames+o.minActiveFrames)*fixtureFrame {
+			t.Errorf("utterance %d starts at frame %d, speech at %d", i, start/fixtureFrame, speech.start/fixtureFrame)
+		}
+		maxTail := (o.silenceFrames + 1) * fixtureFrame
+		maxTail += o.trailingPadMs * fixtureRate / 1000
+		if end < speech.end || end > speech.end+maxTail {
+			t.Errorf("utterance %d ends at frame %d, speech at %d", i, end/fixtureFrame, speech.end/fixtureFrame)
+		}
+		if u.LeadingSilence <= 0 || u.TrailingSilence <= 0 {
+			t.Errorf("utterance %d has leading silence %s and trailing %s, want both set", i, u.LeadingSilence, u.TrailingSilence)
+		}
+	}
+}
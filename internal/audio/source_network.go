@@ -0,0 +1,198 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultNetworkStallTimeout = 2 * time.Second
+
+	networkInitialBackoff = 250 * time.Millisecond
+	networkMaxBackoff     = 10 * time.Second
+	// networkMaxWait bounds how long one ReadFrame waits out a backoff, so
+	// capture's Close never waits on a sleeping source for long.
+	networkMaxWait = 500 * time.Millisecond
+)
+
+type NetworkSourceConfig struct {
+	// URL serves raw mono 16-bit PCM over HTTP, typically chunked.
+	URL        string
+	SampleRate int
+	BigEndian  bool
+	// StallTimeout is how long a connected stream may go without data
+	// before it is dropped and reconnected.
+	StallTimeout time.Duration
+}
+
+// NetworkSource reads a remote microphone, such as a satellite streaming
+// PCM over HTTP. It connects on the first read and reconnects with backoff
+// whenever the stream drops or stalls. Reads fail while it is down rather
+// than block, so capture reports the downtime and stays responsive.
+type NetworkSource struct {
+	cfg     NetworkSourceConfig
+	client  *http.Client
+	order   binary.ByteOrder
+	buf     []byte
+	backoff time.Duration
+	retryAt time.Time
+
+	mu     sync.Mutex
+	body   io.ReadCloser
+	stall  *time.Timer
+	closed bool
+}
+
+// NewNetworkSource returns a source for cfg delivering frames at the
+// capture sampleRate, resampling if the stream runs at another rate.
+func NewNetworkSource(cfg NetworkSourceConfig, sampleRate, frameDurationMs int) (Source, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing network source url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported network source scheme %q, expected http or https", u.Scheme)
+	}
+	if cfg.SampleRate <= 0 {
+		return nil, fmt.Errorf("invalid network source sample rate %d", cfg.SampleRate)
+	}
+	if cfg.StallTimeout <= 0 {
+		cfg.StallTimeout = DefaultNetworkStallTimeout
+	}
+
+	n := &NetworkSource{
+		cfg:   cfg,
+		order: binary.LittleEndian,
+		// No overall timeout: the response is a stream that never ends.
+		// Connecting and each read are bounded separately.
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: cfg.StallTimeout}).DialContext,
+			ResponseHeaderTimeout: cfg.StallTimeout,
+		}},
+	}
+	if cfg.BigEndian {
+		n.order = binary.BigEndian
+	}
+	if cfg.SampleRate == sampleRate {
+		return n, nil
+	}
+	return newResampledInput(n, cfg.SampleRate, sampleRate, cfg.SampleRate*frameDurationMs/1000), nil
+}
+
+func (n *NetworkSource) ReadFrame(frame []int16) error {
+	n.mu.Lock()
+	body := n.body
+	n.mu.Unlock()
+	if body == nil {
+		var err error
+		if body, err = n.connect(); err != nil {
+			return err
+		}
+	}
+
+	if cap(n.buf) < len(frame)*2 {
+		n.buf = make([]byte, len(frame)*2)
+	}
+	buf := n.buf[:len(frame)*2]
+	n.stall.Reset(n.cfg.StallTimeout)
+	_, err := io.ReadFull(body, buf)
+	stalled := !n.stall.Stop()
+	if err != nil {
+		n.disconnect()
+		if stalled {
+			return fmt.Errorf("network source stalled for %s", n.cfg.StallTimeout)
+		}
+		// A live stream has no end, and capture takes io.EOF to mean the
+		// source is exhausted, so it must not leak through.
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return errors.New("network source ended the stream")
+		}
+		return fmt.Errorf("reading network source: %w", err)
+	}
+
+	for i := range frame {
+		frame[i] = int16(n.order.Uint16(buf[i*2:]))
+	}
+	n.backoff = 0
+	return nil
+}
+
+// connect opens the stream once the backoff has passed. While it hasn't,
+// it waits at most networkMaxWait and then reports the source as down.
+func (n *NetworkSource) connect() (io.ReadCloser, error) {
+	if wait := time.Until(n.retryAt); wait > 0 {
+		time.Sleep(min(wait, networkMaxWait))
+		if time.Now().Before(n.retryAt) {
+			return nil, errors.New("network source down, waiting to reconnect")
+		}
+	}
+
+	resp, err := n.client.Get(n.cfg.URL)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err != nil {
+		n.backoff = min(max(n.backoff*2, networkInitialBackoff), networkMaxBackoff)
+		n.retryAt = time.Now().Add(n.backoff)
+		return nil, fmt.Errorf("connecting to network source: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		resp.Body.Close()
+		return nil, errors.New("network source closed")
+	}
+	body := resp.Body
+	n.body = body
+	// Closing the body is the only way to interrupt a blocked read, so a
+	// stall is turned into a read error that way.
+	n.stall = time.AfterFunc(n.cfg.StallTimeout, func() { body.Close() })
+	n.stall.Stop()
+	slog.Info("network source connected", "url", n.cfg.URL)
+	return body, nil
+}
+
+func (n *NetworkSource) disconnect() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.body != nil {
+		n.body.Close()
+		n.body = nil
+	}
+}
+
+// recovers marks NetworkSource as a source capture should wait on while it
+// reconnects, rather than give up after a run of failed reads.
+func (n *NetworkSource) recovers() {}
+
+func (n *NetworkSource) Close() error {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+	n.disconnect()
+	return nil
+}
+
+// recoveringSource is implemented by sources that reconnect on their own.
+type recoveringSource interface {
+	recovers()
+}
+
+func sourceRecovers(s Source) bool {
+	if r, ok := s.(*resampledInput); ok {
+		s = r.src
+	}
+	_, ok := s.(recoveringSource)
+	return ok
+}
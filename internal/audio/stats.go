@@ -0,0 +1,149 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Stats counts what capture has done since it was started.
+type Stats struct {
+	FramesRead        int64
+	VoicedFrames      int64
+	UtterancesEmitted int64
+	UtterancesDropped int64
+	ReadErrors        int64
+}
+
+// captureStats is written by the capture goroutine and read by Stats and the
+// metrics callback, hence the atomics.
+type captureStats struct {
+	framesRead atomic.Int64
+	voiced     atomic.Int64
+	emitted    atomic.Int64
+	dropped    atomic.Int64
+	readErrors atomic.Int64
+}
+
+func (s *captureStats) reset() {
+	s.framesRead.Store(0)
+	s.voiced.Store(0)
+	s.emitted.Store(0)
+	s.dropped.Store(0)
+	s.readErrors.Store(0)
+}
+
+func (s *captureStats) snapshot() Stats {
+	return Stats{
+		FramesRead:        s.framesRead.Load(),
+		VoicedFrames:      s.voiced.Load(),
+		UtterancesEmitted: s.emitted.Load(),
+		UtterancesDropped: s.dropped.Load(),
+		ReadErrors:        s.readErrors.Load(),
+	}
+}
+
+// Stats returns the counts since the capture was started. It is safe to call
+// while capture is running.
+func (c *Capture) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// registerMetrics exports the counters that don't already have a metric of
+// their own; dropped utterances are counted with their reason in discard.
+func (c *Capture) registerMetrics() error {
+	meter := otel.Meter("github.com/joakimcarlsson/smarthome/internal/audio")
+
+	framesRead, err := meter.Int64ObservableCounter("audio.frames.read",
+		metric.WithDescription("Frames read from the capture source"))
+	if err != nil {
+		return fmt.Errorf("creating frames read counter: %w", err)
+	}
+	voiced, err := meter.Int64ObservableCounter("audio.frames.voiced",
+		metric.WithDescription("Frames the VAD flagged as speech"))
+	if err != nil {
+		return fmt.Errorf("creating voiced frames counter: %w", err)
+	}
+	emitted, err := meter.Int64ObservableCounter("audio.utterances.emitted",
+		metric.WithDescription("Utterances handed to the consumer"))
+	if err != nil {
+		return fmt.Errorf("creating emitted utterance counter: %w", err)
+	}
+	readErrors, err := meter.Int64ObservableCounter("audio.read.errors",
+		metric.WithDescription("Failed reads from the capture source"))
+	if err != nil {
+		return fmt.Errorf("creating read error counter: %w", err)
+	}
+
+	c.metrics, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := c.Stats()
+		o.ObserveInt64(framesRead, s.FramesRead)
+		o.ObserveInt64(voiced, s.VoicedFrames)
+		o.ObserveInt64(emitted, s.UtterancesEmitted)
+		o.ObserveInt64(readErrors, s.ReadErrors)
+		return nil
+	}, framesRead, voiced, emitted, readErrors)
+	if err != nil {
+		return fmt.Errorf("registering capture metrics: %w", err)
+	}
+	return nil
+}
+
+// registerMetrics exports PlaybackStats, plus each response's time to first
+// write as a histogram, since the last one alone says little.
+func (p *Playback) registerMetrics() error {
+	meter := otel.Meter("github.com/joakimcarlsson/smarthome/internal/audio")
+
+	var err error
+	p.firstWriteHisto, err = meter.Float64Histogram("audio.playback.first_write",
+		metric.WithUnit("s"),
+		metric.WithDescription("Time from a response's first Play to its first frame reaching the device"))
+	if err != nil {
+		return fmt.Errorf("creating first write histogram: %w", err)
+	}
+	played, err := meter.Int64ObservableCounter("audio.playback.played",
+		metric.WithUnit("By"),
+		metric.WithDescription("Response audio written to the playback device"))
+	if err != nil {
+		return fmt.Errorf("creating played bytes counter: %w", err)
+	}
+	queued, err := meter.Int64ObservableGauge("audio.playback.queued",
+		metric.WithUnit("By"),
+		metric.WithDescription("Audio queued for the playback device"))
+	if err != nil {
+		return fmt.Errorf("creating queued bytes gauge: %w", err)
+	}
+	underflows, err := meter.Int64ObservableCounter("audio.playback.underflows",
+		metric.WithDescription("Frames that reached the playback device after it ran dry"))
+	if err != nil {
+		return fmt.Errorf("creating underflow counter: %w", err)
+	}
+	rejected, err := meter.Int64ObservableCounter("audio.playback.rejected",
+		metric.WithDescription("Chunks dropped because the playback queue was full"))
+	if err != nil {
+		return fmt.Errorf("creating rejected chunk counter: %w", err)
+	}
+
+	p.metrics, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := p.Stats()
+		attrs := p.metricAttrs()
+		o.ObserveInt64(played, s.PlayedBytes, attrs)
+		o.ObserveInt64(queued, int64(s.QueuedBytes), attrs)
+		o.ObserveInt64(underflows, s.Underflows, attrs)
+		o.ObserveInt64(rejected, s.Rejected, attrs)
+		return nil
+	}, played, queued, underflows, rejected)
+	if err != nil {
+		return fmt.Errorf("registering playback metrics: %w", err)
+	}
+	return nil
+}
+
+// metricAttrs tells sinks apart by name.
+func (p *Playback) metricAttrs() metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("sink", p.name))
+}
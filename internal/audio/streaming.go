@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"context"
+	"log/slog"
+)
+
+const DefaultStreamChunkMs = 500
+
+// Chunk is a piece of an utterance still being spoken, for feeding a
+// streaming STT endpoint before the speaker has finished.
+type Chunk struct {
+	PCM         []byte
+	UtteranceID int
+	// Final marks the last chunk of an utterance. Discarded is set on it
+	// when the utterance turned out to be noise or was cut off, and whatever
+	// was already sent for it should be thrown away.
+	Final     bool
+	Discarded bool
+}
+
+// StartStreaming is StartUtterances for streaming STT. It runs the same VAD
+// and segmenting but emits audio every WithStreamChunkMs of speech rather
+// than once the utterance has ended. Chunks are never dropped, so a slow
+// consumer stalls capture.
+func (c *Capture) StartStreaming(ctx context.Context) (<-chan Chunk, error) {
+	frameSize := c.opts.sampleRate * c.opts.frameDurationMs / 1000
+	buf := make([]int16, frameSize)
+
+	if err := c.openSource(); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Chunk, 16)
+	if c.opts.wakeWordAccessKey != "" && c.opts.wakeWordModelPath != "" {
+		c.wakeWordCh = make(chan struct{}, 1)
+	}
+	stream := &chunkStream{
+		ch:       ch,
+		minBytes: c.opts.streamChunkMs * c.opts.sampleRate / 1000 * 2,
+	}
+	c.run(ctx, buf, nil, stream)
+	return ch, nil
+}
+
+// chunkStream tracks how much of the segmenter's utterance has been sent.
+// A nil chunkStream does nothing, which is the batch path.
+type chunkStream struct {
+	ch       chan Chunk
+	minBytes int
+	id       int
+	sent     int
+}
+
+// progress sends whatever the utterance in progress has grown by, once that
+// is at least a chunk's worth.
+func (s *chunkStream) progress(ctx context.Context, seg *segmenter) {
+	if s == nil || !seg.Speaking() || len(seg.utterance)-s.sent < s.minBytes {
+		return
+	}
+	if s.sent == 0 {
+		s.id++
+	}
+	pcm := seg.utterance[s.sent:len(seg.utterance):len(seg.utterance)]
+	s.sent = len(seg.utterance)
+	s.send(ctx, Chunk{PCM: pcm, UtteranceID: s.id})
+}
+
+// finish sends the rest of u as its final chunk.
+func (s *chunkStream) finish(ctx context.Context, u *Utterance, discarded bool) {
+	if s == nil || (discarded && s.sent == 0) {
+		return
+	}
+	if s.sent == 0 {
+		s.id++
+	}
+	pcm := u.PCM[min(s.sent, len(u.PCM)):]
+	s.sent = 0
+	s.send(ctx, Chunk{PCM: pcm, UtteranceID: s.id, Final: true, Discarded: discarded})
+}
+
+// abort closes off an utterance the segmenter was reset in the middle of,
+// if the consumer has already seen part of it.
+func (s *chunkStream) abort(ctx context.Context) {
+	if s == nil || s.sent == 0 {
+		return
+	}
+	s.sent = 0
+	s.send(ctx, Chunk{UtteranceID: s.id, Final: true, Discarded: true})
+}
+
+func (s *chunkStream) send(ctx context.Context, c Chunk) {
+	select {
+	case s.ch <- c:
+	case <-ctx.Done():
+		slog.Debug("dropping chunk on shutdown", "utterance_id", c.UtteranceID)
+	}
+}
+
+func (s *chunkStream) close() {
+	if s != nil {
+		close(s.ch)
+	}
+}
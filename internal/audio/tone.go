@@ -0,0 +1,44 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// toneLevel keeps the test tone well below full scale, at -12 dBFS.
+	toneLevel  = 0.25
+	toneRampMs = 5
+)
+
+// TestTone plays a sine of freq Hz for d through the same queue as Play
+// and waits until it has played, to check that sound comes out. The tone
+// is made at the rate Play takes, so it goes through any resampling too.
+func (p *Playback) TestTone(ctx context.Context, freq float64, d time.Duration) error {
+	if freq <= 0 || freq >= float64(p.srcRate)/2 {
+		return fmt.Errorf("invalid test tone frequency %g Hz at %d Hz", freq, p.srcRate)
+	}
+	n := int(d.Seconds() * float64(p.srcRate))
+	ramp := min(p.srcRate*toneRampMs/1000, n/2)
+	samples := make([]int16, 0, n*p.srcChannels)
+	for i := range n {
+		g := toneLevel
+		// Ramping the ends keeps the tone from starting or stopping with
+		// a click.
+		if i < ramp {
+			g *= float64(i) / float64(ramp)
+		} else if i >= n-ramp {
+			g *= float64(n-1-i) / float64(ramp)
+		}
+		s := clampInt16(g * math.MaxInt16 * math.Sin(2*math.Pi*freq*float64(i)/float64(p.srcRate)))
+		for range p.srcChannels {
+			samples = append(samples, s)
+		}
+	}
+	if err := p.Play(AppendInt16LE(nil, samples)); err != nil {
+		return err
+	}
+	return p.Drain(ctx)
+}
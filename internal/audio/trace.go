@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/joakimcarlsson/smarthome/internal/audio")
+
+// utteranceSpan covers one utterance from its first voiced frame until it is
+// emitted or discarded, so traces show how much of a turn's latency is spent
+// before the audio ever leaves capture. The zero value has no span open.
+type utteranceSpan struct {
+	span trace.Span
+}
+
+func (t *utteranceSpan) start(ctx context.Context, at time.Time) {
+	t.end(nil, false)
+	_, t.span = tracer.Start(ctx, "capture.utterance", trace.WithTimestamp(at))
+	t.span.AddEvent("speech_start", trace.WithTimestamp(at))
+}
+
+// end closes the span. u is nil when the utterance was abandoned mid-way.
+func (t *utteranceSpan) end(u *Utterance, emitted bool) {
+	if t.span == nil {
+		return
+	}
+	if u != nil {
+		t.span.AddEvent("speech_end", trace.WithTimestamp(u.SpeechEnd))
+		t.span.SetAttributes(
+			attribute.Int64("audio.speech_ms", u.SpeechDuration.Milliseconds()),
+			attribute.Int64("audio.hangover_ms", u.Hangover.Milliseconds()),
+			attribute.Int64("audio.duration_ms", u.Duration.Milliseconds()),
+		)
+	}
+	// Emitted is stamped before the send, so ending now also shows any time
+	// spent waiting on a slow consumer.
+	end := time.Now()
+	if emitted {
+		t.span.AddEvent("utterance_emitted", trace.WithTimestamp(u.Emitted))
+	}
+	t.span.SetAttributes(attribute.Bool("audio.discarded", !emitted))
+	t.span.End(trace.WithTimestamp(end))
+	t.span = nil
+}
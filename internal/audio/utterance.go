@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Utterance is what capture emits for each stretch of speech. Fields are
+// only ever added, never renamed or repurposed, so consumers can rely on
+// them; a zero value means the producer doesn't know.
+type Utterance struct {
+	// PCM is mono 16-bit little-endian audio at SampleRate.
+	PCM        []byte
+	SampleRate int
+
+	// Start is when the first sample in PCM was captured, including the
+	// pre-buffer, and End when the last one was.
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+	// SpeechDuration covers only the frames the VAD flagged as speech.
+	SpeechDuration time.Duration
+	// LeadingSilence and TrailingSilence are how much of PCM is pre-buffer
+	// before speech onset and hangover after the last voiced frame, which
+	// is what a consumer would trim.
+	LeadingSilence  time.Duration
+	TrailingSilence time.Duration
+
+	// SpeechStart is when the first voiced frame was read, SpeechEnd when
+	// the frame that closed the utterance was, and Emitted when capture
+	// started handing it on. They carry monotonic readings, so differences between
+	// them are exact.
+	SpeechStart time.Time
+	SpeechEnd   time.Time
+	Emitted     time.Time
+	// Hangover is the silence capture is configured to wait out before
+	// ending an utterance, the fixed part of the delay after speech stops.
+	Hangover time.Duration
+
+	// PeakRMS is the loudest frame's RMS, in int16 sample units.
+	PeakRMS float64
+	// Clipped is set when any sample hit full scale.
+	Clipped bool
+	// TriggerFrames is how many frames the VAD flagged as speech.
+	TriggerFrames int
+	// DroppedFrames counts frames lost to read errors mid-utterance.
+	DroppedFrames int
+
+	// WakeWord is set for the first utterance after a wake word detection.
+	WakeWord bool
+	// Room identifies where the audio was captured, if configured.
+	Room string
+}
+
+func (u Utterance) WAV() []byte {
+	rate := u.SampleRate
+	if rate == 0 {
+		rate = DefaultSampleRate
+	}
+	return EncodeWAV(u.PCM, rate, 1, 16)
+}
+
+// PCMOnly adapts an utterance channel for consumers that only want audio.
+func PCMOnly(ctx context.Context, utterances <-chan Utterance) <-chan []byte {
+	ch := make(chan []byte, cap(utterances))
+	go func() {
+		defer close(ch)
+		for u := range utterances {
+			select {
+			case ch <- u.PCM:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// bytesStats returns a frame's RMS and whether any sample hit full scale.
+func bytesStats(frame []byte) (float64, bool) {
+	n := len(frame) / 2
+	if n == 0 {
+		return 0, false
+	}
+	var sum float64
+	clipped := false
+	for i := 0; i < n; i++ {
+		s := int16(binary.LittleEndian.Uint16(frame[i*2:]))
+		if s == math.MaxInt16 || s == math.MinInt16 {
+			clipped = true
+		}
+		v := float64(s)
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(n)), clipped
+}
@@ -0,0 +1,45 @@
+package audio
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+const (
+	VADWebRTC = "webrtc"
+	VADEnergy = "energy"
+	VADSilero = "silero"
+)
+
+type vadEngine interface {
+	Process(samples []int16) (bool, error)
+	Reset() error
+}
+
+func newVADEngine(o options, frameSize int) (vadEngine, error) {
+	if o.vadMode == VADAdaptive {
+		return newAdaptiveVAD(o, frameSize)
+	}
+
+	backend := o.vadBackend
+	if backend == "" {
+		backend = defaultVADBackend
+	}
+
+	switch backend {
+	case VADWebRTC:
+		return newWebRTCVAD(o.vadMode, o.sampleRate, frameSize)
+	case VADEnergy:
+		return newEnergyVAD(o.vadMode, o.energyThreshold), nil
+	case VADSilero:
+		vad, err := newSileroVAD(o, frameSize)
+		if err != nil {
+			slog.Warn("silero vad unavailable, falling back", "backend", defaultVADBackend, "error", err)
+			o.vadBackend = defaultVADBackend
+			return newVADEngine(o, frameSize)
+		}
+		return vad, nil
+	default:
+		return nil, fmt.Errorf("unknown vad backend %q, expected %s, %s or %s", backend, VADWebRTC, VADEnergy, VADSilero)
+	}
+}
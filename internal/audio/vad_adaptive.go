@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// VADAdaptive as the VAD mode picks the aggressiveness from the background
+// noise, between the bounds set with WithVADModeRange.
+const VADAdaptive = -1
+
+const (
+	// Noise floors at or below adaptiveQuietRMS get the least aggressive
+	// mode, at or above adaptiveLoudRMS the most; in between the mode
+	// scales with the floor in dB.
+	adaptiveQuietRMS = 30.0
+	adaptiveLoudRMS  = 300.0
+	// adaptiveFloorRate is the weight of each unvoiced frame in the floor
+	// estimate, roughly a one second average at 30 ms frames.
+	adaptiveFloorRate = 0.03
+	// adaptiveHold is the least time between mode changes, so a door
+	// slam or a passing car doesn't flip the mode back and forth.
+	adaptiveHold = 10 * time.Second
+)
+
+// modeSetter is a VAD whose aggressiveness can change while it runs.
+type modeSetter interface {
+	setMode(mode int) error
+}
+
+// adaptiveVAD tracks the noise floor over the frames its engine calls
+// unvoiced and moves the engine's mode with it, so quiet rooms get a
+// lenient VAD that catches soft speech and noisy ones a strict VAD.
+type adaptiveVAD struct {
+	engine     vadEngine
+	setter     modeSetter
+	mode       int
+	minMode    int
+	maxMode    int
+	floor      float64
+	holdFrames int
+	sinceMove  int
+}
+
+func newAdaptiveVAD(o options, frameSize int) (vadEngine, error) {
+	if o.vadMinMode < 0 || o.vadMaxMode > 3 || o.vadMinMode > o.vadMaxMode {
+		return nil, fmt.Errorf("invalid adaptive vad range %d to %d, must be within 0 to 3", o.vadMinMode, o.vadMaxMode)
+	}
+
+	// Start strict: until the floor is known, missing a soft word is
+	// better than triggering on noise.
+	inner := o
+	inner.vadMode = o.vadMaxMode
+	engine, err := newVADEngine(inner, frameSize)
+	if err != nil {
+		return nil, err
+	}
+	setter, ok := engine.(modeSetter)
+	if !ok {
+		return nil, fmt.Errorf("adaptive vad mode needs the %s or %s backend", VADWebRTC, VADEnergy)
+	}
+
+	hold := int(adaptiveHold / (time.Duration(o.frameDurationMs) * time.Millisecond))
+	return &adaptiveVAD{
+		engine:     engine,
+		setter:     setter,
+		mode:       o.vadMaxMode,
+		minMode:    o.vadMinMode,
+		maxMode:    o.vadMaxMode,
+		holdFrames: hold,
+		sinceMove:  hold,
+	}, nil
+}
+
+func (a *adaptiveVAD) Process(samples []int16) (bool, error) {
+	active, err := a.engine.Process(samples)
+	if err != nil || active {
+		return active, err
+	}
+
+	rms := frameRMS(samples)
+	if a.floor == 0 {
+		a.floor = rms
+	} else {
+		a.floor += adaptiveFloorRate * (rms - a.floor)
+	}
+
+	a.sinceMove++
+	if a.sinceMove < a.holdFrames {
+		return false, nil
+	}
+	mode := a.modeFor(a.floor)
+	if mode == a.mode {
+		return false, nil
+	}
+	if err := a.setter.setMode(mode); err != nil {
+		return false, err
+	}
+	slog.Debug("vad mode adapted", "from", a.mode, "to", mode, "noise_floor", math.Round(a.floor))
+	a.mode = mode
+	a.sinceMove = 0
+	return false, nil
+}
+
+func (a *adaptiveVAD) modeFor(floor float64) int {
+	pos := (math.Log(max(floor, 1)) - math.Log(adaptiveQuietRMS)) / (math.Log(adaptiveLoudRMS) - math.Log(adaptiveQuietRMS))
+	pos = min(max(pos, 0), 1)
+	return a.minMode + int(math.Round(pos*float64(a.maxMode-a.minMode)))
+}
+
+// Reset clears the engine's history but keeps the floor and mode, which
+// describe the room rather than the utterance.
+func (a *adaptiveVAD) Reset() error {
+	return a.engine.Reset()
+}
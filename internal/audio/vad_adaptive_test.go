@@ -0,0 +1,146 @@
+package audio
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func newTestAdaptiveVAD(t *testing.T, minMode, maxMode int) *adaptiveVAD {
+	t.Helper()
+	o := defaultOptions()
+	o.vadMode = VADAdaptive
+	o.vadBackend = VADEnergy
+	o.vadMinMode, o.vadMaxMode = minMode, maxMode
+	v, err := newVADEngine(o, 480)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v.(*adaptiveVAD)
+}
+
+func TestAdaptiveVADModeFor(t *testing.T) {
+	a := newTestAdaptiveVAD(t, 0, 3)
+	tests := []struct {
+		floor float64
+		want  int
+	}{
+		{0, 0},
+		{10, 0},
+		{adaptiveQuietRMS, 0},
+		// The geometric middle of the range.
+		{95, 2},
+		{adaptiveLoudRMS, 3},
+		{3000, 3},
+	}
+	for _, tt := range tests {
+		if got := a.modeFor(tt.floor); got != tt.want {
+			t.Errorf("modeFor(%v) = %d, want %d", tt.floor, got, tt.want)
+		}
+	}
+
+	narrow := newTestAdaptiveVAD(t, 1, 2)
+	if got := narrow.modeFor(10); got != 1 {
+		t.Errorf("modeFor(10) within 1 to 2 = %d, want 1", got)
+	}
+	if got := narrow.modeFor(3000); got != 2 {
+		t.Errorf("modeFor(3000) within 1 to 2 = %d, want 2", got)
+	}
+}
+
+func TestAdaptiveVADRange(t *testing.T) {
+	for _, r := range [][2]int{{-1, 3}, {0, 4}, {3, 1}} {
+		o := defaultOptions()
+		o.vadMode = VADAdaptive
+		o.vadBackend = VADEnergy
+		o.vadMinMode, o.vadMaxMode = r[0], r[1]
+		if _, err := newVADEngine(o, 480); err == nil {
+			t.Errorf("range %d to %d accepted, want an error", r[0], r[1])
+		}
+	}
+}
+
+func TestAdaptiveVADAdapts(t *testing.T) {
+	tests := []struct {
+		name string
+		rms  float64
+		want int
+	}{
+		{"quiet room", 15, 0},
+		{"noisy room", 500, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			a := newTestAdaptiveVAD(t, 0, 3)
+			if a.mode != 3 {
+				t.Fatalf("starting mode = %d, want the strictest", a.mode)
+			}
+
+			// The first move needn't wait out the hold; there is nothing
+			// to debounce yet.
+			for range 50 {
+				a.Process(noise(r, tt.rms, 480))
+			}
+			if a.mode != tt.want {
+				t.Errorf("mode = %d, want %d (floor %.0f)", a.mode, tt.want, a.floor)
+			}
+		})
+	}
+}
+
+func TestAdaptiveVADHold(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	a := newTestAdaptiveVAD(t, 0, 3)
+	for range 50 {
+		a.Process(noise(r, 15, 480))
+	}
+	if a.mode != 0 {
+		t.Fatalf("mode = %d in a quiet room, want 0", a.mode)
+	}
+
+	// A rise in noise shorter than the hold doesn't move it back up. The
+	// noise has to stay under the VAD's threshold, or it counts as speech
+	// and never reaches the floor.
+	for range a.holdFrames / 2 {
+		if active, _ := a.Process(noise(r, 80, 480)); active {
+			t.Fatal("background taken for speech")
+		}
+	}
+	if a.mode != 0 {
+		t.Errorf("mode = %d after a short rise, want it held at 0", a.mode)
+	}
+
+	// One that lasts past the hold does.
+	for range a.holdFrames {
+		a.Process(noise(r, 80, 480))
+	}
+	if a.mode != 1 {
+		t.Errorf("mode = %d after lasting noise, want 1 (floor %.0f)", a.mode, a.floor)
+	}
+}
+
+func TestAdaptiveVADIgnoresSpeech(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	a := newTestAdaptiveVAD(t, 0, 3)
+	for range 50 {
+		a.Process(noise(r, 15, 480))
+	}
+	floor := a.floor
+
+	// Voiced frames say nothing about the room.
+	for range 50 {
+		if active, _ := a.Process(sine(200, 3000, 16000, 480)); !active {
+			t.Fatal("speech not detected")
+		}
+	}
+	if a.floor != floor {
+		t.Errorf("floor moved from %.1f to %.1f during speech", floor, a.floor)
+	}
+
+	if err := a.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if a.floor != floor {
+		t.Error("Reset forgot the room's floor")
+	}
+}
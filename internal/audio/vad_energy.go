@@ -0,0 +1,76 @@
+package audio
+
+import "math"
+
+const (
+	energyInitFrames = 10
+	// Broadband hiss crosses zero on most samples, voiced speech far less
+	// often. Frames above this rate need a much larger margin to count.
+	energyMaxZCR    = 0.35
+	energyFloorDown = 0.2
+	energyFloorUp   = 0.005
+)
+
+// Speech-to-floor ratios per aggressiveness, mirroring webrtcvad's 0-3.
+var energyRatios = [...]float64{2.0, 2.5, 3.0, 4.0}
+
+type energyVAD struct {
+	ratio  float64
+	minRMS float64
+	floor  float64
+	frames int
+}
+
+func newEnergyVAD(mode int, minRMS float64) *energyVAD {
+	mode = min(max(mode, 0), len(energyRatios)-1)
+	return &energyVAD{ratio: energyRatios[mode], minRMS: minRMS}
+}
+
+func (e *energyVAD) Process(samples []int16) (bool, error) {
+	rms := frameRMS(samples)
+	zcr := zeroCrossingRate(samples)
+
+	e.frames++
+	if e.frames <= energyInitFrames {
+		e.floor += (rms - e.floor) / float64(e.frames)
+		return false, nil
+	}
+
+	threshold := math.Max(e.floor*e.ratio, e.minRMS)
+	if zcr > energyMaxZCR {
+		threshold *= 2
+	}
+	active := rms > threshold
+
+	if rms < e.floor {
+		e.floor += energyFloorDown * (rms - e.floor)
+	} else if !active {
+		e.floor += energyFloorUp * (rms - e.floor)
+	}
+
+	return active, nil
+}
+
+func (e *energyVAD) setMode(mode int) error {
+	e.ratio = energyRatios[min(max(mode, 0), len(energyRatios)-1)]
+	return nil
+}
+
+func (e *energyVAD) Reset() error {
+	e.floor = 0
+	e.frames = 0
+	return nil
+}
+
+func zeroCrossingRate(samples []int16) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	crossings := 0
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] >= 0) != (samples[i] >= 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / float64(len(samples)-1)
+}
@@ -0,0 +1,112 @@
+package audio
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// noise returns n samples of white noise with roughly the given RMS.
+func noise(r *rand.Rand, rms float64, n int) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = clampInt16(r.NormFloat64() * rms)
+	}
+	return out
+}
+
+// learnFloor feeds the VAD enough background for it to settle its floor.
+func learnFloor(t *testing.T, v *energyVAD, r *rand.Rand, rms float64) {
+	t.Helper()
+	for i := range energyInitFrames + 20 {
+		active, err := v.Process(noise(r, rms, 480))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active && i < energyInitFrames {
+			t.Fatalf("frame %d active while learning the floor", i)
+		}
+	}
+}
+
+func TestEnergyVAD(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   int
+		minRMS float64
+		frame  func(r *rand.Rand) []int16
+		want   bool
+	}{
+		{"background", 3, 0, func(r *rand.Rand) []int16 { return noise(r, 100, 480) }, false},
+		{"voiced speech", 3, 0, func(*rand.Rand) []int16 { return sine(200, 3000, 16000, 480) }, true},
+		{"quiet speech at low aggressiveness", 0, 0, func(*rand.Rand) []int16 { return sine(200, 400, 16000, 480) }, true},
+		{"quiet speech at high aggressiveness", 3, 0, func(*rand.Rand) []int16 { return sine(200, 400, 16000, 480) }, false},
+		{"speech below the minimum level", 0, 1000, func(*rand.Rand) []int16 { return sine(200, 800, 16000, 480) }, false},
+		// Hiss at the level that passes for voiced speech needs twice the margin.
+		{"hiss", 0, 0, func(r *rand.Rand) []int16 { return noise(r, 300, 480) }, false},
+		{"loud hiss", 0, 0, func(r *rand.Rand) []int16 { return noise(r, 600, 480) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			v := newEnergyVAD(tt.mode, tt.minRMS)
+			learnFloor(t, v, r, 100)
+			got, err := v.Process(tt.frame(r))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Process = %v, want %v (floor %.0f)", got, tt.want, v.floor)
+			}
+		})
+	}
+}
+
+func TestEnergyVADFloorTracking(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	v := newEnergyVAD(3, 0)
+	learnFloor(t, v, r, 100)
+
+	// Long speech must not drag the floor up to its own level.
+	for range 100 {
+		if active, _ := v.Process(sine(200, 3000, 16000, 480)); !active {
+			t.Fatalf("sustained speech dropped out, floor %.0f", v.floor)
+		}
+	}
+
+	// The floor follows the room down quickly once it gets quieter.
+	for range 30 {
+		v.Process(noise(r, 20, 480))
+	}
+	if v.floor > 40 {
+		t.Errorf("floor = %.0f after a quieter room, want it near 20", v.floor)
+	}
+
+	if err := v.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := v.Process(sine(200, 3000, 16000, 480)); active {
+		t.Error("active on the first frame after Reset")
+	}
+}
+
+func TestZeroCrossingRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []int16
+		want    float64
+	}{
+		{"empty", nil, 0},
+		{"one sample", []int16{5}, 0},
+		{"constant", []int16{3, 3, 3, 3}, 0},
+		{"alternating", []int16{1, -1, 1, -1, 1}, 1},
+		{"one crossing", []int16{1, 2, -1, -2, -3}, 0.25},
+		{"zero counts as positive", []int16{0, 1, 0, -1}, 1.0 / 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := zeroCrossingRate(tt.samples); got != tt.want {
+				t.Errorf("zeroCrossingRate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
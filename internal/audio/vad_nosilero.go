@@ -0,0 +1,9 @@
+//go:build !silero
+
+package audio
+
+import "errors"
+
+func newSileroVAD(o options, frameSize int) (vadEngine, error) {
+	return nil, errors.New("silero vad requires building with -tags silero")
+}
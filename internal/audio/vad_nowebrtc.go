@@ -0,0 +1,11 @@
+//go:build novad
+
+package audio
+
+import "errors"
+
+const defaultVADBackend = VADEnergy
+
+func newWebRTCVAD(mode, sampleRate, frameSize int) (vadEngine, error) {
+	return nil, errors.New("webrtc vad not available in novad builds")
+}
@@ -0,0 +1,119 @@
+//go:build silero
+
+package audio
+
+import (
+	"fmt"
+	"os"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+const (
+	// Silero v5 takes exactly 512 new samples per call at 16 kHz, so each
+	// 30 ms frame is padded with the tail of the previous one.
+	sileroWindow  = 512
+	sileroContext = 64
+	sileroRate    = 16000
+)
+
+var sileroThresholds = [...]float32{0.3, 0.4, 0.5, 0.6}
+
+type sileroVAD struct {
+	session   *ort.AdvancedSession
+	input     *ort.Tensor[float32]
+	state     *ort.Tensor[float32]
+	sr        *ort.Tensor[int64]
+	output    *ort.Tensor[float32]
+	stateOut  *ort.Tensor[float32]
+	history   []float32
+	threshold float32
+}
+
+func newSileroVAD(o options, frameSize int) (vadEngine, error) {
+	if o.sampleRate != sileroRate {
+		return nil, fmt.Errorf("silero vad needs %d Hz input, got %d", sileroRate, o.sampleRate)
+	}
+	if frameSize > sileroWindow {
+		return nil, fmt.Errorf("silero vad frame of %d samples exceeds window %d", frameSize, sileroWindow)
+	}
+	if _, err := os.Stat(o.sileroModelPath); err != nil {
+		return nil, fmt.Errorf("silero model: %w", err)
+	}
+
+	if !ort.IsInitialized() {
+		if o.onnxRuntimeLib != "" {
+			ort.SetSharedLibraryPath(o.onnxRuntimeLib)
+		}
+		if err := ort.InitializeEnvironment(); err != nil {
+			return nil, fmt.Errorf("initializing onnxruntime: %w", err)
+		}
+	}
+
+	s := &sileroVAD{
+		history:   make([]float32, sileroContext+sileroWindow),
+		threshold: sileroThresholds[min(max(o.vadMode, 0), len(sileroThresholds)-1)],
+	}
+
+	var err error
+	if s.input, err = ort.NewTensor(ort.NewShape(1, sileroContext+sileroWindow), make([]float32, sileroContext+sileroWindow)); err != nil {
+		return nil, fmt.Errorf("creating input tensor: %w", err)
+	}
+	if s.state, err = ort.NewEmptyTensor[float32](ort.NewShape(2, 1, 128)); err != nil {
+		return nil, fmt.Errorf("creating state tensor: %w", err)
+	}
+	if s.sr, err = ort.NewTensor(ort.NewShape(1), []int64{sileroRate}); err != nil {
+		return nil, fmt.Errorf("creating sample rate tensor: %w", err)
+	}
+	if s.output, err = ort.NewEmptyTensor[float32](ort.NewShape(1, 1)); err != nil {
+		return nil, fmt.Errorf("creating output tensor: %w", err)
+	}
+	if s.stateOut, err = ort.NewEmptyTensor[float32](ort.NewShape(2, 1, 128)); err != nil {
+		return nil, fmt.Errorf("creating state output tensor: %w", err)
+	}
+
+	s.session, err = ort.NewAdvancedSession(o.sileroModelPath,
+		[]string{"input", "state", "sr"},
+		[]string{"output", "stateN"},
+		[]ort.Value{s.input, s.state, s.sr},
+		[]ort.Value{s.output, s.stateOut},
+		nil,
+	)
+	if err != nil {
+		s.destroy()
+		return nil, fmt.Errorf("loading silero model: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *sileroVAD) Process(samples []int16) (bool, error) {
+	n := len(samples)
+	copy(s.history, s.history[n:])
+	tail := s.history[len(s.history)-n:]
+	for i, v := range samples {
+		tail[i] = float32(v) / 32768
+	}
+
+	copy(s.input.GetData(), s.history)
+	if err := s.session.Run(); err != nil {
+		return false, fmt.Errorf("running silero: %w", err)
+	}
+	copy(s.state.GetData(), s.stateOut.GetData())
+
+	return s.output.GetData()[0] >= s.threshold, nil
+}
+
+func (s *sileroVAD) Reset() error {
+	clear(s.history)
+	clear(s.state.GetData())
+	return nil
+}
+
+func (s *sileroVAD) destroy() {
+	for _, t := range []interface{ Destroy() error }{s.input, s.state, s.sr, s.output, s.stateOut} {
+		if t != nil {
+			t.Destroy()
+		}
+	}
+}
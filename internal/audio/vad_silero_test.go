@@ -0,0 +1,43 @@
+//go:build silero
+
+package audio
+
+import (
+	"os"
+	"testing"
+)
+
+// BenchmarkSileroVAD measures one inference per 30 ms frame, the cost the
+// capture loop pays per frame with WithVAD("silero"). It uses the same
+// SILERO_MODEL_PATH and ONNXRUNTIME_LIB as the service:
+//
+//	SILERO_MODEL_PATH=silero_vad.onnx go test -tags silero -run '^$' -bench SileroVAD ./internal/audio
+func BenchmarkSileroVAD(b *testing.B) {
+	modelPath := os.Getenv("SILERO_MODEL_PATH")
+	if modelPath == "" {
+		b.Skip("SILERO_MODEL_PATH not set")
+	}
+
+	o := defaultOptions()
+	o.sileroModelPath = modelPath
+	o.onnxRuntimeLib = os.Getenv("ONNXRUNTIME_LIB")
+	frameSize := o.sampleRate * DefaultFrameDurationMs / 1000
+	vad, err := newSileroVAD(o, frameSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer vad.(*sileroVAD).destroy()
+
+	frames := [][]int16{
+		sine(200, 3000, o.sampleRate, frameSize),
+		make([]int16, frameSize),
+	}
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		if _, err := vad.Process(frames[i%len(frames)]); err != nil {
+			b.Fatal(err)
+		}
+		i++
+	}
+}
@@ -0,0 +1,68 @@
+//go:build !novad
+
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	webrtcvad "github.com/maxhawkins/go-webrtcvad"
+)
+
+const defaultVADBackend = VADWebRTC
+
+type webrtcVAD struct {
+	vad        *webrtcvad.VAD
+	mode       int
+	sampleRate int
+	buf        []byte
+}
+
+func newWebRTCVAD(mode, sampleRate, frameSize int) (vadEngine, error) {
+	w := &webrtcVAD{
+		mode:       mode,
+		sampleRate: sampleRate,
+		buf:        make([]byte, frameSize*2),
+	}
+	if err := w.Reset(); err != nil {
+		return nil, err
+	}
+
+	if !w.vad.ValidRateAndFrameLength(sampleRate, frameSize) {
+		return nil, fmt.Errorf("invalid sample rate %d or frame size %d for vad", sampleRate, frameSize)
+	}
+	return w, nil
+}
+
+func (w *webrtcVAD) Process(samples []int16) (bool, error) {
+	if len(w.buf) != len(samples)*2 {
+		w.buf = make([]byte, len(samples)*2)
+	}
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(w.buf[i*2:], uint16(s))
+	}
+	return w.vad.Process(w.sampleRate, w.buf)
+}
+
+func (w *webrtcVAD) setMode(mode int) error {
+	if err := w.vad.SetMode(mode); err != nil {
+		return fmt.Errorf("setting vad mode: %w", err)
+	}
+	w.mode = mode
+	return nil
+}
+
+// Reset replaces the underlying instance, the binding has no other way to
+// clear webrtcvad's internal history.
+func (w *webrtcVAD) Reset() error {
+	vad, err := webrtcvad.New()
+	if err != nil {
+		return fmt.Errorf("creating vad: %w", err)
+	}
+
+	if err := vad.SetMode(w.mode); err != nil {
+		return fmt.Errorf("setting vad mode: %w", err)
+	}
+	w.vad = vad
+	return nil
+}
@@ -1,6 +1,11 @@
 package audio
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
 
 func EncodeWAV(pcm []byte, sampleRate, channels, bitsPerSample int) []byte {
 	dataSize := len(pcm)
@@ -25,3 +30,86 @@ func EncodeWAV(pcm []byte, sampleRate, channels, bitsPerSample int) []byte {
 
 	return append(header, pcm...)
 }
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+)
+
+type wavFormat struct {
+	sampleRate int
+	channels   int
+	bits       int
+}
+
+// parseWAVFormat reads a fmt chunk. Only integer PCM is accepted, including
+// the extensible form ffmpeg writes for multichannel or 24-bit audio.
+func parseWAVFormat(chunk []byte) (wavFormat, error) {
+	if len(chunk) < 16 {
+		return wavFormat{}, errors.New("wav format chunk too short")
+	}
+	tag := binary.LittleEndian.Uint16(chunk[0:])
+	if tag == wavFormatExtensible && len(chunk) >= 26 {
+		tag = binary.LittleEndian.Uint16(chunk[24:])
+	}
+	f := wavFormat{
+		channels:   int(binary.LittleEndian.Uint16(chunk[2:])),
+		sampleRate: int(binary.LittleEndian.Uint32(chunk[4:])),
+		bits:       int(binary.LittleEndian.Uint16(chunk[14:])),
+	}
+	switch {
+	case tag == wavFormatFloat:
+		return wavFormat{}, errors.New("float wav is not supported, need integer pcm")
+	case tag != wavFormatPCM:
+		return wavFormat{}, fmt.Errorf("unsupported wav format %#x, need pcm", tag)
+	case f.channels < 1 || f.sampleRate < 1 || f.bits < 8 || f.bits%8 != 0:
+		return wavFormat{}, fmt.Errorf("invalid wav format: %d channels, %d Hz, %d bits", f.channels, f.sampleRate, f.bits)
+	}
+	return f, nil
+}
+
+// DecodeWAV returns the samples of a PCM WAV file as stored, along with their
+// layout. Chunks other than fmt and data, such as LIST, are skipped.
+func DecodeWAV(data []byte) (pcm []byte, sampleRate, channels, bits int, err error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, 0, errors.New("not a wav file")
+	}
+
+	var format *wavFormat
+	for off := 12; ; {
+		if len(data)-off < 8 {
+			return nil, 0, 0, 0, errors.New("wav file has no data chunk")
+		}
+		id := string(data[off : off+4])
+		rawSize := binary.LittleEndian.Uint32(data[off+4:])
+		off += 8
+		body := data[off:]
+		if uint64(rawSize) > uint64(len(body)) && !(id == "data" && rawSize == math.MaxUint32) {
+			return nil, 0, 0, 0, fmt.Errorf("wav chunk %q truncated: %d of %d bytes", id, len(body), rawSize)
+		}
+		size := len(body)
+		if rawSize != math.MaxUint32 {
+			size = int(rawSize)
+		}
+
+		switch {
+		case id == "data" && format == nil:
+			return nil, 0, 0, 0, errors.New("wav data before format chunk")
+		case id == "data":
+			// Streamed WAVs leave the data size at its maximum, meaning
+			// "until the end", which the size check above lets through.
+			if block := format.channels * format.bits / 8; size%block != 0 {
+				return nil, 0, 0, 0, fmt.Errorf("wav data size %d is not a multiple of the %d byte frame", size, block)
+			}
+			return body[:size], format.sampleRate, format.channels, format.bits, nil
+		case id == "fmt ":
+			f, err := parseWAVFormat(body[:size])
+			if err != nil {
+				return nil, 0, 0, 0, err
+			}
+			format = &f
+		}
+		off += size + size%2
+	}
+}
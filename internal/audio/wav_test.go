@@ -0,0 +1,145 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+// riff builds a WAV file from id and body pairs, padding odd chunks the
+// way the spec asks.
+func riff(chunks ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	b.Write(make([]byte, 4))
+	b.WriteString("WAVE")
+	for i := 0; i < len(chunks); i += 2 {
+		b.WriteString(chunks[i])
+		binary.Write(&b, binary.LittleEndian, uint32(len(chunks[i+1])))
+		b.WriteString(chunks[i+1])
+		if len(chunks[i+1])%2 == 1 {
+			b.WriteByte(0)
+		}
+	}
+	data := b.Bytes()
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
+
+// fmtChunk is a fmt chunk body for tag; extensible ones carry subformat.
+func fmtChunk(tag uint16, channels, rate, bits int, subformat uint16) string {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&b, le, tag)
+	binary.Write(&b, le, uint16(channels))
+	binary.Write(&b, le, uint32(rate))
+	binary.Write(&b, le, uint32(rate*channels*bits/8))
+	binary.Write(&b, le, uint16(channels*bits/8))
+	binary.Write(&b, le, uint16(bits))
+	if tag == wavFormatExtensible {
+		binary.Write(&b, le, uint16(22))
+		binary.Write(&b, le, uint16(bits))
+		binary.Write(&b, le, uint32(3)) // front left and right
+		binary.Write(&b, le, subformat)
+		b.Write([]byte("\x00\x00\x00\x00\x10\x00\x80\x00\x00\xaa\x00\x38\x9b\x71"))
+	}
+	return b.String()
+}
+
+func TestDecodeWAV(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	// ffmpeg puts a LIST chunk naming itself ahead of the data.
+	list := "INFOISFT\x0e\x00\x00\x00Lavf61.7.100\x00\x00"
+
+	tests := []struct {
+		name     string
+		data     []byte
+		rate     int
+		channels int
+		bits     int
+		want     []byte
+	}{
+		{"round trip mono", EncodeWAV(pcm, 16000, 1, 16), 16000, 1, 16, pcm},
+		{"round trip stereo", EncodeWAV(pcm, 44100, 2, 16), 44100, 2, 16, pcm},
+		{"round trip 8-bit", EncodeWAV(pcm, 8000, 1, 8), 8000, 1, 8, pcm},
+		{"ffmpeg with LIST", riff("fmt ", fmtChunk(wavFormatPCM, 1, 48000, 16, 0), "LIST", list, "data", string(pcm)), 48000, 1, 16, pcm},
+		{"odd chunk padded", riff("fmt ", fmtChunk(wavFormatPCM, 1, 16000, 16, 0), "junk", "abc", "data", string(pcm)), 16000, 1, 16, pcm},
+		{"extensible 24-bit", riff("fmt ", fmtChunk(wavFormatExtensible, 2, 48000, 24, wavFormatPCM), "data", string(pcm)), 48000, 2, 24, pcm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rate, channels, bits, err := DecodeWAV(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rate != tt.rate || channels != tt.channels || bits != tt.bits {
+				t.Errorf("format %d Hz, %d channels, %d bits, want %d, %d, %d", rate, channels, bits, tt.rate, tt.channels, tt.bits)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("pcm = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeWAVStreamed(t *testing.T) {
+	// A WAV written to a pipe can't go back to fill in the data size.
+	data := EncodeWAV(make([]byte, 960), 16000, 1, 16)
+	binary.LittleEndian.PutUint32(data[40:], math.MaxUint32)
+	pcm, _, _, _, err := DecodeWAV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pcm) != 960 {
+		t.Errorf("got %d bytes, want the 960 to the end of the file", len(pcm))
+	}
+}
+
+func TestDecodeWAVErrors(t *testing.T) {
+	pcm := string(make([]byte, 8))
+	truncated := EncodeWAV(make([]byte, 100), 16000, 1, 16)[:80]
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"not a wav", []byte("ID3\x04 some mp3"), "not a wav file"},
+		{"empty", nil, "not a wav file"},
+		{"float", riff("fmt ", fmtChunk(wavFormatFloat, 1, 16000, 32, 0), "data", pcm), "float wav"},
+		{"extensible float", riff("fmt ", fmtChunk(wavFormatExtensible, 2, 48000, 32, wavFormatFloat), "data", pcm), "float wav"},
+		{"compressed", riff("fmt ", fmtChunk(0x11, 1, 16000, 16, 0), "data", pcm), "unsupported wav format 0x11"},
+		{"truncated data", truncated, "truncated"},
+		{"short format", riff("fmt ", "\x01\x00\x01\x00", "data", pcm), "too short"},
+		{"data before format", riff("data", pcm, "fmt ", fmtChunk(wavFormatPCM, 1, 16000, 16, 0)), "before format"},
+		{"no data", riff("fmt ", fmtChunk(wavFormatPCM, 1, 16000, 16, 0)), "no data chunk"},
+		{"partial frame", riff("fmt ", fmtChunk(wavFormatPCM, 2, 16000, 16, 0), "data", "\x00\x00\x00\x00\x00\x00"), "not a multiple"},
+		{"zero channels", riff("fmt ", fmtChunk(wavFormatPCM, 0, 16000, 16, 0), "data", pcm), "invalid wav format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, _, err := DecodeWAV(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeWAVFixture(t *testing.T) {
+	// Written by Python's wave module rather than EncodeWAV.
+	data, err := os.ReadFile("testdata/two_utterances.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm, rate, channels, bits, err := DecodeWAV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 16000 || channels != 1 || bits != 16 || len(pcm) != 101*480*2 {
+		t.Errorf("got %d bytes at %d Hz, %d channels, %d bits", len(pcm), rate, channels, bits)
+	}
+}
@@ -13,23 +13,188 @@ type Config struct {
 	LogLevel  string
 	LogFormat string
 
+	PanicLimit    int
+	PanicWindowMs int
+
 	OTLPEndpoint string
 	OTLPToken    string
 
-	OpenAIAPIKey string
+	TelemetryContent         string
+	TelemetryContentSalt     string
+	TelemetryContentMaxChars int
+
+	OpenAIAPIKey    string
+	STTUploadFormat string
 
 	AnthropicAPIKey string
 
 	SerpAPIKey string
 
-	PicovoiceAccessKey string
+	PicovoiceAccessKey  string
+	WakeWordSensitivity float64
+	WakeWordWindowMs    int
+
+	ScrubNames    []string
+	ScrubPatterns []string
+	ScrubConsole  bool
+
+	AudioBackend           string
+	AudioSource            string
+	AudioSourceURL         string
+	AudioSourceRate        int
+	AudioSourceBigEndian   bool
+	AudioEchoGate          bool
+	AudioEarcons           bool
+	AudioEarconDir         string
+	AudioPhraseDir         string
+	AudioAGCTargetRMS      float64
+	AudioHighPassHz        float64
+	AudioNoiseSuppression  bool
+	AudioMaxUtteranceMs    int
+	AudioMinUtteranceMs    int
+	AudioSilenceMs         int
+	AudioPreBufferMs       int
+	AudioTrailingPadMs     int
+	AudioVADOnsetFrames    int
+	AudioVADOnsetWindow    int
+	AudioInputDevice       string
+	AudioInputChannels     int
+	AudioChannelSelect     int
+	AudioRecoveryTimeoutMs int
+	AudioBackpressure      string
+	AudioChannelBuffer     int
+	AudioDebugDir          string
+	AudioRoom              string
+	AudioDebugMaxFiles     int
+	AudioDebugMaxMB        int
+	AudioVAD               string
+	AudioVADMode           int
+	AudioVADAdaptive       bool
+	AudioVADModeMin        int
+	AudioVADModeMax        int
+	AudioEnergyThreshold   float64
+	AudioBargeInRMS        float64
+	PlaybackVolume         float64
+	PlaybackBackend        string
+	PlaybackDir            string
+	PlaybackDevice         string
+	PlaybackSinks          string
+	PlaybackQueueMs        int
+	PlaybackSampleRate     int
+	PlaybackChannels       int
+	PlaybackFades          bool
+	PlaybackLimiter        float64
+	PlaybackDuckCommand    string
+	PlaybackDuckHoldMs     int
+	PlaybackDuckDebounceMs int
+	KeywordTemplateDir     string
+	KeywordWords           []string
+	KeywordThreshold       float64
+	SileroModelPath        string
+	ONNXRuntimeLib         string
+
+	TTSProvider string
+	// TTSDailyCharBudget is a soft limit on the ElevenLabs characters sent
+	// in a day; past it turns use TTSBudgetFallback, a provider name, or
+	// only print the response when that is empty. 0 disables it.
+	TTSDailyCharBudget int
+	TTSBudgetFallback  string
+	// TTSCostPer1KChars prices the characters for the usage log.
+	TTSCostPer1KChars float64
+	// TTSCacheDir keeps synthesized audio of short phrases, up to
+	// TTSCacheMaxMB. Empty disables it.
+	TTSCacheDir      string
+	TTSCacheMaxMB    int
+	TTSCacheMaxChars int
+	// TTSBatchChars and TTSBatchMs hold the agent's deltas back until a
+	// phrase ends, that many characters build up or that long passes, so
+	// the voice isn't sent a token at a time.
+	TTSBatchChars int
+	TTSBatchMs    int
+	// TTSNormalize spells out numbers, times and units in this language
+	// before they are spoken, e.g. sv. Empty disables it.
+	TTSNormalize string
 
 	ElevenLabsAPIKey     string
 	ElevenLabsVoiceID    string
 	ElevenLabsModel      string
+	ElevenLabsFormat     string
 	ElevenLabsStability  float64
 	ElevenLabsSimilarity float64
 	ElevenLabsSpeed      float64
+	ElevenLabsReconnects int
+	ElevenLabsBackoffMs  int
+	ElevenLabsPoolSize   int
+	ElevenLabsKeepAlive  int
+	ElevenLabsFlushMs    int
+	ElevenLabsMulti      bool
+	ElevenLabsReuse      bool
+	ElevenLabsAlignment  bool
+	ElevenLabsSchedule   []int
+	ElevenLabsAutoMode   bool
+	// ElevenLabsDictionaries are pronunciation dictionary locators, each
+	// id or id:version.
+	ElevenLabsDictionaries []string
+	ElevenLabsStyle        float64
+	// ElevenLabsSpeakerBoost is nil when unset, keeping the voice's own
+	// setting.
+	ElevenLabsSpeakerBoost *bool
+	ElevenLabsSeed         int
+	ElevenLabsLanguage     string
+	// ElevenLabsDialRetries and the rest retry a failed dial, backing off
+	// from ElevenLabsDialBackoffMs.
+	ElevenLabsDialRetries      int
+	ElevenLabsDialBackoffMs    int
+	ElevenLabsDialMaxBackoffMs int
+	ElevenLabsDialJitter       float64
+
+	OpenAITTSModel  string
+	OpenAITTSVoice  string
+	OpenAITTSFormat string
+	OpenAITTSSpeed  float64
+
+	PiperURL        string
+	PiperBin        string
+	PiperModel      string
+	PiperSampleRate int
+
+	AzureSpeechKey    string
+	AzureSpeechRegion string
+	AzureSpeechVoice  string
+
+	LexiconPath     string
+	LexiconLanguage string
+	// PronunciationRulesPath holds regex replacements applied before the
+	// lexicon.
+	PronunciationRulesPath string
+
+	TranscriptWakePhrases []string
+	TranscriptFillers     []string
+	TranscriptStopPhrases []string
+	// TranscriptWordTimestamps transcribes with TranscriptWordModel to get
+	// word timestamps, if a probe at startup finds the model returns them.
+	TranscriptWordTimestamps bool
+	TranscriptWordModel      string
+	TranscriptWakeTailMs     int
+
+	StyleMatching      bool
+	StyleShortMaxWords int
+	StyleLongMinWords  int
+	// The style hints given to the model; empty keeps the built-in one.
+	StyleShortHint   string
+	StyleLongHint    string
+	StyleCommandHint string
+	StylePoliteHint  string
+	StyleCasualHint  string
+
+	LoadMonitor       bool
+	LoadIntervalMs    int
+	LoadSustainMs     int
+	LoadNSCPUAbove    float64
+	LoadNSCPUBelow    float64
+	LoadTTSCPUAbove   float64
+	LoadTTSCPUBelow   float64
+	LoadDegradedModel string
 }
 
 func Load(envFile string) (*Config, error) {
@@ -38,24 +203,172 @@ func Load(envFile string) (*Config, error) {
 	}
 
 	config := &Config{
-		LogLevel:     getEnv("LOG_LEVEL", "info"),
-		LogFormat:    getEnv("LOG_FORMAT", "json"),
-		OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
-		OTLPToken:    getEnv("OTEL_EXPORTER_OTLP_TOKEN", ""),
-		OpenAIAPIKey: getEnv("OPENAI_API_KEY", ""),
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "json"),
+
+		PanicLimit:    getEnvAsInt("PANIC_LIMIT", 3),
+		PanicWindowMs: getEnvAsInt("PANIC_WINDOW_MS", 600000),
+		OTLPEndpoint:  getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTLPToken:     getEnv("OTEL_EXPORTER_OTLP_TOKEN", ""),
+
+		TelemetryContent:         getEnv("TELEMETRY_CONTENT", "none"),
+		TelemetryContentSalt:     getEnv("TELEMETRY_CONTENT_SALT", ""),
+		TelemetryContentMaxChars: getEnvAsInt("TELEMETRY_CONTENT_MAX_CHARS", 32),
+
+		OpenAIAPIKey:    getEnv("OPENAI_API_KEY", ""),
+		STTUploadFormat: getEnv("STT_UPLOAD_FORMAT", "wav"),
 
 		AnthropicAPIKey: getEnv("ANTHROPIC_API_KEY", ""),
 
 		SerpAPIKey: getEnv("SERPAPI_KEY", ""),
 
-		PicovoiceAccessKey: getEnv("PICOVOICE_ACCESS_KEY", ""),
+		PicovoiceAccessKey:  getEnv("PICOVOICE_ACCESS_KEY", ""),
+		WakeWordSensitivity: getEnvAsFloat("WAKE_WORD_SENSITIVITY", 0.9),
+		WakeWordWindowMs:    getEnvAsInt("WAKE_WORD_WINDOW_MS", 10000),
+
+		ScrubNames:    getEnvAsSlice("SCRUB_NAMES", nil),
+		ScrubPatterns: strings.Split(getEnv("SCRUB_PATTERNS", ""), ";"),
+		ScrubConsole:  getEnvAsBool("SCRUB_CONSOLE", false),
+
+		AudioBackend:           getEnv("AUDIO_BACKEND", "portaudio"),
+		AudioSource:            getEnv("AUDIO_SOURCE", "device"),
+		AudioSourceURL:         getEnv("AUDIO_SOURCE_URL", ""),
+		AudioSourceRate:        getEnvAsInt("AUDIO_SOURCE_RATE", 16000),
+		AudioSourceBigEndian:   getEnvAsBool("AUDIO_SOURCE_BIG_ENDIAN", false),
+		AudioEchoGate:          getEnvAsBool("AUDIO_ECHO_GATE", false),
+		AudioEarcons:           getEnvAsBool("AUDIO_EARCONS", true),
+		AudioEarconDir:         getEnv("AUDIO_EARCON_DIR", ""),
+		AudioPhraseDir:         getEnv("AUDIO_PHRASE_DIR", ""),
+		AudioAGCTargetRMS:      getEnvAsFloat("AUDIO_AGC_TARGET_RMS", 0),
+		AudioHighPassHz:        getEnvAsFloat("AUDIO_HIGHPASS_HZ", 0),
+		AudioNoiseSuppression:  getEnvAsBool("AUDIO_NOISE_SUPPRESSION", false),
+		AudioMaxUtteranceMs:    getEnvAsInt("AUDIO_MAX_UTTERANCE_MS", 30000),
+		AudioMinUtteranceMs:    getEnvAsInt("AUDIO_MIN_UTTERANCE_MS", 300),
+		AudioSilenceMs:         getEnvAsInt("AUDIO_SILENCE_MS", 450),
+		AudioPreBufferMs:       getEnvAsInt("AUDIO_PREBUFFER_MS", 240),
+		AudioTrailingPadMs:     getEnvAsInt("AUDIO_TRAILING_PAD_MS", 150),
+		AudioVADOnsetFrames:    getEnvAsInt("AUDIO_VAD_ONSET_FRAMES", 3),
+		AudioVADOnsetWindow:    getEnvAsInt("AUDIO_VAD_ONSET_WINDOW", 4),
+		AudioInputDevice:       getEnv("AUDIO_INPUT_DEVICE", ""),
+		AudioInputChannels:     getEnvAsInt("AUDIO_INPUT_CHANNELS", 1),
+		AudioChannelSelect:     getEnvAsInt("AUDIO_CHANNEL_SELECT", -1),
+		AudioRecoveryTimeoutMs: getEnvAsInt("AUDIO_RECOVERY_TIMEOUT_MS", 120000),
+		AudioBackpressure:      getEnv("AUDIO_BACKPRESSURE", "block"),
+		AudioChannelBuffer:     getEnvAsInt("AUDIO_CHANNEL_BUFFER", 4),
+		AudioDebugDir:          getEnv("AUDIO_DEBUG_DIR", ""),
+		AudioRoom:              getEnv("AUDIO_ROOM", ""),
+		AudioDebugMaxFiles:     getEnvAsInt("AUDIO_DEBUG_MAX_FILES", 100),
+		AudioDebugMaxMB:        getEnvAsInt("AUDIO_DEBUG_MAX_MB", 50),
+		AudioVAD:               getEnv("AUDIO_VAD", ""),
+		AudioVADMode:           getEnvAsInt("AUDIO_VAD_MODE", 3),
+		AudioVADAdaptive:       getEnvAsBool("AUDIO_VAD_ADAPTIVE", false),
+		AudioVADModeMin:        getEnvAsInt("AUDIO_VAD_MODE_MIN", 1),
+		AudioVADModeMax:        getEnvAsInt("AUDIO_VAD_MODE_MAX", 3),
+		AudioEnergyThreshold:   getEnvAsFloat("AUDIO_ENERGY_THRESHOLD", 100),
+		AudioBargeInRMS:        getEnvAsFloat("AUDIO_BARGE_IN_RMS", 1000),
+		PlaybackVolume:         getEnvAsFloat("PLAYBACK_VOLUME", 1),
+		PlaybackBackend:        getEnv("PLAYBACK_BACKEND", getEnv("AUDIO_BACKEND", "portaudio")),
+		PlaybackDir:            getEnv("PLAYBACK_DIR", "playback"),
+		PlaybackDevice:         getEnv("PLAYBACK_DEVICE", ""),
+		PlaybackSinks:          getEnv("PLAYBACK_SINKS", ""),
+		PlaybackQueueMs:        getEnvAsInt("PLAYBACK_QUEUE_MS", 60000),
+		PlaybackSampleRate:     getEnvAsInt("PLAYBACK_SAMPLE_RATE", 0),
+		PlaybackChannels:       getEnvAsInt("PLAYBACK_CHANNELS", 1),
+		PlaybackFades:          getEnvAsBool("PLAYBACK_FADES", false),
+		PlaybackLimiter:        getEnvAsFloat("PLAYBACK_LIMITER_CEILING", 0),
+		PlaybackDuckCommand:    getEnv("PLAYBACK_DUCK_COMMAND", ""),
+		PlaybackDuckHoldMs:     getEnvAsInt("PLAYBACK_DUCK_HOLD_MS", 750),
+		PlaybackDuckDebounceMs: getEnvAsInt("PLAYBACK_DUCK_DEBOUNCE_MS", 250),
+		KeywordTemplateDir:     getEnv("KEYWORD_TEMPLATE_DIR", ""),
+		KeywordWords:           getEnvAsSlice("KEYWORD_WORDS", []string{"stopp", "tyst"}),
+		KeywordThreshold:       getEnvAsFloat("KEYWORD_THRESHOLD", 0.8),
+		SileroModelPath:        getEnv("SILERO_MODEL_PATH", "silero_vad.onnx"),
+		ONNXRuntimeLib:         getEnv("ONNXRUNTIME_LIB", ""),
+
+		TTSProvider:        getEnv("TTS_PROVIDER", "elevenlabs"),
+		TTSDailyCharBudget: getEnvAsInt("TTS_DAILY_CHAR_BUDGET", 0),
+		TTSBudgetFallback:  getEnv("TTS_BUDGET_FALLBACK", ""),
+		TTSCostPer1KChars:  getEnvAsFloat("TTS_COST_PER_1K_CHARS", 0),
+		TTSCacheDir:        getEnv("TTS_CACHE_DIR", ""),
+		TTSCacheMaxMB:      getEnvAsInt("TTS_CACHE_MAX_MB", 50),
+		TTSCacheMaxChars:   getEnvAsInt("TTS_CACHE_MAX_CHARS", 200),
+		TTSBatchChars:      getEnvAsInt("TTS_BATCH_CHARS", 60),
+		TTSBatchMs:         getEnvAsInt("TTS_BATCH_MS", 150),
+		TTSNormalize:       getEnv("TTS_NORMALIZE", ""),
 
 		ElevenLabsAPIKey:     getEnv("ELEVENLABS_API_KEY", ""),
 		ElevenLabsVoiceID:    getEnv("ELEVENLABS_VOICE_ID", "aSLKtNoVBZlxQEMsnGL2"),
 		ElevenLabsModel:      getEnv("ELEVENLABS_MODEL", "eleven_flash_v2_5"),
+		ElevenLabsFormat:     getEnv("ELEVENLABS_OUTPUT_FORMAT", "pcm_24000"),
 		ElevenLabsStability:  getEnvAsFloat("ELEVENLABS_STABILITY", 0.5),
 		ElevenLabsSimilarity: getEnvAsFloat("ELEVENLABS_SIMILARITY", 0.8),
 		ElevenLabsSpeed:      getEnvAsFloat("ELEVENLABS_SPEED", 1.20),
+		ElevenLabsReconnects: getEnvAsInt("ELEVENLABS_MAX_RECONNECTS", 2),
+		ElevenLabsBackoffMs:  getEnvAsInt("ELEVENLABS_RECONNECT_BACKOFF_MS", 250),
+		ElevenLabsPoolSize:   getEnvAsInt("ELEVENLABS_POOL_SIZE", 1),
+		ElevenLabsKeepAlive:  getEnvAsInt("ELEVENLABS_KEEPALIVE_MS", 10000),
+		ElevenLabsFlushMs:    getEnvAsInt("ELEVENLABS_FLUSH_TIMEOUT_MS", 15000),
+		ElevenLabsMulti:      getEnvAsBool("ELEVENLABS_MULTI_CONTEXT", false),
+		ElevenLabsReuse:      getEnvAsBool("ELEVENLABS_REUSE_SESSION", false),
+		ElevenLabsAlignment:  getEnvAsBool("ELEVENLABS_ALIGNMENT", false),
+		ElevenLabsSchedule:   getEnvAsIntSlice("ELEVENLABS_CHUNK_SCHEDULE", nil),
+		ElevenLabsAutoMode:   getEnvAsBool("ELEVENLABS_AUTO_MODE", false),
+
+		ElevenLabsDictionaries: getEnvAsSlice("ELEVENLABS_PRONUNCIATION_DICTIONARIES", nil),
+		ElevenLabsStyle:        getEnvAsFloat("ELEVENLABS_STYLE", 0),
+		ElevenLabsSpeakerBoost: getEnvAsOptionalBool("ELEVENLABS_SPEAKER_BOOST"),
+		ElevenLabsSeed:         getEnvAsInt("ELEVENLABS_SEED", 0),
+		ElevenLabsLanguage:     getEnv("ELEVENLABS_LANGUAGE", ""),
+
+		ElevenLabsDialRetries:      getEnvAsInt("ELEVENLABS_DIAL_RETRIES", 2),
+		ElevenLabsDialBackoffMs:    getEnvAsInt("ELEVENLABS_DIAL_BACKOFF_MS", 200),
+		ElevenLabsDialMaxBackoffMs: getEnvAsInt("ELEVENLABS_DIAL_MAX_BACKOFF_MS", 2000),
+		ElevenLabsDialJitter:       getEnvAsFloat("ELEVENLABS_DIAL_JITTER", 0.2),
+
+		OpenAITTSModel:  getEnv("OPENAI_TTS_MODEL", "tts-1"),
+		OpenAITTSVoice:  getEnv("OPENAI_TTS_VOICE", "alloy"),
+		OpenAITTSFormat: getEnv("OPENAI_TTS_FORMAT", "pcm"),
+		OpenAITTSSpeed:  getEnvAsFloat("OPENAI_TTS_SPEED", 1.0),
+
+		PiperURL:        getEnv("PIPER_URL", ""),
+		PiperBin:        getEnv("PIPER_BIN", ""),
+		PiperModel:      getEnv("PIPER_MODEL", ""),
+		PiperSampleRate: getEnvAsInt("PIPER_SAMPLE_RATE", 0),
+
+		AzureSpeechKey:    getEnv("AZURE_SPEECH_KEY", ""),
+		AzureSpeechRegion: getEnv("AZURE_SPEECH_REGION", ""),
+		AzureSpeechVoice:  getEnv("AZURE_SPEECH_VOICE", "sv-SE-SofieNeural"),
+
+		LexiconPath:     getEnv("LEXICON_PATH", "lexicon.txt"),
+		LexiconLanguage: getEnv("LEXICON_LANGUAGE", "sv"),
+
+		PronunciationRulesPath: getEnv("PRONUNCIATION_RULES_PATH", "pronunciations.yaml"),
+
+		TranscriptWakePhrases: getEnvAsSlice("TRANSCRIPT_WAKE_PHRASES", []string{"sho bror", "show bror"}),
+		TranscriptFillers:     getEnvAsSlice("TRANSCRIPT_FILLERS", []string{"eh", "öh", "ehm", "hmm", "alltså", "asså"}),
+		TranscriptStopPhrases: getEnvAsSlice("TRANSCRIPT_STOP_PHRASES", []string{"stopp", "sluta", "tyst", "det räcker"}),
+
+		TranscriptWordTimestamps: getEnvAsBool("TRANSCRIPT_WORD_TIMESTAMPS", false),
+		TranscriptWordModel:      getEnv("TRANSCRIPT_WORD_MODEL", "whisper-1"),
+		TranscriptWakeTailMs:     getEnvAsInt("TRANSCRIPT_WAKE_TAIL_MS", 1000),
+
+		StyleMatching:      getEnvAsBool("STYLE_MATCHING", false),
+		StyleShortMaxWords: getEnvAsInt("STYLE_SHORT_MAX_WORDS", 8),
+		StyleLongMinWords:  getEnvAsInt("STYLE_LONG_MIN_WORDS", 30),
+		StyleShortHint:     getEnv("STYLE_SHORT_HINT", ""),
+		StyleLongHint:      getEnv("STYLE_LONG_HINT", ""),
+		StyleCommandHint:   getEnv("STYLE_COMMAND_HINT", ""),
+		StylePoliteHint:    getEnv("STYLE_POLITE_HINT", ""),
+		StyleCasualHint:    getEnv("STYLE_CASUAL_HINT", ""),
+
+		LoadMonitor:       getEnvAsBool("LOAD_MONITOR", true),
+		LoadIntervalMs:    getEnvAsInt("LOAD_INTERVAL_MS", 5000),
+		LoadSustainMs:     getEnvAsInt("LOAD_SUSTAIN_MS", 15000),
+		LoadNSCPUAbove:    getEnvAsFloat("LOAD_NS_CPU_ABOVE", 85),
+		LoadNSCPUBelow:    getEnvAsFloat("LOAD_NS_CPU_BELOW", 60),
+		LoadTTSCPUAbove:   getEnvAsFloat("LOAD_TTS_CPU_ABOVE", 90),
+		LoadTTSCPUBelow:   getEnvAsFloat("LOAD_TTS_CPU_BELOW", 70),
+		LoadDegradedModel: getEnv("LOAD_DEGRADED_TTS_MODEL", "eleven_flash_v2_5"),
 	}
 
 	return config, nil
@@ -76,6 +389,22 @@ func getEnvAsSlice(key string, defaultValue []string) []string {
 	return strings.Split(valueStr, ",")
 }
 
+func getEnvAsIntSlice(key string, defaultValue []int) []int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	var values []int
+	for _, s := range strings.Split(valueStr, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return defaultValue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
 func getEnvAsFloat(key string, defaultValue float64) float64 {
 	valueStr := os.Getenv(key)
 	if valueStr == "" {
@@ -99,3 +428,56 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return value
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsOptionalBool is nil when key is unset or not a bool, for
+// settings whose default belongs to someone else.
+func getEnvAsOptionalBool(key string) *bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+// SetEnvValue updates key in envFile in place, keeping comments and the
+// order of the other entries, and appends it if it isn't set yet.
+func SetEnvValue(envFile, key, value string) error {
+	data, err := os.ReadFile(envFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading env file: %w", err)
+	}
+
+	line := key + "=" + strconv.Quote(value)
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	found := false
+	for i, l := range lines {
+		trimmed := strings.TrimPrefix(strings.TrimSpace(l), "export ")
+		if strings.HasPrefix(trimmed, key+"=") {
+			lines[i] = line
+			found = true
+		}
+	}
+	if !found {
+		if len(lines) == 1 && lines[0] == "" {
+			lines = lines[:0]
+		}
+		lines = append(lines, line)
+	}
+
+	if err := os.WriteFile(envFile, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		return fmt.Errorf("writing env file: %w", err)
+	}
+	return nil
+}
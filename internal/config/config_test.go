@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+func TestGetEnvAsIntSlice(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []int
+	}{
+		{"unset", "", []int{1}},
+		{"one", "120", []int{120}},
+		{"several", "120,160,250,290", []int{120, 160, 250, 290}},
+		{"spaces", " 120 , 160 ", []int{120, 160}},
+		{"not a number", "120,fast", []int{1}},
+		{"empty entry", "120,,160", []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_INT_SLICE", tt.value)
+			if got := getEnvAsIntSlice("TEST_INT_SLICE", []int{1}); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEnvAsOptionalBool(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string // "unset", "true" or "false"
+	}{
+		{"", "unset"},
+		{"true", "true"},
+		{"false", "false"},
+		{"1", "true"},
+		{"maybe", "unset"},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_OPTIONAL_BOOL", tt.value)
+		got := "unset"
+		if b := getEnvAsOptionalBool("TEST_OPTIONAL_BOOL"); b != nil {
+			got = strconv.FormatBool(*b)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSetEnvValue(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string // "" for no file at all
+		want string
+	}{
+		{"no file", "", "ELEVENLABS_VOICE_ID=\"v2\"\n"},
+		{"appended",
+			"OPENAI_API_KEY=sk\n",
+			"OPENAI_API_KEY=sk\nELEVENLABS_VOICE_ID=\"v2\"\n"},
+		{"replaced in place",
+			"A=1\nELEVENLABS_VOICE_ID=v1\nB=2\n",
+			"A=1\nELEVENLABS_VOICE_ID=\"v2\"\nB=2\n"},
+		{"exported",
+			"export ELEVENLABS_VOICE_ID=\"v1\"\n",
+			"ELEVENLABS_VOICE_ID=\"v2\"\n"},
+		{"comments kept",
+			"# ELEVENLABS_VOICE_ID=old\nELEVENLABS_VOICE_ID=v1",
+			"# ELEVENLABS_VOICE_ID=old\nELEVENLABS_VOICE_ID=\"v2\"\n"},
+		{"longer key left alone",
+			"ELEVENLABS_VOICE_ID_BACKUP=v0\n",
+			"ELEVENLABS_VOICE_ID_BACKUP=v0\nELEVENLABS_VOICE_ID=\"v2\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if tt.env != "" {
+				if err := os.WriteFile(path, []byte(tt.env), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := SetEnvValue(path, "ELEVENLABS_VOICE_ID", "v2"); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("env file\n got %q\nwant %q", got, tt.want)
+			}
+			// What was written reads back as the value.
+			env, err := godotenv.Read(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if env["ELEVENLABS_VOICE_ID"] != "v2" {
+				t.Errorf("ELEVENLABS_VOICE_ID reads back as %q", env["ELEVENLABS_VOICE_ID"])
+			}
+		})
+	}
+}
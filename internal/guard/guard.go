@@ -0,0 +1,98 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Guard turns panics in turns, tools and background goroutines into logged
+// errors so one bad code path doesn't take the microphone down with it. If
+// panics keep coming it gives up and calls escalate, which should shut the
+// process down cleanly.
+type Guard struct {
+	limit    int
+	window   time.Duration
+	escalate func()
+	now      func() time.Time
+
+	mu      sync.Mutex
+	recent  []time.Time
+	counter metric.Int64Counter
+}
+
+func New(limit int, window time.Duration, escalate func()) (*Guard, error) {
+	counter, err := otel.Meter("github.com/joakimcarlsson/smarthome/internal/guard").Int64Counter(
+		"panics",
+		metric.WithDescription("Panics recovered, by component"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating panic counter: %w", err)
+	}
+	return &Guard{
+		limit:    limit,
+		window:   window,
+		escalate: escalate,
+		now:      time.Now,
+		counter:  counter,
+	}, nil
+}
+
+// Recover must be deferred directly: defer g.Recover("turn").
+func (g *Guard) Recover(component string) {
+	if r := recover(); r != nil {
+		g.Handle(component, r, debug.Stack())
+	}
+}
+
+// Go runs fn on a new goroutine under Recover.
+func (g *Guard) Go(component string, fn func()) {
+	go func() {
+		defer g.Recover(component)
+		fn()
+	}()
+}
+
+// Handle records a panic that was recovered elsewhere and reports whether
+// it escalated.
+func (g *Guard) Handle(component string, value any, stack []byte) bool {
+	slog.Error("recovered panic",
+		"component", component,
+		"panic", fmt.Sprint(value),
+		"stack", string(stack),
+	)
+	if g == nil {
+		return false
+	}
+	g.counter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("component", component)))
+
+	g.mu.Lock()
+	now := g.now()
+	kept := g.recent[:0]
+	for _, t := range g.recent {
+		if now.Sub(t) < g.window {
+			kept = append(kept, t)
+		}
+	}
+	g.recent = append(kept, now)
+	escalated := g.limit > 0 && len(g.recent) >= g.limit
+	if escalated {
+		g.recent = g.recent[:0]
+	}
+	g.mu.Unlock()
+
+	if escalated {
+		slog.Error("too many panics, shutting down", "limit", g.limit, "window", g.window)
+		if g.escalate != nil {
+			g.escalate()
+		}
+	}
+	return escalated
+}
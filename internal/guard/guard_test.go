@@ -0,0 +1,109 @@
+package guard
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestGuard returns a guard on a clock the test moves by hand, and a
+// count of how often it escalated.
+func newTestGuard(t *testing.T, limit int, window time.Duration) (g *Guard, clock *time.Time, escalations *int) {
+	t.Helper()
+	escalations = new(int)
+	g, err := New(limit, window, func() { *escalations++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock = &start
+	g.now = func() time.Time { return *clock }
+	return g, clock, escalations
+}
+
+func TestHandleEscalation(t *testing.T) {
+	tests := []struct {
+		name string
+		// gaps is the time before each panic.
+		gaps []time.Duration
+		want []bool
+	}{
+		{"under the limit", []time.Duration{0, time.Second}, []bool{false, false}},
+		{"at the limit", []time.Duration{0, time.Second, time.Second}, []bool{false, false, true}},
+		{"count starts over", []time.Duration{0, 0, 0, 0, 0, 0}, []bool{false, false, true, false, false, true}},
+		{"spread past the window", []time.Duration{0, 6 * time.Second, 6 * time.Second, 6 * time.Second}, []bool{false, false, false, false}},
+		{"old panics age out", []time.Duration{0, 9 * time.Second, 2 * time.Second, time.Second}, []bool{false, false, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, clock, escalations := newTestGuard(t, 3, 10*time.Second)
+			wantEscalations := 0
+			for i, gap := range tt.gaps {
+				*clock = clock.Add(gap)
+				if got := g.Handle("test", "boom", nil); got != tt.want[i] {
+					t.Errorf("panic %d: escalated = %v, want %v", i, got, tt.want[i])
+				}
+				if tt.want[i] {
+					wantEscalations++
+				}
+			}
+			if *escalations != wantEscalations {
+				t.Errorf("escalate called %d times, want %d", *escalations, wantEscalations)
+			}
+		})
+	}
+}
+
+func TestHandleWithoutLimit(t *testing.T) {
+	g, _, escalations := newTestGuard(t, 0, time.Minute)
+	for range 100 {
+		if g.Handle("test", "boom", nil) {
+			t.Fatal("escalated with no limit set")
+		}
+	}
+	if *escalations != 0 {
+		t.Errorf("escalate called %d times, want 0", *escalations)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	g, _, escalations := newTestGuard(t, 1, time.Minute)
+	func() {
+		defer g.Recover("turn")
+		var m map[string]int
+		m["x"] = 1
+	}()
+	if *escalations != 1 {
+		t.Errorf("escalate called %d times after a recovered panic, want 1", *escalations)
+	}
+
+	// Nothing to recover is not a panic.
+	func() { defer g.Recover("turn") }()
+	if *escalations != 1 {
+		t.Errorf("escalate called %d times without a panic, want 1", *escalations)
+	}
+}
+
+func TestGo(t *testing.T) {
+	done := make(chan struct{})
+	g, err := New(1, time.Minute, func() { close(done) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Go("worker", func() { panic("boom") })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("panic on the goroutine wasn't handled")
+	}
+}
+
+func TestNilGuard(t *testing.T) {
+	var g *Guard
+	if g.Handle("test", "boom", nil) {
+		t.Error("a nil guard escalated")
+	}
+	func() {
+		defer g.Recover("test")
+		panic("boom")
+	}()
+}
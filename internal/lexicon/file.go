@@ -0,0 +1,111 @@
+package lexicon
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// File is a lexicon backed by a file on disk that is reloaded when the file
+// changes. A missing file is an empty lexicon, and a file that fails to parse
+// keeps the last good version.
+type File struct {
+	watched[Lexicon]
+}
+
+func Open(path string) (*File, error) {
+	f := &File{watched[Lexicon]{name: "lexicon", path: path, load: Load}}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) Lexicon() *Lexicon {
+	return f.current.Load()
+}
+
+// RulesFile is the replacement rules file, reloaded the same way as File.
+type RulesFile struct {
+	watched[Rules]
+}
+
+func OpenRules(path string) (*RulesFile, error) {
+	f := &RulesFile{watched[Rules]{name: "pronunciation rules", path: path, load: LoadRules}}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RulesFile) Rules() *Rules {
+	return f.current.Load()
+}
+
+// watched holds what was last parsed from path.
+type watched[T any] struct {
+	name    string
+	path    string
+	load    func(path string) (*T, error)
+	current atomic.Pointer[T]
+	modTime time.Time
+}
+
+func (w *watched[T]) open() error {
+	w.current.Store(new(T))
+	return w.reload()
+}
+
+func (w *watched[T]) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.reload(); err != nil {
+				slog.Error("reloading "+w.name, "path", w.path, "error", err)
+			}
+		}
+	}
+}
+
+func (w *watched[T]) reload() error {
+	info, err := os.Stat(w.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !w.modTime.IsZero() {
+			slog.Info(w.name+" removed", "path", w.path)
+			w.modTime = time.Time{}
+			w.current.Store(new(T))
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(w.modTime) {
+		return nil
+	}
+
+	v, err := w.load(w.path)
+	if err != nil {
+		return err
+	}
+	w.modTime = info.ModTime()
+	w.current.Store(v)
+	slog.Info(w.name+" loaded", "path", w.path, "entries", entries(v))
+	return nil
+}
+
+func entries(v any) int {
+	if l, ok := v.(interface{ Len() int }); ok {
+		return l.Len()
+	}
+	return 0
+}
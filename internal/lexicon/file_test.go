@@ -0,0 +1,65 @@
+package lexicon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lexicon.txt")
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := f.Lexicon().Len(); n != 0 {
+		t.Fatalf("missing file has %d entries, want none", n)
+	}
+
+	write := func(text string, age time.Duration) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// Each version gets its own modification time.
+		at := time.Now().Add(-age)
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apply := func() string { return f.Lexicon().Apply("Bälstaberg", "sv", false) }
+
+	write("Bälstaberg = Bälsta-berj", 2*time.Hour)
+	if err := f.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := apply(); got != "Bälsta-berj" {
+		t.Errorf("after the file appeared: %q", got)
+	}
+
+	// A broken edit keeps the last good version.
+	write("Bälstaberg", time.Hour)
+	if err := f.reload(); err == nil {
+		t.Error("reload of a broken file succeeded")
+	}
+	if got := apply(); got != "Bälsta-berj" {
+		t.Errorf("after a broken edit: %q, want the last good version", got)
+	}
+
+	write("Bälstaberg = Bälstaberj", 0)
+	if err := f.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := apply(); got != "Bälstaberj" {
+		t.Errorf("after fixing the file: %q", got)
+	}
+
+	os.Remove(path)
+	if err := f.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := apply(); got != "Bälstaberg" {
+		t.Errorf("after removing the file: %q, want no replacement", got)
+	}
+}
@@ -0,0 +1,199 @@
+package lexicon
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// global holds entries declared before any [language] section.
+const global = "*"
+
+type Entry struct {
+	Respelling string
+	Phoneme    string
+}
+
+// Lexicon maps lowercased words to pronunciation hints, per language. The
+// file format is line based:
+//
+//	# comment
+//	Bälstaberg = Bälsta-berj
+//	[sv]
+//	Vallentuna = Vallentoona /ˈvalːɛnˌtʉːna/
+//
+// The right hand side is a respelling, an IPA phoneme hint between slashes,
+// or both.
+type Lexicon struct {
+	entries map[string]map[string]Entry
+}
+
+func Load(path string) (*Lexicon, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening lexicon: %w", err)
+	}
+	defer f.Close()
+
+	lex, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return lex, nil
+}
+
+func Parse(r io.Reader) (*Lexicon, error) {
+	lex := &Lexicon{entries: map[string]map[string]Entry{}}
+	lang := global
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			lang = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if lang == "" {
+				return nil, fmt.Errorf("line %d: empty language section", n)
+			}
+			continue
+		}
+
+		word, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected word = replacement", n)
+		}
+		word = strings.TrimSpace(word)
+		if word == "" || strings.IndexFunc(word, func(r rune) bool { return !isWordRune(r) }) >= 0 {
+			return nil, fmt.Errorf("line %d: %q is not a single word", n, word)
+		}
+
+		entry, err := parseEntry(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		if lex.entries[lang] == nil {
+			lex.entries[lang] = map[string]Entry{}
+		}
+		lex.entries[lang][strings.ToLower(word)] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading lexicon: %w", err)
+	}
+	return lex, nil
+}
+
+func parseEntry(value string) (Entry, error) {
+	var e Entry
+	if i := strings.Index(value, "/"); i >= 0 {
+		j := strings.LastIndex(value, "/")
+		if j == i {
+			return e, fmt.Errorf("unterminated phoneme hint in %q", value)
+		}
+		e.Phoneme = strings.TrimSpace(value[i+1 : j])
+		value = strings.TrimSpace(value[:i] + value[j+1:])
+	}
+	e.Respelling = value
+	if e.Respelling == "" && e.Phoneme == "" {
+		return e, fmt.Errorf("missing replacement")
+	}
+	return e, nil
+}
+
+func (l *Lexicon) Len() int {
+	if l == nil {
+		return 0
+	}
+	n := 0
+	for _, m := range l.entries {
+		n += len(m)
+	}
+	return n
+}
+
+func (l *Lexicon) lookup(word, lang string) (Entry, bool) {
+	if l == nil {
+		return Entry{}, false
+	}
+	key := strings.ToLower(word)
+	if e, ok := l.entries[strings.ToLower(lang)][key]; ok {
+		return e, true
+	}
+	e, ok := l.entries[global][key]
+	return e, ok
+}
+
+// Apply rewrites every whole word in text that has an entry. With phonemes
+// set, entries with a phoneme hint become SSML phoneme tags; otherwise the
+// respelling is used, and words with only a phoneme hint are left alone.
+func (l *Lexicon) Apply(text, lang string, phonemes bool) string {
+	if l.Len() == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			b.WriteString(l.replace(text[start:i], lang, phonemes))
+			start = -1
+		}
+		// Not WriteRune, which would turn a stray byte into U+FFFD.
+		_, size := utf8.DecodeRuneInString(text[i:])
+		b.WriteString(text[i : i+size])
+	}
+	if start >= 0 {
+		b.WriteString(l.replace(text[start:], lang, phonemes))
+	}
+	return b.String()
+}
+
+func (l *Lexicon) replace(word, lang string, phonemes bool) string {
+	e, ok := l.lookup(word, lang)
+	if !ok {
+		return word
+	}
+	if phonemes && e.Phoneme != "" {
+		return fmt.Sprintf(`<phoneme alphabet="ipa" ph="%s">%s</phoneme>`, html.EscapeString(e.Phoneme), word)
+	}
+	if e.Respelling == "" {
+		return word
+	}
+	return matchCase(word, e.Respelling)
+}
+
+// matchCase carries the capitalisation of the spoken word over to its
+// replacement: all caps stays all caps, a leading capital stays leading.
+func matchCase(word, repl string) string {
+	if strings.ToUpper(word) == word && strings.ToLower(word) != word {
+		return strings.ToUpper(repl)
+	}
+	first, _ := utf8.DecodeRuneInString(word)
+	if unicode.IsUpper(first) {
+		r, size := utf8.DecodeRuneInString(repl)
+		return string(unicode.ToUpper(r)) + repl[size:]
+	}
+	return repl
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
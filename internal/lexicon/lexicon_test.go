@@ -0,0 +1,125 @@
+package lexicon
+
+import (
+	"strings"
+	"testing"
+)
+
+const testLexicon = `# names the voice gets wrong
+Bälstaberg = Bälsta-berj
+Vallentuna = /ˈvalːɛnˌtʉːna/
+
+[sv]
+Vallentuna = Vallentoona /ˈvalːɛnˌtʉːna/  # both
+Sollentuna = Sollentoona
+
+[EN]
+Sollentuna = Sollen-too-na
+`
+
+func mustParse(t *testing.T, text string) *Lexicon {
+	t.Helper()
+	lex, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lex
+}
+
+func TestParse(t *testing.T) {
+	lex := mustParse(t, testLexicon)
+	if n := lex.Len(); n != 5 {
+		t.Errorf("Len = %d, want 5", n)
+	}
+	tests := []struct {
+		word, lang string
+		want       Entry
+	}{
+		{"Bälstaberg", "sv", Entry{Respelling: "Bälsta-berj"}},
+		{"vallentuna", "sv", Entry{Respelling: "Vallentoona", Phoneme: "ˈvalːɛnˌtʉːna"}},
+		{"Vallentuna", "fi", Entry{Phoneme: "ˈvalːɛnˌtʉːna"}},
+		{"Sollentuna", "en", Entry{Respelling: "Sollen-too-na"}},
+	}
+	for _, tt := range tests {
+		if got, ok := lex.lookup(tt.word, tt.lang); !ok || got != tt.want {
+			t.Errorf("lookup(%q, %q) = %+v, %v, want %+v", tt.word, tt.lang, got, ok, tt.want)
+		}
+	}
+	if _, ok := lex.lookup("Sollentuna", "fi"); ok {
+		t.Error("found a [sv] entry for another language")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"no equals", "Bälstaberg Bälsta-berj", "line 1: expected word = replacement"},
+		{"two words", "Kungsholms torg = Kungsholms-torj", "not a single word"},
+		{"no word", "= Bälsta-berj", "not a single word"},
+		{"no replacement", "\n\nBälstaberg =", "line 3: missing replacement"},
+		{"unterminated phoneme", "Vallentuna = /ˈvalːɛn", "unterminated phoneme hint"},
+		{"empty section", "[ ]", "empty language section"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.text))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	lex := mustParse(t, testLexicon)
+	tests := []struct {
+		name     string
+		text     string
+		lang     string
+		phonemes bool
+		want     string
+	}{
+		{"respelled", "Vi bor i Bälstaberg.", "sv", false, "Vi bor i Bälsta-berj."},
+		// A lowercase word keeps the respelling as it is written.
+		{"lowercase", "bälstaberg", "sv", false, "Bälsta-berj"},
+		{"all caps", "BÄLSTABERG", "sv", false, "BÄLSTA-BERJ"},
+		{"whole words only", "Bälstabergsvägen och xBälstaberg", "sv", false, "Bälstabergsvägen och xBälstaberg"},
+		{"punctuation around", "(Bälstaberg), Bälstaberg!", "sv", false, "(Bälsta-berj), Bälsta-berj!"},
+		{"language section", "Sollentuna", "en", false, "Sollen-too-na"},
+		{"language section case", "Sollentuna", "SV", false, "Sollentoona"},
+		{"no section for the language", "Sollentuna", "fi", false, "Sollentuna"},
+		{"section over global", "Vallentuna", "sv", false, "Vallentoona"},
+
+		// The model decides between the phoneme hint and the respelling.
+		{"phoneme", "i Vallentuna", "sv", true,
+			`i <phoneme alphabet="ipa" ph="ˈvalːɛnˌtʉːna">Vallentuna</phoneme>`},
+		{"phoneme only, model without", "Vallentuna", "fi", false, "Vallentuna"},
+		{"phoneme only, model with", "Vallentuna", "fi", true,
+			`<phoneme alphabet="ipa" ph="ˈvalːɛnˌtʉːna">Vallentuna</phoneme>`},
+		{"respelling only, model with", "Bälstaberg", "sv", true, "Bälsta-berj"},
+		{"nothing to do", "Hej då", "sv", true, "Hej då"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lex.Apply(tt.text, tt.lang, tt.phonemes); got != tt.want {
+				t.Errorf("Apply(%q)\n got %q\nwant %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEscapesPhonemes(t *testing.T) {
+	lex := mustParse(t, `Hej = /a"b<c>&'/`)
+	want := `<phoneme alphabet="ipa" ph="a&#34;b&lt;c&gt;&amp;&#39;">Hej</phoneme>`
+	if got := lex.Apply("Hej", "sv", true); got != want {
+		t.Errorf("Apply\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestApplyEmpty(t *testing.T) {
+	var lex *Lexicon
+	if got := lex.Apply("Bälstaberg", "sv", true); got != "Bälstaberg" {
+		t.Errorf("nil lexicon changed the text to %q", got)
+	}
+}
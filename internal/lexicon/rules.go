@@ -0,0 +1,85 @@
+package lexicon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules rewrite text before it is spoken, for what a word list can't
+// express: names spelled several ways, several words, or a word only in
+// some context. The file is a YAML list applied in order:
+//
+//	# street names and names the voice gets wrong
+//	- match: '(?i)\bkungsholms? torg\b'
+//	  replace: 'Kungsholms-torj'
+//	- match: '\bLinnéa\b'
+//	  replace: 'Linneea'
+//
+// replace may refer to groups as $1 or ${name}.
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	match   *regexp.Regexp
+	replace string
+}
+
+func LoadRules(path string) (*Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening pronunciation rules: %w", err)
+	}
+	defer f.Close()
+
+	rules, err := ParseRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+func ParseRules(r io.Reader) (*Rules, error) {
+	var entries []struct {
+		Match   string `yaml:"match"`
+		Replace string `yaml:"replace"`
+	}
+	if err := yaml.NewDecoder(r).Decode(&entries); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decoding rules: %w", err)
+	}
+
+	rules := &Rules{}
+	for i, e := range entries {
+		if e.Match == "" {
+			return nil, fmt.Errorf("rule %d: missing match", i+1)
+		}
+		re, err := regexp.Compile(e.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		rules.rules = append(rules.rules, rule{match: re, replace: e.Replace})
+	}
+	return rules, nil
+}
+
+func (r *Rules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// Apply runs every rule over text, each on the result of the one before.
+func (r *Rules) Apply(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, rule := range r.rules {
+		text = rule.match.ReplaceAllString(text, rule.replace)
+	}
+	return text
+}
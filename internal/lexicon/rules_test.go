@@ -0,0 +1,67 @@
+package lexicon
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustParseRules(t *testing.T, text string) *Rules {
+	t.Helper()
+	rules, err := ParseRules(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestRules(t *testing.T) {
+	rules := mustParseRules(t, `
+- match: '(?i)\bkungsholms? torg\b'
+  replace: 'Kungsholms-torj'
+- match: '\b(?P<name>Linn)éa\b'
+  replace: '${name}eea'
+- match: 'torj'
+  replace: 'torg'
+`)
+	tests := []struct {
+		in, want string
+	}{
+		{"vid Kungsholm torg", "vid Kungsholms-torg"},
+		{"Linnéa och Linnéas", "Linneea och Linnéas"},
+		{"inget här", "inget här"},
+	}
+	for _, tt := range tests {
+		if got := rules.Apply(tt.in); got != tt.want {
+			t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"no match", "- replace: x", "rule 1: missing match"},
+		{"bad regexp", "- match: a\n- match: '('", "rule 2:"},
+		{"not a list", "match: a", "decoding rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRules(strings.NewReader(tt.text))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesEmpty(t *testing.T) {
+	rules := mustParseRules(t, "")
+	if rules.Len() != 0 || rules.Apply("hej") != "hej" {
+		t.Error("an empty rules file changed the text")
+	}
+	var none *Rules
+	if none.Apply("hej") != "hej" {
+		t.Error("nil rules changed the text")
+	}
+}
@@ -0,0 +1,95 @@
+package lexicon
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Stream applies a lexicon to text arriving in deltas. A word may be split
+// across deltas, so the trailing partial word is held back until the next
+// delta or Flush shows where it ends.
+type Stream struct {
+	lex      *Lexicon
+	rules    *Rules
+	lang     string
+	phonemes bool
+	pending  strings.Builder
+}
+
+func (l *Lexicon) Stream(lang string, phonemes bool) *Stream {
+	return &Stream{lex: l, lang: lang, phonemes: phonemes}
+}
+
+// WithRules applies rules ahead of the lexicon. A rule may span words, so
+// with any rules text is held back to the end of a sentence instead.
+func (s *Stream) WithRules(rules *Rules) *Stream {
+	s.rules = rules
+	return s
+}
+
+func (s *Stream) Write(delta string) string {
+	s.pending.WriteString(delta)
+	text := s.pending.String()
+
+	var cut int
+	if s.rules.Len() > 0 {
+		cut = sentenceEnd(text)
+	} else {
+		cut = wordEnd(text)
+	}
+
+	s.pending.Reset()
+	s.pending.WriteString(text[cut:])
+	return s.apply(text[:cut])
+}
+
+func (s *Stream) Flush() string {
+	text := s.pending.String()
+	s.pending.Reset()
+	return s.apply(text)
+}
+
+func (s *Stream) apply(text string) string {
+	if text == "" {
+		return ""
+	}
+	return s.lex.Apply(s.rules.Apply(text), s.lang, s.phonemes)
+}
+
+// wordEnd returns where the trailing partial word of text starts. A rune
+// cut off at the end of a delta is held back with it.
+func wordEnd(text string) int {
+	cut := len(text)
+	for i := len(text) - 1; i >= 0 && i >= len(text)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(text[i]) {
+			if !utf8.FullRuneInString(text[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	for cut > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:cut])
+		if !isWordRune(r) {
+			break
+		}
+		cut -= size
+	}
+	return cut
+}
+
+// sentenceEnd returns the index just past the last sentence break in text:
+// a ., !, ?, : or ; followed by a space, or a newline. It is 0 if there is
+// none.
+func sentenceEnd(text string) int {
+	for i := len(text) - 1; i > 0; i-- {
+		switch {
+		case text[i] == '\n':
+			return i + 1
+		case unicode.IsSpace(rune(text[i])) && strings.ContainsRune(".!?:;", rune(text[i-1])):
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package lexicon
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// stream writes deltas to s and returns everything it let through.
+func stream(s *Stream, deltas ...string) string {
+	var b strings.Builder
+	for _, d := range deltas {
+		b.WriteString(s.Write(d))
+	}
+	b.WriteString(s.Flush())
+	return b.String()
+}
+
+func TestStreamSplitWords(t *testing.T) {
+	lex := mustParse(t, testLexicon)
+	tests := []struct {
+		name   string
+		deltas []string
+		want   string
+	}{
+		{"whole", []string{"Vi bor i Bälstaberg."}, "Vi bor i Bälsta-berj."},
+		{"split word", []string{"Vi bor i Bäls", "tab", "erg."}, "Vi bor i Bälsta-berj."},
+		{"split inside a rune", []string{"i B\xc3", "\xa4lstaberg"}, "i Bälsta-berj"},
+		{"word ends the stream", []string{"i Bälsta", "berg"}, "i Bälsta-berj"},
+		// The word is only known to be whole once something follows it.
+		{"prefix of a longer word", []string{"Bälstaberg", "svägen"}, "Bälstabergsvägen"},
+		{"delta per rune", strings.Split("till Sollentuna nu", ""), "till Sollentoona nu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stream(lex.Stream("sv", false), tt.deltas...); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamHoldsPartialWord(t *testing.T) {
+	s := mustParse(t, testLexicon).Stream("sv", false)
+	if got := s.Write("Vi bor i Bälsta"); got != "Vi bor i " {
+		t.Errorf("Write = %q, want the partial word held back", got)
+	}
+	if got := s.Write("berg. Och"); got != "Bälsta-berj. " {
+		t.Errorf("Write = %q, want the finished word", got)
+	}
+	if got := s.Flush(); got != "Och" {
+		t.Errorf("Flush = %q, want the rest", got)
+	}
+	if got := s.Flush(); got != "" {
+		t.Errorf("second Flush = %q, want nothing", got)
+	}
+}
+
+func TestStreamRandomDeltas(t *testing.T) {
+	lex := mustParse(t, testLexicon)
+	rules := mustParseRules(t, "- match: '(?i)kungsholms torg'\n  replace: 'Kungsholms-torj'\n")
+	const text = "Från Bälstaberg till Sollentuna, via Kungsholms torg! Sen Vallentuna.\nBÄLSTABERG igen."
+	for _, phonemes := range []bool{false, true} {
+		want := lex.Apply(rules.Apply(text), "sv", phonemes)
+		r := rand.New(rand.NewPCG(1, 2))
+		for range 200 {
+			var deltas []string
+			for rest := text; rest != ""; {
+				n := min(1+r.IntN(6), len(rest))
+				deltas = append(deltas, rest[:n])
+				rest = rest[n:]
+			}
+			got := stream(lex.Stream("sv", phonemes).WithRules(rules), deltas...)
+			if got != want {
+				t.Fatalf("phonemes %v, deltas %q:\n got %q\nwant %q", phonemes, deltas, got, want)
+			}
+		}
+	}
+}
+
+func TestStreamRulesWaitForSentence(t *testing.T) {
+	rules := mustParseRules(t, "- match: 'Kungsholms torg'\n  replace: 'Kungsholms-torj'\n")
+	s := (*Lexicon)(nil).Stream("sv", false).WithRules(rules)
+	// A rule can span words, so nothing goes out before a sentence ends.
+	if got := s.Write("Vid Kungsholms "); got != "" {
+		t.Errorf("Write = %q, want it held to the end of the sentence", got)
+	}
+	if got := s.Write("torg. Sen"); got != "Vid Kungsholms-torj. " {
+		t.Errorf("Write = %q, want the rewritten sentence", got)
+	}
+	if got := s.Flush(); got != "Sen" {
+		t.Errorf("Flush = %q", got)
+	}
+}
+
+func TestWordEnd(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hej", 0},
+		{"hej ", 4},
+		{"hej då", 4},
+		{"hej då.", 8},
+		{"i Bäls", 2},
+		{"i B\xc3", 2},
+	}
+	for _, tt := range tests {
+		got := wordEnd(tt.text)
+		if got != tt.want {
+			t.Errorf("wordEnd(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+		if !utf8.ValidString(tt.text[:got]) {
+			t.Errorf("wordEnd(%q) cuts a rune", tt.text)
+		}
+	}
+}
+
+func TestSentenceEnd(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"Hej", 0},
+		{"Hej.", 0},
+		{"Hej. Då", 5},
+		{"Ett! Två? Tre", 11},
+		{"Klockan 3.5 nu", 0},
+		{"rad\nnästa", 4},
+		{"först; sen", 8},
+	}
+	for _, tt := range tests {
+		if got := sentenceEnd(tt.text); got != tt.want {
+			t.Errorf("sentenceEnd(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
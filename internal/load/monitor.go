@@ -0,0 +1,128 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Monitor samples process and system load, publishes it as metrics and
+// toggles feature flags from its rules. Components check the flags they
+// care about with Enabled.
+type Monitor struct {
+	interval  time.Duration
+	evaluator *Evaluator
+	sampler   sampler
+
+	mu     sync.RWMutex
+	latest Sample
+	flags  map[Flag]bool
+}
+
+func NewMonitor(interval time.Duration, rules ...Rule) (*Monitor, error) {
+	evaluator, err := NewEvaluator(rules...)
+	if err != nil {
+		return nil, err
+	}
+	m := &Monitor{
+		interval:  interval,
+		evaluator: evaluator,
+		flags:     map[Flag]bool{},
+	}
+	if err := m.registerMetrics(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		s, err := m.sampler.sample(time.Now())
+		if err != nil {
+			slog.Error("sampling load, monitor stopped", "error", err)
+			return
+		}
+		m.record(s)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) record(s Sample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.latest = s
+	for _, c := range m.evaluator.Evaluate(s) {
+		m.flags[c.Flag] = c.Active
+		if c.Active {
+			slog.Warn("degrading under load", "flag", c.Flag, "value", c.Value)
+		} else {
+			slog.Info("load recovered", "flag", c.Flag, "value", c.Value)
+		}
+	}
+}
+
+func (m *Monitor) Enabled(f Flag) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.flags[f]
+}
+
+func (m *Monitor) Latest() Sample {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.latest
+}
+
+func (m *Monitor) registerMetrics() error {
+	meter := otel.Meter("github.com/joakimcarlsson/smarthome/internal/load")
+
+	processCPU, err := meter.Float64ObservableGauge("process.cpu.utilization", metric.WithUnit("%"))
+	if err != nil {
+		return fmt.Errorf("creating process cpu gauge: %w", err)
+	}
+	systemCPU, err := meter.Float64ObservableGauge("system.cpu.utilization", metric.WithUnit("%"))
+	if err != nil {
+		return fmt.Errorf("creating system cpu gauge: %w", err)
+	}
+	systemMem, err := meter.Float64ObservableGauge("system.memory.utilization", metric.WithUnit("%"))
+	if err != nil {
+		return fmt.Errorf("creating system memory gauge: %w", err)
+	}
+	processRSS, err := meter.Int64ObservableGauge("process.memory.rss", metric.WithUnit("By"))
+	if err != nil {
+		return fmt.Errorf("creating rss gauge: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := m.Latest()
+		if s.Time.IsZero() {
+			return nil
+		}
+		o.ObserveFloat64(processCPU, s.ProcessCPU)
+		o.ObserveFloat64(systemCPU, s.SystemCPU)
+		o.ObserveFloat64(systemMem, s.SystemMem)
+		o.ObserveInt64(processRSS, int64(s.ProcessRSS))
+		return nil
+	}, processCPU, systemCPU, systemMem, processRSS)
+	if err != nil {
+		return fmt.Errorf("registering load metrics: %w", err)
+	}
+	return nil
+}
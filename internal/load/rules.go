@@ -0,0 +1,117 @@
+package load
+
+import (
+	"fmt"
+	"time"
+)
+
+type Flag string
+
+const (
+	FlagSkipNoiseSuppression Flag = "skip_noise_suppression"
+	FlagDowngradeTTS         Flag = "downgrade_tts"
+)
+
+type Metric string
+
+const (
+	MetricProcessCPU Metric = "process_cpu"
+	MetricSystemCPU  Metric = "system_cpu"
+	MetricSystemMem  Metric = "system_mem"
+)
+
+// Rule raises Flag once Metric has stayed above Above for Sustain, and
+// clears it once the metric has stayed below Below for Sustain. Keeping
+// Below under Above is what stops the flag flapping around one threshold.
+type Rule struct {
+	Flag    Flag
+	Metric  Metric
+	Above   float64
+	Below   float64
+	Sustain time.Duration
+}
+
+func (r Rule) validate() error {
+	switch r.Metric {
+	case MetricProcessCPU, MetricSystemCPU, MetricSystemMem:
+	default:
+		return fmt.Errorf("rule %s: unknown metric %q", r.Flag, r.Metric)
+	}
+	if r.Below > r.Above {
+		return fmt.Errorf("rule %s: clear threshold %.1f above raise threshold %.1f", r.Flag, r.Below, r.Above)
+	}
+	return nil
+}
+
+type Change struct {
+	Flag   Flag
+	Active bool
+	Value  float64
+}
+
+type ruleState struct {
+	active bool
+	// pendingSince is when the metric first crossed the threshold that
+	// would flip the rule, or zero if it hasn't.
+	pendingSince time.Time
+}
+
+// Evaluator applies rules to a series of samples. It does no I/O, so load
+// curves can be replayed through it directly.
+type Evaluator struct {
+	rules  []Rule
+	states []ruleState
+}
+
+func NewEvaluator(rules ...Rule) (*Evaluator, error) {
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &Evaluator{
+		rules:  rules,
+		states: make([]ruleState, len(rules)),
+	}, nil
+}
+
+func (e *Evaluator) Evaluate(s Sample) []Change {
+	var changes []Change
+	for i, r := range e.rules {
+		st := &e.states[i]
+		v := s.value(r.Metric)
+
+		crossing := v > r.Above
+		if st.active {
+			crossing = v < r.Below
+		}
+		if !crossing {
+			st.pendingSince = time.Time{}
+			continue
+		}
+		if st.pendingSince.IsZero() {
+			st.pendingSince = s.Time
+		}
+		if s.Time.Sub(st.pendingSince) < r.Sustain {
+			continue
+		}
+
+		st.active = !st.active
+		st.pendingSince = time.Time{}
+		changes = append(changes, Change{Flag: r.Flag, Active: st.active, Value: v})
+	}
+	return changes
+}
+
+func (s Sample) value(m Metric) float64 {
+	switch m {
+	case MetricProcessCPU:
+		return s.ProcessCPU
+	case MetricSystemCPU:
+		return s.SystemCPU
+	case MetricSystemMem:
+		return s.SystemMem
+	default:
+		return 0
+	}
+}
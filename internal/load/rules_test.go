@@ -0,0 +1,136 @@
+package load
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// replay runs curve, one sample a second, through rule and returns what
+// happened at each: + raised, - cleared, . nothing.
+func replay(t *testing.T, rule Rule, curve []float64) string {
+	t.Helper()
+	e, err := NewEvaluator(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Unix(0, 0)
+	var b strings.Builder
+	for i, v := range curve {
+		changes := e.Evaluate(Sample{Time: t0.Add(time.Duration(i) * time.Second), SystemCPU: v})
+		switch {
+		case len(changes) == 0:
+			b.WriteByte('.')
+		case len(changes) > 1:
+			t.Fatalf("sample %d: %d changes from one rule", i, len(changes))
+		case changes[0].Value != v:
+			t.Fatalf("sample %d: change reports %.0f, want %.0f", i, changes[0].Value, v)
+		case changes[0].Active:
+			b.WriteByte('+')
+		default:
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+func TestEvaluator(t *testing.T) {
+	rule := Rule{Flag: FlagSkipNoiseSuppression, Metric: MetricSystemCPU, Above: 80, Below: 50, Sustain: 2 * time.Second}
+	tests := []struct {
+		name  string
+		curve []float64
+		want  string
+	}{
+		{"idle", []float64{10, 20, 30, 40}, "...."},
+		// Raised once it has been above for Sustain, and only once.
+		{"sustained load", []float64{90, 90, 90, 95, 99}, "..+.."},
+		{"at the threshold isn't above", []float64{80, 80, 80, 80}, "...."},
+		// A dip below the threshold starts the wait over.
+		{"reset on dip", []float64{90, 90, 70, 90, 90, 90}, ".....+"},
+		{"spikes", []float64{90, 10, 90, 10, 90, 10, 90}, "......."},
+		// Between the thresholds nothing changes, whichever way it's going.
+		{"hysteresis holds", []float64{90, 90, 90, 70, 60, 55, 70, 60}, "..+....."},
+		{"cleared once below for Sustain", []float64{90, 90, 90, 40, 40, 40, 40}, "..+..-."},
+		{"dip while clearing", []float64{90, 90, 90, 40, 40, 60, 40, 40, 40}, "..+.....-"},
+		{"raised again", []float64{90, 90, 90, 40, 40, 40, 90, 90, 90}, "..+..-..+"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replay(t, rule, tt.curve); got != tt.want {
+				t.Errorf("changes %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatorNoSustain(t *testing.T) {
+	rule := Rule{Flag: FlagDowngradeTTS, Metric: MetricSystemCPU, Above: 80, Below: 50}
+	if got := replay(t, rule, []float64{90, 70, 40, 90}); got != "+.-+" {
+		t.Errorf("changes %s, want each crossing at once", got)
+	}
+}
+
+func TestEvaluatorRulesIndependent(t *testing.T) {
+	e, err := NewEvaluator(
+		Rule{Flag: FlagSkipNoiseSuppression, Metric: MetricSystemCPU, Above: 80, Below: 50},
+		Rule{Flag: FlagDowngradeTTS, Metric: MetricSystemMem, Above: 90, Below: 70},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := e.Evaluate(Sample{SystemCPU: 85, SystemMem: 50})
+	if len(changes) != 1 || changes[0].Flag != FlagSkipNoiseSuppression || !changes[0].Active {
+		t.Fatalf("changes %+v, want only the cpu rule raised", changes)
+	}
+	changes = e.Evaluate(Sample{SystemCPU: 85, SystemMem: 95})
+	if len(changes) != 1 || changes[0].Flag != FlagDowngradeTTS || !changes[0].Active {
+		t.Fatalf("changes %+v, want only the memory rule raised", changes)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"ok", Rule{Metric: MetricProcessCPU, Above: 80, Below: 50}, false},
+		{"one threshold", Rule{Metric: MetricSystemMem, Above: 80, Below: 80}, false},
+		{"unknown metric", Rule{Metric: "gpu", Above: 80, Below: 50}, true},
+		{"clear above raise", Rule{Metric: MetricSystemCPU, Above: 50, Below: 80}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewEvaluator(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMonitorFlags(t *testing.T) {
+	m, err := NewMonitor(time.Second, Rule{Flag: FlagDowngradeTTS, Metric: MetricProcessCPU, Above: 80, Below: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Unix(0, 0)
+	m.record(Sample{Time: t0, ProcessCPU: 90})
+	if !m.Enabled(FlagDowngradeTTS) {
+		t.Error("flag not raised")
+	}
+	if m.Enabled(FlagSkipNoiseSuppression) {
+		t.Error("a flag without a rule is raised")
+	}
+	if got := m.Latest(); got.ProcessCPU != 90 {
+		t.Errorf("Latest = %+v", got)
+	}
+	m.record(Sample{Time: t0.Add(time.Second), ProcessCPU: 10})
+	if m.Enabled(FlagDowngradeTTS) {
+		t.Error("flag not cleared")
+	}
+
+	var none *Monitor
+	if none.Enabled(FlagDowngradeTTS) {
+		t.Error("a nil monitor has a flag raised")
+	}
+}
@@ -0,0 +1,169 @@
+package load
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// userHZ is the clock tick rate /proc reports process times in. It is 100
+// on every Linux platform we run on.
+const userHZ = 100
+
+type Sample struct {
+	Time time.Time
+	// ProcessCPU and SystemCPU are percentages of total machine capacity,
+	// averaged since the previous sample.
+	ProcessCPU float64
+	SystemCPU  float64
+	SystemMem  float64
+	ProcessRSS uint64
+}
+
+type cpuTimes struct {
+	at         time.Time
+	procTicks  uint64
+	sysTotal   uint64
+	sysIdle    uint64
+	haveSample bool
+}
+
+type sampler struct {
+	prev cpuTimes
+}
+
+func (s *sampler) sample(now time.Time) (Sample, error) {
+	procTicks, err := readProcessTicks()
+	if err != nil {
+		return Sample{}, err
+	}
+	total, idle, err := readSystemTicks()
+	if err != nil {
+		return Sample{}, err
+	}
+	mem, err := readMemoryUsage()
+	if err != nil {
+		return Sample{}, err
+	}
+	rss, err := readProcessRSS()
+	if err != nil {
+		return Sample{}, err
+	}
+
+	out := Sample{Time: now, SystemMem: mem, ProcessRSS: rss}
+	if s.prev.haveSample {
+		elapsed := now.Sub(s.prev.at).Seconds()
+		if elapsed > 0 {
+			used := float64(procTicks-s.prev.procTicks) / userHZ
+			out.ProcessCPU = 100 * used / (elapsed * float64(runtime.NumCPU()))
+		}
+		if dt := total - s.prev.sysTotal; dt > 0 {
+			out.SystemCPU = 100 * (1 - float64(idle-s.prev.sysIdle)/float64(dt))
+		}
+	}
+	s.prev = cpuTimes{at: now, procTicks: procTicks, sysTotal: total, sysIdle: idle, haveSample: true}
+	return out, nil
+}
+
+func readProcessTicks() (uint64, error) {
+	data, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, fmt.Errorf("reading process stat: %w", err)
+	}
+	// The command name can contain spaces, so fields are counted from the
+	// closing parenthesis. utime and stime are fields 14 and 15.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("parsing process stat: malformed")
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("parsing process stat: too few fields")
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing utime: %w", err)
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing stime: %w", err)
+	}
+	return utime + stime, nil
+}
+
+func readSystemTicks() (total, idle uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading system stat: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, fmt.Errorf("reading system stat: empty")
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, fmt.Errorf("parsing system stat: malformed")
+	}
+	for i, field := range fields[1:] {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing system stat: %w", err)
+		}
+		total += v
+		// idle and iowait
+		if i == 3 || i == 4 {
+			idle += v
+		}
+	}
+	return total, idle, nil
+}
+
+func readMemoryUsage() (float64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("reading meminfo: %w", err)
+	}
+	defer f.Close()
+
+	var total, available uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "MemAvailable:":
+			available, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("parsing meminfo: no MemTotal")
+	}
+	return 100 * (1 - float64(available)/float64(total)), nil
+}
+
+func readProcessRSS() (uint64, error) {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, fmt.Errorf("reading statm: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("parsing statm: too few fields")
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing statm: %w", err)
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
@@ -0,0 +1,222 @@
+package otel
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+type ContentPolicy string
+
+const (
+	ContentNone      ContentPolicy = "none"
+	ContentHashed    ContentPolicy = "hashed"
+	ContentTruncated ContentPolicy = "truncated"
+	ContentFull      ContentPolicy = "full"
+
+	defaultContentMaxChars = 32
+)
+
+// contentKeys are the attribute keys that carry user speech or assistant
+// output. Anything logged under one of these is subject to the policy on
+// its way out through the exporter.
+var contentKeys = map[string]bool{
+	"text":       true,
+	"transcript": true,
+	"response":   true,
+	"content":    true,
+	"query":      true,
+	"prompt":     true,
+}
+
+type contentSettings struct {
+	policy   ContentPolicy
+	salt     string
+	maxChars int
+}
+
+var (
+	contentMu  sync.RWMutex
+	contentCfg = contentSettings{policy: ContentNone, maxChars: defaultContentMaxChars}
+	// contentRedact scrubs content before the policy keeps any of it.
+	contentRedact func(string) string
+)
+
+func ParseContentPolicy(s string) (ContentPolicy, error) {
+	switch p := ContentPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return ContentNone, nil
+	case ContentNone, ContentHashed, ContentTruncated, ContentFull:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown telemetry content policy %q, expected none, hashed, truncated or full", s)
+	}
+}
+
+// SetContentPolicy configures how user content is exported. Without a salt a
+// random one is generated, so hashes only correlate within one process run.
+func SetContentPolicy(policy ContentPolicy, salt string, maxChars int) {
+	if salt == "" {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		salt = hex.EncodeToString(b)
+	}
+	if maxChars <= 0 {
+		maxChars = defaultContentMaxChars
+	}
+
+	contentMu.Lock()
+	contentCfg = contentSettings{policy: policy, salt: salt, maxChars: maxChars}
+	contentMu.Unlock()
+}
+
+// SetContentRedactor makes the full and truncated policies export content
+// only after fn has scrubbed it. Hashes are taken of the original text, so
+// they still tell different requests apart.
+func SetContentRedactor(fn func(string) string) {
+	contentMu.Lock()
+	contentRedact = fn
+	contentMu.Unlock()
+}
+
+func IsContentKey(key string) bool {
+	return contentKeys[key]
+}
+
+// ContentAttrs is how instrumentation attaches user content to spans. It
+// returns nothing when the policy omits content.
+func ContentAttrs(key, value string) []attribute.KeyValue {
+	v, ok := applyContentPolicy(value)
+	if !ok {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String(key, v)}
+}
+
+func applyContentPolicy(value string) (string, bool) {
+	contentMu.RLock()
+	cfg := contentCfg
+	redact := contentRedact
+	contentMu.RUnlock()
+
+	if redact != nil && (cfg.policy == ContentFull || cfg.policy == ContentTruncated) {
+		value = redact(value)
+	}
+
+	switch cfg.policy {
+	case ContentFull:
+		return value, true
+	case ContentHashed:
+		sum := sha256.Sum256([]byte(cfg.salt + value))
+		return "sha256:" + hex.EncodeToString(sum[:8]), true
+	case ContentTruncated:
+		r := []rune(value)
+		if len(r) <= cfg.maxChars {
+			return value, true
+		}
+		return string(r[:cfg.maxChars]) + "…", true
+	default:
+		return "", false
+	}
+}
+
+// contentProcessor is the backstop for log records bridged from slog: it
+// rewrites content attributes in place before the next processor (the
+// exporting batch processor) sees the record. slog groups arrive as map
+// values, so it looks inside maps and slices too, in the attributes and
+// in the body.
+type contentProcessor struct{}
+
+func (contentProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool {
+	return true
+}
+
+func (contentProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	if r == nil {
+		return nil
+	}
+
+	attrs := make([]otellog.KeyValue, 0, r.AttributesLen())
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs = append(attrs, kv)
+		return true
+	})
+	if attrs, changed := applyContentKeyValues(attrs, false); changed {
+		r.SetAttributes(attrs...)
+	}
+
+	if body, keep, changed := applyContentValue(r.Body(), false); changed {
+		if !keep {
+			body = otellog.Value{}
+		}
+		r.SetBody(body)
+	}
+	return nil
+}
+
+// applyContentKeyValues applies the policy to every value under a content
+// key, dropping those it omits. Everything below a content key counts as
+// content, as does everything when content is set.
+func applyContentKeyValues(kvs []otellog.KeyValue, content bool) ([]otellog.KeyValue, bool) {
+	changed := false
+	out := make([]otellog.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		v, keep, c := applyContentValue(kv.Value, content || IsContentKey(kv.Key))
+		changed = changed || c
+		if keep {
+			out = append(out, otellog.KeyValue{Key: kv.Key, Value: v})
+		}
+	}
+	return out, changed
+}
+
+// applyContentValue returns v with the policy applied to its strings when
+// they are content, whether to keep it, and whether anything changed.
+func applyContentValue(v otellog.Value, content bool) (otellog.Value, bool, bool) {
+	switch v.Kind() {
+	case otellog.KindString:
+		if !content {
+			return v, true, false
+		}
+		s, ok := applyContentPolicy(v.AsString())
+		return otellog.StringValue(s), ok, true
+	case otellog.KindMap:
+		kvs, changed := applyContentKeyValues(v.AsMap(), content)
+		if !changed {
+			return v, true, false
+		}
+		return otellog.MapValue(kvs...), true, true
+	case otellog.KindSlice:
+		changed := false
+		var vals []otellog.Value
+		for _, e := range v.AsSlice() {
+			e, keep, c := applyContentValue(e, content)
+			changed = changed || c
+			if keep {
+				vals = append(vals, e)
+			}
+		}
+		if !changed {
+			return v, true, false
+		}
+		return otellog.SliceValue(vals...), true, true
+	default:
+		return v, true, false
+	}
+}
+
+func (contentProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (contentProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,249 @@
+package otel
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// withContentPolicy sets the package-level policy and redactor for one test.
+func withContentPolicy(t *testing.T, policy ContentPolicy, maxChars int, redact func(string) string) {
+	t.Helper()
+	SetContentPolicy(policy, "salt", maxChars)
+	SetContentRedactor(redact)
+	t.Cleanup(func() {
+		SetContentPolicy(ContentNone, "", 0)
+		SetContentRedactor(nil)
+	})
+}
+
+func TestContentRedactor(t *testing.T) {
+	const text = "ring 070-123"
+	tests := []struct {
+		policy ContentPolicy
+		want   string
+	}{
+		{ContentFull, "ring ###-###"},
+		{ContentTruncated, "ring ###…"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			withContentPolicy(t, tt.policy, 8, redactDigits)
+			got, ok := applyContentPolicy(text)
+			if !ok || got != tt.want {
+				t.Errorf("applyContentPolicy(%q) = %q, %v, want %q", text, got, ok, tt.want)
+			}
+		})
+	}
+
+	t.Run("hashed", func(t *testing.T) {
+		withContentPolicy(t, ContentHashed, 0, redactDigits)
+		a, _ := applyContentPolicy("ring 070-123")
+		b, _ := applyContentPolicy("ring 070-456")
+		if a == b || !strings.HasPrefix(a, "sha256:") {
+			t.Errorf("hashes %q and %q should differ for different numbers", a, b)
+		}
+	})
+}
+
+func TestParseContentPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ContentPolicy
+		wantErr bool
+	}{
+		{"", ContentNone, false},
+		{"none", ContentNone, false},
+		{" Hashed ", ContentHashed, false},
+		{"TRUNCATED", ContentTruncated, false},
+		{"full", ContentFull, false},
+		{"all", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseContentPolicy(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseContentPolicy(%q) = %q, %v, want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestApplyContentPolicy(t *testing.T) {
+	const text = "tänd lampan i köket"
+	tests := []struct {
+		policy   ContentPolicy
+		maxChars int
+		want     string
+		wantOK   bool
+	}{
+		{ContentNone, 0, "", false},
+		{ContentFull, 0, text, true},
+		{ContentTruncated, 4, "tänd…", true},
+		{ContentTruncated, 100, text, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			withContentPolicy(t, tt.policy, tt.maxChars, nil)
+			got, ok := applyContentPolicy(text)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("applyContentPolicy(%q) = %q, %v, want %q, %v", text, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestContentHashed(t *testing.T) {
+	withContentPolicy(t, ContentHashed, 0, nil)
+	a, ok := applyContentPolicy("tänd lampan")
+	if !ok || !strings.HasPrefix(a, "sha256:") || strings.Contains(a, "lampan") {
+		t.Fatalf("hashed content = %q, %v", a, ok)
+	}
+	if b, _ := applyContentPolicy("tänd lampan"); a != b {
+		t.Errorf("same text hashed to %q and %q", a, b)
+	}
+
+	SetContentPolicy(ContentHashed, "other salt", 0)
+	if b, _ := applyContentPolicy("tänd lampan"); a == b {
+		t.Error("a different salt gave the same hash")
+	}
+}
+
+func TestContentAttrs(t *testing.T) {
+	withContentPolicy(t, ContentNone, 0, nil)
+	if attrs := ContentAttrs("transcript", "hej"); len(attrs) != 0 {
+		t.Errorf("ContentAttrs under none = %v, want nothing", attrs)
+	}
+
+	SetContentPolicy(ContentFull, "salt", 0)
+	attrs := ContentAttrs("transcript", "hej")
+	if len(attrs) != 1 || string(attrs[0].Key) != "transcript" || attrs[0].Value.AsString() != "hej" {
+		t.Errorf("ContentAttrs under full = %v", attrs)
+	}
+}
+
+// recordCapture keeps the last record it is handed.
+type recordCapture struct {
+	record sdklog.Record
+}
+
+func (c *recordCapture) OnEmit(_ context.Context, r *sdklog.Record) error {
+	c.record = r.Clone()
+	return nil
+}
+
+func (c *recordCapture) Enabled(context.Context, sdklog.EnabledParameters) bool { return true }
+func (c *recordCapture) Shutdown(context.Context) error                         { return nil }
+func (c *recordCapture) ForceFlush(context.Context) error                       { return nil }
+
+// emit runs r through contentProcessor and returns what the exporter
+// would get.
+func emit(t *testing.T, r otellog.Record) sdklog.Record {
+	t.Helper()
+	capture := &recordCapture{}
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(contentProcessor{}),
+		sdklog.WithProcessor(capture),
+	)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	provider.Logger("test").Emit(context.Background(), r)
+	return capture.record
+}
+
+func recordAttrs(r sdklog.Record) map[string]otellog.Value {
+	attrs := map[string]otellog.Value{}
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	return attrs
+}
+
+func TestContentProcessor(t *testing.T) {
+	var in otellog.Record
+	in.SetBody(otellog.StringValue("transcribed"))
+	in.AddAttributes(
+		otellog.String("text", "tänd lampan"),
+		otellog.String("room", "kök"),
+		otellog.Int("count", 2),
+		otellog.Map("turn",
+			otellog.String("response", "lampan är tänd"),
+			otellog.String("mode", "ws"),
+			otellog.Map("agent", otellog.String("prompt", "du är en assistent")),
+		),
+		otellog.Slice("queries",
+			otellog.MapValue(otellog.String("query", "väder"), otellog.Int("n", 1)),
+		),
+		otellog.Slice("content", otellog.StringValue("a"), otellog.StringValue("b")),
+	)
+
+	t.Run("none", func(t *testing.T) {
+		withContentPolicy(t, ContentNone, 0, nil)
+		out := emit(t, in)
+		attrs := recordAttrs(out)
+
+		if _, ok := attrs["text"]; ok {
+			t.Error("text was kept")
+		}
+		if got := attrs["room"].AsString(); got != "kök" {
+			t.Errorf("room = %q, want it untouched", got)
+		}
+		if got := attrs["count"].AsInt64(); got != 2 {
+			t.Errorf("count = %d, want it untouched", got)
+		}
+
+		turn := map[string]otellog.Value{}
+		for _, kv := range attrs["turn"].AsMap() {
+			turn[kv.Key] = kv.Value
+		}
+		if _, ok := turn["response"]; ok {
+			t.Error("turn.response was kept")
+		}
+		if got := turn["mode"].AsString(); got != "ws" {
+			t.Errorf("turn.mode = %q, want it untouched", got)
+		}
+		if agent := turn["agent"].AsMap(); len(agent) != 0 {
+			t.Errorf("turn.agent = %v, want the prompt dropped", agent)
+		}
+
+		queries := attrs["queries"].AsSlice()
+		if len(queries) != 1 {
+			t.Fatalf("queries = %v, want one element", queries)
+		}
+		q := queries[0].AsMap()
+		if len(q) != 1 || q[0].Key != "n" {
+			t.Errorf("queries[0] = %v, want only n", q)
+		}
+		if got := attrs["content"].AsSlice(); len(got) != 0 {
+			t.Errorf("content = %v, want every element dropped", got)
+		}
+		if got := out.Body().AsString(); got != "transcribed" {
+			t.Errorf("body = %q, want the message untouched", got)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		withContentPolicy(t, ContentTruncated, 4, nil)
+		attrs := recordAttrs(emit(t, in))
+		if got := attrs["text"].AsString(); got != "tänd…" {
+			t.Errorf("text = %q, want it truncated", got)
+		}
+		for _, kv := range attrs["turn"].AsMap() {
+			if kv.Key == "response" && kv.Value.AsString() != "lamp…" {
+				t.Errorf("turn.response = %q, want it truncated", kv.Value.AsString())
+			}
+		}
+	})
+
+	t.Run("map body", func(t *testing.T) {
+		withContentPolicy(t, ContentNone, 0, nil)
+		var r otellog.Record
+		r.SetBody(otellog.MapValue(otellog.String("transcript", "tänd lampan"), otellog.String("room", "kök")))
+		out := emit(t, r)
+		body := out.Body().AsMap()
+		if len(body) != 1 || body[0].Key != "room" {
+			t.Errorf("body = %v, want only room", body)
+		}
+	})
+}
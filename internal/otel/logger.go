@@ -10,7 +10,31 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-func SetupLogger(serviceName, level, format string) {
+type LoggerOption func(*loggerOptions)
+
+type loggerOptions struct {
+	exportRedactor  func(string) string
+	consoleRedactor func(string) string
+}
+
+func WithExportRedactor(fn func(string) string) LoggerOption {
+	return func(o *loggerOptions) {
+		o.exportRedactor = fn
+	}
+}
+
+func WithConsoleRedactor(fn func(string) string) LoggerOption {
+	return func(o *loggerOptions) {
+		o.consoleRedactor = fn
+	}
+}
+
+func SetupLogger(serviceName, level, format string, loggerOpts ...LoggerOption) {
+	var lo loggerOptions
+	for _, opt := range loggerOpts {
+		opt(&lo)
+	}
+
 	var baseHandler slog.Handler
 
 	opts := &slog.HandlerOptions{
@@ -23,13 +47,20 @@ func SetupLogger(serviceName, level, format string) {
 		baseHandler = slog.NewJSONHandler(os.Stdout, opts)
 	}
 
-	otelHandler := otelslog.NewHandler(serviceName)
-	multiHandler := &multiHandler{handlers: []slog.Handler{
-		&traceContextHandler{handler: baseHandler},
-		otelHandler,
-	}}
+	console := &traceContextHandler{handler: baseHandler}
+	slog.SetDefault(slog.New(newSinkHandler(console, otelslog.NewHandler(serviceName), lo)))
+}
 
-	slog.SetDefault(slog.New(multiHandler))
+// newSinkHandler sends every record to both the console and the exporter,
+// each through its own redactor when one is set.
+func newSinkHandler(console, export slog.Handler, lo loggerOptions) slog.Handler {
+	if lo.consoleRedactor != nil {
+		console = &redactHandler{handler: console, redact: lo.consoleRedactor}
+	}
+	if lo.exportRedactor != nil {
+		export = &redactHandler{handler: export, redact: lo.exportRedactor}
+	}
+	return &multiHandler{handlers: []slog.Handler{console, export}}
 }
 
 func parseLevel(level string) slog.Level {
@@ -74,6 +105,53 @@ func (h *traceContextHandler) WithGroup(name string) slog.Handler {
 	return &traceContextHandler{handler: h.handler.WithGroup(name)}
 }
 
+type redactHandler struct {
+	handler slog.Handler
+	redact  func(string) string
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &redactHandler{handler: h.handler.WithAttrs(redacted), redact: h.redact}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{handler: h.handler.WithGroup(name), redact: h.redact}
+}
+
+func (h *redactHandler) redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]any, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redactAttr(ga)
+		}
+		return slog.Group(a.Key, redacted...)
+	default:
+		return slog.Attr{Key: a.Key, Value: v}
+	}
+}
+
 type multiHandler struct {
 	handlers []slog.Handler
 }
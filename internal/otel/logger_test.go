@@ -0,0 +1,69 @@
+package otel
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func redactDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return '#'
+		}
+		return r
+	}, s)
+}
+
+func TestSinkHandlerRedaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        loggerOptions
+		wantConsole string
+		wantExport  string
+	}{
+		{
+			name:        "export only",
+			opts:        loggerOptions{exportRedactor: redactDigits},
+			wantConsole: "070-123",
+			wantExport:  "###-###",
+		},
+		{
+			name:        "both",
+			opts:        loggerOptions{exportRedactor: redactDigits, consoleRedactor: redactDigits},
+			wantConsole: "###-###",
+			wantExport:  "###-###",
+		},
+		{
+			name:        "neither",
+			wantConsole: "070-123",
+			wantExport:  "070-123",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var console, export bytes.Buffer
+			h := newSinkHandler(
+				slog.NewJSONHandler(&console, nil),
+				slog.NewJSONHandler(&export, nil),
+				tt.opts,
+			)
+			logger := slog.New(h).With("caller", "070-123").WithGroup("turn")
+			logger.Info("call 070-123", "text", "070-123", slog.Group("inner", "number", "070-123"), "count", 3)
+
+			for sink, out := range map[string]string{"console": console.String(), "export": export.String()} {
+				want := tt.wantConsole
+				if sink == "export" {
+					want = tt.wantExport
+				}
+				if got := strings.Count(out, want); got != 4 {
+					t.Errorf("%s: %q appears %d times, want 4 (message, attr, group, With): %s", sink, want, got, out)
+				}
+				if !strings.Contains(out, `"count":3`) {
+					t.Errorf("%s: non-string attribute changed: %s", sink, out)
+				}
+			}
+		})
+	}
+}
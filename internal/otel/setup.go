@@ -22,6 +22,10 @@ type Config struct {
 	ServiceVersion string
 	OTLPEndpoint   string
 	OTLPToken      string
+
+	ContentPolicy   ContentPolicy
+	ContentSalt     string
+	ContentMaxChars int
 }
 
 func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
@@ -41,6 +45,8 @@ func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) erro
 		err = errors.Join(inErr, shutdown(ctx))
 	}
 
+	SetContentPolicy(cfg.ContentPolicy, cfg.ContentSalt, cfg.ContentMaxChars)
+
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
 			semconv.ServiceName(cfg.ServiceName),
@@ -158,6 +164,7 @@ func newLoggerProvider(
 			return nil, err
 		}
 		return log.NewLoggerProvider(
+			log.WithProcessor(contentProcessor{}),
 			log.WithProcessor(log.NewBatchProcessor(exporter)),
 			log.WithResource(res),
 		), nil
@@ -0,0 +1,112 @@
+package scrub
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	KindEmail        = "EMAIL"
+	KindPersonnummer = "PERSONNUMMER"
+	KindPhone        = "PHONE"
+	KindName         = "NAME"
+	KindCustom       = "REDACTED"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// YYMMDD-NNNN or YYYYMMDDNNNN, with the optional + separator used for
+	// people over 100.
+	personnummerPattern = regexp.MustCompile(`\b(?:19|20)?\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01]|[6-8]\d|9[01])[-+]?\d{4}\b`)
+	// Swedish numbers (070-123 45 67, 08-123 456 78) and international ones
+	// (+46 70 123 45 67). A leading 0 or + is required so plain amounts are
+	// left alone, the number can't start inside a run of digits joined by -
+	// (2024-01-05), and it needs at least eight digits, so clock times like
+	// 05 30 00 aren't taken for one. The character before it is kept in ${1}.
+	phonePattern = regexp.MustCompile(`(^|[^\w+-])(?:\+\d{1,3}|00\d{1,3}|0)[\s-]?\(?\d{1,4}\)?(?:[\s-]?\d{2,4}){3,4}\b`)
+)
+
+type Config struct {
+	Names    []string
+	Patterns []string
+}
+
+type detector struct {
+	kind    string
+	re      *regexp.Regexp
+	replace string
+}
+
+type Scrubber struct {
+	detectors []detector
+}
+
+func New(cfg Config) (*Scrubber, error) {
+	s := &Scrubber{}
+
+	for _, p := range cfg.Patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("compiling scrub pattern %q: %w", p, err)
+		}
+		s.add(KindCustom, re)
+	}
+
+	s.add(KindEmail, emailPattern)
+	s.add(KindPersonnummer, personnummerPattern)
+	s.detectors = append(s.detectors, detector{
+		kind:    KindPhone,
+		re:      phonePattern,
+		replace: "${1}" + placeholder(KindPhone),
+	})
+
+	var names []string
+	for _, n := range cfg.Names {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, regexp.QuoteMeta(n))
+		}
+	}
+	if len(names) > 0 {
+		// regexp's \b is ASCII only, so Åsa or Örjan would never match it.
+		re := regexp.MustCompile(`(?i)(^|[^\p{L}\p{N}])(` + strings.Join(names, "|") + `)([^\p{L}\p{N}]|$)`)
+		s.detectors = append(s.detectors, detector{
+			kind:    KindName,
+			re:      re,
+			replace: "${1}" + placeholder(KindName) + "${3}",
+		})
+	}
+
+	return s, nil
+}
+
+func (s *Scrubber) add(kind string, re *regexp.Regexp) {
+	s.detectors = append(s.detectors, detector{
+		kind:    kind,
+		re:      re,
+		replace: placeholder(kind),
+	})
+}
+
+func (s *Scrubber) Scrub(text string) string {
+	if s == nil || text == "" {
+		return text
+	}
+	for _, d := range s.detectors {
+		text = d.re.ReplaceAllString(text, d.replace)
+		if d.kind == KindName {
+			// Adjacent names share a separator, so the second one is only
+			// found on another pass.
+			text = d.re.ReplaceAllString(text, d.replace)
+		}
+	}
+	return text
+}
+
+func placeholder(kind string) string {
+	return "[" + kind + "]"
+}
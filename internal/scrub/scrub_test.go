@@ -0,0 +1,69 @@
+package scrub
+
+import "testing"
+
+func TestScrub(t *testing.T) {
+	s, err := New(Config{
+		Names:    []string{"Åsa", "Per Olsson"},
+		Patterns: []string{`kod \d{4}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"mobile", "ring 070-123 45 67", "ring [PHONE]"},
+		{"mobile without separators", "ring 0739876543", "ring [PHONE]"},
+		{"mobile with one dash", "ring 070-1234567", "ring [PHONE]"},
+		{"stockholm", "numret är 08-123 456 78.", "numret är [PHONE]."},
+		{"short subscriber number", "08-12 34 56", "[PHONE]"},
+		{"international", "+46 70 123 45 67", "[PHONE]"},
+		{"international with 00", "0046 70 123 45 67", "[PHONE]"},
+		{"two numbers", "070-123 45 67, 08-123 456 78", "[PHONE], [PHONE]"},
+		{"personnummer", "mitt personnummer är 850101-1234", "mitt personnummer är [PERSONNUMMER]"},
+		{"personnummer with century", "198501011234", "[PERSONNUMMER]"},
+		{"personnummer over 100", "150101+1234", "[PERSONNUMMER]"},
+		{"samordningsnummer", "850161-1234", "[PERSONNUMMER]"},
+		{"email", "maila anna.b@example.se nu", "maila [EMAIL] nu"},
+		{"name", "säg hej till Åsa", "säg hej till [NAME]"},
+		{"name case", "säg hej till åsa!", "säg hej till [NAME]!"},
+		{"full name", "ring Per Olsson", "ring [NAME]"},
+		{"adjacent names", "Åsa Åsa", "[NAME] [NAME]"},
+		{"custom pattern", "dörren har kod 1234", "dörren har [REDACTED]"},
+		{"empty", "", ""},
+
+		{"iso date and time", "2024-01-05 12:30", "2024-01-05 12:30"},
+		{"timer", "set timer 05 30 00", "set timer 05 30 00"},
+		{"clock time", "väck mig 07 30", "väck mig 07 30"},
+		{"amount", "det kostar 1 200 kr", "det kostar 1 200 kr"},
+		{"year range", "2019-2024", "2019-2024"},
+		{"too few digits", "ring 070-123 4", "ring 070-123 4"},
+		{"name inside a word", "Åsaka", "Åsaka"},
+		{"not an email", "a@b", "a@b"},
+		{"invalid month", "851301-1234", "851301-1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Scrub(tt.in); got != tt.want {
+				t.Errorf("Scrub(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrubNil(t *testing.T) {
+	var s *Scrubber
+	if got := s.Scrub("070-123 45 67"); got != "070-123 45 67" {
+		t.Errorf("nil Scrubber changed the text to %q", got)
+	}
+}
+
+func TestNewBadPattern(t *testing.T) {
+	if _, err := New(Config{Patterns: []string{"("}}); err == nil {
+		t.Error("New accepted an invalid pattern")
+	}
+}
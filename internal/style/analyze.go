@@ -0,0 +1,123 @@
+package style
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+type Kind int
+
+const (
+	KindStatement Kind = iota
+	KindQuestion
+	KindCommand
+)
+
+type Features struct {
+	Words  int
+	Kind   Kind
+	Polite bool
+	Casual bool
+	// Swedish is a best guess from vocabulary; transcripts are mostly
+	// Swedish but people switch to English for some questions.
+	Swedish bool
+}
+
+var (
+	questionWords = set(
+		"vad", "vem", "vems", "var", "vart", "när", "hur", "varför", "vilken", "vilket", "vilka",
+		"kan", "kommer", "skulle", "är", "har", "finns", "ska", "blir",
+		"what", "who", "where", "when", "how", "why", "which", "can", "could", "is", "are", "do", "does", "will",
+	)
+	commandWords = set(
+		"tänd", "släck", "sätt", "starta", "stoppa", "stäng", "öppna", "spela", "pausa", "sök", "googla",
+		"berätta", "visa", "ställ", "höj", "sänk", "påminn", "lägg", "ring",
+		"turn", "switch", "set", "play", "pause", "stop", "start", "search", "tell", "show", "remind", "call",
+	)
+	politePhrases = phrases(
+		"tack", "tackar", "snälla", "vänligen", "skulle du kunna", "var snäll", "om du vill",
+		"please", "thank", "thanks", "could you", "would you",
+	)
+	casualWords = set(
+		"tja", "tjena", "hallå", "yo", "asså", "typ", "liksom", "fan", "najs", "ba",
+		"hey", "yeah", "gonna", "wanna", "dude", "cool",
+	)
+	swedishWords = set("och", "jag", "är", "det", "att", "en", "du", "vad", "inte", "på", "med", "kan", "hur", "om", "som")
+	// "i" is left out, as it is as common in Swedish.
+	englishWords = set("and", "is", "the", "it", "to", "a", "you", "what", "not", "on", "with", "can", "how", "if", "that")
+)
+
+// Analyze describes how something was said, as opposed to what was said.
+func Analyze(text string) Features {
+	words := tokenize(text)
+	f := Features{Words: len(words)}
+	if len(words) == 0 {
+		return f
+	}
+
+	for _, p := range politePhrases {
+		if containsPhrase(words, p) {
+			f.Polite = true
+			break
+		}
+	}
+
+	sv, en := 0, 0
+	for _, w := range words {
+		if casualWords[w] {
+			f.Casual = true
+		}
+		if swedishWords[w] {
+			sv++
+		}
+		if englishWords[w] {
+			en++
+		}
+		if strings.ContainsAny(w, "åäö") {
+			sv++
+		}
+	}
+	f.Swedish = sv >= en
+
+	switch {
+	case strings.HasSuffix(strings.TrimSpace(text), "?") || questionWords[words[0]]:
+		f.Kind = KindQuestion
+	case commandWords[words[0]]:
+		f.Kind = KindCommand
+	}
+	return f
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+// containsPhrase reports whether phrase occurs in words as whole words, so
+// "tack" isn't found in "tacksam".
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func phrases(ps ...string) [][]string {
+	out := make([][]string, len(ps))
+	for i, p := range ps {
+		out[i] = strings.Fields(p)
+	}
+	return out
+}
+
+func set(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
@@ -0,0 +1,57 @@
+package style
+
+import "testing"
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		text string
+		want Features
+	}{
+		{"", Features{}},
+		{"Vad är klockan?", Features{Words: 3, Kind: KindQuestion, Swedish: true}},
+		{"hur varmt är det ute", Features{Words: 5, Kind: KindQuestion, Swedish: true}},
+		{"Tänd lampan i köket.", Features{Words: 4, Kind: KindCommand, Swedish: true}},
+		{"Släck i vardagsrummet tack", Features{Words: 4, Kind: KindCommand, Polite: true, Swedish: true}},
+		{"Skulle du kunna sätta på musik?", Features{Words: 6, Kind: KindQuestion, Polite: true, Swedish: true}},
+		{"Jag är tacksam för hjälpen.", Features{Words: 5, Swedish: true}},
+		{"Tjena, vad händer typ", Features{Words: 4, Casual: true, Swedish: true}},
+		{"Jag var i Stockholm i går", Features{Words: 6, Swedish: true}},
+		{"What is the weather like?", Features{Words: 5, Kind: KindQuestion}},
+		{"Turn off the lights please", Features{Words: 5, Kind: KindCommand, Polite: true}},
+		{"Thanks, that is all.", Features{Words: 4, Polite: true}},
+		{"I think it is going to rain", Features{Words: 7}},
+		{"Play the music, dude", Features{Words: 4, Kind: KindCommand, Casual: true}},
+		// With no telling words either way it is taken for Swedish.
+		{"Hey, musik", Features{Words: 2, Casual: true, Swedish: true}},
+		{"Pleased to meet you", Features{Words: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Analyze(tt.text); got != tt.want {
+				t.Errorf("Analyze(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsPhrase(t *testing.T) {
+	tests := []struct {
+		text   string
+		phrase string
+		want   bool
+	}{
+		{"skulle du kunna hjälpa mig", "skulle du kunna", true},
+		{"hjälp mig om du vill", "om du vill", true},
+		{"du skulle kunna", "skulle du kunna", false},
+		{"tacksam", "tack", false},
+		{"tack", "tack", true},
+		{"", "tack", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text+"/"+tt.phrase, func(t *testing.T) {
+			if got := containsPhrase(tokenize(tt.text), phrases(tt.phrase)[0]); got != tt.want {
+				t.Errorf("containsPhrase(%q, %q) = %v, want %v", tt.text, tt.phrase, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package style
+
+// Rules maps features to hints for the model. The word limits decide what
+// counts as a short or long way of asking.
+type Rules struct {
+	ShortMaxWords int
+	LongMinWords  int
+
+	ShortHint   string
+	LongHint    string
+	CommandHint string
+	PoliteHint  string
+	CasualHint  string
+}
+
+func DefaultRules() Rules {
+	return Rules{
+		ShortMaxWords: 8,
+		LongMinWords:  30,
+		ShortHint:     "Answer in at most two short sentences.",
+		LongHint:      "The user is talking at length, so a somewhat fuller answer is fine.",
+		CommandHint:   "This is a request to do something; confirm briefly.",
+		PoliteHint:    "Match the user's polite tone.",
+		CasualHint:    "Match the user's casual tone.",
+	}
+}
+
+// Hints returns the style hints for a transcript, in a stable order.
+func (r Rules) Hints(f Features) []string {
+	var hints []string
+	add := func(h string) {
+		if h != "" {
+			hints = append(hints, h)
+		}
+	}
+
+	switch {
+	case f.Kind == KindCommand:
+		add(r.CommandHint)
+	case f.Words > 0 && f.Words <= r.ShortMaxWords:
+		add(r.ShortHint)
+	case r.LongMinWords > 0 && f.Words >= r.LongMinWords:
+		add(r.LongHint)
+	}
+
+	switch {
+	case f.Casual:
+		add(r.CasualHint)
+	case f.Polite:
+		add(r.PoliteHint)
+	}
+	return hints
+}
@@ -0,0 +1,54 @@
+package style
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHints(t *testing.T) {
+	r := DefaultRules()
+	long := "Jag har funderat på en sak ganska länge nu och undrar om du kan förklara för mig hur det egentligen fungerar när man värmer upp huset med en luftvärmepump på vintern när det är kallt ute"
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Vad är klockan?", []string{r.ShortHint}},
+		{"Tänd lampan i köket", []string{r.CommandHint}},
+		{"Tänd lampan i köket tack", []string{r.CommandHint, r.PoliteHint}},
+		{"Tjena, vad händer", []string{r.ShortHint, r.CasualHint}},
+		{"Tjena, kan du snälla berätta", []string{r.ShortHint, r.CasualHint}},
+		{"What is the weather like today?", []string{r.ShortHint}},
+		{"Could you turn off the lights?", []string{r.ShortHint, r.PoliteHint}},
+		{long, []string{r.LongHint}},
+		{"Jag undrar om du kan berätta lite mer om hur vädret blir i helgen", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := r.Hints(Analyze(tt.text)); !slices.Equal(got, tt.want) {
+				t.Errorf("Hints(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHintsConfigured(t *testing.T) {
+	r := Rules{ShortMaxWords: 3, LongMinWords: 0, ShortHint: "Kort.", PoliteHint: "Artigt."}
+	tests := []struct {
+		name string
+		f    Features
+		want []string
+	}{
+		{"short", Features{Words: 3}, []string{"Kort."}},
+		{"no long limit", Features{Words: 50}, nil},
+		{"empty hint left out", Features{Words: 2, Kind: KindCommand, Polite: true}, []string{"Artigt."}},
+		{"casual without hint", Features{Words: 10, Casual: true, Polite: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Hints(tt.f); !slices.Equal(got, tt.want) {
+				t.Errorf("Hints(%+v) = %q, want %q", tt.f, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/joakimcarlsson/ai/tool"
+)
+
+type panicHandler func(component string, value any, stack []byte) bool
+
+type recoveringTool struct {
+	tool.BaseTool
+	onPanic panicHandler
+}
+
+// WithRecovery wraps a tool so a panic in Run becomes an error response the
+// agent can carry on from, instead of killing the turn.
+func WithRecovery(t tool.BaseTool, onPanic panicHandler) tool.BaseTool {
+	return &recoveringTool{BaseTool: t, onPanic: onPanic}
+}
+
+func (r *recoveringTool) Run(ctx context.Context, params tool.ToolCall) (resp tool.ToolResponse, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.onPanic("tool:"+params.Name, v, debug.Stack())
+			resp, err = tool.NewTextErrorResponse("The tool failed unexpectedly."), nil
+		}
+	}()
+	return r.BaseTool.Run(ctx, params)
+}
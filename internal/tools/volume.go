@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/joakimcarlsson/ai/tool"
+)
+
+const defaultVolumeStep = 20
+
+var volumeLogger = slog.With("tool", "set_volume")
+
+// VolumeControl is the part of audio.Playback the volume tool drives.
+type VolumeControl interface {
+	SetVolume(v float64)
+	Volume() float64
+}
+
+type VolumeTool struct {
+	control VolumeControl
+}
+
+func NewVolumeTool(control VolumeControl) *VolumeTool {
+	return &VolumeTool{control: control}
+}
+
+type VolumeParams struct {
+	Action string `json:"action" desc:"One of \"set\", \"up\" or \"down\""`
+	Level  int    `json:"level" desc:"For set, the volume in percent from 0 to 100. For up and down, how many percentage points to change it by; 0 means the default step of 20"`
+}
+
+func (v *VolumeTool) Info() tool.ToolInfo {
+	return tool.NewToolInfo(
+		"set_volume",
+		"Change how loud the assistant speaks. Use this when the user asks to turn the volume up or down, or to set it to a level.",
+		VolumeParams{},
+	)
+}
+
+func (v *VolumeTool) Run(_ context.Context, params tool.ToolCall) (tool.ToolResponse, error) {
+	var p VolumeParams
+	if err := json.Unmarshal([]byte(params.Input), &p); err != nil {
+		volumeLogger.Error("invalid parameters", "error", err)
+		return tool.NewTextErrorResponse("Invalid parameters: " + err.Error()), nil
+	}
+
+	current := int(math.Round(v.control.Volume() * 100))
+	step := p.Level
+	if step == 0 {
+		step = defaultVolumeStep
+	}
+
+	var level int
+	switch p.Action {
+	case "set":
+		level = p.Level
+	case "up":
+		level = current + step
+	case "down":
+		level = current - step
+	default:
+		return tool.NewTextErrorResponse(fmt.Sprintf("Unknown action %q, expected set, up or down", p.Action)), nil
+	}
+	level = min(max(level, 0), 100)
+
+	v.control.SetVolume(float64(level) / 100)
+	volumeLogger.Info("volume changed", "from", current, "to", level)
+	return tool.NewTextResponse(fmt.Sprintf("Volume changed from %d%% to %d%%.", current, level)), nil
+}
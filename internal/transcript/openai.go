@@ -0,0 +1,196 @@
+package transcript
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// transcriptionURL is OpenAI's transcription endpoint. Tests point it at a
+// fake server.
+var transcriptionURL = "https://api.openai.com/v1/audio/transcriptions"
+
+// ErrNoWordTimestamps is returned by Probe when the model answers without
+// word timestamps or refuses to be asked for them, as the gpt-4o
+// transcription models do.
+var ErrNoWordTimestamps = errors.New("transcription model doesn't return word timestamps")
+
+// Transcription is a transcript with its words timed.
+type Transcription struct {
+	Text  string
+	Words []Word
+	// NoSpeechProb is the highest probability of any segment holding no
+	// speech, for telling a hallucination on noise from a request.
+	NoSpeechProb float64
+}
+
+type verboseResponse struct {
+	Text     string         `json:"text"`
+	Words    *[]verboseWord `json:"words"`
+	Segments []struct {
+		NoSpeechProb float64 `json:"no_speech_prob"`
+	} `json:"segments"`
+}
+
+type verboseWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// WordClient transcribes through OpenAI's transcription endpoint in its
+// verbose format, asking for word and segment timestamps, which the
+// transcription client in the ai module has no options for.
+type WordClient struct {
+	apiKey string
+	model  string
+	prompt string
+}
+
+// NewWordClient transcribes with model, which has to support word
+// timestamps; whisper-1 does. prompt is passed along to bias spelling.
+func NewWordClient(apiKey, model, prompt string) *WordClient {
+	return &WordClient{apiKey: apiKey, model: model, prompt: prompt}
+}
+
+// Transcribe transcribes audio, named filename so the format is known, in
+// language.
+func (c *WordClient) Transcribe(ctx context.Context, audio []byte, filename, language string) (*Transcription, error) {
+	vr, err := c.request(ctx, audio, filename, language)
+	if err != nil {
+		return nil, err
+	}
+	return parseVerbose(vr), nil
+}
+
+// Probe transcribes a moment of silence to find out whether the model
+// returns word timestamps, and returns ErrNoWordTimestamps if it doesn't.
+func (c *WordClient) Probe(ctx context.Context) error {
+	vr, err := c.request(ctx, silentWAV(500*time.Millisecond), "probe.wav", "sv")
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusBadRequest {
+		return fmt.Errorf("%w: %v", ErrNoWordTimestamps, err)
+	}
+	if err != nil {
+		return err
+	}
+	if vr.Words == nil {
+		return ErrNoWordTimestamps
+	}
+	return nil
+}
+
+func (c *WordClient) request(ctx context.Context, audio []byte, filename, language string) (*verboseResponse, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, fmt.Errorf("encoding transcription request: %w", err)
+	}
+	file.Write(audio)
+	fields := [][2]string{
+		{"model", c.model},
+		{"response_format", "verbose_json"},
+		{"timestamp_granularities[]", "word"},
+		{"timestamp_granularities[]", "segment"},
+		{"language", language},
+		{"prompt", c.prompt},
+	}
+	for _, f := range fields {
+		if f[1] != "" {
+			form.WriteField(f[0], f[1])
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("encoding transcription request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, transcriptionURL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("creating transcription request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting transcription: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading transcription response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("requesting transcription: %w", &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(data))})
+	}
+
+	var vr verboseResponse
+	if err := json.Unmarshal(data, &vr); err != nil {
+		return nil, fmt.Errorf("parsing transcription response: %w", err)
+	}
+	return &vr, nil
+}
+
+// parseVerbose aligns the timed words onto the text, which is the one
+// with punctuation.
+func parseVerbose(vr *verboseResponse) *Transcription {
+	t := &Transcription{Text: strings.TrimSpace(vr.Text)}
+	var timed []Word
+	if vr.Words != nil {
+		for _, w := range *vr.Words {
+			timed = append(timed, Word{
+				Text:  w.Word,
+				Start: seconds(w.Start),
+				End:   seconds(w.End),
+			})
+		}
+	}
+	t.Words = Align(t.Text, timed)
+	for _, s := range vr.Segments {
+		t.NoSpeechProb = max(t.NoSpeechProb, s.NoSpeechProb)
+	}
+	return t
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.code, e.body)
+}
+
+// silentWAV is d of 16 kHz mono silence.
+func silentWAV(d time.Duration) []byte {
+	const rate = 16000
+	samples := int(d.Seconds() * rate)
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+samples*2))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, struct {
+		Size                 uint32
+		Format, Channels     uint16
+		Rate, ByteRate       uint32
+		BlockAlign, BitDepth uint16
+	}{16, 1, 1, rate, rate * 2, 2, 16})
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(samples*2))
+	b.Write(make([]byte, samples*2))
+	return b.Bytes()
+}
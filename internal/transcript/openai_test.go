@@ -0,0 +1,93 @@
+package transcript
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// transcriptionAPI answers transcription requests with status and the
+// testdata file named body, after checking that word timestamps were asked
+// for.
+func transcriptionAPI(t *testing.T, status int, body string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		granularities := r.MultipartForm.Value["timestamp_granularities[]"]
+		if r.Header.Get("Authorization") != "Bearer key" ||
+			r.FormValue("model") != "whisper-1" ||
+			r.FormValue("response_format") != "verbose_json" ||
+			!slices.Contains(granularities, "word") || !slices.Contains(granularities, "segment") ||
+			r.FormValue("language") != "sv" {
+			t.Errorf("unexpected request: %v", r.MultipartForm.Value)
+		}
+		if _, _, err := r.FormFile("file"); err != nil {
+			t.Errorf("no audio: %v", err)
+		}
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte(body))
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", body))
+		if err != nil {
+			t.Error(err)
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	prev := transcriptionURL
+	transcriptionURL = srv.URL
+	t.Cleanup(func() { transcriptionURL = prev })
+}
+
+func TestWordClientTranscribe(t *testing.T) {
+	transcriptionAPI(t, http.StatusOK, "wake_tail.json")
+	c := NewWordClient("key", "whisper-1", "Smarthome")
+	tr, err := c.Transcribe(context.Background(), silentWAV(ms(100)), "utterance.wav", "sv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Text != "Bror, eh, tänd lampan i köket." || len(tr.Words) != 6 || tr.Words[1].End != ms(700) {
+		t.Errorf("Transcribe = %+v", tr)
+	}
+}
+
+func TestWordClientProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"words", http.StatusOK, "no_speech.json", nil},
+		{"no words", http.StatusOK, "no_words.json", ErrNoWordTimestamps},
+		{"refused", http.StatusBadRequest, `{"error":{"message":"timestamp_granularities is not supported"}}`, ErrNoWordTimestamps},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcriptionAPI(t, tt.status, tt.body)
+			err := NewWordClient("key", "whisper-1", "").Probe(context.Background())
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Probe = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordClientProbeError(t *testing.T) {
+	// A failure that says nothing about word timestamps is passed on as is.
+	transcriptionAPI(t, http.StatusUnauthorized, `{"error":{"message":"invalid key"}}`)
+	err := NewWordClient("key", "whisper-1", "").Probe(context.Background())
+	if err == nil || errors.Is(err, ErrNoWordTimestamps) {
+		t.Errorf("Probe = %v, want an error other than ErrNoWordTimestamps", err)
+	}
+}
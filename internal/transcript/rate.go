@@ -0,0 +1,39 @@
+package transcript
+
+import "time"
+
+type RateStats struct {
+	Words          int
+	Speech         time.Duration
+	WordsPerMinute float64
+	MeanPause      time.Duration
+	MaxPause       time.Duration
+}
+
+// SpeechRate measures how fast and with how long pauses someone speaks. It
+// needs word timestamps and reports false without them.
+func SpeechRate(words []Word) (RateStats, bool) {
+	if len(words) == 0 || !hasTimings(words) {
+		return RateStats{}, false
+	}
+
+	stats := RateStats{
+		Words:  len(words),
+		Speech: words[len(words)-1].End - words[0].Start,
+	}
+	if stats.Speech <= 0 {
+		return RateStats{}, false
+	}
+	stats.WordsPerMinute = float64(len(words)) / stats.Speech.Minutes()
+
+	var total time.Duration
+	for i := 1; i < len(words); i++ {
+		pause := max(words[i].Start-words[i-1].End, 0)
+		total += pause
+		stats.MaxPause = max(stats.MaxPause, pause)
+	}
+	if len(words) > 1 {
+		stats.MeanPause = total / time.Duration(len(words)-1)
+	}
+	return stats, true
+}
@@ -0,0 +1,56 @@
+package transcript
+
+import "testing"
+
+func TestSpeechRate(t *testing.T) {
+	stats, ok := SpeechRate(fixture(t, "full_phrase.json").Words)
+	if !ok {
+		t.Fatal("no stats from timed words")
+	}
+	want := RateStats{
+		Words:          6,
+		Speech:         ms(2500),
+		WordsPerMinute: 144,
+		MeanPause:      ms(160),
+		MaxPause:       ms(500),
+	}
+	if stats != want {
+		t.Errorf("SpeechRate = %+v, want %+v", stats, want)
+	}
+}
+
+func TestSpeechRateOverlap(t *testing.T) {
+	// Words that overlap count as no pause rather than a negative one.
+	words := []Word{
+		{Text: "tänd", Start: 0, End: ms(500)},
+		{Text: "lampan", Start: ms(400), End: ms(1000)},
+	}
+	stats, ok := SpeechRate(words)
+	if !ok {
+		t.Fatal("no stats")
+	}
+	if stats.MeanPause != 0 || stats.MaxPause != 0 {
+		t.Errorf("pauses = %v mean, %v max, want none", stats.MeanPause, stats.MaxPause)
+	}
+	if stats.WordsPerMinute != 120 {
+		t.Errorf("WordsPerMinute = %v, want 120", stats.WordsPerMinute)
+	}
+}
+
+func TestSpeechRateUnavailable(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []Word
+	}{
+		{"none", nil},
+		{"untimed", Words("tänd lampan")},
+		{"no length", []Word{{Text: "ja", Start: ms(300), End: ms(300)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if stats, ok := SpeechRate(tt.words); ok {
+				t.Errorf("SpeechRate = %+v, want none", stats)
+			}
+		})
+	}
+}
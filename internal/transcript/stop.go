@@ -0,0 +1,31 @@
+package transcript
+
+import "slices"
+
+// StopPhrases recognises a bare "stop" said over a response, which should
+// end the response rather than start a new turn.
+type StopPhrases struct {
+	phrases [][]string
+}
+
+func NewStopPhrases(phrases []string) *StopPhrases {
+	s := &StopPhrases{}
+	for _, p := range phrases {
+		if tokens := phraseTokens(p); len(tokens) > 0 {
+			s.phrases = append(s.phrases, tokens)
+		}
+	}
+	return s
+}
+
+// Match reports whether text is one of the phrases and nothing else, so
+// "stopp" matches but "stoppa musiken" does not.
+func (s *StopPhrases) Match(text string) bool {
+	tokens := phraseTokens(text)
+	for _, p := range s.phrases {
+		if slices.Equal(tokens, p) {
+			return true
+		}
+	}
+	return false
+}
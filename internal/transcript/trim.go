@@ -0,0 +1,124 @@
+package transcript
+
+import "time"
+
+type Cleaner struct {
+	wakePhrases [][]string
+	fillers     map[string]bool
+}
+
+// NewCleaner builds a cleaner from wake phrases ("sho bror") and single word
+// fillers ("eh", "alltså").
+func NewCleaner(wakePhrases, fillers []string) *Cleaner {
+	c := &Cleaner{fillers: map[string]bool{}}
+	for _, p := range wakePhrases {
+		if tokens := phraseTokens(p); len(tokens) > 0 {
+			c.wakePhrases = append(c.wakePhrases, tokens)
+		}
+	}
+	for _, f := range fillers {
+		if t := normalize(f); t != "" {
+			c.fillers[t] = true
+		}
+	}
+	return c
+}
+
+// Clean strips a leading wake phrase and leading fillers, in any order, and
+// returns what is left to send on as the prompt.
+func (c *Cleaner) Clean(words []Word) []Word {
+	return c.clean(words, 0)
+}
+
+// CleanWoken is Clean for the utterance that follows a wake word. With word
+// timestamps it also strips what the audio trim left of the wake phrase,
+// such as "bror" of "sho bror", if it ends within tail of the start.
+func (c *Cleaner) CleanWoken(words []Word, tail time.Duration) []Word {
+	return c.clean(words, tail)
+}
+
+func (c *Cleaner) clean(words []Word, tail time.Duration) []Word {
+	out := words
+	for {
+		trimmed := c.DropLeadingFillers(c.TrimWakeWord(out))
+		if tail > 0 {
+			trimmed = c.TrimWakeTail(trimmed, tail)
+		}
+		if len(trimmed) == len(out) {
+			break
+		}
+		out = trimmed
+	}
+	if len(out) == len(words) {
+		return words
+	}
+	return capitalize(append([]Word(nil), out...))
+}
+
+func (c *Cleaner) CleanText(text string) string {
+	return Join(c.Clean(Words(text)))
+}
+
+// TrimWakeWord removes the wake phrase if the words start with it. Matching
+// ignores case and punctuation, which is where a transcript of the wake
+// word usually differs from the configured phrase.
+func (c *Cleaner) TrimWakeWord(words []Word) []Word {
+	for _, phrase := range c.wakePhrases {
+		if startsWith(words, phrase) {
+			return words[len(phrase):]
+		}
+	}
+	return words
+}
+
+// TrimWakeTail removes the end of a wake phrase from the start of the
+// words, longest first, as long as it ends within tail of the start of the
+// audio. Without timings there is no telling the tail of the wake word
+// from a request that starts with the same word, so nothing is removed.
+func (c *Cleaner) TrimWakeTail(words []Word, tail time.Duration) []Word {
+	if !hasTimings(words) {
+		return words
+	}
+	for n := c.longestPhrase() - 1; n > 0; n-- {
+		if len(words) < n || words[n-1].End == 0 || words[n-1].End > tail {
+			continue
+		}
+		for _, phrase := range c.wakePhrases {
+			if len(phrase) <= n {
+				continue
+			}
+			if startsWith(words, phrase[len(phrase)-n:]) {
+				return words[n:]
+			}
+		}
+	}
+	return words
+}
+
+func (c *Cleaner) longestPhrase() int {
+	n := 0
+	for _, p := range c.wakePhrases {
+		n = max(n, len(p))
+	}
+	return n
+}
+
+func (c *Cleaner) DropLeadingFillers(words []Word) []Word {
+	for len(words) > 0 && c.fillers[normalize(words[0].Text)] {
+		words = words[1:]
+	}
+	return words
+}
+
+// startsWith reports whether the words begin with the normalized tokens.
+func startsWith(words []Word, tokens []string) bool {
+	if len(words) < len(tokens) {
+		return false
+	}
+	for i, t := range tokens {
+		if normalize(words[i].Text) != t {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package transcript
+
+import "testing"
+
+func testCleaner() *Cleaner {
+	return NewCleaner([]string{"sho bror", "show bror"}, []string{"eh", "öh", "alltså"})
+}
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Sho bror, tänd lampan.", "Tänd lampan."},
+		{"SHOW BROR tänd lampan", "Tänd lampan"},
+		{"Eh, sho bror, alltså, vad är klockan?", "Vad är klockan?"},
+		{"sho bror eh", ""},
+		{"Tänd lampan, eh, i köket.", "Tänd lampan, eh, i köket."},
+		{"Bror till mig fyller år.", "Bror till mig fyller år."},
+		{"sho", "sho"},
+		{"", ""},
+	}
+	c := testCleaner()
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := c.CleanText(tt.text); got != tt.want {
+				t.Errorf("CleanText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanWoken(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		// The audio trim cut "sho", leaving "bror" at the start.
+		{"wake_tail.json", "Tänd lampan i köket."},
+		{"full_phrase.json", "Vad är klockan?"},
+		// "Bror" a second in is what was said, not what was left of the
+		// wake word.
+		{"late_bror.json", "Bror till mig fyller år i morgon."},
+	}
+	c := testCleaner()
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			words := fixture(t, tt.fixture).Words
+			if got := Join(c.CleanWoken(words, ms(1000))); got != tt.want {
+				t.Errorf("CleanWoken = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanWokenUntimed(t *testing.T) {
+	// Without timings a leading "bror" can't be told from a request.
+	c := testCleaner()
+	words := Words("Bror, tänd lampan.")
+	if got := Join(c.CleanWoken(words, ms(1000))); got != "Bror, tänd lampan." {
+		t.Errorf("CleanWoken = %q", got)
+	}
+}
+
+func TestCleanKeepsInput(t *testing.T) {
+	c := testCleaner()
+	words := fixture(t, "wake_tail.json").Words
+	c.CleanWoken(words, ms(1000))
+	if words[2].Text != "tänd" {
+		t.Errorf("cleaning changed the input: %+v", words[2])
+	}
+}
+
+func TestTrimWakeTail(t *testing.T) {
+	c := NewCleaner([]string{"hej min vän"}, nil)
+	tests := []struct {
+		name  string
+		words []Word
+		want  string
+	}{
+		{"two words", []Word{{Text: "min", End: ms(200)}, {Text: "vän", End: ms(500)}, {Text: "tänd", End: ms(900)}}, "tänd"},
+		{"one word", []Word{{Text: "vän,", End: ms(300)}, {Text: "tänd", End: ms(900)}}, "tänd"},
+		{"too late", []Word{{Text: "min", End: ms(600)}, {Text: "vän", End: ms(1200)}, {Text: "tänd", End: ms(1500)}}, "min vän tänd"},
+		{"not a tail", []Word{{Text: "hej", End: ms(200)}, {Text: "tänd", End: ms(500)}}, "hej tänd"},
+		{"untimed word", []Word{{Text: "vän"}, {Text: "tänd", End: ms(500)}}, "vän tänd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Join(c.TrimWakeTail(tt.words, ms(1000))); got != tt.want {
+				t.Errorf("TrimWakeTail = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropLeadingFillers(t *testing.T) {
+	c := testCleaner()
+	words := fixture(t, "full_phrase.json").Words
+	got := c.DropLeadingFillers(c.TrimWakeWord(words))
+	if len(got) == 0 || got[0].Text != "vad" || got[0].Start != ms(1800) {
+		t.Errorf("left with %+v, want to start at \"vad\" 1.8s in", got)
+	}
+}
@@ -0,0 +1,99 @@
+package transcript
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Word is one transcribed word. Start and End are offsets into the audio and
+// are zero when the backend didn't return word timestamps.
+type Word struct {
+	Text  string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Words splits plain text into untimed words, for backends without word
+// timestamps.
+func Words(text string) []Word {
+	fields := strings.Fields(text)
+	words := make([]Word, len(fields))
+	for i, f := range fields {
+		words[i] = Word{Text: f}
+	}
+	return words
+}
+
+func Join(words []Word) string {
+	parts := make([]string, len(words))
+	for i, w := range words {
+		parts[i] = w.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+func hasTimings(words []Word) bool {
+	for _, w := range words {
+		if w.End > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Align copies timings from timed, as a backend returns them without
+// punctuation, onto the words of text, so the cleaned prompt keeps the
+// punctuation and the trimming gets the timings. A word of text that
+// can't be found among the next few timed words stays untimed.
+func Align(text string, timed []Word) []Word {
+	const lookahead = 3
+	words := Words(text)
+	next := 0
+	for i := range words {
+		t := normalize(words[i].Text)
+		if t == "" {
+			continue
+		}
+		for j := next; j < len(timed) && j < next+lookahead; j++ {
+			if normalize(timed[j].Text) == t {
+				words[i].Start, words[i].End = timed[j].Start, timed[j].End
+				next = j + 1
+				break
+			}
+		}
+	}
+	return words
+}
+
+// normalize reduces a word to what matching cares about, so "Bror," and
+// "bror" compare equal.
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}))
+}
+
+// phraseTokens splits a configured phrase into normalized words.
+func phraseTokens(phrase string) []string {
+	var tokens []string
+	for _, f := range strings.Fields(phrase) {
+		if t := normalize(f); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// capitalize restores a sentence start after leading words were removed.
+func capitalize(words []Word) []Word {
+	if len(words) == 0 {
+		return words
+	}
+	r := []rune(words[0].Text)
+	if len(r) > 0 && unicode.IsLower(r[0]) {
+		r[0] = unicode.ToUpper(r[0])
+		words[0].Text = string(r)
+	}
+	return words
+}
@@ -0,0 +1,106 @@
+package transcript
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixture parses a verbose transcription response from testdata.
+func fixture(t *testing.T, name string) *Transcription {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vr verboseResponse
+	if err := json.Unmarshal(data, &vr); err != nil {
+		t.Fatal(err)
+	}
+	return parseVerbose(&vr)
+}
+
+func ms(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+
+func TestAlign(t *testing.T) {
+	timed := []Word{
+		{Text: "Sho", Start: ms(0), End: ms(200)},
+		{Text: "bror", Start: ms(200), End: ms(500)},
+		{Text: "tänd", Start: ms(900), End: ms(1200)},
+		{Text: "lampan", Start: ms(1200), End: ms(1600)},
+	}
+	got := Align("Sho bror, tänd - lampan.", timed)
+	want := []Word{
+		{Text: "Sho", Start: ms(0), End: ms(200)},
+		{Text: "bror,", Start: ms(200), End: ms(500)},
+		{Text: "tänd", Start: ms(900), End: ms(1200)},
+		{Text: "-"},
+		{Text: "lampan.", Start: ms(1200), End: ms(1600)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d words, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("word %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAlignMismatch(t *testing.T) {
+	// The text says "20:00" where the timed words say "20" "00"; the words
+	// around it still line up.
+	timed := []Word{
+		{Text: "väck", End: ms(300)},
+		{Text: "mig", End: ms(500)},
+		{Text: "20", End: ms(800)},
+		{Text: "00", End: ms(1000)},
+		{Text: "tack", End: ms(1300)},
+	}
+	got := Align("Väck mig 20:00, tack.", timed)
+	ends := []time.Duration{ms(300), ms(500), 0, ms(1300)}
+	for i, end := range ends {
+		if got[i].End != end {
+			t.Errorf("%q ends at %v, want %v", got[i].Text, got[i].End, end)
+		}
+	}
+}
+
+func TestAlignUntimed(t *testing.T) {
+	got := Align("tänd lampan", nil)
+	if hasTimings(got) {
+		t.Errorf("words without timed words have timings: %+v", got)
+	}
+	if Join(got) != "tänd lampan" {
+		t.Errorf("Join = %q", Join(got))
+	}
+}
+
+func TestParseVerbose(t *testing.T) {
+	tr := fixture(t, "wake_tail.json")
+	if tr.Text != "Bror, eh, tänd lampan i köket." {
+		t.Errorf("Text = %q", tr.Text)
+	}
+	if len(tr.Words) != 6 {
+		t.Fatalf("got %d words, want 6", len(tr.Words))
+	}
+	if w := tr.Words[0]; w.Text != "Bror," || w.Start != 0 || w.End != ms(320) {
+		t.Errorf("first word = %+v", w)
+	}
+	if w := tr.Words[5]; w.Text != "köket." || w.Start != ms(1900) || w.End != ms(2400) {
+		t.Errorf("last word = %+v", w)
+	}
+	if tr.NoSpeechProb != 0.02 {
+		t.Errorf("NoSpeechProb = %v, want 0.02", tr.NoSpeechProb)
+	}
+
+	// The highest segment counts.
+	if p := fixture(t, "noise.json").NoSpeechProb; p != 0.91 {
+		t.Errorf("noise NoSpeechProb = %v, want 0.91", p)
+	}
+	if tr := fixture(t, "no_words.json"); tr.Text != "" || len(tr.Words) != 0 {
+		t.Errorf("empty response parsed as %+v", tr)
+	}
+}
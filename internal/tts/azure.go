@@ -0,0 +1,272 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	azureOutputFormat = "raw-24khz-16bit-mono-pcm"
+	azureSampleRate   = 24000
+	// azureMaxIdle is how long a connection is reused after its last
+	// request. The service drops idle connections, which a write doesn't
+	// always notice, so older ones are replaced up front.
+	azureMaxIdle = 2 * time.Minute
+)
+
+type AzureConfig struct {
+	Key    string
+	Region string
+	// Voice is a neural voice such as sv-SE-SofieNeural. Its locale, the
+	// first two parts of the name, is the language of the SSML.
+	Voice string
+}
+
+func (c AzureConfig) Validate() error {
+	switch {
+	case c.Key == "":
+		return errors.New("azure speech needs a key")
+	case c.Region == "":
+		return errors.New("azure speech needs a region")
+	case len(strings.Split(c.Voice, "-")) < 3:
+		return fmt.Errorf("invalid azure voice %q, expected e.g. sv-SE-SofieNeural", c.Voice)
+	}
+	return nil
+}
+
+// Azure speaks through the Speech service's websocket v1 endpoint. That
+// takes a whole SSML document per request, so text goes a sentence at a
+// time, and one connection is kept for all requests, one at a time.
+type Azure struct {
+	cfg AzureConfig
+	url string
+
+	// mu is held for a request from sending its SSML until its audio has
+	// been read or abandoned, which closes conn.
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	lastUsed time.Time
+}
+
+func NewAzure(cfg AzureConfig) (*Azure, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Azure{
+		cfg: cfg,
+		url: fmt.Sprintf("wss://%s.tts.speech.microsoft.com/cognitiveservices/websocket/v1", cfg.Region),
+	}, nil
+}
+
+func (a *Azure) Name() string    { return "azure" }
+func (a *Azure) SampleRate() int { return azureSampleRate }
+func (a *Azure) Phonemes() bool  { return false }
+
+func (a *Azure) NewStream(ctx context.Context, _ StreamOptions) (Stream, error) {
+	return newSentenceStream(ctx, a.synthesize), nil
+}
+
+func (a *Azure) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn != nil {
+		a.conn.Close()
+		a.conn = nil
+	}
+	return nil
+}
+
+// azureID is a request or connection id: a UUID without dashes, which is
+// the form the service expects.
+func azureID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return hex.EncodeToString(b[:])
+}
+
+// azureMessage is a text frame: headers, a blank line and a body.
+func azureMessage(path, requestID, contentType, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Path: %s\r\nX-RequestId: %s\r\nX-Timestamp: %s\r\n", path, requestID, time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	fmt.Fprintf(&b, "Content-Type: %s\r\n\r\n%s", contentType, body)
+	return []byte(b.String())
+}
+
+// parseAzureHeaders reads the header block of a message.
+func parseAzureHeaders(block []byte) map[string]string {
+	headers := map[string]string{}
+	for _, line := range strings.Split(string(block), "\r\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			headers[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
+
+// dial connects and sends the speech config, which asks for raw PCM.
+// Call with mu held.
+func (a *Azure) dial(ctx context.Context) error {
+	header := http.Header{}
+	header.Set("Ocp-Apim-Subscription-Key", a.cfg.Key)
+	header.Set("X-ConnectionId", azureID())
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, a.url, header)
+	if err != nil {
+		return fmt.Errorf("dialing azure speech: %w", err)
+	}
+	config := fmt.Sprintf(`{"context":{"synthesis":{"audio":{"metadataOptions":{"sentenceBoundaryEnabled":false,"wordBoundaryEnabled":false},"outputFormat":%q}}}}`, azureOutputFormat)
+	if err := conn.WriteMessage(websocket.TextMessage, azureMessage("speech.config", azureID(), "application/json", config)); err != nil {
+		conn.Close()
+		return fmt.Errorf("sending speech config: %w", err)
+	}
+	a.conn = conn
+	return nil
+}
+
+// ssml wraps text for the configured voice.
+func (a *Azure) ssml(text string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(text))
+	parts := strings.Split(a.cfg.Voice, "-")
+	lang := parts[0] + "-" + parts[1]
+	return fmt.Sprintf(`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="%s"><voice name="%s">%s</voice></speak>`,
+		lang, a.cfg.Voice, escaped.String())
+}
+
+func (a *Azure) synthesize(ctx context.Context, text string) (io.ReadCloser, error) {
+	a.mu.Lock()
+	if a.conn != nil && time.Since(a.lastUsed) > azureMaxIdle {
+		a.conn.Close()
+		a.conn = nil
+	}
+	if a.conn == nil {
+		if err := a.dial(ctx); err != nil {
+			a.mu.Unlock()
+			return nil, err
+		}
+	}
+	id := azureID()
+	if err := a.conn.WriteMessage(websocket.TextMessage, azureMessage("ssml", id, "application/ssml+xml", a.ssml(text))); err != nil {
+		// The connection may have been closed by the service anyway; one
+		// redial covers that.
+		a.conn.Close()
+		a.conn = nil
+		if err := a.dial(ctx); err != nil {
+			a.mu.Unlock()
+			return nil, err
+		}
+		if err := a.conn.WriteMessage(websocket.TextMessage, azureMessage("ssml", id, "application/ssml+xml", a.ssml(text))); err != nil {
+			a.conn.Close()
+			a.conn = nil
+			a.mu.Unlock()
+			return nil, fmt.Errorf("sending ssml: %w", err)
+		}
+	}
+
+	r, w := io.Pipe()
+	turn := &azureTurn{a: a, conn: a.conn, id: id, r: r, done: make(chan struct{})}
+	go turn.read(ctx, w)
+	return turn, nil
+}
+
+// azureTurn is the audio of one request. The service answers each with
+// turn.start, the audio frames tagged with its request id, and turn.end,
+// which is what ends the turn's audio.
+type azureTurn struct {
+	a    *Azure
+	conn *websocket.Conn
+	id   string
+	r    *io.PipeReader
+	done chan struct{}
+	once sync.Once
+	// ended is set before done is closed if turn.end was read.
+	ended bool
+}
+
+func (t *azureTurn) read(ctx context.Context, w *io.PipeWriter) {
+	defer close(t.done)
+	stop := context.AfterFunc(ctx, func() { t.conn.Close() })
+	defer stop()
+	err := t.readTurn(w)
+	t.ended = err == nil
+	w.CloseWithError(err)
+}
+
+// readTurn copies the turn's audio to w. It returns nil at turn.end.
+func (t *azureTurn) readTurn(w io.Writer) error {
+	for {
+		kind, msg, err := t.conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("ws read: %w", err)
+		}
+		switch kind {
+		case websocket.BinaryMessage:
+			// Audio frames start with the length of their headers.
+			if len(msg) < 2 {
+				continue
+			}
+			n := int(binary.BigEndian.Uint16(msg))
+			if len(msg) < 2+n {
+				continue
+			}
+			headers := parseAzureHeaders(msg[2 : 2+n])
+			if headers["path"] != "audio" || headers["x-requestid"] != t.id {
+				continue
+			}
+			if audio := msg[2+n:]; len(audio) > 0 {
+				if _, err := w.Write(audio); err != nil {
+					return err
+				}
+			}
+		case websocket.TextMessage:
+			block, _, _ := bytes.Cut(msg, []byte("\r\n\r\n"))
+			headers := parseAzureHeaders(block)
+			if headers["x-requestid"] == t.id && headers["path"] == "turn.end" {
+				return nil
+			}
+		}
+	}
+}
+
+func (t *azureTurn) Read(p []byte) (int, error) {
+	return t.r.Read(p)
+}
+
+// Close releases the connection for the next request. A turn closed
+// before its turn.end leaves audio in flight, so the connection is closed
+// and the next request dials a new one.
+func (t *azureTurn) Close() error {
+	t.once.Do(func() {
+		// Closing the pipe first unblocks a write nobody is reading.
+		t.r.Close()
+		select {
+		case <-t.done:
+		default:
+			t.conn.Close()
+			<-t.done
+		}
+		if !t.ended {
+			t.conn.Close()
+			if t.a.conn == t.conn {
+				t.a.conn = nil
+			}
+		}
+		t.a.lastUsed = time.Now()
+		t.a.mu.Unlock()
+	})
+	return nil
+}
@@ -0,0 +1,126 @@
+package tts
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// BatchConfig sets how long a Batcher holds text back. With neither set,
+// text is sent as it comes.
+type BatchConfig struct {
+	// MaxChars is how much text is held before it is sent, up to the last
+	// word, even without a break.
+	MaxChars int
+	// MaxDelay is how long text is held before it is sent anyway.
+	MaxDelay time.Duration
+	// Normalize rewrites what is sent, e.g. NormalizeSV. It sees whole
+	// phrases, so a time or an amount is only split by MaxDelay.
+	Normalize func(text string) string
+}
+
+// Batcher collects the deltas of a response and sends them on a break in
+// the text (the end of a phrase), once MaxChars have built up, or after
+// MaxDelay, whichever comes first. The agent writes a token at a time,
+// and sending each on its own costs ElevenLabs both prosody and rate
+// limit.
+type Batcher struct {
+	send func(text string) error
+	cfg  BatchConfig
+
+	mu      sync.Mutex
+	pending strings.Builder
+	timer   *time.Timer
+	// gen tells a timer that fired while text was being sent from the one
+	// started for what is left.
+	gen int
+	err error
+}
+
+func NewBatcher(send func(text string) error, cfg BatchConfig) *Batcher {
+	return &Batcher{send: send, cfg: cfg}
+}
+
+// Write adds text, sending what is ready. It returns the first error from
+// sending, including one from a send after MaxDelay.
+func (b *Batcher) Write(text string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.pending.WriteString(text)
+	pending := b.pending.String()
+
+	cut := breakEnd(pending, ".,!?:;")
+	switch {
+	case b.cfg.MaxChars <= 0 && b.cfg.MaxDelay <= 0:
+		cut = len(pending)
+	case cut == 0 && b.cfg.MaxChars > 0 && utf8.RuneCountInString(pending) >= b.cfg.MaxChars:
+		cut = strings.LastIndexAny(pending, " \t\n") + 1
+		if cut == 0 {
+			cut = len(pending)
+		}
+	}
+	if cut > 0 {
+		b.sendLocked(pending[:cut], pending[cut:])
+	}
+	if b.pending.Len() > 0 && b.timer == nil && b.err == nil && b.cfg.MaxDelay > 0 {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.cfg.MaxDelay, func() { b.expire(gen) })
+	}
+	return b.err
+}
+
+// Flush sends whatever is left; call it before flushing the stream.
+func (b *Batcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil && b.pending.Len() > 0 {
+		b.sendLocked(b.pending.String(), "")
+	}
+	b.stopLocked()
+	return b.err
+}
+
+// Close drops what is left without sending it, for a response abandoned
+// part way.
+func (b *Batcher) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending.Reset()
+	b.stopLocked()
+}
+
+// sendLocked sends text and keeps rest, whose wait starts over. Call with
+// mu held.
+func (b *Batcher) sendLocked(text, rest string) {
+	b.pending.Reset()
+	b.pending.WriteString(rest)
+	b.stopLocked()
+	if b.cfg.Normalize != nil {
+		text = b.cfg.Normalize(text)
+	}
+	if err := b.send(text); err != nil {
+		b.err = err
+	}
+}
+
+func (b *Batcher) expire(gen int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen || b.err != nil || b.pending.Len() == 0 {
+		return
+	}
+	b.sendLocked(b.pending.String(), "")
+}
+
+// stopLocked stops the timer. Call with mu held.
+func (b *Batcher) stopLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.gen++
+}
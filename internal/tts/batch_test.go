@@ -0,0 +1,218 @@
+package tts
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sink records what a Batcher sends.
+type sink struct {
+	mu   sync.Mutex
+	sent []string
+	err  error
+}
+
+func (s *sink) send(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, text)
+	return s.err
+}
+
+func (s *sink) got() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.sent)
+}
+
+func TestBatcherBreaks(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    BatchConfig
+		writes []string
+		// want is what is sent before Flush, and rest what Flush sends.
+		want []string
+		rest string
+	}{
+		{
+			name:   "unbatched",
+			writes: []string{"Hej", " där"},
+			want:   []string{"Hej", " där"},
+		},
+		{
+			name:   "end of sentence",
+			cfg:    BatchConfig{MaxChars: 100},
+			writes: []string{"Hej", " där.", " Hur", " mår du?"},
+			want:   []string{"Hej där. "},
+			rest:   "Hur mår du?",
+		},
+		{
+			name:   "comma and newline",
+			cfg:    BatchConfig{MaxChars: 100},
+			writes: []string{"Först, sen", "\nsist"},
+			want:   []string{"Först, ", "sen\n"},
+			rest:   "sist",
+		},
+		{
+			name:   "decimal point",
+			cfg:    BatchConfig{MaxChars: 100},
+			writes: []string{"Det är 3.5 grader"},
+			rest:   "Det är 3.5 grader",
+		},
+		{
+			name:   "max chars at the last word",
+			cfg:    BatchConfig{MaxChars: 10},
+			writes: []string{"ett", " två", " tre", " fyra"},
+			want:   []string{"ett två "},
+			rest:   "tre fyra",
+		},
+		{
+			name:   "max chars in one word",
+			cfg:    BatchConfig{MaxChars: 5},
+			writes: []string{"Nationalencyklopedin"},
+			want:   []string{"Nationalencyklopedin"},
+		},
+		{
+			name:   "max chars counts runes",
+			cfg:    BatchConfig{MaxChars: 6},
+			writes: []string{"åäö", " åäö"},
+			want:   []string{"åäö "},
+			rest:   "åäö",
+		},
+		{
+			name:   "normalized",
+			cfg:    BatchConfig{MaxChars: 100, Normalize: strings.ToUpper},
+			writes: []string{"klockan är", " nio. Och", " sen"},
+			want:   []string{"KLOCKAN ÄR NIO. "},
+			rest:   "OCH SEN",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s sink
+			b := NewBatcher(s.send, tt.cfg)
+			for _, w := range tt.writes {
+				if err := b.Write(w); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := s.got(); !slices.Equal(got, tt.want) {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+			if err := b.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if tt.rest != "" {
+				want = append(slices.Clone(want), tt.rest)
+			}
+			if got := s.got(); !slices.Equal(got, want) {
+				t.Errorf("after Flush sent %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBatcherRandomChunking(t *testing.T) {
+	const text = "Klockan är 21:35. Det är 3,5 grader ute, och regn i natt!\n" +
+		"Vill du att jag tänder lampan i köket? Den kostar 150 kr; " +
+		"Nationalencyklopedin säger något annat om saken."
+	configs := []struct {
+		name string
+		cfg  BatchConfig
+	}{
+		{"unbatched", BatchConfig{}},
+		{"max chars", BatchConfig{MaxChars: 12}},
+		{"max delay", BatchConfig{MaxDelay: 100 * time.Microsecond}},
+		{"both", BatchConfig{MaxChars: 30, MaxDelay: 100 * time.Microsecond}},
+	}
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			for range 200 {
+				var s sink
+				b := NewBatcher(s.send, c.cfg)
+				for rest := text; rest != ""; {
+					n := min(1+r.IntN(8), len(rest))
+					if err := b.Write(rest[:n]); err != nil {
+						t.Fatal(err)
+					}
+					rest = rest[n:]
+					if r.IntN(10) == 0 {
+						time.Sleep(200 * time.Microsecond)
+					}
+				}
+				if err := b.Flush(); err != nil {
+					t.Fatal(err)
+				}
+				sent := s.got()
+				if got := strings.Join(sent, ""); got != text {
+					t.Fatalf("sent text differs:\n got %q\nwant %q", got, text)
+				}
+				if slices.Contains(sent, "") {
+					t.Fatalf("sent an empty message in %q", sent)
+				}
+			}
+		})
+	}
+}
+
+func TestBatcherMaxDelay(t *testing.T) {
+	var s sink
+	b := NewBatcher(s.send, BatchConfig{MaxChars: 100, MaxDelay: 20 * time.Millisecond})
+	b.Write("Hej")
+	b.Write(" där")
+	if got := s.got(); len(got) != 0 {
+		t.Fatalf("sent %q before MaxDelay", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.got()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := s.got(); !slices.Equal(got, []string{"Hej där"}) {
+		t.Fatalf("after MaxDelay sent %q, want [\"Hej där\"]", got)
+	}
+	// Nothing is left to send twice.
+	b.Flush()
+	if got := s.got(); len(got) != 1 {
+		t.Errorf("Flush sent %q again", got[1:])
+	}
+}
+
+func TestBatcherClose(t *testing.T) {
+	var s sink
+	b := NewBatcher(s.send, BatchConfig{MaxChars: 100, MaxDelay: 10 * time.Millisecond})
+	b.Write("never said")
+	b.Close()
+	time.Sleep(50 * time.Millisecond)
+	if got := s.got(); len(got) != 0 {
+		t.Errorf("sent %q after Close", got)
+	}
+}
+
+func TestBatcherSendError(t *testing.T) {
+	failed := errors.New("connection lost")
+	s := sink{err: failed}
+	b := NewBatcher(s.send, BatchConfig{MaxChars: 100})
+
+	b.Write("Ett")
+	if err := b.Write(" två. "); err != failed {
+		t.Fatalf("Write = %v, want the send error", err)
+	}
+	// From then on nothing more is sent, and every call says why.
+	if err := b.Write("Tre. "); err != failed {
+		t.Errorf("Write after the error = %v, want it again", err)
+	}
+	if err := b.Flush(); err != failed {
+		t.Errorf("Flush = %v, want the send error", err)
+	}
+	if got := s.got(); len(got) != 1 {
+		t.Errorf("sent %q, want only the failed send", got)
+	}
+}
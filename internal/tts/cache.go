@@ -0,0 +1,288 @@
+package tts
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	cacheExt        = ".pcm"
+	cacheTempPrefix = "put-"
+)
+
+// CacheSource is what produced some speech: a provider or a session
+// config. Its voice, model and settings go into the cache key, so changing
+// any of them misses rather than playing the old voice.
+type CacheSource interface {
+	cacheScope() string
+}
+
+// Cache keeps synthesized PCM on disk for text that is spoken again and
+// again, like announcements and error messages, evicting the least
+// recently used entries once it is over its size.
+type Cache struct {
+	cfg CacheConfig
+
+	mu     sync.Mutex
+	lru    *list.List // of *cacheEntry, most recently used first
+	byKey  map[string]*list.Element
+	size   int64
+	hits   int64
+	misses int64
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+type CacheConfig struct {
+	Dir      string
+	MaxBytes int64
+	// MaxChars is the longest text cached, 0 for any. Most responses are
+	// said once, and would only push the fixed phrases out.
+	MaxChars int
+}
+
+// CacheStats counts lookups of text short enough to be cached since the
+// cache was opened.
+type CacheStats struct {
+	Hits    int64
+	Misses  int64
+	Entries int
+	Bytes   int64
+}
+
+// OpenCache picks up what an earlier run left in cfg.Dir, in the order it
+// was last used.
+func OpenCache(cfg CacheConfig) (*Cache, error) {
+	if cfg.MaxBytes <= 0 {
+		return nil, errors.New("the tts cache needs a size")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating tts cache dir: %w", err)
+	}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading tts cache dir: %w", err)
+	}
+
+	type found struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var files []found
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), cacheTempPrefix) {
+			// Left by a Put that never finished.
+			os.Remove(filepath.Join(cfg.Dir, e.Name()))
+			continue
+		}
+		key, ok := strings.CutSuffix(e.Name(), cacheExt)
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, found{key, info.Size(), info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	c := &Cache{cfg: cfg, lru: list.New(), byKey: map[string]*list.Element{}}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range files {
+		c.byKey[f.key] = c.lru.PushBack(&cacheEntry{key: f.key, size: f.size})
+		c.size += f.size
+	}
+	c.evict()
+	return c, nil
+}
+
+// key hashes text with how src speaks, or is "" when it isn't cached.
+func (c *Cache) key(src CacheSource, text string) string {
+	text = strings.TrimSpace(text)
+	if c == nil || src == nil || c.cfg.MaxChars > 0 && utf8.RuneCountInString(text) > c.cfg.MaxChars {
+		return ""
+	}
+	scope := src.cacheScope()
+	if scope == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(scope + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.cfg.Dir, key+cacheExt)
+}
+
+// Get returns the PCM src produced for text, if it is cached.
+func (c *Cache) Get(src CacheSource, text string) ([]byte, bool) {
+	key := c.key(src, text)
+	if key == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.byKey[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	pcm, err := os.ReadFile(c.path(key))
+	if err != nil {
+		// Removed behind our back; forget it.
+		c.remove(el)
+		c.misses++
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	now := time.Now()
+	os.Chtimes(c.path(key), now, now)
+	c.hits++
+	return pcm, true
+}
+
+// Put stores the PCM src produced for text, evicting the least recently
+// used entries to make room.
+func (c *Cache) Put(src CacheSource, text string, pcm []byte) error {
+	key := c.key(src, text)
+	if key == "" || int64(len(pcm)) > c.cfg.MaxBytes {
+		return nil
+	}
+	// Written to a temporary file first, so a crash never leaves a
+	// truncated entry that would later play as the whole phrase.
+	tmp, err := os.CreateTemp(c.cfg.Dir, cacheTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("creating tts cache entry: %w", err)
+	}
+	_, err = tmp.Write(pcm)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing tts cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.byKey[key]; ok {
+		e := el.Value.(*cacheEntry)
+		c.size += int64(len(pcm)) - e.size
+		e.size = int64(len(pcm))
+		c.lru.MoveToFront(el)
+	} else {
+		c.byKey[key] = c.lru.PushFront(&cacheEntry{key: key, size: int64(len(pcm))})
+		c.size += int64(len(pcm))
+	}
+	c.evict()
+	return nil
+}
+
+// Synthesize returns the cached PCM for text, or synthesizes and caches
+// it. A failure to cache is only logged, as the audio is still good.
+func (c *Cache) Synthesize(ctx context.Context, p Provider, text string, synth func(ctx context.Context, text string) ([]byte, error)) ([]byte, error) {
+	src, _ := p.(CacheSource)
+	if pcm, ok := c.Get(src, text); ok {
+		return pcm, nil
+	}
+	pcm, err := synth(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Put(src, text, pcm); err != nil {
+		slog.Warn("caching tts audio", "error", err)
+	}
+	return pcm, nil
+}
+
+// Stats is safe to call on a nil Cache, as is every other method; a nil
+// Cache caches nothing.
+func (c *Cache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.lru.Len(), Bytes: c.size}
+}
+
+// evict drops least recently used entries until the cache fits. Call with
+// mu held.
+func (c *Cache) evict() {
+	for c.size > c.cfg.MaxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops el and its file. Call with mu held.
+func (c *Cache) remove(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.byKey, e.key)
+	c.size -= e.size
+	if err := os.Remove(c.path(e.key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("removing tts cache entry", "error", err)
+	}
+}
+
+// scope is a cache scope from v's fields, for the providers' settings.
+func scope(name string, v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return name + ":" + string(data)
+}
+
+func (c SessionConfig) cacheScope() string {
+	return scope("elevenlabs", struct {
+		Voice, Model string
+		Format       OutputFormat
+		Language     string
+		SSML         bool
+		Settings     *wsVoiceSettings
+		Seed         uint32
+		Dictionaries []DictionaryLocator
+	}{c.VoiceID, c.ModelID, c.OutputFormat, c.LanguageCode, c.EnableSSML, c.voiceSettings(), c.Seed, c.Dictionaries})
+}
+
+func (e *ElevenLabs) cacheScope() string { return e.config().cacheScope() }
+
+func (o *OpenAI) cacheScope() string {
+	cfg := o.cfg
+	cfg.APIKey = ""
+	return scope("openai", cfg)
+}
+
+func (p *Piper) cacheScope() string {
+	return scope("piper", struct {
+		URL, Bin, Model string
+		Rate            int
+	}{p.cfg.URL, p.cfg.Bin, p.cfg.Model, p.rate})
+}
+
+func (a *Azure) cacheScope() string { return scope("azure", a.cfg.Voice) }
@@ -0,0 +1,297 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSource is a CacheSource with a fixed scope.
+type testSource string
+
+func (s testSource) cacheScope() string { return string(s) }
+
+// testProvider is a Provider that caches under a fixed scope.
+type testProvider struct {
+	Provider
+	testSource
+}
+
+func openTestCache(t *testing.T, cfg CacheConfig) *Cache {
+	t.Helper()
+	if cfg.Dir == "" {
+		cfg.Dir = t.TempDir()
+	}
+	c, err := OpenCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// entries returns the keys on disk, sorted.
+func entries(t *testing.T, c *Cache) []string {
+	t.Helper()
+	files, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, f := range files {
+		keys = append(keys, strings.TrimSuffix(f.Name(), cacheExt))
+	}
+	return keys
+}
+
+func TestCacheKey(t *testing.T) {
+	c := openTestCache(t, CacheConfig{MaxBytes: 1 << 20, MaxChars: 10})
+	base := c.key(testSource("voice"), "Hej")
+
+	tests := []struct {
+		name string
+		c    *Cache
+		src  CacheSource
+		text string
+		same bool // as base
+		none bool // not cached at all
+	}{
+		{"same text", c, testSource("voice"), "Hej", true, false},
+		{"surrounding space", c, testSource("voice"), "  Hej\n", true, false},
+		{"other text", c, testSource("voice"), "Hej då", false, false},
+		{"other case", c, testSource("voice"), "hej", false, false},
+		{"other voice", c, testSource("other"), "Hej", false, false},
+		{"at MaxChars", c, testSource("voice"), "åäöåäöåäöå", false, false},
+		{"over MaxChars", c, testSource("voice"), "åäöåäöåäöåä", false, true},
+		{"no source", c, nil, "Hej", false, true},
+		{"empty scope", c, testSource(""), "Hej", false, true},
+		{"nil cache", nil, testSource("voice"), "Hej", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.c.key(tt.src, tt.text)
+			switch {
+			case tt.none:
+				if got != "" {
+					t.Errorf("key = %q, want none", got)
+				}
+			case tt.same && got != base:
+				t.Errorf("key = %q, want %q", got, base)
+			case !tt.same && (got == base || got == ""):
+				t.Errorf("key = %q, want a key of its own", got)
+			}
+		})
+	}
+}
+
+func TestCacheScope(t *testing.T) {
+	on := true
+	base := SessionConfig{APIKey: "secret-api-key", VoiceID: "v", ModelID: "m", OutputFormat: FormatPCM24000, Stability: 0.5}
+	tests := []struct {
+		name   string
+		change func(c *SessionConfig)
+		same   bool
+	}{
+		{"api key", func(c *SessionConfig) { c.APIKey = "other" }, true},
+		{"reconnects", func(c *SessionConfig) { c.MaxReconnects = 3 }, true},
+		{"voice", func(c *SessionConfig) { c.VoiceID = "w" }, false},
+		{"model", func(c *SessionConfig) { c.ModelID = "n" }, false},
+		{"format", func(c *SessionConfig) { c.OutputFormat = FormatPCM16000 }, false},
+		{"stability", func(c *SessionConfig) { c.Stability = 0.6 }, false},
+		{"style", func(c *SessionConfig) { c.Style = 0.2 }, false},
+		{"speaker boost", func(c *SessionConfig) { c.SpeakerBoost = &on }, false},
+		{"seed", func(c *SessionConfig) { c.Seed = 7 }, false},
+		{"language", func(c *SessionConfig) { c.LanguageCode = "sv" }, false},
+		{"dictionary", func(c *SessionConfig) { c.Dictionaries = []DictionaryLocator{{ID: "d"}} }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.change(&cfg)
+			if same := cfg.cacheScope() == base.cacheScope(); same != tt.same {
+				t.Errorf("same scope = %v, want %v", same, tt.same)
+			}
+		})
+	}
+	if strings.Contains(base.cacheScope(), base.APIKey) {
+		t.Error("the api key is part of the cache scope")
+	}
+}
+
+func TestCacheGetPut(t *testing.T) {
+	c := openTestCache(t, CacheConfig{MaxBytes: 1 << 20})
+	src := testSource("voice")
+
+	if _, ok := c.Get(src, "hello"); ok {
+		t.Fatal("hit on an empty cache")
+	}
+	if err := c.Put(src, "hello", []byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	pcm, ok := c.Get(src, "hello")
+	if !ok || !slices.Equal(pcm, []byte{1, 2, 3, 4}) {
+		t.Fatalf("Get = %v, %v, want the stored audio", pcm, ok)
+	}
+	if _, ok := c.Get(testSource("other"), "hello"); ok {
+		t.Error("hit for another voice")
+	}
+
+	// Storing again replaces the audio and its size.
+	if err := c.Put(src, "hello", []byte{5, 6}); err != nil {
+		t.Fatal(err)
+	}
+	if pcm, _ := c.Get(src, "hello"); !slices.Equal(pcm, []byte{5, 6}) {
+		t.Errorf("Get after replacing = %v, want [5 6]", pcm)
+	}
+	want := CacheStats{Hits: 2, Misses: 2, Entries: 1, Bytes: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// A file removed behind the cache's back is a miss, and forgotten.
+	os.Remove(c.path(c.key(src, "hello")))
+	if _, ok := c.Get(src, "hello"); ok {
+		t.Error("hit for a removed file")
+	}
+	if s := c.Stats(); s.Entries != 0 || s.Bytes != 0 {
+		t.Errorf("Stats() = %+v, want the removed entry forgotten", s)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := openTestCache(t, CacheConfig{MaxBytes: 30})
+	src := testSource("voice")
+	put := func(text string, size int) {
+		t.Helper()
+		if err := c.Put(src, text, make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cached := func(text string) bool {
+		_, ok := c.byKey[c.key(src, text)]
+		return ok
+	}
+
+	put("a", 10)
+	put("b", 10)
+	put("c", 10)
+	// Using a makes b the least recently used.
+	c.Get(src, "a")
+	put("d", 10)
+	for text, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if cached(text) != want {
+			t.Errorf("%s cached = %v, want %v", text, !want, want)
+		}
+	}
+	if got := len(entries(t, c)); got != 3 {
+		t.Errorf("%d files on disk, want the evicted one removed", got)
+	}
+
+	// A big entry pushes out as many as it needs.
+	put("e", 25)
+	if !cached("e") || cached("a") || cached("c") || cached("d") {
+		t.Error("a 25 byte entry didn't evict the three older ones")
+	}
+	// One over the limit on its own isn't cached at all.
+	put("f", 31)
+	if cached("f") || !cached("e") {
+		t.Error("an entry bigger than the cache was stored")
+	}
+	if s := c.Stats(); s.Bytes != 25 || s.Entries != 1 {
+		t.Errorf("Stats() = %+v, want 25 bytes in one entry", s)
+	}
+}
+
+func TestOpenCacheRestores(t *testing.T) {
+	dir := t.TempDir()
+	src := testSource("voice")
+	c := openTestCache(t, CacheConfig{Dir: dir, MaxBytes: 100})
+	// Oldest first, each used a minute after the last.
+	now := time.Now()
+	for i, text := range []string{"old", "mid", "new"} {
+		if err := c.Put(src, text, make([]byte, 10)); err != nil {
+			t.Fatal(err)
+		}
+		at := now.Add(time.Duration(i-3) * time.Minute)
+		os.Chtimes(c.path(c.key(src, text)), at, at)
+	}
+	// And a Put that never finished.
+	os.WriteFile(filepath.Join(dir, cacheTempPrefix+"123"), []byte{1}, 0o644)
+
+	// Reopened smaller, the least recently used goes first.
+	c = openTestCache(t, CacheConfig{Dir: dir, MaxBytes: 20})
+	if _, ok := c.Get(src, "old"); ok {
+		t.Error("the oldest entry survived a smaller cache")
+	}
+	for _, text := range []string{"mid", "new"} {
+		if _, ok := c.Get(src, text); !ok {
+			t.Errorf("%s was lost on reopening", text)
+		}
+	}
+	for _, key := range entries(t, c) {
+		if strings.HasPrefix(key, cacheTempPrefix) {
+			t.Errorf("unfinished entry %s left behind", key)
+		}
+	}
+}
+
+func TestOpenCacheNeedsSize(t *testing.T) {
+	if _, err := OpenCache(CacheConfig{Dir: t.TempDir()}); err == nil {
+		t.Error("opened a cache without a size")
+	}
+}
+
+func TestCacheSynthesize(t *testing.T) {
+	c := openTestCache(t, CacheConfig{MaxBytes: 1 << 20})
+	p := testProvider{testSource: "voice"}
+	calls := 0
+	synth := func(_ context.Context, text string) ([]byte, error) {
+		calls++
+		if text == "broken" {
+			return nil, errors.New("synthesis failed")
+		}
+		return []byte(text), nil
+	}
+
+	for range 3 {
+		pcm, err := c.Synthesize(context.Background(), p, "timer done", synth)
+		if err != nil || string(pcm) != "timer done" {
+			t.Fatalf("Synthesize = %q, %v", pcm, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("synthesized %d times, want once", calls)
+	}
+
+	// A failure isn't cached, so the next call tries again.
+	for range 2 {
+		if _, err := c.Synthesize(context.Background(), p, "broken", synth); err == nil {
+			t.Error("Synthesize succeeded, want the error")
+		}
+	}
+	if calls != 3 {
+		t.Errorf("synthesized %d times, want a retry after the failure", calls)
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	if _, ok := c.Get(testSource("voice"), "hello"); ok {
+		t.Error("hit on a nil cache")
+	}
+	if err := c.Put(testSource("voice"), "hello", []byte{1}); err != nil {
+		t.Error(err)
+	}
+	if got := c.Stats(); got != (CacheStats{}) {
+		t.Errorf("Stats() = %+v, want zero", got)
+	}
+	pcm, err := c.Synthesize(context.Background(), testProvider{testSource: "voice"}, "hi",
+		func(context.Context, string) ([]byte, error) { return []byte("hi"), nil })
+	if err != nil || string(pcm) != "hi" {
+		t.Errorf("Synthesize = %q, %v, want it synthesized", pcm, err)
+	}
+}
@@ -4,101 +4,675 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"reflect"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"time"
+	"unicode"
 
 	"github.com/gorilla/websocket"
+	"github.com/joakimcarlsson/smarthome/internal/guard"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const defaultBaseURL = "wss://api.elevenlabs.io/v1"
+// defaultBaseURL is the ElevenLabs websocket API. Tests point it at a fake
+// server.
+var defaultBaseURL = "wss://api.elevenlabs.io/v1"
 
 type SessionConfig struct {
 	APIKey       string
 	VoiceID      string
 	ModelID      string
-	OutputFormat string
+	OutputFormat OutputFormat
 	Stability    float64
 	Similarity   float64
 	Speed        float64
+	EnableSSML   bool
+	// LanguageCode is the ISO 639-1 language of the text, e.g. sv, which
+	// multilingual models pronounce better for knowing. Empty lets the
+	// model guess.
+	LanguageCode string
+	// Style exaggerates the voice's style, 0 to 1; 0 leaves it alone.
+	// SpeakerBoost, when set, turns speaker boost on or off rather than
+	// keeping the voice's setting. Seed, when not 0, makes generation
+	// repeatable for the same text and settings.
+	Style        float64
+	SpeakerBoost *bool
+	Seed         uint32
+
+	// MaxReconnects is how many times a session redials after its
+	// connection drops, replaying the text that hasn't been spoken yet. 0
+	// gives up on the first drop. ReconnectBackoff is the wait before the
+	// first attempt, doubling after each.
+	MaxReconnects    int
+	ReconnectBackoff time.Duration
+	// DialRetries is how many more times NewSession dials after a failure
+	// that may pass, such as DNS or TLS, though not a rejected key or
+	// voice. DialBackoff is the wait before the first retry, doubling up
+	// to DialMaxBackoff, and DialJitter, 0 to 1, the share of each wait
+	// that is random, so sessions failing together don't retry together.
+	DialRetries    int
+	DialBackoff    time.Duration
+	DialMaxBackoff time.Duration
+	DialJitter     float64
+	// KeepAlive is how long a session may go without sending text before
+	// it sends the keep-alive ElevenLabs documents, a lone space, so the
+	// server doesn't close it for inactivity. 0 sends none.
+	KeepAlive time.Duration
+	// FlushTimeout is how long FlushAndWait waits for the server to
+	// finish a response. 0 waits as long as ctx allows.
+	FlushTimeout time.Duration
+	// Alignment asks for character timings with the audio and passes
+	// them on in AudioChunk.Alignment.
+	Alignment bool
+	// ChunkLengthSchedule is how many characters the server waits for
+	// before generating the first chunk of audio, then the next, and so
+	// on, each 50 to 500. Shorter starts sooner, longer reads better.
+	// Empty keeps ElevenLabs' default.
+	ChunkLengthSchedule []int
+	// AutoMode has the server decide when to generate instead of
+	// following a schedule, which ElevenLabs recommends when text comes
+	// in whole sentences.
+	AutoMode bool
+	// Dictionaries are pronunciation dictionaries kept in the ElevenLabs
+	// account, applied in order. ElevenLabs takes at most three.
+	Dictionaries []DictionaryLocator
+	// Guard is told about panics in the read loop, so they count toward
+	// its limit. Nil only logs them.
+	Guard *guard.Guard
+}
+
+// DictionaryLocator names a pronunciation dictionary and, optionally, the
+// version to use; without one the latest is used.
+type DictionaryLocator struct {
+	ID        string `json:"pronunciation_dictionary_id"`
+	VersionID string `json:"version_id,omitempty"`
+}
+
+// ParseDictionaryLocators reads locators written as id or id:version.
+func ParseDictionaryLocators(values []string) ([]DictionaryLocator, error) {
+	var locators []DictionaryLocator
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		id, version, _ := strings.Cut(v, ":")
+		if id == "" {
+			return nil, fmt.Errorf("invalid pronunciation dictionary %q, expected id or id:version", v)
+		}
+		locators = append(locators, DictionaryLocator{ID: id, VersionID: version})
+	}
+	return locators, nil
+}
+
+// sameAs reports whether a session made for c can serve a turn that asked
+// for o.
+func (c SessionConfig) sameAs(o SessionConfig) bool {
+	return reflect.DeepEqual(c, o)
+}
+
+// phonemeModels are the models that honour SSML phoneme tags; the others
+// read the tag contents out loud or ignore them.
+var phonemeModels = map[string]bool{
+	"eleven_flash_v2":       true,
+	"eleven_turbo_v2":       true,
+	"eleven_monolingual_v1": true,
+}
+
+func SupportsPhonemes(modelID string) bool {
+	return phonemeModels[modelID]
 }
 
 type AudioChunk struct {
 	Data  []byte
 	Error error
 	Done  bool
+	// Reconnected marks the point where the connection dropped and the
+	// session redialed. The chunk has no Data.
+	Reconnected bool
+	// Alignment times the characters spoken in Data. For compressed
+	// formats it comes in a chunk of its own, ahead of the decoded audio.
+	Alignment *Alignment
+	// Utterance is the number BeginUtterance gave the response the chunk
+	// belongs to, or 0 on a session used for a single response.
+	Utterance int
+}
+
+// Alignment is when each character of the input text is spoken. Start is
+// from the beginning of the response's audio, so it can be compared with
+// how much has been played.
+type Alignment struct {
+	Chars    []string
+	Start    []time.Duration
+	Duration []time.Duration
 }
 
 type Session struct {
-	conn   *websocket.Conn
+	cfg    SessionConfig
+	url    string
 	audio  chan AudioChunk
 	done   chan struct{}
+	ctx    context.Context
 	cancel context.CancelFunc
 	once   sync.Once
+	// closing is closed when Close starts, after which readLoop only
+	// waits for the server to end the session. closeErr is what Close
+	// returns.
+	closing  chan struct{}
+	closeErr error
+
+	// connMu guards conn and what a reconnect replays, since SendText and
+	// Flush write from the caller's goroutine while readLoop may be
+	// redialing. unacked is the text sent that the alignment of received
+	// audio hasn't covered yet, and flushed whether Flush was called.
+	connMu     sync.Mutex
+	conn       *websocket.Conn
+	unacked    []rune
+	flushed    bool
+	reconnects int
+	sentText   bool
+	lastSend   time.Time
+	// redialing is closed when the reconnect under way ends, and nil when
+	// there is none.
+	redialing chan struct{}
+	// chars counts the characters sent with SendText, for Characters.
+	chars int
+	// firstText and firstAudio time the response for FirstAudioLatency.
+	// readLoop sets firstAudio, and alone reads sawAudio.
+	firstText  time.Time
+	firstAudio time.Time
+	sawAudio   bool
+
+	// On a session kept across responses, utterance numbers the current
+	// one, open is whether it is still taking text, and ends are the
+	// responses ended but not yet spoken to the end. sent and acked count
+	// the characters sent and covered by the alignment since the session
+	// started, as spokenCounter counts them, which is how a response's end
+	// is recognized in the audio.
+	reused    bool
+	utterance int
+	open      bool
+	ends      []utteranceEnd
+	counter   spokenCounter
+	sent      int
+	acked     int
+
+	// span covers the session from dialing until readLoop ends.
+	span trace.Span
+
+	// spoken is how far into the response's audio the next message
+	// starts, for Alignment. It is only used by readLoop.
+	spoken time.Duration
+
+	// Audio arrives in the configured output format and leaves as PCM at
+	// its sample rate.
+	dec *streamDecoder
 }
 
 type wsInitMessage struct {
-	Text          string          `json:"text"`
-	VoiceSettings *wsVoiceSettings `json:"voice_settings,omitempty"`
+	Text             string              `json:"text"`
+	VoiceSettings    *wsVoiceSettings    `json:"voice_settings,omitempty"`
+	GenerationConfig *wsGenerationConfig `json:"generation_config,omitempty"`
+	Dictionaries     []DictionaryLocator `json:"pronunciation_dictionary_locators,omitempty"`
+}
+
+type wsGenerationConfig struct {
+	ChunkLengthSchedule []int `json:"chunk_length_schedule"`
 }
 
 type wsVoiceSettings struct {
 	Stability       float64 `json:"stability"`
 	SimilarityBoost float64 `json:"similarity_boost"`
 	Speed           float64 `json:"speed"`
+	Style           float64 `json:"style,omitempty"`
+	UseSpeakerBoost *bool   `json:"use_speaker_boost,omitempty"`
 }
 
 type wsTextMessage struct {
 	Text                 string `json:"text"`
 	TryTriggerGeneration bool   `json:"try_trigger_generation,omitempty"`
+	// Flush has the server speak what it holds without ending the
+	// session, as an empty Text would.
+	Flush bool `json:"flush,omitempty"`
 }
 
 type wsAudioMessage struct {
-	Audio   string `json:"audio"`
-	IsFinal bool   `json:"isFinal"`
+	Audio     string       `json:"audio"`
+	IsFinal   bool         `json:"isFinal"`
+	Alignment *wsAlignment `json:"alignment"`
+	wsError
+}
+
+// wsError is how the server reports a failure on the socket, in a message
+// of its own instead of audio.
+type wsError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// err returns the failure the message reports, or nil if it is audio.
+func (e wsError) err() error {
+	if e.Error == "" && e.Message == "" {
+		return nil
+	}
+	return &APIError{Status: e.Error, Message: e.Message}
+}
+
+// wsAlignment maps the audio in a message to the characters of the input
+// text it speaks, timed from the start of the message's audio.
+type wsAlignment struct {
+	Chars       []string `json:"chars"`
+	StartsMs    []int    `json:"charStartTimesMs"`
+	DurationsMs []int    `json:"charsDurationsMs"`
+}
+
+// errStreamInterrupted ends the encoded stream of a dropped connection,
+// whose last frame is likely cut short.
+var errStreamInterrupted = errors.New("tts stream interrupted")
+
+// utteranceEnd is where a response ended, as a count of characters sent.
+type utteranceEnd struct {
+	utterance int
+	at        int
 }
 
+// spokenCounter counts the characters of text the alignment will cover.
+// Whitespace isn't counted, as the server may collapse or drop it, and a
+// keep-alive is nothing but. Nor are SSML tags, which are read for their
+// content; inTag carries a tag split between two texts over.
+type spokenCounter struct {
+	inTag bool
+}
+
+func (c *spokenCounter) count(text string, ssml bool) int {
+	n := 0
+	for _, r := range text {
+		switch {
+		case ssml && c.inTag:
+			c.inTag = r != '>'
+		case ssml && r == '<':
+			c.inTag = true
+		case !unicode.IsSpace(r):
+			n++
+		}
+	}
+	return n
+}
+
+// alignedChars counts the characters of an alignment as spokenCounter
+// counts the text it covers.
+func alignedChars(chars []string) int {
+	n := 0
+	for _, c := range chars {
+		if strings.TrimSpace(c) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// ErrCloseTimeout is returned by Close when the server didn't answer the
+// close handshake in time, and the connection was dropped instead.
+var ErrCloseTimeout = errors.New("tts server did not acknowledge close")
+
+// closeTimeout bounds how long Close waits for the server.
+const closeTimeout = 500 * time.Millisecond
+
+// ErrFlushTimeout is returned by FlushAndWait when the server never ended
+// the response.
+var ErrFlushTimeout = errors.New("tts response not finished in time")
+
+// NewSession connects a streaming session. The output format is checked
+// before connecting, so one that can't be played fails here rather than at
+// the first chunk of audio.
 func NewSession(ctx context.Context, cfg SessionConfig) (*Session, error) {
-	url := fmt.Sprintf("%s/text-to-speech/%s/stream-input?model_id=%s&output_format=%s",
-		defaultBaseURL, cfg.VoiceID, cfg.ModelID, cfg.OutputFormat)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Session{
+		cfg:     cfg,
+		url:     streamURL("stream-input", cfg),
+		audio:   make(chan AudioChunk, 32),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+		closing: make(chan struct{}),
+	}
+	s.dec = newStreamDecoder(ctx, cfg, s.audio)
+	_, s.span = tracer.Start(ctx, "tts.session", trace.WithAttributes(cfg.attrs()...))
+	conn, err := s.dialRetrying(ctx)
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.End()
+		cancel()
+		return nil, err
+	}
+	s.conn = conn
+	s.lastSend = time.Now()
+
+	s.dec.start()
+	go s.readLoop(ctx, conn)
+	if cfg.KeepAlive > 0 {
+		go s.keepAlive(ctx)
+	}
+
+	return s, nil
+}
+
+// streamURL is the websocket URL of endpoint for cfg's voice, model and
+// output format.
+func streamURL(endpoint string, cfg SessionConfig) string {
+	url := fmt.Sprintf("%s/text-to-speech/%s/%s?model_id=%s&output_format=%s",
+		defaultBaseURL, cfg.VoiceID, endpoint, cfg.ModelID, cfg.OutputFormat)
+	if cfg.EnableSSML {
+		url += "&enable_ssml_parsing=true"
+	}
+	if cfg.Alignment {
+		url += "&sync_alignment=true"
+	}
+	if cfg.AutoMode {
+		url += "&auto_mode=true"
+	}
+	if cfg.LanguageCode != "" {
+		url += "&language_code=" + cfg.LanguageCode
+	}
+	if cfg.Seed != 0 {
+		url += fmt.Sprintf("&seed=%d", cfg.Seed)
+	}
+	return url
+}
 
+// dialWS opens a websocket to url with cfg's API key.
+func dialWS(ctx context.Context, url string, cfg SessionConfig) (*websocket.Conn, error) {
 	header := http.Header{}
 	header.Set("xi-api-key", cfg.APIKey)
 
-	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
 	if err != nil {
+		// A refused upgrade says why in the response body, which tells a
+		// bad key or voice apart from the network.
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			return nil, fmt.Errorf("dialing elevenlabs ws: %w", parseAPIError(resp.StatusCode, body))
+		}
 		return nil, fmt.Errorf("dialing elevenlabs ws: %w", err)
 	}
+	return conn, nil
+}
+
+// dialRetrying dials for NewSession, retrying as DialRetries allows, so
+// one failed lookup or handshake doesn't cost a whole response.
+func (s *Session) dialRetrying(ctx context.Context) (*websocket.Conn, error) {
+	wait := s.cfg.DialBackoff
+	for attempt := 1; ; attempt++ {
+		conn, err := s.dial(ctx)
+		countDial(ctx, attempt)
+		if err == nil {
+			slog.Debug("tts session dialed", "attempts", attempt)
+			return conn, nil
+		}
+		if attempt > s.cfg.DialRetries || !retryable(err) || ctx.Err() != nil {
+			slog.Debug("dialing tts session failed", "attempts", attempt, "error", err)
+			return nil, err
+		}
+
+		delay := jitter(wait, s.cfg.DialJitter)
+		slog.Debug("dialing tts session failed, retrying", "attempt", attempt, "backoff", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+		wait *= 2
+		if s.cfg.DialMaxBackoff > 0 {
+			wait = min(wait, s.cfg.DialMaxBackoff)
+		}
+	}
+}
+
+// jitter returns d with up to share of it made random.
+func jitter(d time.Duration, share float64) time.Duration {
+	if share <= 0 || d <= 0 {
+		return d
+	}
+	return d - time.Duration(share*rand.Float64()*float64(d))
+}
+
+func (c SessionConfig) voiceSettings() *wsVoiceSettings {
+	return &wsVoiceSettings{
+		Stability:       c.Stability,
+		SimilarityBoost: c.Similarity,
+		Speed:           c.Speed,
+		Style:           c.Style,
+		UseSpeakerBoost: c.SpeakerBoost,
+	}
+}
+
+func (c SessionConfig) generationConfig() *wsGenerationConfig {
+	if len(c.ChunkLengthSchedule) == 0 {
+		return nil
+	}
+	return &wsGenerationConfig{ChunkLengthSchedule: c.ChunkLengthSchedule}
+}
+
+// initMessage opens a session's stream with cfg's voice and generation
+// settings.
+func (c SessionConfig) initMessage() wsInitMessage {
+	return wsInitMessage{
+		Text:             " ",
+		VoiceSettings:    c.voiceSettings(),
+		GenerationConfig: c.generationConfig(),
+		Dictionaries:     c.Dictionaries,
+	}
+}
+
+// dial connects and sends the init message with the voice settings.
+func (s *Session) dial(ctx context.Context) (*websocket.Conn, error) {
+	conn, err := dialWS(ctx, s.url, s.cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := conn.WriteJSON(wsInitMessage{
-		Text: " ",
-		VoiceSettings: &wsVoiceSettings{
-			Stability:       cfg.Stability,
-			SimilarityBoost: cfg.Similarity,
-			Speed:           cfg.Speed,
-		},
-	}); err != nil {
+	if err := conn.WriteJSON(s.cfg.initMessage()); err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("sending init message: %w", err)
 	}
+	s.span.AddEvent("init_sent")
+	return conn, nil
+}
 
-	ctx, cancel := context.WithCancel(ctx)
-	s := &Session{
-		conn:   conn,
-		audio:  make(chan AudioChunk, 32),
-		done:   make(chan struct{}),
-		cancel: cancel,
+// reconnect replaces conn after it failed with cause, and replays what
+// wasn't spoken on the new connection. connMu is only taken to swap the
+// new connection in, so SendText, Close and the rest don't wait out the
+// backoff and the dial. A write and readLoop often both see the same
+// connection fail; the second waits for the first's reconnect and shares
+// its outcome. It returns cause, or the last attempt's error, once
+// MaxReconnects is used up. Call without connMu held.
+func (s *Session) reconnect(conn *websocket.Conn, cause error) error {
+	s.connMu.Lock()
+	for s.redialing != nil {
+		ch := s.redialing
+		s.connMu.Unlock()
+		<-ch
+		s.connMu.Lock()
 	}
+	if s.conn != conn {
+		s.connMu.Unlock()
+		return nil
+	}
+	done := make(chan struct{})
+	s.redialing = done
+	s.connMu.Unlock()
+	defer func() {
+		s.connMu.Lock()
+		s.redialing = nil
+		s.connMu.Unlock()
+		close(done)
+	}()
+	return s.redial(conn, cause)
+}
 
-	go s.readLoop(ctx)
+// redial is reconnect's loop, backing off between attempts.
+func (s *Session) redial(conn *websocket.Conn, cause error) error {
+	conn.Close()
+	for {
+		s.connMu.Lock()
+		if s.reconnects >= s.cfg.MaxReconnects || s.ctx.Err() != nil || s.isClosing() {
+			s.connMu.Unlock()
+			return cause
+		}
+		wait := s.cfg.ReconnectBackoff << s.reconnects
+		s.reconnects++
+		attempt := s.reconnects
+		s.connMu.Unlock()
 
-	return s, nil
+		slog.Warn("tts connection lost, reconnecting", "error", cause, "attempt", attempt, "backoff", wait)
+		select {
+		case <-time.After(wait):
+		case <-s.ctx.Done():
+			return cause
+		case <-s.closing:
+			return cause
+		}
+		next, err := s.dial(s.ctx)
+		if err != nil {
+			cause = err
+			continue
+		}
+
+		s.connMu.Lock()
+		// conn may have been replaced, or Close started, while dialing;
+		// either way the new connection isn't wanted.
+		if s.conn != conn {
+			s.connMu.Unlock()
+			next.Close()
+			return nil
+		}
+		if s.ctx.Err() != nil || s.isClosing() {
+			s.connMu.Unlock()
+			next.Close()
+			return cause
+		}
+		// Text sent while dialing is in unacked too, so it is replayed.
+		if err := s.replay(next); err != nil {
+			s.connMu.Unlock()
+			next.Close()
+			cause = err
+			continue
+		}
+		s.conn = next
+		replayed := len(s.unacked)
+		s.connMu.Unlock()
+		slog.Info("tts reconnected", "replayed_chars", replayed)
+		return nil
+	}
+}
+
+// replay resends the text that hasn't been spoken, and the flush if there
+// was one. It starts where the alignment left off, which may be mid-word.
+func (s *Session) replay(conn *websocket.Conn) error {
+	if len(s.unacked) > 0 {
+		if err := conn.WriteJSON(wsTextMessage{Text: string(s.unacked), TryTriggerGeneration: true}); err != nil {
+			return fmt.Errorf("replaying text: %w", err)
+		}
+	}
+	if s.flushed {
+		if err := conn.WriteJSON(wsTextMessage{Text: ""}); err != nil {
+			return fmt.Errorf("replaying flush: %w", err)
+		}
+	} else if len(s.ends) > 0 {
+		if err := conn.WriteJSON(wsTextMessage{Text: " ", Flush: true}); err != nil {
+			return fmt.Errorf("replaying flush: %w", err)
+		}
+	}
+	return nil
 }
 
-func (s *Session) readLoop(ctx context.Context) {
+// recoverConn is readLoop's side of a failed connection: unless a write
+// already replaced conn, it reconnects. It returns the connection to
+// read from next.
+func (s *Session) recoverConn(conn *websocket.Conn, cause error) (*websocket.Conn, error) {
+	if err := s.reconnect(conn, cause); err != nil {
+		return nil, err
+	}
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.conn, nil
+}
+
+// ack drops the text the alignment of audio read from conn covers, and
+// returns the responses that were spoken to the end by it. Audio still
+// arriving on a connection that was already replaced is ignored, since its
+// text has been replayed.
+func (s *Session) ack(conn *websocket.Conn, a *wsAlignment) []int {
+	if a == nil {
+		return nil
+	}
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.conn != conn {
+		return nil
+	}
+	n := min(len(a.Chars), len(s.unacked))
+	s.unacked = s.unacked[n:]
+	s.acked = min(s.acked+alignedChars(a.Chars), s.sent)
+	return s.spokenLocked()
+}
+
+// spokenLocked takes the responses whose text has all been acked off ends.
+// Call with connMu held.
+func (s *Session) spokenLocked() []int {
+	var spoken []int
+	for len(s.ends) > 0 && s.ends[0].at <= s.acked {
+		spoken = append(spoken, s.ends[0].utterance)
+		s.ends = s.ends[1:]
+	}
+	return spoken
+}
+
+// speaking returns the response whose audio comes next: the first ended
+// one not yet spoken to the end, or else the current one.
+func (s *Session) speaking() int {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if len(s.ends) > 0 {
+		return s.ends[0].utterance
+	}
+	return s.utterance
+}
+
+func (s *Session) readLoop(ctx context.Context, conn *websocket.Conn) {
+	defer s.span.End()
 	defer close(s.done)
 	defer close(s.audio)
+	// The decoder sends on audio too, so it has to finish first.
+	defer s.dec.stop(nil)
+	defer func() {
+		if r := recover(); r != nil {
+			s.cfg.Guard.Handle("tts_read_loop", r, debug.Stack())
+			select {
+			case s.audio <- AudioChunk{Error: fmt.Errorf("tts read loop panic: %v", r)}:
+			case <-ctx.Done():
+			}
+		}
+	}()
 
+	// current is the response the last audio belonged to.
+	current := 0
 	for {
 		select {
 		case <-ctx.Done():
@@ -106,7 +680,7 @@ func (s *Session) readLoop(ctx context.Context) {
 		default:
 		}
 
-		_, msg, err := s.conn.ReadMessage()
+		_, msg, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
 				return
@@ -116,60 +690,398 @@ func (s *Session) readLoop(ctx context.Context) {
 				return
 			default:
 			}
-			s.audio <- AudioChunk{Error: fmt.Errorf("ws read: %w", err)}
-			return
+			if s.isClosing() {
+				return
+			}
+			// A session the server closed before any text was sent has
+			// nothing to resume; it just ends, and Done tells a pool.
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) && s.idle() {
+				slog.Debug("tts session closed by server while idle", "code", closeErr.Code, "reason", closeErr.Text)
+				return
+			}
+			next, err := s.recoverConn(conn, fmt.Errorf("ws read: %w", err))
+			if err != nil {
+				s.send(AudioChunk{Error: err})
+				return
+			}
+			// The new connection's audio starts a stream of its own.
+			s.dec.stop(errStreamInterrupted)
+			s.dec.start()
+			conn = next
+			if !s.send(AudioChunk{Reconnected: true, Utterance: s.speaking()}) {
+				return
+			}
+			continue
 		}
 
 		var am wsAudioMessage
 		if err := json.Unmarshal(msg, &am); err != nil {
-			s.audio <- AudioChunk{Error: fmt.Errorf("ws unmarshal: %w", err)}
+			s.send(AudioChunk{Error: fmt.Errorf("ws unmarshal: %w", err)})
+			return
+		}
+		if s.isClosing() {
+			// Nobody is listening any more; the final message ends the
+			// session as well as the server's close frame would.
+			if am.IsFinal {
+				return
+			}
+			continue
+		}
+		if err := am.err(); err != nil {
+			if s.idle() {
+				slog.Debug("tts session ended by server while idle", "error", err)
+				return
+			}
+			s.send(AudioChunk{Error: err})
 			return
 		}
 
 		if am.IsFinal {
-			s.audio <- AudioChunk{Done: true}
+			s.ack(conn, am.Alignment)
+			s.span.AddEvent("final")
+			s.dec.stop(nil)
+			s.send(AudioChunk{Done: true, Utterance: s.speaking()})
 			return
 		}
 
-		if am.Audio == "" {
-			continue
+		// A response's audio is passed on before its text is acked, so its
+		// Done never overtakes it.
+		if utterance := s.speaking(); utterance != current {
+			current = utterance
+			s.spoken = 0
+			s.sawAudio = false
+		}
+		if !s.deliver(am, current) {
+			return
 		}
+		for _, utterance := range s.ack(conn, am.Alignment) {
+			if !s.send(AudioChunk{Done: true, Utterance: utterance}) {
+				return
+			}
+		}
+	}
+}
 
-		data, err := base64.StdEncoding.DecodeString(am.Audio)
-		if err != nil {
-			s.audio <- AudioChunk{Error: fmt.Errorf("ws base64 decode: %w", err)}
+// deliver passes on the audio in a message, tagged with the response it
+// belongs to. It is false if readLoop should end.
+func (s *Session) deliver(am wsAudioMessage, utterance int) bool {
+	if am.Audio == "" {
+		return true
+	}
+	data, err := base64.StdEncoding.DecodeString(am.Audio)
+	if err != nil {
+		s.send(AudioChunk{Error: fmt.Errorf("ws base64 decode: %w", err)})
+		return false
+	}
+	if len(data) == 0 {
+		return true
+	}
+
+	if !s.sawAudio {
+		s.sawAudio = true
+		s.noteFirstAudio()
+	}
+	var align *Alignment
+	if s.cfg.Alignment && am.Alignment != nil {
+		align = s.align(am.Alignment, data)
+	}
+	if s.dec.active() {
+		if align != nil && !s.send(AudioChunk{Alignment: align, Utterance: utterance}) {
+			return false
+		}
+		// A failed write means the decoder gave up and reported why.
+		return s.dec.write(data) == nil
+	}
+	return s.send(AudioChunk{Data: data, Alignment: align, Utterance: utterance})
+}
+
+// send passes chunk on to Audio, or drops it once Close has started, so
+// readLoop can go on to the server's close frame. It is false once the
+// session has been torn down.
+func (s *Session) send(chunk AudioChunk) bool {
+	select {
+	case s.audio <- chunk:
+		return true
+	case <-s.closing:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// align times a message's characters from the start of the response and
+// moves spoken past its audio. That is exact for PCM; for compressed
+// formats, whose duration isn't known before decoding, the end of the last
+// character stands in for it.
+func (s *Session) align(a *wsAlignment, data []byte) *Alignment {
+	out := &Alignment{
+		Chars:    a.Chars,
+		Start:    make([]time.Duration, len(a.Chars)),
+		Duration: make([]time.Duration, len(a.Chars)),
+	}
+	var end time.Duration
+	for i := range a.Chars {
+		if i < len(a.StartsMs) {
+			out.Start[i] = s.spoken + time.Duration(a.StartsMs[i])*time.Millisecond
+		}
+		if i < len(a.DurationsMs) {
+			out.Duration[i] = time.Duration(a.DurationsMs[i]) * time.Millisecond
+		}
+		end = max(end, out.Start[i]+out.Duration[i]-s.spoken)
+	}
+	if s.dec.active() {
+		s.spoken += end
+	} else {
+		s.spoken += time.Duration(len(data)/2) * time.Second / time.Duration(s.dec.rate)
+	}
+	return out
+}
+
+// keepAlive sends a lone space whenever KeepAlive passes without any text
+// being sent, so it stays quiet while a response streams, and stops after
+// Flush, once the server is only finishing up.
+func (s *Session) keepAlive(ctx context.Context) {
+	for {
+		s.connMu.Lock()
+		if s.flushed {
+			s.connMu.Unlock()
 			return
 		}
+		wait := s.cfg.KeepAlive - time.Since(s.lastSend)
+		if wait <= 0 {
+			err := s.conn.WriteJSON(wsTextMessage{Text: " "})
+			s.lastSend = time.Now()
+			wait = s.cfg.KeepAlive
+			if err != nil {
+				// readLoop sees the connection fail too and handles it.
+				s.connMu.Unlock()
+				return
+			}
+		}
+		s.connMu.Unlock()
 
-		if len(data) > 0 {
-			s.audio <- AudioChunk{Data: data}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.done:
+			timer.Stop()
+			return
 		}
 	}
 }
 
+// idle reports whether no text has been sent yet or, on a session kept
+// across responses, whether it is between them.
+func (s *Session) idle() bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.reused && !s.flushed {
+		return !s.open && len(s.ends) == 0
+	}
+	return !s.sentText && !s.flushed
+}
+
 func (s *Session) SendText(text string) error {
-	return s.conn.WriteJSON(wsTextMessage{
+	s.connMu.Lock()
+	s.lastSend = time.Now()
+	s.chars += countUsage(text, s.firstText.IsZero())
+	if s.firstText.IsZero() {
+		s.firstText = time.Now()
+		s.span.AddEvent("first_text")
+	}
+	s.sentText = true
+	s.unacked = append(s.unacked, []rune(text)...)
+	s.sent += s.counter.count(text, s.cfg.EnableSSML)
+	conn := s.conn
+	err := conn.WriteJSON(wsTextMessage{
 		Text:                 text,
 		TryTriggerGeneration: true,
 	})
+	s.connMu.Unlock()
+	if err != nil {
+		return s.reconnect(conn, fmt.Errorf("ws write: %w", err))
+	}
+	return nil
+}
+
+// Characters returns how many characters the session was sent, which is
+// what ElevenLabs bills. A reconnect's replay isn't counted again.
+func (s *Session) Characters() int {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.chars
+}
+
+// noteFirstAudio records the time to first audio, once per session.
+func (s *Session) noteFirstAudio() {
+	s.connMu.Lock()
+	s.firstAudio = time.Now()
+	latency := s.firstAudio.Sub(s.firstText)
+	sent := !s.firstText.IsZero()
+	s.connMu.Unlock()
+
+	s.span.AddEvent("first_audio")
+	if sent && firstAudioHisto != nil {
+		firstAudioHisto.Record(s.ctx, latency.Seconds(), metric.WithAttributes(s.cfg.attrs()...))
+	}
+}
+
+// FirstAudioLatency is the time from the first SendText to the first audio
+// received, which is ElevenLabs' share of a turn's latency. It is 0 until
+// both have happened, and on a reused session is for the current response.
+func (s *Session) FirstAudioLatency() time.Duration {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.firstText.IsZero() || s.firstAudio.IsZero() {
+		return 0
+	}
+	return s.firstAudio.Sub(s.firstText)
+}
+
+// BeginUtterance starts another response on a session kept across them,
+// instead of dialing one per response, and returns its number, which tags
+// its audio. End it with EndUtterance. Only PCM can be split between
+// responses, so compressed formats can't be reused.
+func (s *Session) BeginUtterance() (int, error) {
+	if s.dec.active() {
+		return 0, fmt.Errorf("reusing a session needs a pcm output format, not %s", s.cfg.OutputFormat)
+	}
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	s.reused = true
+	s.utterance++
+	s.open = true
+	s.firstText, s.firstAudio = time.Time{}, time.Time{}
+	return s.utterance, nil
+}
+
+// EndUtterance has the server speak the rest of the current response,
+// as Flush does, but keeps the session for the next. The response's audio
+// ends with a Done chunk once its alignment has covered all its text.
+func (s *Session) EndUtterance() error {
+	s.connMu.Lock()
+	s.ends = append(s.ends, utteranceEnd{utterance: s.utterance, at: s.sent})
+	s.open = false
+	// All of it may have been spoken already, and no more audio will come
+	// to say so.
+	spoken := s.spokenLocked()
+	s.lastSend = time.Now()
+	conn := s.conn
+	err := conn.WriteJSON(wsTextMessage{Text: " ", Flush: true})
+	s.connMu.Unlock()
+	if err != nil {
+		err = s.reconnect(conn, fmt.Errorf("ws write: %w", err))
+	}
+
+	for _, utterance := range spoken {
+		s.send(AudioChunk{Done: true, Utterance: utterance})
+	}
+	return err
 }
 
 func (s *Session) Flush() error {
-	return s.conn.WriteJSON(wsTextMessage{Text: ""})
+	s.connMu.Lock()
+	s.flushed = true
+	conn := s.conn
+	err := conn.WriteJSON(wsTextMessage{Text: ""})
+	s.connMu.Unlock()
+	if err != nil {
+		return s.reconnect(conn, fmt.Errorf("ws write: %w", err))
+	}
+	return nil
+}
+
+// FlushAndWait flushes and waits for the session to end, which it does
+// after the response's final message. If that hasn't come within
+// FlushTimeout, the session is closed, so Audio ends with what arrived,
+// and ErrFlushTimeout is returned.
+func (s *Session) FlushAndWait(ctx context.Context) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	var timeout <-chan time.Time
+	if s.cfg.FlushTimeout > 0 {
+		timer := time.NewTimer(s.cfg.FlushTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		s.Close()
+		return fmt.Errorf("%w after %s", ErrFlushTimeout, s.cfg.FlushTimeout)
+	}
 }
 
 func (s *Session) Audio() <-chan AudioChunk {
 	return s.audio
 }
 
+// Close ends the session with a close handshake, so the server sees a
+// normal closure rather than a dropped connection. It waits up to
+// closeTimeout for the server to answer, or for the final message of a
+// response still playing out, then tears the connection down either way
+// and returns once readLoop has ended. The error says why the shutdown
+// wasn't clean; it is the same on every call.
 func (s *Session) Close() error {
 	s.once.Do(func() {
+		close(s.closing)
+		s.connMu.Lock()
+		err := s.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(closeTimeout))
+		s.connMu.Unlock()
+
+		select {
+		case <-s.done:
+			// It had already ended, or ended on the close frame.
+			err = nil
+		default:
+			if err != nil {
+				err = fmt.Errorf("sending close: %w", err)
+				break
+			}
+			timer := time.NewTimer(closeTimeout)
+			select {
+			case <-s.done:
+			case <-timer.C:
+				err = ErrCloseTimeout
+			}
+			timer.Stop()
+		}
+
 		s.cancel()
+		s.connMu.Lock()
 		s.conn.Close()
+		s.connMu.Unlock()
+		<-s.done
+		s.closeErr = err
 	})
-	return nil
+	return s.closeErr
+}
+
+func (s *Session) isClosing() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
 }
 
 func (s *Session) Wait() {
 	<-s.done
 }
+
+// Done is closed once the session has ended, whether its response
+// finished, it was closed, or the server dropped it.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
@@ -0,0 +1,422 @@
+package tts
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// wsServer accepts websockets and hands each one to the test as it
+// connects.
+type wsServer struct {
+	*httptest.Server
+	conns chan *serverConn
+}
+
+// serverConn is the server's side of one connection: every message the
+// client sent on it, and closed once it is gone. The test answers on conn,
+// which only it writes to.
+type serverConn struct {
+	conn   *websocket.Conn
+	query  url.Values
+	msgs   chan []byte
+	closed chan struct{}
+}
+
+func newWSServer(t *testing.T) *wsServer {
+	t.Helper()
+	srv := &wsServer{conns: make(chan *serverConn, 8)}
+	var upgrader websocket.Upgrader
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sc := &serverConn{conn: conn, query: r.URL.Query(), msgs: make(chan []byte, 64), closed: make(chan struct{})}
+		defer close(sc.closed)
+		srv.conns <- sc
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			sc.msgs <- msg
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// useBaseURL points sessions dialed by the test at url.
+func useBaseURL(t *testing.T, url string) {
+	t.Helper()
+	prev := defaultBaseURL
+	defaultBaseURL = "ws" + strings.TrimPrefix(url, "http")
+	t.Cleanup(func() { defaultBaseURL = prev })
+}
+
+// testConfig is a valid config for sessions dialed to srv.
+func testConfig(t *testing.T, srv *wsServer) SessionConfig {
+	t.Helper()
+	useBaseURL(t, srv.URL)
+	return SessionConfig{APIKey: "key", VoiceID: "voice", ModelID: "model", OutputFormat: FormatPCM16000}
+}
+
+// accept returns the next connection made to the server.
+func (srv *wsServer) accept(t *testing.T) *serverConn {
+	t.Helper()
+	select {
+	case sc := <-srv.conns:
+		return sc
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection to the server")
+		return nil
+	}
+}
+
+// next returns the next message's text.
+func (sc *serverConn) next(t *testing.T) string {
+	t.Helper()
+	var msg wsTextMessage
+	sc.nextJSON(t, &msg)
+	return msg.Text
+}
+
+// nextJSON decodes the next message into v.
+func (sc *serverConn) nextJSON(t *testing.T, v any) {
+	t.Helper()
+	select {
+	case msg := <-sc.msgs:
+		if err := json.Unmarshal(msg, v); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message from the session")
+	}
+}
+
+// send writes v to the client as JSON.
+func (sc *serverConn) send(t *testing.T, v any) {
+	t.Helper()
+	if err := sc.conn.WriteJSON(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitClosed fails the test if the client doesn't close the connection.
+func (sc *serverConn) waitClosed(t *testing.T) {
+	t.Helper()
+	select {
+	case <-sc.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the connection was left open")
+	}
+}
+
+// audioMessage is a message of audio speaking chars, the way the server
+// aligns it.
+func audioMessage(pcm []byte, chars string) map[string]any {
+	msg := map[string]any{"audio": base64.StdEncoding.EncodeToString(pcm)}
+	if chars != "" {
+		var a wsAlignment
+		for i, r := range []rune(chars) {
+			a.Chars = append(a.Chars, string(r))
+			a.StartsMs = append(a.StartsMs, i*10)
+			a.DurationsMs = append(a.DurationsMs, 10)
+		}
+		msg["alignment"] = a
+	}
+	return msg
+}
+
+// collect reads chunks off audio until one is Done or has an Error, or the
+// channel closes.
+func collect(t *testing.T, audio <-chan AudioChunk) (pcm []byte, last AudioChunk) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case chunk, ok := <-audio:
+			if !ok {
+				return pcm, AudioChunk{}
+			}
+			pcm = append(pcm, chunk.Data...)
+			if chunk.Done || chunk.Error != nil {
+				return pcm, chunk
+			}
+		case <-timeout:
+			t.Fatal("audio didn't end")
+			return nil, AudioChunk{}
+		}
+	}
+}
+
+// testSession returns a session connected to srv without its read loop,
+// so the test drives reconnects itself.
+func testSession(t *testing.T, srv *wsServer, cfg SessionConfig) (*Session, *serverConn) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s := &Session{
+		cfg:     cfg,
+		url:     "ws" + strings.TrimPrefix(srv.URL, "http"),
+		ctx:     ctx,
+		cancel:  cancel,
+		closing: make(chan struct{}),
+		span:    trace.SpanFromContext(ctx),
+	}
+	conn, err := s.dial(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.conn = conn
+	t.Cleanup(func() { s.conn.Close() })
+	sc := srv.accept(t)
+	if text := sc.next(t); text != " " {
+		t.Fatalf("first message %q, want the init message", text)
+	}
+	return s, sc
+}
+
+func TestSessionReconnect(t *testing.T) {
+	const backoff = 200 * time.Millisecond
+	srv := newWSServer(t)
+	s, first := testSession(t, srv, SessionConfig{MaxReconnects: 1, ReconnectBackoff: backoff})
+	s.unacked = []rune("hello")
+	old := s.conn
+
+	done := make(chan error, 1)
+	go func() { done <- s.reconnect(old, errors.New("read failed")) }()
+	<-first.closed
+
+	// The lock is free while it backs off, so text keeps being taken.
+	start := time.Now()
+	s.connMu.Lock()
+	s.unacked = append(s.unacked, []rune(" world")...)
+	s.connMu.Unlock()
+	if took := time.Since(start); took > backoff/2 {
+		t.Errorf("waited %s for connMu during the backoff", took)
+	}
+
+	// A write that saw the same connection fail waits for this reconnect
+	// instead of dialing again.
+	second := make(chan error, 1)
+	go func() { second <- s.reconnect(old, errors.New("write failed")) }()
+
+	if err := <-done; err != nil {
+		t.Fatalf("reconnect = %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("the second reconnect = %v, want the first's outcome", err)
+	}
+	if s.conn == old {
+		t.Fatal("the connection wasn't replaced")
+	}
+	sc := srv.accept(t)
+	if text := sc.next(t); text != " " {
+		t.Errorf("first message %q, want the init message", text)
+	}
+	if text := sc.next(t); text != "hello world" {
+		t.Errorf("replayed %q, want the text sent during the backoff too", text)
+	}
+	select {
+	case <-srv.conns:
+		t.Error("the session dialed twice")
+	default:
+	}
+}
+
+func TestSessionReconnectReplaced(t *testing.T) {
+	const backoff = 100 * time.Millisecond
+	srv := newWSServer(t)
+	s, _ := testSession(t, srv, SessionConfig{MaxReconnects: 1, ReconnectBackoff: backoff})
+	old := s.conn
+
+	done := make(chan error, 1)
+	go func() { done <- s.redial(old, errors.New("read failed")) }()
+
+	// Replace the connection while it backs off.
+	other, err := s.dial(s.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.accept(t)
+	s.connMu.Lock()
+	s.conn = other
+	s.connMu.Unlock()
+
+	if err := <-done; err != nil {
+		t.Errorf("redial = %v, want nil as conn was already replaced", err)
+	}
+	if s.conn != other {
+		t.Error("the replacement was swapped out")
+	}
+	// Its own connection is closed again.
+	sc := srv.accept(t)
+	select {
+	case <-sc.closed:
+	case <-time.After(5 * time.Second):
+		t.Error("the unneeded connection was left open")
+	}
+}
+
+func TestSessionReconnectGivesUp(t *testing.T) {
+	srv := newWSServer(t)
+	s, _ := testSession(t, srv, SessionConfig{MaxReconnects: 0})
+	cause := errors.New("read failed")
+	if err := s.reconnect(s.conn, cause); err != cause {
+		t.Errorf("reconnect = %v, want the cause with no reconnects allowed", err)
+	}
+
+	s, _ = testSession(t, srv, SessionConfig{MaxReconnects: 3, ReconnectBackoff: time.Hour})
+	close(s.closing)
+	start := time.Now()
+	if err := s.reconnect(s.conn, cause); err != cause {
+		t.Errorf("reconnect while closing = %v, want the cause", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("reconnect while closing took %s, want it to give up at once", took)
+	}
+}
+
+func TestInitMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  SessionConfig
+		want string
+	}{
+		{
+			name: "defaults",
+			cfg:  SessionConfig{Stability: 0.5, Similarity: 0.75, Speed: 1},
+			want: `{"text":" ","voice_settings":{"stability":0.5,"similarity_boost":0.75,"speed":1}}`,
+		},
+		{
+			name: "fully populated",
+			cfg: SessionConfig{
+				Stability:           0.4,
+				Similarity:          0.8,
+				Speed:               1.1,
+				ChunkLengthSchedule: []int{120, 160, 250, 290},
+				Dictionaries: []DictionaryLocator{
+					{ID: "dict1"},
+					{ID: "dict2", VersionID: "v2"},
+				},
+			},
+			want: `{"text":" ",` +
+				`"voice_settings":{"stability":0.4,"similarity_boost":0.8,"speed":1.1},` +
+				`"generation_config":{"chunk_length_schedule":[120,160,250,290]},` +
+				`"pronunciation_dictionary_locators":[` +
+				`{"pronunciation_dictionary_id":"dict1"},` +
+				`{"pronunciation_dictionary_id":"dict2","version_id":"v2"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.cfg.initMessage())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("init message\n got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamURL(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  SessionConfig
+		want map[string]string
+	}{
+		{"plain", SessionConfig{}, nil},
+		{"auto mode", SessionConfig{AutoMode: true}, map[string]string{"auto_mode": "true"}},
+		{"alignment", SessionConfig{Alignment: true}, map[string]string{"sync_alignment": "true"}},
+		{"ssml and language", SessionConfig{EnableSSML: true, LanguageCode: "sv"},
+			map[string]string{"enable_ssml_parsing": "true", "language_code": "sv"}},
+		{"seed", SessionConfig{Seed: 42}, map[string]string{"seed": "42"}},
+	}
+	optional := []string{"auto_mode", "sync_alignment", "enable_ssml_parsing", "language_code", "seed"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.VoiceID, tt.cfg.ModelID, tt.cfg.OutputFormat = "voice", "model", FormatPCM24000
+			u, err := url.Parse(streamURL("stream-input", tt.cfg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Path != "/v1/text-to-speech/voice/stream-input" {
+				t.Errorf("path %s, want the voice's stream-input", u.Path)
+			}
+			q := u.Query()
+			if q.Get("model_id") != "model" || q.Get("output_format") != "pcm_24000" {
+				t.Errorf("query %s, want the model and output format", u.RawQuery)
+			}
+			for _, key := range optional {
+				if got := q.Get(key); got != tt.want[key] {
+					t.Errorf("%s = %q, want %q", key, got, tt.want[key])
+				}
+			}
+		})
+	}
+}
+
+func TestVoiceSettings(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name string
+		cfg  SessionConfig
+		want string
+	}{
+		{"unset", SessionConfig{}, `{"stability":0,"similarity_boost":0,"speed":0}`},
+		{"style", SessionConfig{Style: 0.3}, `{"stability":0,"similarity_boost":0,"speed":0,"style":0.3}`},
+		{"speaker boost on", SessionConfig{SpeakerBoost: &on},
+			`{"stability":0,"similarity_boost":0,"speed":0,"use_speaker_boost":true}`},
+		// Turned off explicitly is sent, unlike left unset.
+		{"speaker boost off", SessionConfig{SpeakerBoost: &off},
+			`{"stability":0,"similarity_boost":0,"speed":0,"use_speaker_boost":false}`},
+		{"all", SessionConfig{Stability: 0.5, Similarity: 0.75, Speed: 1, Style: 1, SpeakerBoost: &on},
+			`{"stability":0.5,"similarity_boost":0.75,"speed":1,"style":1,"use_speaker_boost":true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.cfg.voiceSettings())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("voice settings\n got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupportsPhonemes(t *testing.T) {
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"eleven_flash_v2", true},
+		{"eleven_turbo_v2", true},
+		{"eleven_monolingual_v1", true},
+		// The others read the tags out or ignore them.
+		{"eleven_flash_v2_5", false},
+		{"eleven_multilingual_v2", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := SupportsPhonemes(tt.model); got != tt.want {
+			t.Errorf("SupportsPhonemes(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package tts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The ElevenLabs failures worth telling apart from a network problem. An
+// APIError wraps one of them when it is that kind of failure.
+var (
+	ErrUnauthorized  = errors.New("elevenlabs rejected the api key")
+	ErrQuotaExceeded = errors.New("elevenlabs quota exceeded")
+	ErrVoiceNotFound = errors.New("elevenlabs voice not found")
+)
+
+// APIError is an error reported by ElevenLabs, either in the response to a
+// request or websocket upgrade, or as a message on the socket.
+type APIError struct {
+	// StatusCode is the HTTP status, or 0 for an error sent on the socket.
+	StatusCode int
+	// Status is ElevenLabs' own code for the error, e.g. invalid_api_key,
+	// if it gave one.
+	Status  string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString("elevenlabs")
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, ": status %d", e.StatusCode)
+	}
+	if e.Status != "" {
+		fmt.Fprintf(&b, ": %s", e.Status)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	return b.String()
+}
+
+// Unwrap returns the kind of failure, or nil if it is none of the known
+// ones.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.Status == "quota_exceeded":
+		return ErrQuotaExceeded
+	case e.Status == "voice_not_found":
+		return ErrVoiceNotFound
+	case e.Status == "invalid_api_key", e.Status == "needs_authorization", e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusNotFound && strings.Contains(strings.ToLower(e.Message), "voice"):
+		return ErrVoiceNotFound
+	}
+	return nil
+}
+
+// retryable reports whether dialing again may get past err: a network
+// failure, or ElevenLabs being overloaded, but not a refusal that will be
+// the same next time.
+func retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	if apiErr.Unwrap() != nil {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+}
+
+// parseAPIError reads the body of a failed request. ElevenLabs puts the
+// error in detail, as an object with a status and message or as a plain
+// string; anything else is kept as the message.
+func parseAPIError(statusCode int, body []byte) *APIError {
+	e := &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+	var resp struct {
+		Detail json.RawMessage `json:"detail"`
+	}
+	if json.Unmarshal(body, &resp) != nil || resp.Detail == nil {
+		return e
+	}
+	var detail struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	var message string
+	switch {
+	case json.Unmarshal(resp.Detail, &detail) == nil && (detail.Status != "" || detail.Message != ""):
+		e.Status = detail.Status
+		e.Message = detail.Message
+	case json.Unmarshal(resp.Detail, &message) == nil:
+		e.Message = message
+	}
+	return e
+}
@@ -0,0 +1,202 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantStatus  string
+		wantMessage string
+		want        error
+	}{
+		{
+			name:        "detail object",
+			status:      http.StatusUnauthorized,
+			body:        `{"detail":{"status":"invalid_api_key","message":"Invalid API key"}}`,
+			wantStatus:  "invalid_api_key",
+			wantMessage: "Invalid API key",
+			want:        ErrUnauthorized,
+		},
+		{
+			name:        "quota",
+			status:      http.StatusUnauthorized,
+			body:        `{"detail":{"status":"quota_exceeded","message":"This request exceeds your quota."}}`,
+			wantStatus:  "quota_exceeded",
+			wantMessage: "This request exceeds your quota.",
+			want:        ErrQuotaExceeded,
+		},
+		{
+			name:        "voice by status",
+			status:      http.StatusNotFound,
+			body:        `{"detail":{"status":"voice_not_found","message":"A voice with that id was not found."}}`,
+			wantStatus:  "voice_not_found",
+			wantMessage: "A voice with that id was not found.",
+			want:        ErrVoiceNotFound,
+		},
+		{
+			name:        "voice by message",
+			status:      http.StatusNotFound,
+			body:        `{"detail":"Voice not found"}`,
+			wantMessage: "Voice not found",
+			want:        ErrVoiceNotFound,
+		},
+		{
+			name:        "unauthorized without detail",
+			status:      http.StatusUnauthorized,
+			body:        "Unauthorized",
+			wantMessage: "Unauthorized",
+			want:        ErrUnauthorized,
+		},
+		{
+			name:        "unknown",
+			status:      http.StatusInternalServerError,
+			body:        `{"detail":{"status":"internal_error","message":"Try again"}}`,
+			wantStatus:  "internal_error",
+			wantMessage: "Try again",
+		},
+		{
+			name:        "other json",
+			status:      http.StatusBadGateway,
+			body:        ` {"error":"bad gateway"} `,
+			wantMessage: `{"error":"bad gateway"}`,
+		},
+	}
+	known := []error{ErrUnauthorized, ErrQuotaExceeded, ErrVoiceNotFound}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := parseAPIError(tt.status, []byte(tt.body))
+			if e.StatusCode != tt.status || e.Status != tt.wantStatus || e.Message != tt.wantMessage {
+				t.Errorf("parseAPIError = %+v", e)
+			}
+			for _, k := range known {
+				if got := errors.Is(e, k); got != (k == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v", e, k, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWSErrorMessage(t *testing.T) {
+	tests := []struct {
+		msg  wsError
+		want error
+	}{
+		{wsError{Error: "quota_exceeded", Message: "out of characters"}, ErrQuotaExceeded},
+		{wsError{Error: "invalid_api_key"}, ErrUnauthorized},
+		{wsError{Error: "voice_not_found"}, ErrVoiceNotFound},
+		{wsError{Message: "input timeout exceeded"}, nil},
+	}
+	for _, tt := range tests {
+		err := tt.msg.err()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != 0 {
+			t.Errorf("%+v: err = %v, want an APIError without a status code", tt.msg, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) || tt.want == nil && apiErr.Unwrap() != nil {
+			t.Errorf("%+v: err = %v, want %v", tt.msg, err, tt.want)
+		}
+	}
+	if err := (wsError{}).err(); err != nil {
+		t.Errorf("audio message reported %v", err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network", errors.New("dial tcp: connection refused"), true},
+		{"overloaded", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", &APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"bad key", &APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"quota on a 5xx", &APIError{StatusCode: http.StatusInternalServerError, Status: "quota_exceeded"}, false},
+		{"bad request", &APIError{StatusCode: http.StatusBadRequest}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// refuseUpgrade answers every websocket upgrade with status and body, and
+// counts the attempts.
+func refuseUpgrade(t *testing.T, status int, body string) *atomic.Int32 {
+	t.Helper()
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	useBaseURL(t, srv.URL)
+	return &attempts
+}
+
+func TestNewSessionRefused(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		want     error
+		attempts int32
+	}{
+		{"bad key", http.StatusUnauthorized, `{"detail":{"status":"invalid_api_key","message":"Invalid API key"}}`, ErrUnauthorized, 1},
+		{"quota", http.StatusUnauthorized, `{"detail":{"status":"quota_exceeded","message":"Quota exceeded"}}`, ErrQuotaExceeded, 1},
+		{"voice", http.StatusNotFound, `{"detail":{"status":"voice_not_found","message":"Voice not found"}}`, ErrVoiceNotFound, 1},
+		// Worth retrying, so all three attempts are made.
+		{"overloaded", http.StatusServiceUnavailable, `{"detail":"busy"}`, nil, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := refuseUpgrade(t, tt.status, tt.body)
+			cfg := SessionConfig{APIKey: "key", VoiceID: "voice", ModelID: "model", OutputFormat: FormatPCM16000, DialRetries: 2}
+			_, err := NewSession(context.Background(), cfg)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Fatalf("NewSession = %v, want an APIError with status %d", err, tt.status)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("NewSession = %v, want %v", err, tt.want)
+			}
+			if n := attempts.Load(); n != tt.attempts {
+				t.Errorf("dialed %d times, want %d", n, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestSessionErrorMessage(t *testing.T) {
+	srv := newWSServer(t)
+	s, err := NewSession(context.Background(), testConfig(t, srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	sc := acceptSession(t, srv)
+	if err := s.SendText("Hej då."); err != nil {
+		t.Fatal(err)
+	}
+	sc.next(t)
+
+	sc.send(t, map[string]any{"error": "quota_exceeded", "message": "This request exceeds your quota."})
+	if _, last := collect(t, s.Audio()); !errors.Is(last.Error, ErrQuotaExceeded) {
+		t.Errorf("audio ended with %v, want ErrQuotaExceeded", last.Error)
+	}
+}
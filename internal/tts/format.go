@@ -0,0 +1,381 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+)
+
+// OutputFormat is an ElevenLabs output_format: the codec and sample rate,
+// followed by a bitrate for the compressed codecs, e.g. pcm_24000 or
+// mp3_22050_32.
+type OutputFormat string
+
+// The formats ElevenLabs offers that can be decoded for playback; its
+// ulaw and alaw telephony formats can't.
+const (
+	FormatPCM8000  OutputFormat = "pcm_8000"
+	FormatPCM16000 OutputFormat = "pcm_16000"
+	FormatPCM22050 OutputFormat = "pcm_22050"
+	FormatPCM24000 OutputFormat = "pcm_24000"
+	FormatPCM32000 OutputFormat = "pcm_32000"
+	FormatPCM44100 OutputFormat = "pcm_44100"
+	FormatPCM48000 OutputFormat = "pcm_48000"
+
+	FormatMP3_22050_32  OutputFormat = "mp3_22050_32"
+	FormatMP3_24000_48  OutputFormat = "mp3_24000_48"
+	FormatMP3_44100_32  OutputFormat = "mp3_44100_32"
+	FormatMP3_44100_64  OutputFormat = "mp3_44100_64"
+	FormatMP3_44100_96  OutputFormat = "mp3_44100_96"
+	FormatMP3_44100_128 OutputFormat = "mp3_44100_128"
+	FormatMP3_44100_192 OutputFormat = "mp3_44100_192"
+
+	FormatOpus48000_32  OutputFormat = "opus_48000_32"
+	FormatOpus48000_64  OutputFormat = "opus_48000_64"
+	FormatOpus48000_96  OutputFormat = "opus_48000_96"
+	FormatOpus48000_128 OutputFormat = "opus_48000_128"
+	FormatOpus48000_192 OutputFormat = "opus_48000_192"
+)
+
+var outputFormats = []OutputFormat{
+	FormatPCM8000, FormatPCM16000, FormatPCM22050, FormatPCM24000, FormatPCM32000, FormatPCM44100, FormatPCM48000,
+	FormatMP3_22050_32, FormatMP3_24000_48, FormatMP3_44100_32, FormatMP3_44100_64, FormatMP3_44100_96, FormatMP3_44100_128, FormatMP3_44100_192,
+	FormatOpus48000_32, FormatOpus48000_64, FormatOpus48000_96, FormatOpus48000_128, FormatOpus48000_192,
+}
+
+// ParseOutputFormat returns s as an OutputFormat if it is one of the
+// supported ones.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	if slices.Contains(outputFormats, OutputFormat(s)) {
+		return OutputFormat(s), nil
+	}
+	names := make([]string, len(outputFormats))
+	for i, f := range outputFormats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unsupported output format %q, expected one of %s", s, strings.Join(names, ", "))
+}
+
+// Codec returns pcm, mp3 or opus.
+func (f OutputFormat) Codec() string {
+	codec, _, _ := strings.Cut(string(f), "_")
+	return codec
+}
+
+// SampleRate returns the rate of the PCM that f decodes to, or 0 if f is
+// malformed.
+func (f OutputFormat) SampleRate() int {
+	parts := strings.Split(string(f), "_")
+	if len(parts) < 2 {
+		return 0
+	}
+	rate, _ := strconv.Atoi(parts[1])
+	return rate
+}
+
+// pcmReader decodes a compressed stream into mono int16 samples.
+type pcmReader interface {
+	Read(dst []int16) (int, error)
+	SampleRate() int
+	Close() error
+}
+
+type pcmDecoder func(r io.Reader) (pcmReader, error)
+
+// decoder returns what turns f into PCM, or nil for raw PCM.
+func (f OutputFormat) decoder() (pcmDecoder, error) {
+	switch f.Codec() {
+	case "mp3":
+		return newMP3Reader, nil
+	case "opus":
+		return opusDecoder()
+	default:
+		return nil, nil
+	}
+}
+
+// Validate checks the config before anything connects, in particular that
+// audio in the output format can be decoded for playback.
+func (c SessionConfig) Validate() error {
+	if _, err := ParseOutputFormat(string(c.OutputFormat)); err != nil {
+		return err
+	}
+	if _, err := c.OutputFormat.decoder(); err != nil {
+		return fmt.Errorf("output format %s: %w", c.OutputFormat, err)
+	}
+	for _, n := range c.ChunkLengthSchedule {
+		if n < 50 || n > 500 {
+			return fmt.Errorf("invalid chunk length schedule %v, each value must be 50 to 500", c.ChunkLengthSchedule)
+		}
+	}
+	for _, v := range []struct {
+		name  string
+		value float64
+	}{{"stability", c.Stability}, {"similarity", c.Similarity}, {"style", c.Style}, {"dial jitter", c.DialJitter}} {
+		if v.value < 0 || v.value > 1 {
+			return fmt.Errorf("invalid %s %g, expected 0 to 1", v.name, v.value)
+		}
+	}
+	if len(c.Dictionaries) > 3 {
+		return fmt.Errorf("%d pronunciation dictionaries, elevenlabs takes at most 3", len(c.Dictionaries))
+	}
+	return nil
+}
+
+// SampleRate returns the rate of the PCM a session sends on Audio, the one
+// in the output format, or 0 if the format is invalid.
+func (c SessionConfig) SampleRate() int {
+	if _, err := ParseOutputFormat(string(c.OutputFormat)); err != nil {
+		return 0
+	}
+	return c.OutputFormat.SampleRate()
+}
+
+// mp3Reader downmixes go-mp3's output, which is always 16-bit stereo.
+type mp3Reader struct {
+	dec *mp3.Decoder
+	buf []byte
+}
+
+func newMP3Reader(r io.Reader) (pcmReader, error) {
+	dec, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("opening mp3 stream: %w", err)
+	}
+	return &mp3Reader{dec: dec}, nil
+}
+
+func (m *mp3Reader) Read(dst []int16) (int, error) {
+	if cap(m.buf) < len(dst)*4 {
+		m.buf = make([]byte, len(dst)*4)
+	}
+	n, err := io.ReadFull(m.dec, m.buf[:len(dst)*4])
+	samples := n / 4
+	for i := range samples {
+		l := int16(uint16(m.buf[i*4]) | uint16(m.buf[i*4+1])<<8)
+		r := int16(uint16(m.buf[i*4+2]) | uint16(m.buf[i*4+3])<<8)
+		dst[i] = int16((int(l) + int(r)) / 2)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return samples, err
+}
+
+func (m *mp3Reader) SampleRate() int { return m.dec.SampleRate() }
+func (m *mp3Reader) Close() error    { return nil }
+
+// pcmConverter brings decoded samples to the rate the output format asks
+// for, as the little-endian bytes Playback takes. Decoders usually already
+// produce that rate; libopusfile always decodes at 48 kHz.
+type pcmConverter struct {
+	rs *audio.Resampler
+}
+
+func newPCMConverter(fromRate, toRate int) *pcmConverter {
+	c := &pcmConverter{}
+	if fromRate != toRate {
+		c.rs = audio.NewResampler(fromRate, toRate)
+	}
+	return c
+}
+
+// convert returns a new slice each time, since it is handed off in an
+// AudioChunk.
+func (c *pcmConverter) convert(samples []int16) []byte {
+	if c.rs != nil {
+		samples = c.rs.Process(nil, samples)
+	}
+	return audio.AppendInt16LE(nil, samples)
+}
+
+// streamDecoder turns the audio of one response, in the configured output
+// format, into PCM chunks on out. Compressed formats go through a pipe to a
+// decoder goroutine; raw PCM is passed on by the caller as is.
+type streamDecoder struct {
+	ctx    context.Context
+	decode pcmDecoder
+	rate   int
+	out    chan<- AudioChunk
+
+	encoded *io.PipeWriter
+	decoded chan struct{}
+}
+
+// newStreamDecoder takes a config that has been validated.
+func newStreamDecoder(ctx context.Context, cfg SessionConfig, out chan<- AudioChunk) *streamDecoder {
+	decode, _ := cfg.OutputFormat.decoder()
+	return &streamDecoder{ctx: ctx, decode: decode, rate: cfg.OutputFormat.SampleRate(), out: out}
+}
+
+// active reports whether audio has to go through write rather than
+// straight to out.
+func (d *streamDecoder) active() bool {
+	return d.decode != nil
+}
+
+// start starts a decoder for a new encoded stream. It does nothing for raw
+// PCM.
+func (d *streamDecoder) start() {
+	if d.decode == nil {
+		return
+	}
+	r, w := io.Pipe()
+	d.encoded = w
+	d.decoded = make(chan struct{})
+	go d.loop(r)
+}
+
+// write passes encoded audio to the decoder. An error means the decoder
+// gave up and reported why.
+func (d *streamDecoder) write(data []byte) error {
+	_, err := d.encoded.Write(data)
+	return err
+}
+
+// stop ends the encoded stream and waits for the decoder to send what is
+// left of it. It does nothing for raw PCM or once stopped.
+func (d *streamDecoder) stop(err error) {
+	if d.encoded == nil {
+		return
+	}
+	d.encoded.CloseWithError(err)
+	<-d.decoded
+}
+
+func (d *streamDecoder) loop(r *io.PipeReader) {
+	defer close(d.decoded)
+	fail := func(err error) {
+		r.CloseWithError(err)
+		select {
+		case d.out <- AudioChunk{Error: err}:
+		case <-d.ctx.Done():
+		}
+	}
+
+	dec, err := d.decode(r)
+	if errors.Is(err, io.EOF) || errors.Is(err, errStreamInterrupted) {
+		return
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer dec.Close()
+
+	conv := newPCMConverter(dec.SampleRate(), d.rate)
+	buf := make([]int16, dec.SampleRate()/20)
+	for {
+		n, err := dec.Read(buf)
+		if n > 0 {
+			select {
+			case d.out <- AudioChunk{Data: conv.convert(buf[:n])}:
+			case <-d.ctx.Done():
+				r.CloseWithError(d.ctx.Err())
+				return
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, errStreamInterrupted) {
+			return
+		}
+		if err != nil {
+			fail(fmt.Errorf("decoding audio: %w", err))
+			return
+		}
+	}
+}
+
+// decodedReader reads a compressed stream as PCM bytes at a given rate,
+// for providers that stream audio as an HTTP body.
+type decodedReader struct {
+	dec  pcmReader
+	conv *pcmConverter
+	src  io.Closer
+	buf  []int16
+	out  []byte
+	err  error
+}
+
+func newDecodedReader(decode pcmDecoder, rate int, src io.ReadCloser) (io.ReadCloser, error) {
+	dec, err := decode(src)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return &decodedReader{
+		dec:  dec,
+		conv: newPCMConverter(dec.SampleRate(), rate),
+		src:  src,
+		buf:  make([]int16, dec.SampleRate()/20),
+	}, nil
+}
+
+func (d *decodedReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		n, err := d.dec.Read(d.buf)
+		if n > 0 {
+			d.out = d.conv.convert(d.buf[:n])
+		}
+		if errors.Is(err, io.EOF) {
+			d.err = io.EOF
+		} else if err != nil {
+			d.err = fmt.Errorf("decoding audio: %w", err)
+		}
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+func (d *decodedReader) Close() error {
+	d.dec.Close()
+	return d.src.Close()
+}
+
+// rawPCMReader reads little-endian 16-bit mono PCM as a pcmReader, so a
+// raw stream at another rate can go through a decodedReader to be
+// resampled.
+type rawPCMReader struct {
+	r    io.Reader
+	rate int
+	buf  []byte
+}
+
+func (p *rawPCMReader) Read(dst []int16) (int, error) {
+	if cap(p.buf) < len(dst)*2 {
+		p.buf = make([]byte, len(dst)*2)
+	}
+	n, err := io.ReadFull(p.r, p.buf[:len(dst)*2])
+	samples := n / 2
+	for i := range samples {
+		dst[i] = int16(uint16(p.buf[i*2]) | uint16(p.buf[i*2+1])<<8)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return samples, err
+}
+
+func (p *rawPCMReader) SampleRate() int { return p.rate }
+func (p *rawPCMReader) Close() error    { return nil }
+
+// resampledPCM returns r, raw PCM at fromRate, as PCM at toRate.
+func resampledPCM(r io.ReadCloser, fromRate, toRate int) io.ReadCloser {
+	if fromRate == toRate {
+		return r
+	}
+	rc, _ := newDecodedReader(func(src io.Reader) (pcmReader, error) {
+		return &rawPCMReader{r: src, rate: fromRate}, nil
+	}, toRate, r)
+	return rc
+}
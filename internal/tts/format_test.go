@@ -0,0 +1,101 @@
+package tts
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SessionConfig
+		wantErr bool
+	}{
+		{"defaults", SessionConfig{}, false},
+		{"schedule in range", SessionConfig{ChunkLengthSchedule: []int{50, 120, 500}}, false},
+		{"schedule too short", SessionConfig{ChunkLengthSchedule: []int{120, 49}}, true},
+		{"schedule too long", SessionConfig{ChunkLengthSchedule: []int{501}}, true},
+		{"unsupported format", SessionConfig{OutputFormat: "ulaw_8000"}, true},
+		{"style in range", SessionConfig{Style: 1}, false},
+		{"negative style", SessionConfig{Style: -0.1}, true},
+		{"style over 1", SessionConfig{Style: 1.5}, true},
+		{"stability over 1", SessionConfig{Stability: 2}, true},
+		{"similarity over 1", SessionConfig{Similarity: 1.01}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cfg.OutputFormat == "" {
+				tt.cfg.OutputFormat = FormatPCM24000
+			}
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testdata/speech.mp3 is the start of go-mp3's public domain example, 22050
+// Hz stereo speech.
+func TestDecodeMP3(t *testing.T) {
+	data, err := os.ReadFile("testdata/speech.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := mp3.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stereo, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each stereo frame becomes the average of its channels.
+	var want []byte
+	for i := 0; i+4 <= len(stereo); i += 4 {
+		l := int16(uint16(stereo[i]) | uint16(stereo[i+1])<<8)
+		r := int16(uint16(stereo[i+2]) | uint16(stereo[i+3])<<8)
+		m := uint16(int16((int(l) + int(r)) / 2))
+		want = append(want, byte(m), byte(m>>8))
+	}
+
+	tests := []struct {
+		name string
+		rate int
+	}{
+		{"as is", 22050},
+		{"resampled", 24000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newDecodedReader(newMP3Reader, tt.rate, io.NopCloser(bytes.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.rate == 22050 {
+				if !bytes.Equal(got, want) {
+					t.Errorf("decoded %d bytes, want the %d bytes of the downmixed stream", len(got), len(want))
+				}
+				return
+			}
+			wantLen := len(want) / 2 * tt.rate / 22050
+			if n := len(got) / 2; n < wantLen-tt.rate/100 || n > wantLen+tt.rate/100 {
+				t.Errorf("resampled to %d samples, want about %d", n, wantLen)
+			}
+		})
+	}
+}
+
+func TestDecodeMP3NotMP3(t *testing.T) {
+	if _, err := newDecodedReader(newMP3Reader, 22050, io.NopCloser(bytes.NewReader([]byte("not audio")))); err == nil {
+		t.Error("decoded text as mp3, want an error")
+	}
+}
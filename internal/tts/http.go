@@ -0,0 +1,78 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type httpSynthesisRequest struct {
+	Text          string              `json:"text"`
+	ModelID       string              `json:"model_id"`
+	LanguageCode  string              `json:"language_code,omitempty"`
+	VoiceSettings *wsVoiceSettings    `json:"voice_settings"`
+	Dictionaries  []DictionaryLocator `json:"pronunciation_dictionary_locators,omitempty"`
+	Seed          uint32              `json:"seed,omitempty"`
+}
+
+// SynthesizeHTTP speaks text in one request to the plain text-to-speech
+// endpoint, for when the websocket can't be reached. It returns PCM at
+// cfg's sample rate, the same as a session's audio, once all of it has
+// arrived.
+func SynthesizeHTTP(ctx context.Context, cfg SessionConfig, text string) ([]byte, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	decode, _ := cfg.OutputFormat.decoder()
+
+	body, err := json.Marshal(httpSynthesisRequest{
+		Text:          text,
+		ModelID:       cfg.ModelID,
+		LanguageCode:  cfg.LanguageCode,
+		VoiceSettings: cfg.voiceSettings(),
+		Dictionaries:  cfg.Dictionaries,
+		Seed:          cfg.Seed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding tts request: %w", err)
+	}
+	query := url.Values{"output_format": {string(cfg.OutputFormat)}}
+	if cfg.EnableSSML {
+		query.Set("enable_ssml_parsing", "true")
+	}
+	endpoint := fmt.Sprintf("%s/text-to-speech/%s?%s", apiBaseURL, url.PathEscape(cfg.VoiceID), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating tts request: %w", err)
+	}
+	req.Header.Set("xi-api-key", cfg.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting tts: %w", err)
+	}
+	defer resp.Body.Close()
+	countUsage(text, true)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading tts response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("requesting tts: %w", parseAPIError(resp.StatusCode, data))
+	}
+	if decode == nil {
+		return data, nil
+	}
+	r, err := newDecodedReader(decode, cfg.OutputFormat.SampleRate(), io.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,437 @@
+package tts
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// multiInactivityTimeout is the longest ElevenLabs lets a multi-context
+// socket sit without text, in seconds, so one connection lasts across the
+// turns of a conversation.
+const multiInactivityTimeout = 180
+
+var (
+	errMultiSessionClosed = errors.New("multi-context tts session closed")
+	errSocketClosed       = errors.New("multi-context tts socket closed")
+)
+
+// MultiSession speaks any number of responses over one multi-stream-input
+// socket, each in a context of its own, instead of dialing a session per
+// response. The socket is dialed on first use and again after it drops or
+// the config changes; a context open on a socket that drops gets an error,
+// as there is nothing to replay it onto.
+type MultiSession struct {
+	config func() SessionConfig
+
+	mu     sync.Mutex
+	sock   *multiSocket
+	closed bool
+}
+
+// NewMultiSession connects for config's current value when the first
+// context is opened.
+func NewMultiSession(config func() SessionConfig) *MultiSession {
+	return &MultiSession{config: config}
+}
+
+// OpenContext starts a context with its own text and audio on the shared
+// socket. id has to be unique among the contexts open at the same time.
+// The language is set per socket, so a language other than the current
+// socket's redials it.
+func (m *MultiSession) OpenContext(ctx context.Context, id, language string) (*Context, error) {
+	cfg := m.config()
+	if language != "" {
+		cfg.LanguageCode = language
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, errMultiSessionClosed
+	}
+	if m.sock != nil && (m.sock.ended() || !m.sock.cfg.sameAs(cfg)) {
+		m.sock.retire()
+		m.sock = nil
+	}
+	if m.sock == nil {
+		sock, err := dialMultiSocket(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		m.sock = sock
+	}
+	return m.sock.open(ctx, id)
+}
+
+// Close closes the socket, ending the contexts still open on it.
+func (m *MultiSession) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	if m.sock != nil {
+		m.sock.close()
+		m.sock = nil
+	}
+	return nil
+}
+
+type wsContextMessage struct {
+	ContextID        string              `json:"context_id"`
+	Text             string              `json:"text,omitempty"`
+	VoiceSettings    *wsVoiceSettings    `json:"voice_settings,omitempty"`
+	GenerationConfig *wsGenerationConfig `json:"generation_config,omitempty"`
+	Dictionaries     []DictionaryLocator `json:"pronunciation_dictionary_locators,omitempty"`
+	Flush            bool                `json:"flush,omitempty"`
+	CloseContext     bool                `json:"close_context,omitempty"`
+}
+
+type wsCloseSocketMessage struct {
+	CloseSocket bool `json:"close_socket"`
+}
+
+type wsContextAudioMessage struct {
+	Audio     string `json:"audio"`
+	IsFinal   bool   `json:"isFinal"`
+	ContextID string `json:"contextId"`
+	wsError
+}
+
+// multiSocket is one connection and the contexts open on it. A retired
+// socket takes no new contexts and closes once the last one ends.
+type multiSocket struct {
+	cfg  SessionConfig
+	conn *websocket.Conn
+
+	// writeMu serializes writes, which come from every context.
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	contexts map[string]*Context
+	retired  bool
+	closing  bool
+	// dead is set once the read loop has ended the open contexts.
+	dead bool
+}
+
+func dialMultiSocket(ctx context.Context, cfg SessionConfig) (*multiSocket, error) {
+	url := fmt.Sprintf("%s&inactivity_timeout=%d", streamURL("multi-stream-input", cfg), multiInactivityTimeout)
+	conn, err := dialWS(ctx, url, cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &multiSocket{
+		cfg:      cfg,
+		conn:     conn,
+		contexts: make(map[string]*Context),
+	}
+	go s.readLoop()
+	return s, nil
+}
+
+func (s *multiSocket) write(msg any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.conn.WriteJSON(msg); err != nil {
+		return fmt.Errorf("ws write: %w", err)
+	}
+	return nil
+}
+
+// ended reports whether the read loop has stopped, so the socket is of no
+// more use.
+func (s *multiSocket) ended() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dead
+}
+
+func (s *multiSocket) open(ctx context.Context, id string) (*Context, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &Context{
+		id:     id,
+		sock:   s,
+		audio:  make(chan AudioChunk, 32),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	c.dec = newStreamDecoder(ctx, s.cfg, c.audio)
+
+	s.mu.Lock()
+	switch {
+	case s.dead:
+		s.mu.Unlock()
+		cancel()
+		return nil, errSocketClosed
+	case s.contexts[id] != nil:
+		s.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("tts context %q is already open", id)
+	}
+	// The decoder has to be running before the read loop can find c.
+	c.dec.start()
+	s.contexts[id] = c
+	s.mu.Unlock()
+
+	if err := s.write(wsContextMessage{
+		ContextID:        id,
+		Text:             " ",
+		VoiceSettings:    s.cfg.voiceSettings(),
+		GenerationConfig: s.cfg.generationConfig(),
+		Dictionaries:     s.cfg.Dictionaries,
+	}); err != nil {
+		s.remove(c)
+		cancel()
+		c.end(nil, false)
+		return nil, fmt.Errorf("opening tts context: %w", err)
+	}
+	return c, nil
+}
+
+func (s *multiSocket) lookup(id string) *Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.contexts[id]
+}
+
+// remove forgets c, closing the socket if it was retired and c was the
+// last context on it.
+func (s *multiSocket) remove(c *Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contexts[c.id] != c {
+		return
+	}
+	delete(s.contexts, c.id)
+	if s.retired && len(s.contexts) == 0 {
+		s.conn.Close()
+	}
+}
+
+func (s *multiSocket) retire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retired = true
+	if len(s.contexts) == 0 {
+		s.conn.Close()
+	}
+}
+
+// close asks the server to close the socket, and closes it regardless.
+func (s *multiSocket) close() {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+	_ = s.write(wsCloseSocketMessage{CloseSocket: true})
+	s.conn.Close()
+}
+
+// readLoop hands each message to the context it is for. When the socket
+// fails, every context still open ends with the error, and the connection
+// is closed rather than left for the next OpenContext to retire.
+func (s *multiSocket) readLoop() {
+	var failure error
+	defer s.conn.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			s.cfg.Guard.Handle("tts_multi_read_loop", r, debug.Stack())
+			failure = fmt.Errorf("tts read loop panic: %v", r)
+		}
+		s.mu.Lock()
+		s.dead = true
+		switch {
+		case s.closing:
+			failure = errMultiSessionClosed
+		case failure == nil:
+			failure = errSocketClosed
+		}
+		open := make([]*Context, 0, len(s.contexts))
+		for _, c := range s.contexts {
+			open = append(open, c)
+		}
+		s.contexts = map[string]*Context{}
+		s.mu.Unlock()
+		for _, c := range open {
+			c.finish(failure)
+		}
+	}()
+
+	for {
+		_, msg, err := s.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				failure = fmt.Errorf("ws read: %w", err)
+			}
+			return
+		}
+
+		var am wsContextAudioMessage
+		if err := json.Unmarshal(msg, &am); err != nil {
+			failure = fmt.Errorf("ws unmarshal: %w", err)
+			return
+		}
+		// An error for no context in particular is about the socket.
+		if err := am.err(); err != nil && am.ContextID == "" {
+			failure = err
+			return
+		}
+		// Audio for a context closed on our side is still in flight.
+		c := s.lookup(am.ContextID)
+		if c == nil {
+			continue
+		}
+		if err := am.err(); err != nil {
+			s.remove(c)
+			c.finish(err)
+			continue
+		}
+		if am.IsFinal {
+			s.remove(c)
+			c.finish(nil)
+			continue
+		}
+		if am.Audio == "" {
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(am.Audio)
+		if err != nil {
+			s.remove(c)
+			c.finish(fmt.Errorf("ws base64 decode: %w", err))
+			continue
+		}
+		if len(data) > 0 {
+			c.deliver(data)
+		}
+	}
+}
+
+// Context is one response on a MultiSession. It is used like a Session:
+// text goes in with SendText, and PCM comes out of Audio until the context
+// ends.
+type Context struct {
+	id     string
+	sock   *multiSocket
+	dec    *streamDecoder
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu is held while sending on audio, so it isn't closed mid-send.
+	mu    sync.Mutex
+	audio chan AudioChunk
+	ended bool
+
+	// chars is kept apart from mu, which a send to a slow reader holds.
+	chars atomic.Int64
+}
+
+func (c *Context) SendText(text string) error {
+	c.chars.Add(int64(countUsage(text, c.chars.Load() == 0)))
+	return c.sock.write(wsContextMessage{ContextID: c.id, Text: text})
+}
+
+// Characters returns how many characters the context was sent.
+func (c *Context) Characters() int {
+	return int(c.chars.Load())
+}
+
+// Flush has the server generate the text it has buffered rather than wait
+// for more.
+func (c *Context) Flush() error {
+	return c.sock.write(wsContextMessage{ContextID: c.id, Flush: true})
+}
+
+// CloseContext tells the server the context is done. Audio ends once the
+// server has sent what it generated and its final message.
+func (c *Context) CloseContext() error {
+	return c.sock.write(wsContextMessage{ContextID: c.id, CloseContext: true})
+}
+
+func (c *Context) Audio() <-chan AudioChunk {
+	return c.audio
+}
+
+// Close abandons the context: the server is told to stop generating it and
+// Audio ends right away.
+func (c *Context) Close() error {
+	// Cancelling first unblocks a send nobody is reading.
+	c.cancel()
+	c.mu.Lock()
+	ended := c.ended
+	c.mu.Unlock()
+	if !ended {
+		if err := c.CloseContext(); err != nil {
+			slog.Debug("closing tts context", "context", c.id, "error", err)
+		}
+	}
+	c.sock.remove(c)
+	c.end(nil, false)
+	return nil
+}
+
+// deliver passes audio read from the socket on, through the decoder for
+// compressed formats.
+func (c *Context) deliver(data []byte) {
+	if c.dec.active() {
+		// A failed write means the decoder gave up and reported why.
+		if err := c.dec.write(data); err != nil {
+			c.sock.remove(c)
+			c.end(nil, false)
+		}
+		return
+	}
+	c.send(AudioChunk{Data: data})
+}
+
+// finish ends the context after what the decoder still has, with err, or
+// with a Done chunk when err is nil.
+func (c *Context) finish(err error) {
+	c.end(err, err == nil)
+}
+
+func (c *Context) end(err error, done bool) {
+	c.dec.stop(err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ended {
+		return
+	}
+	c.ended = true
+	switch {
+	case err != nil:
+		c.sendLocked(AudioChunk{Error: err})
+	case done:
+		c.sendLocked(AudioChunk{Done: true})
+	}
+	close(c.audio)
+}
+
+func (c *Context) send(chunk AudioChunk) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ended {
+		return
+	}
+	c.sendLocked(chunk)
+}
+
+func (c *Context) sendLocked(chunk AudioChunk) {
+	select {
+	case c.audio <- chunk:
+	case <-c.ctx.Done():
+	}
+}
@@ -0,0 +1,168 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// openContext opens id on m and returns the server's side of the socket,
+// after the context's init message.
+func openContext(t *testing.T, m *MultiSession, srv *wsServer, sc *serverConn, id string) (*Context, *serverConn) {
+	t.Helper()
+	c, err := m.OpenContext(context.Background(), id, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if sc == nil {
+		sc = srv.accept(t)
+	}
+	var msg wsContextMessage
+	sc.nextJSON(t, &msg)
+	if msg.ContextID != id || msg.Text != " " || msg.VoiceSettings == nil {
+		t.Fatalf("init message %+v, want one opening %s", msg, id)
+	}
+	return c, sc
+}
+
+// contextAudio is audioMessage for the context id.
+func contextAudio(id string, pcm []byte) map[string]any {
+	msg := audioMessage(pcm, "")
+	msg["contextId"] = id
+	return msg
+}
+
+func TestMultiSessionDemux(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := testConfig(t, srv)
+	m := NewMultiSession(func() SessionConfig { return cfg })
+	defer m.Close()
+
+	a, sc := openContext(t, m, srv, nil, "a")
+	b, _ := openContext(t, m, srv, sc, "b")
+	noConnection(t, srv)
+
+	if err := b.SendText("hej"); err != nil {
+		t.Fatal(err)
+	}
+	var msg wsContextMessage
+	sc.nextJSON(t, &msg)
+	if msg.ContextID != "b" || msg.Text != "hej" {
+		t.Errorf("sent %+v, want b's text", msg)
+	}
+
+	// Interleaved audio reaches the context it is for; audio for a
+	// context nobody has open is dropped.
+	sc.send(t, contextAudio("b", []byte{1, 1}))
+	sc.send(t, contextAudio("a", []byte{2, 2}))
+	sc.send(t, contextAudio("gone", []byte{9, 9}))
+	sc.send(t, contextAudio("b", []byte{3, 3}))
+	sc.send(t, map[string]any{"contextId": "a", "isFinal": true})
+	sc.send(t, map[string]any{"contextId": "b", "isFinal": true})
+
+	for _, tt := range []struct {
+		c    *Context
+		want []byte
+	}{{a, []byte{2, 2}}, {b, []byte{1, 1, 3, 3}}} {
+		pcm, last := collect(t, tt.c.Audio())
+		if !bytes.Equal(pcm, tt.want) || !last.Done {
+			t.Errorf("context %s got %v, done %v, want %v", tt.c.id, pcm, last.Done, tt.want)
+		}
+	}
+}
+
+func TestMultiSessionContextError(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := testConfig(t, srv)
+	m := NewMultiSession(func() SessionConfig { return cfg })
+	defer m.Close()
+	a, sc := openContext(t, m, srv, nil, "a")
+	b, _ := openContext(t, m, srv, sc, "b")
+
+	// An error for one context ends that one only.
+	sc.send(t, map[string]any{"contextId": "a", "error": "quota_exceeded", "message": "out of characters"})
+	if _, last := collect(t, a.Audio()); !errors.Is(last.Error, ErrQuotaExceeded) {
+		t.Errorf("context a ended with %v, want ErrQuotaExceeded", last.Error)
+	}
+	sc.send(t, contextAudio("b", []byte{1, 1}))
+	sc.send(t, map[string]any{"contextId": "b", "isFinal": true})
+	if pcm, last := collect(t, b.Audio()); len(pcm) != 2 || !last.Done {
+		t.Errorf("context b got %v, done %v", pcm, last.Done)
+	}
+}
+
+func TestMultiSessionSocketError(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := testConfig(t, srv)
+	m := NewMultiSession(func() SessionConfig { return cfg })
+	defer m.Close()
+	a, sc := openContext(t, m, srv, nil, "a")
+	b, _ := openContext(t, m, srv, sc, "b")
+
+	// An error for no context is about the socket, and ends them all.
+	sc.send(t, map[string]any{"error": "invalid_api_key", "message": "bad key"})
+	for _, c := range []*Context{a, b} {
+		if _, last := collect(t, c.Audio()); !errors.Is(last.Error, ErrUnauthorized) {
+			t.Errorf("context %s ended with %v, want ErrUnauthorized", c.id, last.Error)
+		}
+	}
+	sc.waitClosed(t)
+
+	// The next context dials a new socket.
+	openContext(t, m, srv, nil, "c")
+}
+
+func TestMultiSessionConfigChange(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := testConfig(t, srv)
+	pc := &poolConfig{cfg: cfg}
+	m := NewMultiSession(pc.get)
+	defer m.Close()
+	a, first := openContext(t, m, srv, nil, "a")
+
+	// A new model gets a socket of its own; the old one closes once its
+	// last context is done.
+	pc.setModel("eleven_flash_v2_5")
+	_, second := openContext(t, m, srv, nil, "b")
+	if model := second.query.Get("model_id"); model != "eleven_flash_v2_5" {
+		t.Errorf("dialed model %q", model)
+	}
+	if inactivity := second.query.Get("inactivity_timeout"); inactivity != "180" {
+		t.Errorf("inactivity_timeout = %q, want 180", inactivity)
+	}
+	a.Close()
+	first.waitClosed(t)
+}
+
+func TestMultiSessionDuplicateID(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := testConfig(t, srv)
+	m := NewMultiSession(func() SessionConfig { return cfg })
+	defer m.Close()
+	openContext(t, m, srv, nil, "a")
+	if _, err := m.OpenContext(context.Background(), "a", ""); err == nil {
+		t.Error("opened a second context with the same id")
+	}
+}
+
+func TestMultiSessionClose(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := testConfig(t, srv)
+	m := NewMultiSession(func() SessionConfig { return cfg })
+	a, sc := openContext(t, m, srv, nil, "a")
+
+	m.Close()
+	var msg wsCloseSocketMessage
+	sc.nextJSON(t, &msg)
+	if !msg.CloseSocket {
+		t.Errorf("sent %+v, want close_socket", msg)
+	}
+	if _, last := collect(t, a.Audio()); !errors.Is(last.Error, errMultiSessionClosed) {
+		t.Errorf("open context ended with %v, want errMultiSessionClosed", last.Error)
+	}
+	if _, err := m.OpenContext(context.Background(), "b", ""); !errors.Is(err, errMultiSessionClosed) {
+		t.Errorf("OpenContext after Close = %v", err)
+	}
+}
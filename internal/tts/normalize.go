@@ -0,0 +1,70 @@
+package tts
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// normalizers turn what the agent writes into what is to be said, by
+// language. Numbers, times and units are read in the voice's own language
+// only once they are spelled out, so a language is a table of rules like
+// svRules.
+var normalizers = map[string]func(text string) string{
+	"sv": NormalizeSV,
+}
+
+// Normalizer returns the normalizer for lang, e.g. sv.
+func Normalizer(lang string) (func(text string) string, error) {
+	n, ok := normalizers[lang]
+	if !ok {
+		return nil, fmt.Errorf("no text normalization for %q", lang)
+	}
+	return n, nil
+}
+
+// normalizeRule rewrites every match of re with say, which is given the
+// match and its groups and returns it unchanged when it isn't what the
+// rule is for after all, like a 45th of a month.
+type normalizeRule struct {
+	re  *regexp.Regexp
+	say func(m []string) string
+}
+
+func normalize(text string, rules []normalizeRule) string {
+	for _, r := range rules {
+		// A rule ending in the character after its match consumes it, so
+		// a match right after another one is only found on a second pass.
+		for range 4 {
+			out := replaceMatches(r.re, text, r.say)
+			if out == text {
+				break
+			}
+			text = out
+		}
+	}
+	return text
+}
+
+// replaceMatches is ReplaceAllStringFunc with the groups of each match.
+func replaceMatches(re *regexp.Regexp, text string, say func(m []string) string) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, idx := range matches {
+		m := make([]string, len(idx)/2)
+		for i := range m {
+			if idx[2*i] >= 0 {
+				m[i] = text[idx[2*i]:idx[2*i+1]]
+			}
+		}
+		b.WriteString(text[last:idx[0]])
+		b.WriteString(say(m))
+		last = idx[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,344 @@
+package tts
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NormalizeSV spells out clock times, dates, ordinals, amounts of money,
+// temperatures and units in Swedish, so "kl. 21:35" is read as "klockan
+// tjugoett trettiofem" and "150 kr" as "hundrafemtio kronor" rather than
+// in English or letter by letter. Other text is left alone.
+func NormalizeSV(text string) string {
+	return normalize(text, svRules)
+}
+
+// svNumber is a whole number, its thousands maybe grouped with spaces.
+const svNumber = `(\d{1,3}(?:[ \x{a0}]\d{3})+|\d+)`
+
+// svEnd is the character after a word, which a regexp's \b can't find
+// after å, ä or ö. It is put back after the match.
+const svEnd = `([^\p{L}\p{N}]|$)`
+
+var svMonths = []string{"januari", "februari", "mars", "april", "maj", "juni", "juli", "augusti", "september", "oktober", "november", "december"}
+
+var svMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "jun": 6, "jul": 7,
+	"aug": 8, "sep": 9, "sept": 9, "okt": 10, "nov": 11, "dec": 12,
+}
+
+func init() {
+	for i, m := range svMonths {
+		svMonthNames[m] = i + 1
+	}
+}
+
+// svUnit is how a unit is read after one of it and after any other amount.
+type svUnit struct {
+	one, many string
+	neuter    bool
+}
+
+var svUnits = map[string]svUnit{
+	"km/h": {"kilometer i timmen", "kilometer i timmen", false},
+	"m/s":  {"meter per sekund", "meter per sekund", false},
+	"kWh":  {"kilowattimme", "kilowattimmar", false},
+	"kW":   {"kilowatt", "kilowatt", false},
+	"W":    {"watt", "watt", false},
+	"km":   {"kilometer", "kilometer", false},
+	"m":    {"meter", "meter", false},
+	"cm":   {"centimeter", "centimeter", false},
+	"mm":   {"millimeter", "millimeter", false},
+	"kg":   {"kilo", "kilo", true},
+	"g":    {"gram", "gram", true},
+	"l":    {"liter", "liter", false},
+	"dl":   {"deciliter", "deciliter", false},
+	"cl":   {"centiliter", "centiliter", false},
+	"ml":   {"milliliter", "milliliter", false},
+	"h":    {"timme", "timmar", false},
+	"min":  {"minut", "minuter", false},
+	"sek":  {"sekund", "sekunder", false},
+	"%":    {"procent", "procent", false},
+}
+
+// svFractions are the fractions said in a recipe. Others are left as they
+// are written.
+var svFractions = map[string]struct{ common, neuter string }{
+	"1/2": {"en halv", "ett halvt"},
+	"1/4": {"en kvarts", "ett kvarts"},
+	"3/4": {"tre fjärdedels", "tre fjärdedels"},
+	"1/3": {"en tredjedels", "ett tredjedels"},
+	"2/3": {"två tredjedels", "två tredjedels"},
+}
+
+// svCurrencies are read with their minor unit after och.
+var svCurrencies = map[string]struct{ one, many, minor string }{
+	"kr":     {"krona", "kronor", "öre"},
+	"kronor": {"krona", "kronor", "öre"},
+	"krona":  {"krona", "kronor", "öre"},
+	"SEK":    {"krona", "kronor", "öre"},
+	":-":     {"krona", "kronor", "öre"},
+	"€":      {"euro", "euro", "cent"},
+	"EUR":    {"euro", "euro", "cent"},
+	"euro":   {"euro", "euro", "cent"},
+	"$":      {"dollar", "dollar", "cent"},
+	"USD":    {"dollar", "dollar", "cent"},
+	"dollar": {"dollar", "dollar", "cent"},
+}
+
+// svRules run in order, dates and times before the plain numbers in them
+// could be taken for anything else.
+var svRules = []normalizeRule{
+	// 2026-10-17
+	{regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`), func(m []string) string {
+		return svDate(m[0], m[3], m[2], m[1])
+	}},
+	// 17/10/2026, and 17/10 after den, as alone it could be a fraction.
+	{regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4})\b`), func(m []string) string {
+		return svDate(m[0], m[1], m[2], m[3])
+	}},
+	{regexp.MustCompile(`\b([Dd]en) (\d{1,2})/(\d{1,2})\b`), func(m []string) string {
+		if date := svDate("", m[2], m[3], ""); date != "" {
+			return m[1] + " " + date
+		}
+		return m[0]
+	}},
+	// 17 oktober, 17:e okt 2026
+	{regexp.MustCompile(`\b(\d{1,2})(?::[ae])?\.? ((?i:` + strings.Join(svMonths, "|") + `|jan|feb|mar|apr|jun|jul|aug|sept|sep|okt|nov|dec))\.?(?: (\d{4}))?` + svEnd), func(m []string) string {
+		month := strconv.Itoa(svMonthNames[strings.ToLower(m[2])])
+		if date := svDate("", m[1], month, m[3]); date != "" {
+			return date + m[4]
+		}
+		return m[0]
+	}},
+	// kl. 21.35, klockan 8, and Kl. starting a sentence
+	{regexp.MustCompile(`\b([Kk]l\.?|[Kk]lockan) ?([01]?\d|2[0-3])(?:[.:]([0-5]\d))?\b`), func(m []string) string {
+		word := "klockan"
+		if m[1][0] == 'K' {
+			word = "Klockan"
+		}
+		return word + " " + svClock(m[2], m[3])
+	}},
+	// 21:35
+	{regexp.MustCompile(`\b([01]?\d|2[0-3]):([0-5]\d)\b`), func(m []string) string {
+		return svClock(m[1], m[2])
+	}},
+	// 1:a, 3:e
+	{regexp.MustCompile(`\b(\d{1,3}):[ae]\b`), func(m []string) string {
+		n, _ := strconv.ParseInt(m[1], 10, 64)
+		if word, ok := svOrdinal(n); ok {
+			return word
+		}
+		return m[0]
+	}},
+	// 150 kr, 12,50 kr, 99:-, 5 €
+	{regexp.MustCompile(`\b` + svNumber + `(?:[,.](\d{1,2}))? ?(kronor|krona|kr|SEK|:-|€|EUR|euro|USD|\$|dollar)` + svEnd), func(m []string) string {
+		return svMoney(m[1], m[2], m[3]) + m[4]
+	}},
+	// $5, €12,50
+	{regexp.MustCompile(`([$€]) ?` + svNumber + `(?:[,.](\d{1,2}))?\b`), func(m []string) string {
+		return svMoney(m[2], m[3], m[1])
+	}},
+	// -5 °C, 21° F. A minus only counts after a space, since 3-5° is a
+	// range.
+	{regexp.MustCompile(`(^|[\s(])([-−]?)` + svNumber + `(?:[,.](\d+))? ?° ?([CF]?)` + svEnd), func(m []string) string {
+		say := m[1]
+		if m[2] != "" {
+			say += "minus "
+		}
+		if m[4] == "" && svParse(m[3]) == 1 {
+			say += "en grad"
+		} else {
+			say += svAmount(m[3], m[4], false) + " grader"
+		}
+		if m[5] == "F" {
+			say += " Fahrenheit"
+		}
+		return say + m[6]
+	}},
+	// 5 km, 2,5 dl, 30 %, 1/2 dl
+	{regexp.MustCompile(`\b(\d+/)?` + svNumber + `(?:[,.](\d+))? ?(km/h|m/s|kWh|kW|km|cm|mm|kg|dl|cl|ml|min|sek|m|g|l|h|W|%)` + svEnd), func(m []string) string {
+		unit := svUnits[m[4]]
+		switch {
+		case m[1] != "":
+			fraction, ok := svFractions[m[1]+m[2]]
+			if !ok || m[3] != "" {
+				return m[0]
+			}
+			if unit.neuter {
+				return fraction.neuter + " " + unit.one + m[5]
+			}
+			return fraction.common + " " + unit.one + m[5]
+		case m[3] == "" && svParse(m[2]) == 1:
+			return svAmount(m[2], "", unit.neuter) + " " + unit.one + m[5]
+		}
+		return svAmount(m[2], m[3], unit.neuter) + " " + unit.many + m[5]
+	}},
+}
+
+// svDate reads day, month and maybe year as "sjuttonde oktober
+// tvåtusentjugosex", or returns orig if it isn't a date.
+func svDate(orig, day, month, year string) string {
+	d, m := svParse(day), svParse(month)
+	if d < 1 || d > 31 || m < 1 || m > 12 {
+		return orig
+	}
+	say, _ := svOrdinal(d)
+	say += " " + svMonths[m-1]
+	if year != "" {
+		say += " " + svYear(svParse(year))
+	}
+	return say
+}
+
+// svClock reads a time as it is said on a digital clock: "tjugoett
+// trettiofem", "åtta noll fem", or just the hour on the hour.
+func svClock(hour, minute string) string {
+	say := svCardinal(svParse(hour))
+	switch m := svParse(minute); {
+	case minute == "" || m == 0:
+	case m < 10:
+		say += " noll " + svCardinal(m)
+	default:
+		say += " " + svCardinal(m)
+	}
+	return say
+}
+
+func svMoney(amount, cents, currency string) string {
+	c := svCurrencies[currency]
+	var say string
+	if svParse(amount) == 1 {
+		say = "en " + c.one
+	} else {
+		say = svCardinal(svParse(amount)) + " " + c.many
+	}
+	if cents != "" {
+		if len(cents) == 1 {
+			cents += "0"
+		}
+		if n := svParse(cents); n > 0 {
+			say += " och " + svCardinal(n) + " " + c.minor
+		}
+	}
+	return say
+}
+
+// svAmount reads a number with its decimals as "två komma fem". One is
+// "ett" before neuter words and "en" before the rest.
+func svAmount(whole, decimals string, neuter bool) string {
+	n := svParse(whole)
+	say := svCardinal(n)
+	if n == 1 && !neuter {
+		say = "en"
+	}
+	if decimals == "" {
+		return say
+	}
+	say += " komma"
+	// Leading zeros are read one by one, the rest as a number.
+	trimmed := strings.TrimLeft(decimals, "0")
+	for range len(decimals) - len(trimmed) {
+		say += " noll"
+	}
+	if trimmed != "" {
+		say += " " + svCardinal(svParse(trimmed))
+	}
+	return say
+}
+
+// svParse reads a number the rules matched, dropping the spaces between
+// its thousands.
+func svParse(s string) int64 {
+	s = strings.NewReplacer(" ", "", " ", "").Replace(s)
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+var (
+	svOnes = []string{"noll", "ett", "två", "tre", "fyra", "fem", "sex", "sju", "åtta", "nio",
+		"tio", "elva", "tolv", "tretton", "fjorton", "femton", "sexton", "sjutton", "arton", "nitton"}
+	svTens = []string{"", "", "tjugo", "trettio", "fyrtio", "femtio", "sextio", "sjuttio", "åttio", "nittio"}
+
+	svOrdinalOnes = []string{"nollte", "första", "andra", "tredje", "fjärde", "femte", "sjätte", "sjunde", "åttonde", "nionde",
+		"tionde", "elfte", "tolfte", "trettonde", "fjortonde", "femtonde", "sextonde", "sjuttonde", "artonde", "nittonde"}
+	svOrdinalTens = []string{"", "", "tjugonde", "trettionde", "fyrtionde", "femtionde", "sextionde", "sjuttionde", "åttionde", "nittionde"}
+)
+
+// svCardinal spells out n as one word up to the millions, as Swedish
+// writes them: "tvåhundrafemtiotre".
+func svCardinal(n int64) string {
+	switch {
+	case n < 0:
+		return "minus " + svCardinal(-n)
+	case n < 20:
+		return svOnes[n]
+	case n < 100:
+		return svTens[n/10] + svOnesAfter(n%10)
+	case n < 1000:
+		return svCount(n/100) + "hundra" + svOnesAfter(n%100)
+	case n < 1_000_000:
+		return svCount(n/1000) + "tusen" + svOnesAfter(n%1000)
+	case n < 1_000_000_000:
+		return svLarge(n, 1_000_000, "miljon", "miljoner")
+	default:
+		return svLarge(n, 1_000_000_000, "miljard", "miljarder")
+	}
+}
+
+// svCount is how many hundreds or thousands, left out when it is one.
+func svCount(n int64) string {
+	if n == 1 {
+		return ""
+	}
+	return svCardinal(n)
+}
+
+// svOnesAfter is the rest of a number after its tens, hundreds or
+// thousands, nothing when it is 0.
+func svOnesAfter(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return svCardinal(n)
+}
+
+func svLarge(n, unit int64, one, many string) string {
+	say := "en " + one
+	if n/unit > 1 {
+		say = svCardinal(n/unit) + " " + many
+	}
+	if n%unit != 0 {
+		say += " " + svCardinal(n%unit)
+	}
+	return say
+}
+
+// svOrdinal spells out n as "första" or "tjugoandra", up to 999.
+func svOrdinal(n int64) (string, bool) {
+	switch {
+	case n < 0 || n > 999:
+		return "", false
+	case n < 20:
+		return svOrdinalOnes[n], true
+	case n < 100 && n%10 == 0:
+		return svOrdinalTens[n/10], true
+	case n < 100:
+		return svTens[n/10] + svOrdinalOnes[n%10], true
+	case n%100 == 0:
+		return svCardinal(n) + "de", true
+	default:
+		rest, _ := svOrdinal(n % 100)
+		return svCardinal(n-n%100) + rest, true
+	}
+}
+
+// svYear reads years before 2000 in hundreds, "nittonhundraåttiofyra",
+// and later ones as a number.
+func svYear(y int64) string {
+	if y >= 1100 && y < 2000 {
+		return svCardinal(y/100) + "hundra" + svOnesAfter(y%100)
+	}
+	return svCardinal(y)
+}
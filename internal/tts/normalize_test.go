@@ -0,0 +1,179 @@
+package tts
+
+import "testing"
+
+func TestNormalizeSV(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		// Clock times.
+		{"clock", "Larmet går 21:35.", "Larmet går tjugoett trettiofem."},
+		{"clock before ten past", "kl 8:05", "klockan åtta noll fem"},
+		{"clock with kl.", "Vi ses kl. 21.35", "Vi ses klockan tjugoett trettiofem"},
+		{"clock on the hour", "Klockan 7 imorgon", "Klockan sju imorgon"},
+		{"sentence starting with Kl.", "Kl. 9 börjar det", "Klockan nio börjar det"},
+		{"clock at zero minutes", "kl. 14:00", "klockan fjorton"},
+		{"midnight", "00:30", "noll trettio"},
+		{"not a time", "Resultatet blev 25:70", "Resultatet blev 25:70"},
+
+		// Dates.
+		{"iso date", "2026-10-17", "sjuttonde oktober tvåtusentjugosex"},
+		{"slashed date", "17/10/2026", "sjuttonde oktober tvåtusentjugosex"},
+		{"short date after den", "den 1/5", "den första maj"},
+		{"fraction stays", "1/5 av alla", "1/5 av alla"},
+		{"day and month", "Den 3 mars kommer de.", "Den tredje mars kommer de."},
+		{"day with :e", "den 24:e december", "den tjugofjärde december"},
+		{"abbreviated month", "31 dec. 1999", "trettioförsta december nittonhundranittionio"},
+		{"month and year", "17 okt 2026", "sjuttonde oktober tvåtusentjugosex"},
+		{"no such day", "45 maj", "45 maj"},
+		{"no such month", "2026-13-01", "2026-13-01"},
+
+		// Ordinals.
+		{"first", "Hon kom 1:a", "Hon kom första"},
+		{"third", "den 3:e gången", "den tredje gången"},
+		{"twenty second", "22:a", "tjugoandra"},
+		{"hundredth", "100:e", "hundrade"},
+
+		// Money.
+		{"kronor", "Det kostar 150 kr.", "Det kostar hundrafemtio kronor."},
+		{"one krona", "1 kr", "en krona"},
+		{"öre", "12,50 kr", "tolv kronor och femtio öre"},
+		{"single decimal", "3,5 kr", "tre kronor och femtio öre"},
+		{"zero öre", "10,00 kr", "tio kronor"},
+		{"SEK", "2 500 SEK", "tvåtusenfemhundra kronor"},
+		{"colon dash", "99:-", "nittionio kronor"},
+		{"euro after", "5 €", "fem euro"},
+		{"dollar before", "$20", "tjugo dollar"},
+		{"cents", "€12,50", "tolv euro och femtio cent"},
+		{"large amount", "1 250 000 kr", "en miljon tvåhundrafemtiotusen kronor"},
+
+		// Temperatures.
+		{"degrees", "Det är 21 °C ute.", "Det är tjugoett grader ute."},
+		{"one degree", "1°", "en grad"},
+		{"minus", "Det blir -5°C i natt", "Det blir minus fem grader i natt"},
+		{"decimal degrees", "3,5°", "tre komma fem grader"},
+		{"fahrenheit", "70°F", "sjuttio grader Fahrenheit"},
+		{"range isn't negative", "3-5°", "3-5°"},
+
+		// Units.
+		{"kilometer", "5 km bort", "fem kilometer bort"},
+		{"speed", "Vinden är 8 m/s", "Vinden är åtta meter per sekund"},
+		{"one hour", "1 h", "en timme"},
+		{"hours", "2 h", "två timmar"},
+		{"minutes", "15 min", "femton minuter"},
+		{"neuter one", "1 kg", "ett kilo"},
+		{"common one", "1 l", "en liter"},
+		{"decimals", "2,5 dl", "två komma fem deciliter"},
+		{"leading zero decimals", "0,05 l", "noll komma noll fem liter"},
+		{"percent", "30 %", "trettio procent"},
+		{"energy", "12 kWh", "tolv kilowattimmar"},
+		{"fraction", "1/2 dl mjölk", "en halv deciliter mjölk"},
+		{"neuter fraction", "1/2 kg", "ett halvt kilo"},
+		{"unknown fraction", "5/7 dl", "5/7 dl"},
+		{"unit inside a word", "5 mil", "5 mil"},
+		{"unit before å", "3 gånger", "3 gånger"},
+
+		// Several in one sentence, and a match right after another.
+		{"mixed", "Kl. 7:30 den 2/3 var det -4° och 8 m/s.",
+			"Klockan sju trettio den andra mars var det minus fyra grader och åtta meter per sekund."},
+		{"back to back", "1 kr 2 kr", "en krona två kronor"},
+		{"nothing to do", "Hej, hur mår du?", "Hej, hur mår du?"},
+		{"bare number", "Jag har 3 katter", "Jag har 3 katter"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeSV(tt.in); got != tt.want {
+				t.Errorf("NormalizeSV(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSVCardinal(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "noll"},
+		{1, "ett"},
+		{13, "tretton"},
+		{20, "tjugo"},
+		{21, "tjugoett"},
+		{99, "nittionio"},
+		{100, "hundra"},
+		{101, "hundraett"},
+		{253, "tvåhundrafemtiotre"},
+		{1000, "tusen"},
+		{1999, "tusenniohundranittionio"},
+		{2026, "tvåtusentjugosex"},
+		{21000, "tjugoetttusen"},
+		{999_999, "niohundranittioniotusenniohundranittionio"},
+		{1_000_000, "en miljon"},
+		{2_000_005, "två miljoner fem"},
+		{3_000_000_000, "tre miljarder"},
+		{-7, "minus sju"},
+	}
+	for _, tt := range tests {
+		if got := svCardinal(tt.n); got != tt.want {
+			t.Errorf("svCardinal(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSVOrdinal(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+		ok   bool
+	}{
+		{1, "första", true},
+		{2, "andra", true},
+		{11, "elfte", true},
+		{20, "tjugonde", true},
+		{21, "tjugoförsta", true},
+		{31, "trettioförsta", true},
+		{100, "hundrade", true},
+		{102, "hundraandra", true},
+		{340, "trehundrafyrtionde", true},
+		{1000, "", false},
+		{-1, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := svOrdinal(tt.n)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("svOrdinal(%d) = %q, %v, want %q, %v", tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSVYear(t *testing.T) {
+	tests := []struct {
+		y    int64
+		want string
+	}{
+		{1984, "nittonhundraåttiofyra"},
+		{1900, "nittonhundra"},
+		{2000, "tvåtusen"},
+		{2026, "tvåtusentjugosex"},
+		{1066, "tusensextiosex"},
+	}
+	for _, tt := range tests {
+		if got := svYear(tt.y); got != tt.want {
+			t.Errorf("svYear(%d) = %q, want %q", tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	n, err := Normalizer("sv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n("150 kr"); got != "hundrafemtio kronor" {
+		t.Errorf("sv normalizer = %q, want Swedish", got)
+	}
+	if _, err := Normalizer("xx"); err == nil {
+		t.Error("Normalizer(xx) found a normalizer, want an error")
+	}
+}
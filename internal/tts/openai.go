@@ -0,0 +1,102 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	openAISpeechURL = "https://api.openai.com/v1/audio/speech"
+	// openAISampleRate is the rate of OpenAI's pcm format. Its mp3 comes
+	// at the same rate and is decoded to it.
+	openAISampleRate = 24000
+)
+
+type OpenAIConfig struct {
+	APIKey string
+	Model  string
+	Voice  string
+	// Format is the response format asked for, pcm or mp3. pcm needs no
+	// decoding; mp3 is smaller on a slow link.
+	Format string
+	Speed  float64
+}
+
+func (c OpenAIConfig) Validate() error {
+	if c.APIKey == "" {
+		return errors.New("openai tts needs an API key")
+	}
+	if c.Format != "pcm" && c.Format != "mp3" {
+		return fmt.Errorf("unsupported openai tts format %q, expected pcm or mp3", c.Format)
+	}
+	return nil
+}
+
+// OpenAI speaks through the audio/speech endpoint, which takes the whole
+// text in one request and streams the audio back in the response body.
+type OpenAI struct {
+	cfg OpenAIConfig
+}
+
+func NewOpenAI(cfg OpenAIConfig) (*OpenAI, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &OpenAI{cfg: cfg}, nil
+}
+
+func (o *OpenAI) Name() string    { return "openai" }
+func (o *OpenAI) SampleRate() int { return openAISampleRate }
+func (o *OpenAI) Phonemes() bool  { return false }
+func (o *OpenAI) Close() error    { return nil }
+
+func (o *OpenAI) NewStream(ctx context.Context, _ StreamOptions) (Stream, error) {
+	return newSentenceStream(ctx, o.synthesize), nil
+}
+
+type openAISpeechRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+func (o *OpenAI) synthesize(ctx context.Context, text string) (io.ReadCloser, error) {
+	body, err := json.Marshal(openAISpeechRequest{
+		Model:          o.cfg.Model,
+		Input:          text,
+		Voice:          o.cfg.Voice,
+		ResponseFormat: o.cfg.Format,
+		Speed:          o.cfg.Speed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding tts request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, openAISpeechURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating tts request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+o.cfg.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting tts: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("requesting tts: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if o.cfg.Format == "mp3" {
+		return newDecodedReader(newMP3Reader, openAISampleRate, resp.Body)
+	}
+	return resp.Body, nil
+}
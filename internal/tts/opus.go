@@ -0,0 +1,42 @@
+//go:build opus
+
+package tts
+
+import (
+	"fmt"
+	"io"
+
+	"gopkg.in/hraban/opus.v2"
+)
+
+// libopusfile always decodes at 48 kHz, whatever rate the stream was
+// encoded at.
+const opusRate = 48000
+
+func opusDecoder() (pcmDecoder, error) {
+	return newOpusReader, nil
+}
+
+// opusReader decodes Ogg Opus. ElevenLabs streams are mono, which is what
+// Read assumes.
+type opusReader struct {
+	stream *opus.Stream
+}
+
+func newOpusReader(r io.Reader) (pcmReader, error) {
+	stream, err := opus.NewStream(r)
+	if err != nil {
+		return nil, fmt.Errorf("opening opus stream: %w", err)
+	}
+	return &opusReader{stream: stream}, nil
+}
+
+func (o *opusReader) Read(dst []int16) (int, error) {
+	return o.stream.Read(dst)
+}
+
+func (o *opusReader) SampleRate() int { return opusRate }
+
+func (o *opusReader) Close() error {
+	return o.stream.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !opus
+
+package tts
+
+import "errors"
+
+func opusDecoder() (pcmDecoder, error) {
+	return nil, errors.New("opus output requires building with -tags opus")
+}
@@ -0,0 +1,206 @@
+package tts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/joakimcarlsson/smarthome/internal/audio"
+)
+
+// piperDefaultRate is what piper's medium and high quality voices produce,
+// for an HTTP server whose model isn't available locally.
+const piperDefaultRate = 22050
+
+// PiperConfig runs piper either as an HTTP server, with URL, or as a
+// local binary, with Bin, one process per sentence.
+type PiperConfig struct {
+	URL string
+	Bin string
+	// Model is the voice model's .onnx file. The binary needs it; for the
+	// server it is only read for the sample rate, from the .onnx.json
+	// next to it.
+	Model string
+	// SampleRate overrides the rate read from the model. A server
+	// answering at another rate is resampled to it.
+	SampleRate int
+}
+
+// Piper speaks with a piper server on the LAN or a local piper binary, for
+// running without any cloud service.
+type Piper struct {
+	cfg  PiperConfig
+	rate int
+}
+
+func NewPiper(cfg PiperConfig) (*Piper, error) {
+	switch {
+	case cfg.URL == "" && cfg.Bin == "":
+		return nil, errors.New("piper needs a server URL or a binary")
+	case cfg.URL != "" && cfg.Bin != "":
+		return nil, errors.New("piper takes a server URL or a binary, not both")
+	case cfg.Bin != "" && cfg.Model == "":
+		return nil, errors.New("the piper binary needs a voice model")
+	}
+
+	rate := cfg.SampleRate
+	switch {
+	case cfg.Bin != "" || (rate == 0 && cfg.Model != ""):
+		// The binary writes raw PCM at the model's rate, which has to
+		// be known before anything plays.
+		modelRate, err := piperModelRate(cfg.Model)
+		if err != nil {
+			return nil, err
+		}
+		rate = modelRate
+	case rate == 0:
+		rate = piperDefaultRate
+	}
+	return &Piper{cfg: cfg, rate: rate}, nil
+}
+
+// piperModelRate reads the sample rate from the config piper keeps next
+// to a model, model.onnx.json.
+func piperModelRate(model string) (int, error) {
+	data, err := os.ReadFile(model + ".json")
+	if err != nil {
+		return 0, fmt.Errorf("reading piper model config: %w", err)
+	}
+	var mc struct {
+		Audio struct {
+			SampleRate int `json:"sample_rate"`
+		} `json:"audio"`
+	}
+	if err := json.Unmarshal(data, &mc); err != nil {
+		return 0, fmt.Errorf("parsing piper model config: %w", err)
+	}
+	if mc.Audio.SampleRate <= 0 {
+		return 0, fmt.Errorf("piper model config %s.json has no sample rate", model)
+	}
+	return mc.Audio.SampleRate, nil
+}
+
+func (p *Piper) Name() string    { return "piper" }
+func (p *Piper) SampleRate() int { return p.rate }
+func (p *Piper) Phonemes() bool  { return false }
+func (p *Piper) Close() error    { return nil }
+
+func (p *Piper) NewStream(ctx context.Context, _ StreamOptions) (Stream, error) {
+	if p.cfg.Bin != "" {
+		return newSentenceStream(ctx, p.exec), nil
+	}
+	return newSentenceStream(ctx, p.request), nil
+}
+
+// request has the server speak text, streaming the PCM of the WAV it
+// answers with.
+func (p *Piper) request(ctx context.Context, text string) (io.ReadCloser, error) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, fmt.Errorf("encoding piper request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating piper request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting piper: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("requesting piper: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	br := bufio.NewReader(resp.Body)
+	rate, err := audio.ReadWAVHeader(br)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("reading piper response: %w", err)
+	}
+	return resampledPCM(readCloser{br, resp.Body}, rate, p.rate), nil
+}
+
+// exec runs the binary on text, streaming its raw output.
+func (p *Piper) exec(ctx context.Context, text string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, p.cfg.Bin, "--model", p.cfg.Model, "--output-raw")
+	// Piper speaks each line on its own; a newline inside a sentence
+	// would only add a pause.
+	cmd.Stdin = strings.NewReader(strings.ReplaceAll(text, "\n", " ") + "\n")
+	stderr := &tailBuffer{max: 4096}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating piper pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting piper: %w", err)
+	}
+	return &piperProcess{cmd: cmd, stdout: stdout, stderr: stderr}, nil
+}
+
+// piperProcess reads the output of a running piper, and reports how it
+// exited once the output ends.
+type piperProcess struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *tailBuffer
+	waited bool
+}
+
+func (p *piperProcess) Read(b []byte) (int, error) {
+	n, err := p.stdout.Read(b)
+	if errors.Is(err, io.EOF) && !p.waited {
+		p.waited = true
+		if werr := p.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("piper: %w: %s", werr, strings.TrimSpace(p.stderr.String()))
+		}
+	}
+	return n, err
+}
+
+// Close stops piper if it is still speaking.
+func (p *piperProcess) Close() error {
+	if p.waited {
+		return nil
+	}
+	p.waited = true
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// tailBuffer keeps the last max bytes written to it, for the end of a
+// process's stderr.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = t.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
@@ -0,0 +1,233 @@
+package tts
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPoolMaxAge stays under the 20 seconds ElevenLabs lets a
+	// stream-input socket sit idle before closing it.
+	DefaultPoolMaxAge = 15 * time.Second
+
+	poolInitialBackoff = time.Second
+	poolMaxBackoff     = 30 * time.Second
+)
+
+// Pool keeps sessions connected ahead of time, so a turn doesn't wait on
+// the websocket handshake. Idle sessions are replaced before ElevenLabs
+// would close them, unless they are kept alive, as soon as the server
+// drops them, and when the config changes, such as the model being
+// downgraded under load.
+type Pool struct {
+	size   int
+	maxAge time.Duration
+	config func() SessionConfig
+	dial   func(context.Context, SessionConfig) (*Session, error)
+
+	mu     sync.Mutex
+	idle   []pooledSession
+	closed bool
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type pooledSession struct {
+	s      *Session
+	cfg    SessionConfig
+	dialed time.Time
+}
+
+// NewPool keeps size sessions for config's current value connected. A
+// size of 0 connects on each Get.
+func NewPool(size int, config func() SessionConfig) *Pool {
+	return newPool(size, DefaultPoolMaxAge, config)
+}
+
+func newPool(size int, maxAge time.Duration, config func() SessionConfig) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		size:   size,
+		maxAge: maxAge,
+		config: config,
+		dial:   NewSession,
+		wake:   make(chan struct{}, 1),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.run(ctx)
+	return p
+}
+
+// Get hands out an idle session, or connects one if none is usable. The
+// caller owns the session and closes it; the pool replaces it in the
+// background.
+//
+// A language other than the configured one gets a session of its own,
+// leaving the pooled ones for the next turn.
+func (p *Pool) Get(ctx context.Context, language string) (*Session, error) {
+	cfg := p.config()
+	if language != "" && language != cfg.LanguageCode {
+		cfg.LanguageCode = language
+		return p.dial(ctx, cfg)
+	}
+	p.mu.Lock()
+	for len(p.idle) > 0 {
+		ps := p.idle[0]
+		p.idle = p.idle[1:]
+		if p.usable(ps, cfg) {
+			p.mu.Unlock()
+			p.signal()
+			return ps.s, nil
+		}
+		// Close waits on the server, which the turn shouldn't.
+		go ps.s.Close()
+	}
+	p.mu.Unlock()
+	p.signal()
+	return p.dial(ctx, cfg)
+}
+
+// usable reports whether ps is still connected, made for cfg, and either
+// kept alive or young enough. Call with mu held.
+func (p *Pool) usable(ps pooledSession, cfg SessionConfig) bool {
+	select {
+	case <-ps.s.Done():
+		return false
+	default:
+	}
+	if !ps.cfg.sameAs(cfg) {
+		return false
+	}
+	return ps.cfg.KeepAlive > 0 || time.Since(ps.dialed) < p.maxAge
+}
+
+func (p *Pool) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops refilling and closes the idle sessions. Sessions are
+// connected under the pool, so any still handed out end too.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	p.cancel()
+	<-p.done
+
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, ps := range idle {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ps.s.Close()
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// run keeps the pool topped up, dropping sessions as they go stale and
+// backing off while dialing fails.
+func (p *Pool) run(ctx context.Context) {
+	defer close(p.done)
+	backoff := poolInitialBackoff
+	for {
+		wait := p.maxAge
+		if p.size > 0 {
+			if err := p.refill(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("pre-connecting tts session", "error", err, "retry_in", backoff)
+				wait = backoff
+				backoff = min(backoff*2, poolMaxBackoff)
+			} else {
+				backoff = poolInitialBackoff
+				wait = p.nextExpiry()
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-p.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// refill drops unusable sessions and dials until there are size idle.
+func (p *Pool) refill(ctx context.Context) error {
+	cfg := p.config()
+	p.mu.Lock()
+	kept := p.idle[:0]
+	for _, ps := range p.idle {
+		if p.usable(ps, cfg) {
+			kept = append(kept, ps)
+		} else {
+			go ps.s.Close()
+		}
+	}
+	p.idle = kept
+	missing := p.size - len(p.idle)
+	p.mu.Unlock()
+
+	for range missing {
+		s, err := p.dial(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			s.Close()
+			return ctx.Err()
+		}
+		p.idle = append(p.idle, pooledSession{s: s, cfg: cfg, dialed: time.Now()})
+		p.mu.Unlock()
+		go p.watch(ctx, s)
+	}
+	return nil
+}
+
+// watch wakes run when s ends, so a session the server closed is replaced
+// right away rather than found dead by Get. Once handed out, s ending
+// just causes a refill that finds nothing to do.
+func (p *Pool) watch(ctx context.Context, s *Session) {
+	select {
+	case <-s.Done():
+		p.signal()
+	case <-ctx.Done():
+	}
+}
+
+// nextExpiry is how long until the oldest idle session goes stale. Kept
+// alive sessions don't, and are only checked every maxAge.
+func (p *Pool) nextExpiry() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle) == 0 || p.idle[0].cfg.KeepAlive > 0 {
+		return p.maxAge
+	}
+	return max(p.maxAge-time.Since(p.idle[0].dialed), 0)
+}
@@ -0,0 +1,186 @@
+package tts
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// poolConfig hands the pool cfg, and lets the test change it.
+type poolConfig struct {
+	mu  sync.Mutex
+	cfg SessionConfig
+}
+
+func (c *poolConfig) get() SessionConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg
+}
+
+func (c *poolConfig) setModel(model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg.ModelID = model
+}
+
+// acceptSession returns the next connection, after its init message.
+func acceptSession(t *testing.T, srv *wsServer) *serverConn {
+	t.Helper()
+	sc := srv.accept(t)
+	if text := sc.next(t); text != " " {
+		t.Fatalf("first message %q, want the init message", text)
+	}
+	return sc
+}
+
+// noConnection fails the test if anything dials srv for a while.
+func noConnection(t *testing.T, srv *wsServer) {
+	t.Helper()
+	select {
+	case <-srv.conns:
+		t.Error("unexpected connection")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPoolGet(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := &poolConfig{cfg: testConfig(t, srv)}
+	p := newPool(1, time.Minute, cfg.get)
+	defer p.Close()
+	first := acceptSession(t, srv)
+
+	s, err := p.Get(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendText("hej"); err != nil {
+		t.Fatal(err)
+	}
+	if text := first.next(t); text != "hej" {
+		t.Errorf("pre-connected session got %q, want the text", text)
+	}
+	// The pool fills up again behind it.
+	acceptSession(t, srv)
+	s.Close()
+	first.waitClosed(t)
+}
+
+func TestPoolWithoutSessions(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := &poolConfig{cfg: testConfig(t, srv)}
+	p := newPool(0, time.Minute, cfg.get)
+	defer p.Close()
+	noConnection(t, srv)
+
+	for range 2 {
+		s, err := p.Get(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		acceptSession(t, srv)
+		s.Close()
+	}
+}
+
+func TestPoolRefresh(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := &poolConfig{cfg: testConfig(t, srv)}
+	p := newPool(1, time.Minute, cfg.get)
+	defer p.Close()
+	first := acceptSession(t, srv)
+
+	// A session made for the old model is discarded, and the turn and the
+	// pool both get the new one.
+	cfg.setModel("eleven_flash_v2_5")
+	s, err := p.Get(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	first.waitClosed(t)
+	for range 2 {
+		if model := acceptSession(t, srv).query.Get("model_id"); model != "eleven_flash_v2_5" {
+			t.Errorf("dialed model %q after the config changed", model)
+		}
+	}
+}
+
+func TestPoolDiscardsStale(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := &poolConfig{cfg: testConfig(t, srv)}
+	p := newPool(1, 200*time.Millisecond, cfg.get)
+	defer p.Close()
+	first := acceptSession(t, srv)
+
+	// Replaced before the server would close it for being idle.
+	second := acceptSession(t, srv)
+	first.waitClosed(t)
+	select {
+	case <-second.closed:
+		t.Error("the replacement was closed too")
+	default:
+	}
+}
+
+func TestPoolReplacesDropped(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := &poolConfig{cfg: testConfig(t, srv)}
+	p := newPool(1, time.Minute, cfg.get)
+	defer p.Close()
+	first := acceptSession(t, srv)
+
+	err := first.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Redialed right away rather than found dead by the next Get.
+	acceptSession(t, srv)
+}
+
+func TestPoolOtherLanguage(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := &poolConfig{cfg: testConfig(t, srv)}
+	cfg.cfg.LanguageCode = "sv"
+	p := newPool(1, time.Minute, cfg.get)
+	defer p.Close()
+	first := acceptSession(t, srv)
+
+	s, err := p.Get(context.Background(), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if lang := acceptSession(t, srv).query.Get("language_code"); lang != "en" {
+		t.Errorf("dialed language %q, want en", lang)
+	}
+	// The pooled Swedish session is left for the next turn.
+	select {
+	case <-first.closed:
+		t.Error("the pooled session was closed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	srv := newWSServer(t)
+	cfg := &poolConfig{cfg: testConfig(t, srv)}
+	p := newPool(2, time.Minute, cfg.get)
+	conns := []*serverConn{acceptSession(t, srv), acceptSession(t, srv)}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, sc := range conns {
+		sc.waitClosed(t)
+	}
+	noConnection(t, srv)
+	if err := p.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// Stream is one response being synthesized. Text goes in with SendText,
+// Flush marks the end of it, and PCM at the provider's SampleRate comes
+// out of Audio, ending with a Done chunk once all of it has been sent.
+type Stream interface {
+	SendText(text string) error
+	Flush() error
+	Audio() <-chan AudioChunk
+	Close() error
+}
+
+// Provider is a text-to-speech service.
+type Provider interface {
+	Name() string
+	// NewStream starts a response.
+	NewStream(ctx context.Context, opts StreamOptions) (Stream, error)
+	// SampleRate is the rate of the mono PCM its streams send, which
+	// playback resamples from.
+	SampleRate() int
+	// Phonemes reports whether text may carry SSML phoneme tags.
+	Phonemes() bool
+	Close() error
+}
+
+// StreamOptions are settings for one response.
+type StreamOptions struct {
+	// Language is the ISO 639-1 language of the response, e.g. sv, in
+	// place of the provider's configured one. Empty keeps that, and
+	// providers whose voice fixes the language ignore it.
+	Language string
+}
+
+// Fallback is implemented by providers that can still answer, in one
+// piece, when NewStream fails.
+type Fallback interface {
+	Synthesize(ctx context.Context, text string) ([]byte, error)
+}
+
+// SessionMode is how ElevenLabs streams are given a connection.
+type SessionMode int
+
+const (
+	// SessionPerTurn takes a session from the pool for each response.
+	SessionPerTurn SessionMode = iota
+	// SessionReused keeps one session for response after response,
+	// redialing only when the server closes it.
+	SessionReused
+	// SessionMulti opens a context per response on one multi-context
+	// socket.
+	SessionMulti
+)
+
+// ElevenLabs streams over the websocket API, from a pool of sessions, one
+// session kept across responses or, with multi-context, one socket kept
+// for the whole run. When the websocket can't be reached it falls back to
+// the HTTP endpoint.
+type ElevenLabs struct {
+	config func() SessionConfig
+	pool   *Pool
+	multi  *MultiSession
+	reuse  *reusedSession
+	turns  atomic.Int64
+}
+
+// NewElevenLabs reads config for each stream, so the model can change
+// under load.
+func NewElevenLabs(config func() SessionConfig, poolSize int, mode SessionMode) *ElevenLabs {
+	e := &ElevenLabs{config: config}
+	switch mode {
+	case SessionMulti:
+		e.multi = NewMultiSession(config)
+	case SessionReused:
+		// The pool dials the next session ahead, for when the server
+		// closes the one in use.
+		e.pool = NewPool(poolSize, config)
+		e.reuse = &reusedSession{pool: e.pool, quiet: utteranceQuiet}
+	default:
+		e.pool = NewPool(poolSize, config)
+	}
+	return e
+}
+
+func (e *ElevenLabs) Name() string { return "elevenlabs" }
+
+func (e *ElevenLabs) NewStream(ctx context.Context, opts StreamOptions) (Stream, error) {
+	if e.multi != nil {
+		c, err := e.multi.OpenContext(ctx, fmt.Sprintf("turn-%d", e.turns.Add(1)), opts.Language)
+		if err != nil {
+			return nil, err
+		}
+		return contextStream{c}, nil
+	}
+	if e.reuse != nil && (opts.Language == "" || opts.Language == e.config().LanguageCode) {
+		return e.reuse.stream(ctx, e.config())
+	}
+	s, err := e.pool.Get(ctx, opts.Language)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (e *ElevenLabs) SampleRate() int { return e.config().SampleRate() }
+func (e *ElevenLabs) Phonemes() bool  { return e.config().EnableSSML }
+
+func (e *ElevenLabs) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	return SynthesizeHTTP(ctx, e.config(), text)
+}
+
+func (e *ElevenLabs) Close() error {
+	if e.multi != nil {
+		return e.multi.Close()
+	}
+	if e.reuse != nil {
+		e.reuse.Close()
+	}
+	return e.pool.Close()
+}
+
+// contextStream ends a context on Flush, which a Session does by itself.
+type contextStream struct {
+	*Context
+}
+
+func (c contextStream) Flush() error {
+	if err := c.Context.Flush(); err != nil {
+		return err
+	}
+	return c.CloseContext()
+}
@@ -0,0 +1,235 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var errProviderClosed = errors.New("tts provider closed")
+
+// errUtteranceUnfinished ends a response whose end wasn't recognized in its
+// audio before the server went quiet.
+var errUtteranceUnfinished = errors.New("tts response never reached its end")
+
+// utteranceQuiet is how long the audio of a response that has ended may
+// stop before the response is given up on. Its end is recognized by
+// counting the text the alignment covers, and if the count is off, as it
+// may be for text the server reads differently, it would never come.
+const utteranceQuiet = 3 * time.Second
+
+// reusedSession keeps one Session for response after response, so a turn
+// doesn't wait on a dial. A response that is cut short closes it, as the
+// server would go on speaking the rest, and the next one takes a new
+// session from the pool.
+type reusedSession struct {
+	pool  *Pool
+	quiet time.Duration
+
+	mu     sync.Mutex
+	s      *Session
+	cfg    SessionConfig
+	busy   bool
+	closed bool
+}
+
+// stream starts a response on the kept session, replacing it first if it
+// has ended, was made for another config, or is still busy with a
+// response that hasn't been closed.
+func (r *reusedSession) stream(ctx context.Context, cfg SessionConfig) (Stream, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, errProviderClosed
+	}
+	if r.s != nil && (r.busy || ended(r.s) || !r.cfg.sameAs(cfg)) {
+		if !r.busy {
+			go r.s.Close()
+		}
+		r.s = nil
+	}
+	if r.s == nil {
+		s, err := r.pool.Get(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		r.s, r.cfg = s, cfg
+	}
+
+	utterance, err := r.s.BeginUtterance()
+	if err != nil {
+		return nil, err
+	}
+	r.busy = true
+	u := &utteranceStream{
+		r:         r,
+		s:         r.s,
+		utterance: utterance,
+		audio:     make(chan AudioChunk, 32),
+		ended:     make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go u.forward()
+	return u, nil
+}
+
+// release hands s back once a response on it is over. A session that was
+// replaced meanwhile, or that the response had to close, is dropped.
+func (r *reusedSession) release(s *Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.s != s {
+		go s.Close()
+		return
+	}
+	r.busy = false
+}
+
+func (r *reusedSession) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.s != nil {
+		r.s.Close()
+		r.s = nil
+	}
+}
+
+func ended(s *Session) bool {
+	select {
+	case <-s.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// utteranceStream is one response on a reused session. Its Audio carries
+// only the response's own chunks, ending with its Done.
+type utteranceStream struct {
+	r         *reusedSession
+	s         *Session
+	utterance int
+	audio     chan AudioChunk
+	// ended is closed by Flush, and done once the response's Done has
+	// been passed on, or the session ended or went quiet before it.
+	ended    chan struct{}
+	endOnce  sync.Once
+	done     chan struct{}
+	finished bool
+	once     sync.Once
+}
+
+func (u *utteranceStream) forward() {
+	defer close(u.audio)
+	defer close(u.done)
+	ended := u.ended
+	var quiet *time.Timer
+	var quietC <-chan time.Time
+	defer func() {
+		if quiet != nil {
+			quiet.Stop()
+		}
+	}()
+	for {
+		var chunk AudioChunk
+		select {
+		case <-ended:
+			ended = nil
+			quiet = time.NewTimer(u.r.quiet)
+			quietC = quiet.C
+			continue
+		case <-quietC:
+			slog.Warn("tts response end not recognized, giving up on it", "utterance", u.utterance, "quiet", u.r.quiet)
+			select {
+			case u.audio <- AudioChunk{Error: errUtteranceUnfinished, Utterance: u.utterance}:
+			case <-u.s.Done():
+			}
+			return
+		case c, ok := <-u.s.Audio():
+			if !ok {
+				return
+			}
+			chunk = c
+		}
+		if quiet != nil {
+			quiet.Reset(u.r.quiet)
+		}
+		// What is left of an earlier response, which ended before all of
+		// it was read.
+		if chunk.Utterance < u.utterance && chunk.Error == nil {
+			continue
+		}
+		select {
+		case u.audio <- chunk:
+		case <-u.s.Done():
+			return
+		}
+		if chunk.Done && chunk.Utterance == u.utterance {
+			u.finished = true
+			return
+		}
+		if chunk.Error != nil {
+			return
+		}
+	}
+}
+
+func (u *utteranceStream) SendText(text string) error { return u.s.SendText(text) }
+
+// Flush ends the response. If its end isn't recognized in the audio by the
+// time the server has been quiet for utteranceQuiet, Audio ends with an
+// error and the session is replaced.
+func (u *utteranceStream) Flush() error {
+	u.endOnce.Do(func() { close(u.ended) })
+	return u.s.EndUtterance()
+}
+
+func (u *utteranceStream) Audio() <-chan AudioChunk { return u.audio }
+
+func (u *utteranceStream) FirstAudioLatency() time.Duration { return u.s.FirstAudioLatency() }
+
+// FlushAndWait ends the response and waits for its last audio, as
+// Session.FlushAndWait does for a whole session. On timeout the session is
+// closed, since its server is no longer answering.
+func (u *utteranceStream) FlushAndWait(ctx context.Context) error {
+	if err := u.Flush(); err != nil {
+		return err
+	}
+	var timeout <-chan time.Time
+	if u.s.cfg.FlushTimeout > 0 {
+		timer := time.NewTimer(u.s.cfg.FlushTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-u.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		u.s.Close()
+		return fmt.Errorf("%w after %s", ErrFlushTimeout, u.s.cfg.FlushTimeout)
+	}
+}
+
+// Close gives the session back for the next response, or closes it if this
+// one hasn't been spoken to the end.
+func (u *utteranceStream) Close() error {
+	u.once.Do(func() {
+		select {
+		case <-u.done:
+		default:
+			u.s.Close()
+			<-u.done
+		}
+		if !u.finished {
+			u.s.Close()
+		}
+		u.r.release(u.s)
+	})
+	return nil
+}
@@ -0,0 +1,209 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testReuse keeps a session for cfg across responses, dialing each one
+// to srv as it is needed.
+func testReuse(t *testing.T, cfg SessionConfig, quiet time.Duration) *reusedSession {
+	t.Helper()
+	pool := newPool(0, time.Minute, func() SessionConfig { return cfg })
+	r := &reusedSession{pool: pool, quiet: quiet}
+	t.Cleanup(func() {
+		r.Close()
+		pool.Close()
+	})
+	return r
+}
+
+// nextText returns the next text the session sent, skipping keep-alives.
+func nextText(t *testing.T, sc *serverConn) wsTextMessage {
+	t.Helper()
+	for {
+		var msg wsTextMessage
+		sc.nextJSON(t, &msg)
+		if msg.Text != " " || msg.Flush {
+			return msg
+		}
+	}
+}
+
+// respond runs one response on r: texts are sent, the response ended, and
+// the server answers with pcm aligned to spoken. It returns the audio and
+// the chunk that ended it.
+func respond(t *testing.T, r *reusedSession, cfg SessionConfig, srv *wsServer, sc *serverConn, texts []string, pcm []byte, spoken string) ([]byte, AudioChunk, *serverConn) {
+	t.Helper()
+	st, err := r.stream(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if sc == nil {
+		sc = acceptSession(t, srv)
+	}
+	for _, text := range texts {
+		if err := st.SendText(text); err != nil {
+			t.Fatal(err)
+		}
+		if msg := nextText(t, sc); msg.Text != text {
+			t.Fatalf("server got %q, want %q", msg.Text, text)
+		}
+	}
+	if err := st.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if msg := nextText(t, sc); !msg.Flush {
+		t.Fatalf("server got %+v, want the flush", msg)
+	}
+	sc.send(t, audioMessage(pcm, spoken))
+	got, last := collect(t, st.Audio())
+	return got, last, sc
+}
+
+func TestReusedSessionBackToBack(t *testing.T) {
+	tests := []struct {
+		name      string
+		keepAlive time.Duration
+		first     []string
+		second    []string
+		spoken    string
+	}{
+		{
+			name:   "plain",
+			first:  []string{"Hej ", "där."},
+			second: []string{"Klockan är  tre.\n"},
+			spoken: "Klockan är tre.",
+		},
+		{
+			name:   "ssml",
+			first:  []string{"Hej där."},
+			second: []string{`Välkommen till <phoneme alphabet="ipa" ph="ˈbɛlstaˌbærj">Bälstaberg</phoneme>.`},
+			spoken: "Välkommen till Bälstaberg.",
+		},
+		{
+			name:   "ssml tag split",
+			first:  []string{"Hej där."},
+			second: []string{`Till <phoneme alphabet="ipa" `, `ph="ˈbɛlsta">Bälsta</phoneme> nu.`},
+			spoken: "Till Bälsta nu.",
+		},
+		{
+			// The server aligns the keep-alive spaces it was sent in
+			// between along with the next response.
+			name:      "keep-alive",
+			keepAlive: 20 * time.Millisecond,
+			first:     []string{"Hej där."},
+			second:    []string{"Klockan är tre."},
+			spoken:    "  Klockan är tre.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newWSServer(t)
+			cfg := testConfig(t, srv)
+			cfg.EnableSSML = true
+			cfg.KeepAlive = tt.keepAlive
+			r := testReuse(t, cfg, time.Minute)
+
+			pcm, last, sc := respond(t, r, cfg, srv, nil, tt.first, []byte{1, 1}, "Hej där.")
+			if !bytes.Equal(pcm, []byte{1, 1}) || !last.Done || last.Utterance != 1 {
+				t.Fatalf("first response got %v, ended with %+v", pcm, last)
+			}
+			if tt.keepAlive > 0 {
+				time.Sleep(5 * tt.keepAlive)
+			}
+			pcm, last, _ = respond(t, r, cfg, srv, sc, tt.second, []byte{2, 2}, tt.spoken)
+			if !bytes.Equal(pcm, []byte{2, 2}) || !last.Done || last.Utterance != 2 {
+				t.Errorf("second response got %v, ended with %+v", pcm, last)
+			}
+			noConnection(t, srv)
+		})
+	}
+}
+
+func TestReusedSessionUnfinished(t *testing.T) {
+	const quiet = 200 * time.Millisecond
+	srv := newWSServer(t)
+	cfg := testConfig(t, srv)
+	r := testReuse(t, cfg, quiet)
+
+	// Less is aligned than was sent, so the end is never recognized; the
+	// response ends with an error once the server has gone quiet.
+	start := time.Now()
+	pcm, last, sc := respond(t, r, cfg, srv, nil, []string{"Hej där."}, []byte{1, 1}, "Hej")
+	if !bytes.Equal(pcm, []byte{1, 1}) || !errors.Is(last.Error, errUtteranceUnfinished) {
+		t.Fatalf("got %v, ended with %+v, want the audio and errUtteranceUnfinished", pcm, last)
+	}
+	if took := time.Since(start); took > 10*quiet {
+		t.Errorf("took %s to give up", took)
+	}
+
+	// Its count is off, so the session isn't used again.
+	sc.waitClosed(t)
+	_, last, _ = respond(t, r, cfg, srv, nil, []string{"Igen."}, []byte{2, 2}, "Igen.")
+	if !last.Done {
+		t.Errorf("next response ended with %+v", last)
+	}
+}
+
+func TestUtteranceFlushAndWait(t *testing.T) {
+	const quiet = 200 * time.Millisecond
+	srv := newWSServer(t)
+	cfg := testConfig(t, srv)
+	cfg.FlushTimeout = time.Minute
+	r := testReuse(t, cfg, quiet)
+
+	st, err := r.stream(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	acceptSession(t, srv)
+	if err := st.SendText("Hej."); err != nil {
+		t.Fatal(err)
+	}
+	// No audio at all comes back.
+	start := time.Now()
+	go func() {
+		for range st.Audio() {
+		}
+	}()
+	if err := st.(*utteranceStream).FlushAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took > 10*quiet {
+		t.Errorf("FlushAndWait took %s, want it to give up after the quiet period", took)
+	}
+}
+
+func TestSpokenCounter(t *testing.T) {
+	tests := []struct {
+		name  string
+		ssml  bool
+		texts []string
+		want  int
+	}{
+		{"words", false, []string{"Hej där."}, 7},
+		{"whitespace", false, []string{" Hej\n\n  där. "}, 7},
+		{"keep-alive", false, []string{" ", " "}, 0},
+		{"tag", true, []string{`<phoneme alphabet="ipa" ph="x">Bälsta</phoneme>berg`}, 10},
+		{"split tag", true, []string{`a <phoneme ph="`, `x">b</pho`, `neme> c`}, 3},
+		{"not ssml", false, []string{`<b>`}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c spokenCounter
+			n := 0
+			for _, text := range tt.texts {
+				n += c.count(text, tt.ssml)
+			}
+			if n != tt.want {
+				t.Errorf("counted %d, want %d", n, tt.want)
+			}
+		})
+	}
+}
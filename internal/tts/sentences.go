@@ -0,0 +1,220 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// synthesizeFunc speaks a piece of text in one request, returning its PCM
+// as it is produced.
+type synthesizeFunc func(ctx context.Context, text string) (io.ReadCloser, error)
+
+var errStreamFlushed = errors.New("tts stream already flushed")
+
+// sentenceStream is a Stream for providers that take the whole text in one
+// request. Text is sent a sentence or so at a time as it completes, so the
+// first sentence plays while the model is still writing the rest.
+type sentenceStream struct {
+	synth  synthesizeFunc
+	audio  chan AudioChunk
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending strings.Builder
+	queue   []string
+	flushed bool
+	wake    chan struct{}
+}
+
+func newSentenceStream(ctx context.Context, synth synthesizeFunc) *sentenceStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &sentenceStream{
+		synth:  synth,
+		audio:  make(chan AudioChunk, 32),
+		ctx:    ctx,
+		cancel: cancel,
+		wake:   make(chan struct{}, 1),
+	}
+	go s.run()
+	return s
+}
+
+func (s *sentenceStream) SendText(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flushed {
+		return errStreamFlushed
+	}
+	s.pending.WriteString(text)
+	pending := s.pending.String()
+	if end := sentenceEnd(pending); end > 0 {
+		s.enqueue(pending[:end])
+		s.pending.Reset()
+		s.pending.WriteString(pending[end:])
+	}
+	return nil
+}
+
+func (s *sentenceStream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flushed {
+		return nil
+	}
+	s.flushed = true
+	s.enqueue(s.pending.String())
+	s.pending.Reset()
+	s.signal()
+	return nil
+}
+
+// enqueue queues text for run unless it is blank. Call with mu held.
+func (s *sentenceStream) enqueue(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	s.queue = append(s.queue, text)
+	s.signal()
+}
+
+func (s *sentenceStream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for the next piece of text; ok is false once the stream is
+// flushed and everything has been taken.
+func (s *sentenceStream) next() (text string, ok bool) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			text = s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			return text, true
+		}
+		flushed := s.flushed
+		s.mu.Unlock()
+		if flushed {
+			return "", false
+		}
+		select {
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return "", false
+		}
+	}
+}
+
+func (s *sentenceStream) Audio() <-chan AudioChunk {
+	return s.audio
+}
+
+// Close abandons the response; Audio ends right after.
+func (s *sentenceStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+func (s *sentenceStream) send(chunk AudioChunk) bool {
+	select {
+	case s.audio <- chunk:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// run synthesizes the queued text in order, one request at a time.
+func (s *sentenceStream) run() {
+	defer close(s.audio)
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("recovered panic", "component", "tts_sentence_stream", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			s.send(AudioChunk{Error: fmt.Errorf("tts stream panic: %v", r)})
+		}
+	}()
+
+	for {
+		text, ok := s.next()
+		if !ok {
+			break
+		}
+		if err := s.speak(text); err != nil {
+			if s.ctx.Err() == nil {
+				s.send(AudioChunk{Error: err})
+			}
+			return
+		}
+	}
+	if s.ctx.Err() == nil {
+		s.send(AudioChunk{Done: true})
+	}
+}
+
+func (s *sentenceStream) speak(text string) error {
+	body, err := s.synth(s.ctx, text)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return readPCM(body, func(pcm []byte) bool {
+		return s.send(AudioChunk{Data: pcm})
+	})
+}
+
+// readPCM passes 16-bit PCM from r to emit as it arrives, in whole
+// samples, until r ends or emit returns false.
+func readPCM(r io.Reader, emit func(pcm []byte) bool) error {
+	buf := make([]byte, 4096)
+	carry := 0
+	for {
+		n, err := r.Read(buf[carry:])
+		n += carry
+		if whole := n &^ 1; whole > 0 {
+			if !emit(append([]byte(nil), buf[:whole]...)) {
+				return nil
+			}
+			carry = copy(buf, buf[whole:n])
+		} else {
+			carry = n
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tts audio: %w", err)
+		}
+	}
+}
+
+// sentenceEnd returns the index just past the last sentence break in s: a
+// ., !, ?, : or ; followed by a space, or a newline. It is 0 if there is
+// none.
+func sentenceEnd(s string) int {
+	return breakEnd(s, ".!?:;")
+}
+
+// breakEnd returns the index just past the last of marks followed by a
+// space, or newline, in s, or 0 if there is none.
+func breakEnd(s, marks string) int {
+	for i := len(s) - 1; i > 0; i-- {
+		switch {
+		case s[i] == '\n':
+			return i + 1
+		case unicode.IsSpace(rune(s[i])) && strings.ContainsRune(marks, rune(s[i-1])):
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package tts
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	tracer = otel.Tracer("github.com/joakimcarlsson/smarthome/internal/tts")
+	meter  = otel.Meter("github.com/joakimcarlsson/smarthome/internal/tts")
+
+	firstAudioHisto metric.Float64Histogram
+	dialAttempts    metric.Int64Counter
+)
+
+func init() {
+	var err error
+	firstAudioHisto, err = meter.Float64Histogram("tts.first_audio",
+		metric.WithUnit("s"),
+		metric.WithDescription("Time from a session's first text to its first audio, by voice and model"),
+	)
+	if err != nil {
+		slog.Warn("creating tts.first_audio histogram", "error", err)
+	}
+	dialAttempts, err = meter.Int64Counter("tts.dial.attempts",
+		metric.WithDescription("Attempts at dialing a tts session, by whether it was a retry"),
+	)
+	if err != nil {
+		slog.Warn("creating tts.dial.attempts counter", "error", err)
+	}
+}
+
+func countDial(ctx context.Context, attempt int) {
+	if dialAttempts != nil {
+		dialAttempts.Add(ctx, 1, metric.WithAttributes(attribute.Bool("retry", attempt > 1)))
+	}
+}
+
+func (c SessionConfig) attrs() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("tts.voice", c.VoiceID),
+		attribute.String("tts.model", c.ModelID),
+	}
+}
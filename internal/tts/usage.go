@@ -0,0 +1,51 @@
+package tts
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// UsageStats is the ElevenLabs text sent since Since, which is what the
+// quota is billed on.
+type UsageStats struct {
+	// Characters counts runes, the way ElevenLabs counts them.
+	Characters int64
+	// Sessions counts the sessions, contexts and HTTP requests that were
+	// sent any text; pooled sessions that never were aren't billed.
+	Sessions int64
+	Since    time.Time
+}
+
+var usage = struct {
+	sync.Mutex
+	stats UsageStats
+}{stats: UsageStats{Since: time.Now()}}
+
+// Usage returns the characters and sessions counted since the last
+// ResetUsage, or since start.
+func Usage() UsageStats {
+	usage.Lock()
+	defer usage.Unlock()
+	return usage.stats
+}
+
+// ResetUsage starts counting from zero, e.g. at the start of a day.
+func ResetUsage() {
+	usage.Lock()
+	defer usage.Unlock()
+	usage.stats = UsageStats{Since: time.Now()}
+}
+
+// countUsage adds text to the totals, counting a session too if it is the
+// first text the session was sent. It returns the runes counted.
+func countUsage(text string, first bool) int {
+	n := utf8.RuneCountInString(text)
+	usage.Lock()
+	defer usage.Unlock()
+	usage.stats.Characters += int64(n)
+	if first {
+		usage.stats.Sessions++
+	}
+	return n
+}
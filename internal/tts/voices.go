@@ -0,0 +1,96 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiBaseURL is the ElevenLabs REST API. Tests point it at a fake server.
+var apiBaseURL = "https://api.elevenlabs.io/v1"
+
+type Voice struct {
+	VoiceID    string            `json:"voice_id"`
+	Name       string            `json:"name"`
+	Category   string            `json:"category"`
+	Labels     map[string]string `json:"labels"`
+	PreviewURL string            `json:"preview_url"`
+}
+
+type voicesResponse struct {
+	Voices []Voice `json:"voices"`
+}
+
+func ListVoices(ctx context.Context, apiKey string) ([]Voice, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiBaseURL+"/voices", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating voices request: %w", err)
+	}
+	req.Header.Set("xi-api-key", apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching voices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading voices response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching voices: %w", parseAPIError(resp.StatusCode, body))
+	}
+
+	var vr voicesResponse
+	if err := json.Unmarshal(body, &vr); err != nil {
+		return nil, fmt.Errorf("parsing voices response: %w", err)
+	}
+	return vr.Voices, nil
+}
+
+// ListVoicesCached serves the voice list from cachePath while it is younger
+// than ttl and refreshes it from the API otherwise.
+func ListVoicesCached(ctx context.Context, apiKey, cachePath string, ttl time.Duration) ([]Voice, error) {
+	if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < ttl {
+		data, err := os.ReadFile(cachePath)
+		if err == nil {
+			var voices []Voice
+			if err := json.Unmarshal(data, &voices); err == nil {
+				return voices, nil
+			}
+		}
+	}
+
+	voices, err := ListVoices(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(voices)
+	if err != nil {
+		return nil, fmt.Errorf("encoding voice cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return nil, fmt.Errorf("creating voice cache dir: %w", err)
+	}
+	if err := os.WriteFile(cachePath, data, 0o644); err != nil {
+		return nil, fmt.Errorf("writing voice cache: %w", err)
+	}
+	return voices, nil
+}
+
+func FindVoice(voices []Voice, nameOrID string) (Voice, bool) {
+	for _, v := range voices {
+		if v.VoiceID == nameOrID || strings.EqualFold(v.Name, nameOrID) {
+			return v, true
+		}
+	}
+	return Voice{}, false
+}
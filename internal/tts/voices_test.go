@@ -0,0 +1,150 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// voicesAPI serves /voices with status and body, and counts the requests.
+func voicesAPI(t *testing.T, status int, body string) *atomic.Int32 {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/voices" || r.Header.Get("xi-api-key") != "key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	prev := apiBaseURL
+	apiBaseURL = srv.URL
+	t.Cleanup(func() { apiBaseURL = prev })
+	return &requests
+}
+
+const voicesBody = `{"voices":[
+	{"voice_id":"v1","name":"Sanna","category":"premade","labels":{"accent":"swedish"},"preview_url":"https://example.com/v1.mp3"},
+	{"voice_id":"v2","name":"Adam","category":"cloned","labels":{}}
+]}`
+
+func TestListVoices(t *testing.T) {
+	voicesAPI(t, http.StatusOK, voicesBody)
+	voices, err := ListVoices(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voices) != 2 {
+		t.Fatalf("got %d voices, want 2", len(voices))
+	}
+	v := voices[0]
+	if v.VoiceID != "v1" || v.Name != "Sanna" || v.Category != "premade" ||
+		v.Labels["accent"] != "swedish" || v.PreviewURL != "https://example.com/v1.mp3" {
+		t.Errorf("first voice = %+v", v)
+	}
+	if voices[1].PreviewURL != "" {
+		t.Errorf("second voice has preview %q, want none", voices[1].PreviewURL)
+	}
+}
+
+func TestListVoicesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"bad key", http.StatusUnauthorized,
+			`{"detail":{"status":"invalid_api_key","message":"Invalid API key"}}`, ErrUnauthorized},
+		{"not json", http.StatusOK, `<html>`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voicesAPI(t, tt.status, tt.body)
+			_, err := ListVoices(context.Background(), "key")
+			if err == nil {
+				t.Fatal("ListVoices succeeded, want an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestListVoicesCached(t *testing.T) {
+	requests := voicesAPI(t, http.StatusOK, voicesBody)
+	path := filepath.Join(t.TempDir(), "cache", "voices.json")
+	list := func() []Voice {
+		t.Helper()
+		voices, err := ListVoicesCached(context.Background(), "key", path, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return voices
+	}
+
+	if got := list(); len(got) != 2 || requests.Load() != 1 {
+		t.Fatalf("first list: %d voices after %d requests", len(got), requests.Load())
+	}
+	if got := list(); len(got) != 2 || got[0].Labels["accent"] != "swedish" || requests.Load() != 1 {
+		t.Errorf("cached list: %+v after %d requests, want it from the cache", got, requests.Load())
+	}
+
+	// An old cache is refreshed.
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	list()
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d requests with an expired cache, want 2", n)
+	}
+
+	// So is one that can't be read.
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(); len(got) != 2 || requests.Load() != 3 {
+		t.Errorf("list over a broken cache: %d voices after %d requests", len(got), requests.Load())
+	}
+}
+
+func TestListVoicesCachedError(t *testing.T) {
+	voicesAPI(t, http.StatusInternalServerError, "down")
+	path := filepath.Join(t.TempDir(), "voices.json")
+	if _, err := ListVoicesCached(context.Background(), "key", path, time.Hour); err == nil {
+		t.Fatal("ListVoicesCached succeeded, want the API error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("a failed list was cached: %v", err)
+	}
+}
+
+func TestFindVoice(t *testing.T) {
+	voices := []Voice{{VoiceID: "v1", Name: "Sanna"}, {VoiceID: "v2", Name: "Adam"}}
+	tests := []struct {
+		query  string
+		wantID string
+	}{
+		{"v2", "v2"},
+		{"Sanna", "v1"},
+		{"sanna", "v1"},
+		{"Eva", ""},
+	}
+	for _, tt := range tests {
+		v, ok := FindVoice(voices, tt.query)
+		if ok != (tt.wantID != "") || v.VoiceID != tt.wantID {
+			t.Errorf("FindVoice(%q) = %q, %v, want %q", tt.query, v.VoiceID, ok, tt.wantID)
+		}
+	}
+}